@@ -0,0 +1,161 @@
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/majd/ipatool/v2/pkg/appstore"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+func authCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "auth",
+		Short: "Authenticate with the App Store",
+	}
+
+	cmd.AddCommand(loginCmd())
+	cmd.AddCommand(infoCmd())
+	cmd.AddCommand(revokeCmd())
+
+	return cmd
+}
+
+func loginCmd() *cobra.Command {
+	var (
+		email    string
+		password string
+		authCode string
+		proxy    string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "login",
+		Short: "Login to the App Store",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := validateEmail(email); err != nil {
+				return err
+			}
+
+			if err := validateAuthCode(authCode); err != nil {
+				return err
+			}
+
+			if err := validateProxy(proxy); err != nil {
+				return err
+			}
+
+			interactive := term.IsTerminal(int(os.Stdin.Fd()))
+
+			if password == "" && interactive {
+				fmt.Fprint(cmd.ErrOrStderr(), "Enter password: ")
+
+				data, err := dependencies.Machine.ReadPassword(int(os.Stdin.Fd()))
+				fmt.Fprintln(cmd.ErrOrStderr())
+
+				if err != nil {
+					return fmt.Errorf("failed to read password: %w", err)
+				}
+
+				password = string(data)
+			}
+
+			input := appstore.LoginInput{
+				Email:    email,
+				Password: password,
+				AuthCode: authCode,
+				Proxy:    proxy,
+			}
+
+			output, err := dependencies.AppStore.Login(input)
+			if errors.Is(err, appstore.ErrAuthCodeRequired) && authCode == "" && interactive {
+				fmt.Fprint(cmd.ErrOrStderr(), "Enter 2FA code: ")
+
+				input.AuthCode, err = bufio.NewReader(cmd.InOrStdin()).ReadString('\n')
+				if err != nil {
+					return fmt.Errorf("failed to read auth code: %w", err)
+				}
+
+				input.AuthCode = strings.TrimSpace(input.AuthCode)
+				output, err = dependencies.AppStore.Login(input)
+			}
+
+			if errors.Is(err, appstore.ErrAuthCodeRequired) {
+				return fmt.Errorf("two-factor authentication code is required, pass it with --auth-code: %w", err)
+			}
+
+			if err != nil {
+				return fmt.Errorf("failed to login: %w", err)
+			}
+
+			countryCode, language := storefrontLocale(output.Account.StoreFront)
+			response := AuthLoginResponse{
+				Success:     true,
+				Email:       output.Account.Email,
+				Name:        output.Account.Name,
+				CountryCode: countryCode,
+				Language:    language,
+				Storefront:  output.Account.StoreFront,
+			}
+
+			return printResult(cmd, response, []string{"email", "name", "country", "language"}, [][]string{
+				{response.Email, response.Name, response.CountryCode, response.Language},
+			})
+		},
+	}
+
+	cmd.Flags().StringVarP(&email, "email", "e", "", "email address for the Apple ID")
+	cmd.Flags().StringVarP(&password, "password", "p", "", "password for the Apple ID (prompted for when omitted)")
+	cmd.Flags().StringVar(&authCode, "auth-code", "", "2FA code for the Apple ID")
+	cmd.Flags().StringVar(&proxy, "proxy", "", "HTTP or SOCKS5 proxy URL for this account's App Store traffic")
+
+	_ = cmd.MarkFlagRequired("email")
+
+	return cmd
+}
+
+func infoCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "info",
+		Short: "Show current account info",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			acc, err := currentAccount()
+			if err != nil {
+				return err
+			}
+
+			countryCode, language := storefrontLocale(acc.StoreFront)
+			response := AuthInfoResponse{
+				Email:       acc.Email,
+				Name:        acc.Name,
+				CountryCode: countryCode,
+				Language:    language,
+				Storefront:  acc.StoreFront,
+				DeviceGUID:  dependencies.DeviceGUID,
+				Proxy:       redactProxy(acc.Proxy),
+			}
+
+			return printResult(cmd, response, []string{"email", "name", "country", "language", "device guid", "proxy"}, [][]string{
+				{response.Email, response.Name, response.CountryCode, response.Language, response.DeviceGUID, response.Proxy},
+			})
+		},
+	}
+}
+
+func revokeCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "revoke",
+		Short: "Revoke your App Store credentials",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := dependencies.AppStore.Revoke(); err != nil {
+				return fmt.Errorf("failed to revoke credentials: %w", err)
+			}
+
+			return printResult(cmd, map[string]bool{"success": true}, nil, [][]string{{"Credentials revoked."}})
+		},
+	}
+}
@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/majd/ipatool/v2/pkg/appstore"
+)
+
+// CountryAvailabilityInfo reports whether an app is sold in a storefront.
+type CountryAvailabilityInfo struct {
+	CountryCode string `json:"country_code"`
+	Name        string `json:"name"`
+	Available   bool   `json:"available"`
+	// Price is only set if the app is available; 0 means free.
+	Price    *float64 `json:"price,omitempty"`
+	Currency string   `json:"currency,omitempty"`
+	Version  string   `json:"version,omitempty"`
+	// Error is set if the storefront could not be checked.
+	Error string `json:"error,omitempty"`
+}
+
+// AvailabilityResponse is the response for GET /api/v1/availability.
+type AvailabilityResponse struct {
+	AppID          int64                     `json:"app_id,omitempty"`
+	BundleID       string                    `json:"bundle_id,omitempty"`
+	Name           string                    `json:"name,omitempty"`
+	AvailableCount int                       `json:"available_count"`
+	Countries      []CountryAvailabilityInfo `json:"countries"`
+}
+
+func handleAvailability(w http.ResponseWriter, r *http.Request) {
+	bundleID := r.URL.Query().Get("bundle_id")
+	appIDStr := r.URL.Query().Get("app_id")
+
+	if err := validateAppIDOrBundleID(appIDStr, bundleID); err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var countryCodes []string
+	if countries := r.URL.Query().Get("countries"); countries != "" {
+		for _, code := range strings.Split(countries, ",") {
+			code = strings.ToUpper(strings.TrimSpace(code))
+			if err := validateCountryCode(code); err != nil {
+				respondError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			countryCodes = append(countryCodes, code)
+		}
+	}
+
+	accountInfo, ok := getAccountInfo(r)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	input := appstore.AvailabilityInput{
+		Account:      accountInfo.Account,
+		CountryCodes: countryCodes,
+	}
+	if appIDStr != "" {
+		input.AppID, _ = strconv.ParseInt(appIDStr, 10, 64)
+	} else {
+		input.BundleID = bundleID
+	}
+
+	result, err := dependencies.AppStore.Availability(input)
+	if err != nil {
+		statusCode, message := mapAppStoreErrorToHTTPStatus(err)
+		respondError(w, statusCode, message)
+		return
+	}
+
+	response := AvailabilityResponse{
+		AppID:     result.App.ID,
+		BundleID:  result.App.BundleID,
+		Name:      result.App.Name,
+		Countries: make([]CountryAvailabilityInfo, len(result.Countries)),
+	}
+
+	for i, country := range result.Countries {
+		info := CountryAvailabilityInfo{
+			CountryCode: country.Country.Code,
+			Name:        country.Country.Name,
+			Available:   country.Available,
+		}
+
+		if country.Available {
+			price := country.App.Price
+			info.Price = &price
+			info.Currency = country.Country.Currency
+			info.Version = country.App.Version
+			response.AvailableCount++
+		}
+
+		if country.Err != nil {
+			dependencies.Logger.Verbose().Err(country.Err).Str("country", country.Country.Code).Msg("Availability lookup failed")
+			info.Error = "Lookup failed"
+		}
+
+		response.Countries[i] = info
+	}
+
+	respondSuccess(w, response)
+}
@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/spf13/cobra"
+)
+
+func cacheCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "cache",
+		Short: "Inspect or purge the persistent version metadata cache",
+	}
+
+	cmd.AddCommand(cacheStatsCmd())
+	cmd.AddCommand(cachePurgeCmd())
+
+	return cmd
+}
+
+func cacheStatsCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "stats",
+		Short: "Show the statistics of the metadata cache",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			stats := metadataCacheStats()
+
+			return printResult(cmd, stats, []string{"enabled", "path", "entries", "size (bytes)"}, [][]string{
+				{strconv.FormatBool(stats.Enabled), stats.Path, strconv.Itoa(stats.Entries), strconv.FormatInt(stats.SizeBytes, 10)},
+			})
+		},
+	}
+}
+
+func cachePurgeCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "purge",
+		Short: "Remove all entries from the metadata cache",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if dependencies.MetadataCache == nil {
+				return errors.New("metadata cache is disabled")
+			}
+
+			entries := dependencies.MetadataCache.Stats().Entries
+			if err := dependencies.MetadataCache.Purge(); err != nil {
+				return fmt.Errorf("failed to purge the metadata cache: %w", err)
+			}
+
+			return printResult(cmd, MetadataCachePurgeResponse{Success: true, PurgedEntries: entries}, nil, [][]string{
+				{fmt.Sprintf("Purged %d entries.", entries)},
+			})
+		},
+	}
+}
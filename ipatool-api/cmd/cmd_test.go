@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/99designs/keyring"
+	cookiejar "github.com/juju/persistent-cookiejar"
+	"github.com/majd/ipatool/v2/pkg/appstore"
+	"github.com/majd/ipatool/v2/pkg/keychain"
+	"github.com/majd/ipatool/v2/pkg/kvstore"
+	"github.com/majd/ipatool/v2/pkg/log"
+	"github.com/majd/ipatool/v2/pkg/util/machine"
+	"github.com/majd/ipatool/v2/pkg/util/operatingsystem"
+	"github.com/majd/ipatool/v2/pkg/watchlist"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestCmd(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Cmd Suite")
+}
+
+// setTestDependencies points the global dependencies at the App Store served from storeURL,
+// with an in-memory keychain and a temporary cookie jar.
+func setTestDependencies(storeURL string) {
+	jar, err := cookiejar.New(&cookiejar.Options{Filename: filepath.Join(GinkgoT().TempDir(), "cookies")})
+	Expect(err).ToNot(HaveOccurred())
+
+	metadataCache, err := kvstore.Open(filepath.Join(GinkgoT().TempDir(), MetadataCacheFileName))
+	Expect(err).ToNot(HaveOccurred())
+	DeferCleanup(metadataCache.Close)
+
+	digests, err := kvstore.Open(filepath.Join(GinkgoT().TempDir(), DigestsFileName))
+	Expect(err).ToNot(HaveOccurred())
+	DeferCleanup(digests.Close)
+
+	os := operatingsystem.New()
+	dependencies = Dependencies{
+		Logger:     log.NewLogger(log.Args{Writer: GinkgoWriter}),
+		OS:         os,
+		Machine:    machine.New(machine.Args{OS: os}),
+		CookieJar:  jar,
+		Keychain:   keychain.New(keychain.Args{Keyring: keyring.NewArrayKeyring(nil)}),
+		DeviceGUID: "0123456789AB",
+
+		MetadataCache: metadataCache,
+		Digests:       digests,
+		Downloads: DownloadDirectory{
+			Path:     filepath.Join(GinkgoT().TempDir(), DownloadsDirectoryName),
+			Template: DefaultDownloadTemplate,
+		},
+	}
+	dependencies.AppStore = appstore.NewAppStore(appstore.Args{
+		Keychain:        dependencies.Keychain,
+		CookieJar:       dependencies.CookieJar,
+		OperatingSystem: dependencies.OS,
+		Machine:         dependencies.Machine,
+		DeviceGUID:      dependencies.DeviceGUID,
+		Endpoints:       appstore.EndpointsWithBaseURL(storeURL),
+		MetadataCache:   metadataCache,
+	})
+
+	watchlistStore, err := watchlist.Open(filepath.Join(GinkgoT().TempDir(), WatchlistFileName))
+	Expect(err).ToNot(HaveOccurred())
+	dependencies.Watchlist = watchlist.New(watchlist.Args{
+		Store:     watchlistStore,
+		AppStore:  dependencies.AppStore,
+		Logger:    dependencies.Logger,
+		Directory: filepath.Join(GinkgoT().TempDir(), WatchlistDirectoryName),
+	})
+}
@@ -3,19 +3,24 @@ package cmd
 import (
 	"errors"
 	"fmt"
+	"net/url"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/99designs/keyring"
 	cookiejar "github.com/juju/persistent-cookiejar"
 	"github.com/majd/ipatool/v2/pkg/appstore"
 	"github.com/majd/ipatool/v2/pkg/http"
 	"github.com/majd/ipatool/v2/pkg/keychain"
+	"github.com/majd/ipatool/v2/pkg/kvstore"
 	"github.com/majd/ipatool/v2/pkg/log"
 	"github.com/majd/ipatool/v2/pkg/util"
 	"github.com/majd/ipatool/v2/pkg/util/machine"
 	"github.com/majd/ipatool/v2/pkg/util/operatingsystem"
+	"github.com/majd/ipatool/v2/pkg/watchlist"
 	"github.com/rs/zerolog"
 )
 
@@ -23,12 +28,21 @@ var dependencies = Dependencies{}
 
 // Dependencies holds all the server dependencies.
 type Dependencies struct {
-	Logger    log.Logger
-	OS        operatingsystem.OperatingSystem
-	Machine   machine.Machine
-	CookieJar http.CookieJar
-	Keychain  keychain.Keychain
-	AppStore  appstore.AppStore
+	Logger       log.Logger
+	OS           operatingsystem.OperatingSystem
+	Machine      machine.Machine
+	CookieJar    http.CookieJar
+	Keychain     keychain.Keychain
+	AppStore     appstore.AppStore
+	DeviceGUID   string
+	RetryMetrics *http.RetryMetrics
+	// MetadataCache is nil if the cache is disabled.
+	MetadataCache *kvstore.Store
+	// Digests is nil if the digest store could not be opened.
+	Digests   *kvstore.Store
+	Downloads DownloadDirectory
+	Watchlist *watchlist.Watcher
+	Feeds     Feeds
 }
 
 // newLogger creates a new logger instance for server mode.
@@ -87,22 +101,209 @@ func newKeychain(machine machine.Machine, logger log.Logger) keychain.Keychain {
 	return keychain.New(keychain.Args{Keyring: ring})
 }
 
+// newDefaultProxy parses the server-wide default proxy used for accounts without their own proxy.
+func newDefaultProxy(raw string) (*url.URL, error) {
+	if raw == "" {
+		return nil, nil
+	}
+
+	proxyURL, err := http.ParseProxyURL(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid IPATOOL_PROXY: %w", err)
+	}
+
+	return proxyURL, nil
+}
+
+// newRetryPolicy returns the retry policy for App Store and CDN requests.
+// IPATOOL_RETRY_ATTEMPTS (1 disables retries) and IPATOOL_RETRY_DELAY (initial backoff, e.g. "500ms") override the defaults.
+func newRetryPolicy(logger log.Logger, metrics *http.RetryMetrics) (http.RetryPolicy, error) {
+	policy := http.DefaultRetryPolicy()
+	policy.Logger = logger
+	policy.Metrics = metrics
+
+	if value := os.Getenv("IPATOOL_RETRY_ATTEMPTS"); value != "" {
+		attempts, err := strconv.ParseUint(value, 10, 32)
+		if err != nil || attempts < 1 {
+			return http.RetryPolicy{}, fmt.Errorf("invalid IPATOOL_RETRY_ATTEMPTS: %q", value)
+		}
+		policy.Attempts = uint(attempts)
+	}
+
+	if value := os.Getenv("IPATOOL_RETRY_DELAY"); value != "" {
+		delay, err := time.ParseDuration(value)
+		if err != nil || delay <= 0 {
+			return http.RetryPolicy{}, fmt.Errorf("invalid IPATOOL_RETRY_DELAY: %q", value)
+		}
+		policy.Delay = delay
+	}
+
+	return policy, nil
+}
+
+// newDownloadConnections returns the number of concurrent range requests of a CDN transfer.
+// IPATOOL_DOWNLOAD_CONNECTIONS (1 disables chunked downloads) overrides the default.
+func newDownloadConnections() (int, error) {
+	value := os.Getenv("IPATOOL_DOWNLOAD_CONNECTIONS")
+	if value == "" {
+		return DefaultDownloadConnections, nil
+	}
+
+	connections, err := strconv.Atoi(value)
+	if err != nil || connections < 1 || connections > MaxDownloadConnections {
+		return 0, fmt.Errorf("invalid IPATOOL_DOWNLOAD_CONNECTIONS: %q (1-%d)", value, MaxDownloadConnections)
+	}
+
+	return connections, nil
+}
+
+// newDownloadChunkSize returns the size of the ranges requested by chunked downloads, from
+// IPATOOL_DOWNLOAD_CHUNK_SIZE_MB. Zero uses the App Store default.
+func newDownloadChunkSize() (int64, error) {
+	value := os.Getenv("IPATOOL_DOWNLOAD_CHUNK_SIZE_MB")
+	if value == "" {
+		return 0, nil
+	}
+
+	megabytes, err := strconv.Atoi(value)
+	if err != nil || megabytes < 1 || megabytes > MaxDownloadChunkSizeMB {
+		return 0, fmt.Errorf("invalid IPATOOL_DOWNLOAD_CHUNK_SIZE_MB: %q (1-%d)", value, MaxDownloadChunkSizeMB)
+	}
+
+	return int64(megabytes) * 1024 * 1024, nil
+}
+
+// newDownloadStallTimeout returns how long a CDN transfer may go without receiving data, from
+// IPATOOL_DOWNLOAD_STALL_TIMEOUT (e.g. "30s"). Zero uses the App Store default.
+func newDownloadStallTimeout() (time.Duration, error) {
+	value := os.Getenv("IPATOOL_DOWNLOAD_STALL_TIMEOUT")
+	if value == "" {
+		return 0, nil
+	}
+
+	timeout, err := time.ParseDuration(value)
+	if err != nil || timeout < time.Second {
+		return 0, fmt.Errorf("invalid IPATOOL_DOWNLOAD_STALL_TIMEOUT: %q (minimum 1s)", value)
+	}
+
+	return timeout, nil
+}
+
+// newEndpoints returns the App Store base URLs. IPATOOL_APPSTORE_URL points every service at
+// one host (e.g. the fake App Store); IPATOOL_ITUNES_API_URL, IPATOOL_STORE_API_URL and
+// IPATOOL_STORE_DOWNLOAD_API_URL override individual services.
+func newEndpoints() (appstore.Endpoints, error) {
+	var endpoints appstore.Endpoints
+
+	if baseURL := os.Getenv("IPATOOL_APPSTORE_URL"); baseURL != "" {
+		endpoints = appstore.EndpointsWithBaseURL(baseURL)
+	}
+
+	overrides := map[string]*string{
+		"IPATOOL_ITUNES_API_URL":         &endpoints.ITunesAPI,
+		"IPATOOL_STORE_API_URL":          &endpoints.PrivateAppStoreAPI,
+		"IPATOOL_STORE_DOWNLOAD_API_URL": &endpoints.PrivateAppStoreDownloadAPI,
+	}
+	for key, endpoint := range overrides {
+		if value := os.Getenv(key); value != "" {
+			*endpoint = value
+		}
+	}
+
+	for _, endpoint := range []string{endpoints.ITunesAPI, endpoints.PrivateAppStoreAPI, endpoints.PrivateAppStoreDownloadAPI} {
+		if endpoint == "" {
+			continue
+		}
+		parsed, err := url.Parse(endpoint)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return appstore.Endpoints{}, fmt.Errorf("invalid App Store endpoint URL: %q", endpoint)
+		}
+	}
+
+	return endpoints, nil
+}
+
 // initServer initializes all dependencies for server mode.
 // Server mode uses JSON logging format and non-interactive keychain access.
-func initServer(verbose bool) {
-	dependencies.Logger = newLogger(verbose)
+func initServer(verbose bool) error {
+	initDependencies(newLogger(verbose))
+
+	return initServerDependencies()
+}
+
+// initServerDependencies initializes the dependencies that only the server uses, so that a misconfigured
+// download directory, watchlist or feed does not break the command-line interface.
+func initServerDependencies() error {
+	downloads, err := newDownloadDirectory(dependencies.Machine)
+	if err != nil {
+		return err
+	}
+	dependencies.Downloads = downloads
+
+	watcher, err := newWatchlist(dependencies.Machine, dependencies.Logger, dependencies.AppStore)
+	if err != nil {
+		return err
+	}
+	dependencies.Watchlist = watcher
+
+	feeds, err := newFeeds(dependencies.Machine, dependencies.Logger, dependencies.AppStore)
+	if err != nil {
+		return err
+	}
+	dependencies.Feeds = feeds
+
+	return nil
+}
+
+// initCLI initializes all dependencies for the command-line interface.
+// Logs go to stderr in a human-readable format, so that stdout only carries command output.
+func initCLI(verbose bool) {
+	initDependencies(log.NewLogger(log.Args{
+		Verbose: verbose,
+		Writer:  zerolog.ConsoleWriter{Out: os.Stderr, TimeFormat: time.Kitchen},
+	}))
+}
+
+// initDependencies initializes the dependencies shared by server mode and the command-line interface.
+func initDependencies(logger log.Logger) {
+	dependencies.Logger = logger
 	dependencies.OS = operatingsystem.New()
 	dependencies.Machine = machine.New(machine.Args{OS: dependencies.OS})
 	dependencies.CookieJar = newCookieJar(dependencies.Machine)
 	dependencies.Keychain = newKeychain(dependencies.Machine, dependencies.Logger)
+
+	util.Must("", createConfigDirectory(dependencies.OS, dependencies.Machine))
+
+	dependencies.MetadataCache = newMetadataCache(dependencies.Machine, dependencies.Logger)
+	dependencies.Digests = newDigestStore(dependencies.Machine, dependencies.Logger)
+
+	dependencies.RetryMetrics = &http.RetryMetrics{}
+	dependencies.DeviceGUID = util.Must(resolveDeviceGUID(os.Getenv("IPATOOL_DEVICE_GUID"), dependencies.Machine, dependencies.OS))
 	dependencies.AppStore = appstore.NewAppStore(appstore.Args{
 		CookieJar:       dependencies.CookieJar,
 		OperatingSystem: dependencies.OS,
 		Keychain:        dependencies.Keychain,
 		Machine:         dependencies.Machine,
+		DeviceGUID:      dependencies.DeviceGUID,
+		Proxy:           util.Must(newDefaultProxy(os.Getenv("IPATOOL_PROXY"))),
+		Retry:           util.Must(newRetryPolicy(dependencies.Logger, dependencies.RetryMetrics)),
+		Endpoints:       util.Must(newEndpoints()),
+		MetadataCache:   appStoreMetadataCache(dependencies.MetadataCache),
+		Logger:          dependencies.Logger,
+
+		DownloadConnections:  util.Must(newDownloadConnections()),
+		DownloadChunkSize:    util.Must(newDownloadChunkSize()),
+		DownloadStallTimeout: util.Must(newDownloadStallTimeout()),
 	})
+}
 
-	util.Must("", createConfigDirectory(dependencies.OS, dependencies.Machine))
+// appStoreMetadataCache avoids passing a nil store as a non-nil interface.
+func appStoreMetadataCache(store *kvstore.Store) appstore.MetadataCache {
+	if store == nil {
+		return nil
+	}
+
+	return store
 }
 
 // createConfigDirectory creates the configuration directory for the server, if needed.
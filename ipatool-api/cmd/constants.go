@@ -1,7 +1,42 @@
 package cmd
 
+import "time"
+
 const (
 	ConfigDirectoryName = ".ipatool"
 	CookieJarFileName   = "cookies"
 	KeychainServiceName = "ipatool-auth.service"
+	DeviceGUIDFileName  = "guid"
+	// MetadataCacheFileName is the persistent cache of version metadata in the config directory.
+	MetadataCacheFileName = "metadata-cache"
+	// WatchlistFileName stores the watchlist in the config directory.
+	WatchlistFileName = "watchlist.json"
+	// WatchlistDirectoryName is the default directory in the config directory that watched apps are downloaded to.
+	WatchlistDirectoryName = "watchlist"
+	// FeedsFileName defines the Atom feeds in the config directory.
+	FeedsFileName = "feeds.json"
+	// FeedStateFileName stores the versions seen by the feeds in the config directory.
+	FeedStateFileName = "feed-state"
+	// DownloadsDirectoryName is the default directory in the config directory that packages are saved to with
+	// "destination": "server".
+	DownloadsDirectoryName = "downloads"
+	// DefaultDownloadTemplate names the packages saved in the download directory.
+	DefaultDownloadTemplate = "{name}/{bundle_id}-{version}-{external_version_id}.ipa"
+	// DigestsFileName stores the SHA-256 checksums of delivered packages in the config directory.
+	DigestsFileName = "digests"
+	// DefaultDownloadConnections is the number of concurrent range requests of a CDN transfer.
+	DefaultDownloadConnections = 4
+	// MaxDownloadConnections caps IPATOOL_DOWNLOAD_CONNECTIONS.
+	MaxDownloadConnections = 16
+	// MaxDownloadChunkSizeMB caps IPATOOL_DOWNLOAD_CHUNK_SIZE_MB.
+	MaxDownloadChunkSizeMB = 1024
+	// MaxPatchUploadSize caps the IPA uploaded to POST /api/v1/patch.
+	MaxPatchUploadSize = 16 << 30
+	// PatchUploadTimeout is how long clients have to upload an IPA to POST /api/v1/patch. The server read
+	// timeout is too short for packages of several gigabytes.
+	PatchUploadTimeout = 2 * time.Hour
+	// DeviceInfoTimeout is how long the command reading the iOS version of a device may run.
+	DeviceInfoTimeout = 30 * time.Second
+	// InstallCommandTimeout is how long the command installing a package on a device may run.
+	InstallCommandTimeout = 10 * time.Minute
 )
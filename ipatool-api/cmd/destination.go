@@ -0,0 +1,506 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/majd/ipatool/v2/pkg/appstore"
+	"github.com/majd/ipatool/v2/pkg/util"
+	"github.com/majd/ipatool/v2/pkg/util/machine"
+)
+
+const (
+	// DestinationClient streams the package in the response of POST /api/v1/download.
+	DestinationClient = "client"
+	// DestinationServer saves the package in the download directory of the server.
+	DestinationServer = "server"
+)
+
+const (
+	// ConflictSkip keeps the existing file and does not download the package again.
+	ConflictSkip = "skip"
+	// ConflictOverwrite replaces the existing file.
+	ConflictOverwrite = "overwrite"
+	// ConflictSuffix saves the package next to the existing file, with a numeric suffix.
+	ConflictSuffix = "suffix"
+)
+
+// maxConflictSuffix bounds the search for a free file name with ConflictSuffix.
+const maxConflictSuffix = 1000
+
+// maxTemplateValueLength bounds the length of a template value in a file name, in bytes.
+const maxTemplateValueLength = 100
+
+var templatePlaceholder = regexp.MustCompile(`\{([^{}]*)\}`)
+
+var templatePlaceholders = map[string]bool{
+	"name":                true,
+	"bundle_id":           true,
+	"app_id":              true,
+	"version":             true,
+	"external_version_id": true,
+}
+
+// DownloadDirectory is where POST /api/v1/download saves packages with "destination": "server".
+type DownloadDirectory struct {
+	Path string
+	// Template names a package relative to Path, e.g. "{name}/{bundle_id}-{version}.ipa".
+	Template string
+}
+
+// SavedDownloadResponse is the response for POST /api/v1/download with "destination": "server".
+type SavedDownloadResponse struct {
+	Success           bool   `json:"success"`
+	Path              string `json:"path"`
+	SizeBytes         int64  `json:"size_bytes"`
+	SHA256            string `json:"sha256,omitempty"`
+	ExternalVersionID string `json:"external_version_id"`
+	// Skipped is true if the file already existed and was kept.
+	Skipped bool `json:"skipped,omitempty"`
+	// ChecksumFile is set if a kept file has no checksum file ("missing") or does not match it ("mismatch"),
+	// e.g. because it was not saved by the server. SHA256 is the checksum of the file as it is.
+	ChecksumFile string `json:"checksum_file,omitempty"`
+}
+
+// newDownloadDirectory configures the download directory from the environment: IPATOOL_DOWNLOAD_DIR (defaults to
+// the downloads directory in the config directory) and IPATOOL_DOWNLOAD_TEMPLATE.
+func newDownloadDirectory(machine machine.Machine) (DownloadDirectory, error) {
+	dir := DownloadDirectory{
+		Path:     filepath.Join(machine.HomeDirectory(), ConfigDirectoryName, DownloadsDirectoryName),
+		Template: DefaultDownloadTemplate,
+	}
+
+	if value := os.Getenv("IPATOOL_DOWNLOAD_DIR"); value != "" {
+		dir.Path = value
+	}
+
+	if value := os.Getenv("IPATOOL_DOWNLOAD_TEMPLATE"); value != "" {
+		if err := validateDownloadTemplate(value); err != nil {
+			return DownloadDirectory{}, fmt.Errorf("invalid IPATOOL_DOWNLOAD_TEMPLATE: %w", err)
+		}
+		dir.Template = value
+	}
+
+	return dir, nil
+}
+
+// validateDownloadTemplate checks that a template only uses known placeholders and names an .ipa file inside the
+// download directory.
+func validateDownloadTemplate(template string) error {
+	for _, match := range templatePlaceholder.FindAllStringSubmatch(template, -1) {
+		if !templatePlaceholders[match[1]] {
+			return fmt.Errorf("unknown placeholder %q", match[0])
+		}
+	}
+
+	if !strings.HasSuffix(template, ".ipa") {
+		return errors.New("file name must end with .ipa")
+	}
+
+	if !filepath.IsLocal(filepath.FromSlash(template)) || strings.Contains(template, "\\") {
+		return errors.New("path must be relative to the download directory")
+	}
+
+	return nil
+}
+
+func validateDestination(destination, onConflict string) error {
+	switch destination {
+	case "", DestinationClient, DestinationServer:
+	default:
+		return fmt.Errorf("destination must be %q or %q", DestinationClient, DestinationServer)
+	}
+
+	switch onConflict {
+	case "", ConflictSkip, ConflictOverwrite, ConflictSuffix:
+	default:
+		return fmt.Errorf("on_conflict must be %q, %q or %q", ConflictSkip, ConflictOverwrite, ConflictSuffix)
+	}
+
+	if onConflict != "" && destination != DestinationServer {
+		return fmt.Errorf("on_conflict requires destination %q", DestinationServer)
+	}
+
+	return nil
+}
+
+// templateValues returns the values of the template placeholders for a package.
+func templateValues(app appstore.App, metadata map[string]interface{}, externalVersionID string) map[string]string {
+	values := map[string]string{
+		"name":                metadataString(metadata, "itemName"),
+		"bundle_id":           metadataString(metadata, "softwareVersionBundleId"),
+		"app_id":              metadataString(metadata, "itemId"),
+		"version":             metadataString(metadata, "bundleShortVersionString"),
+		"external_version_id": externalVersionID,
+	}
+
+	if values["bundle_id"] == "" {
+		values["bundle_id"] = app.BundleID
+	}
+	if app.ID != 0 {
+		values["app_id"] = strconv.FormatInt(app.ID, 10)
+	}
+
+	return values
+}
+
+func metadataString(metadata map[string]interface{}, key string) string {
+	value, ok := metadata[key]
+	if !ok || value == nil {
+		return ""
+	}
+
+	return fmt.Sprint(value)
+}
+
+// render returns the path of a package in the download directory. Values are sanitized so that they can't add
+// directories or leave the download directory.
+func (d DownloadDirectory) render(values map[string]string) (string, error) {
+	relative := templatePlaceholder.ReplaceAllStringFunc(d.Template, func(placeholder string) string {
+		return sanitizePathComponent(values[strings.Trim(placeholder, "{}")])
+	})
+
+	relative = filepath.FromSlash(relative)
+	if !filepath.IsLocal(relative) {
+		return "", fmt.Errorf("file name %q is outside the download directory", relative)
+	}
+
+	return filepath.Join(d.Path, relative), nil
+}
+
+// sanitizePathComponent replaces separators and characters that file systems reject, and trims the dots and spaces
+// that would hide the file or make it a relative path element.
+func sanitizePathComponent(value string) string {
+	safe := strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f || strings.ContainsRune(`/\:*?"<>|`, r) {
+			return '_'
+		}
+		return r
+	}, value)
+
+	for len(safe) > maxTemplateValueLength {
+		_, size := utf8.DecodeLastRuneInString(safe)
+		safe = safe[:len(safe)-size]
+	}
+
+	safe = strings.Trim(safe, ". ")
+	if safe == "" {
+		return "unknown"
+	}
+
+	return safe
+}
+
+// Paths reserved by the requests that are saving a package, so that concurrent downloads don't pick the same name.
+var (
+	reservedPaths = make(map[string]struct{})
+	reservedMu    sync.Mutex
+)
+
+// errDownloadInProgress is returned by reserve if another request is saving the package to the same path.
+var errDownloadInProgress = errors.New("the package is being saved by another request")
+
+// reserve applies the conflict policy to the path of a package. With ConflictSkip and ConflictSuffix, the path
+// it returns is reserved until releaseDownload is called, once the package is saved or the download failed. With
+// ConflictSkip, any file at the path is kept instead: kept is true and nothing is reserved.
+func reserve(path, onConflict string) (reserved string, kept bool, err error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", false, fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	if onConflict == ConflictOverwrite {
+		return path, false, nil
+	}
+
+	ext := filepath.Ext(path)
+	base := strings.TrimSuffix(path, ext)
+
+	reservedMu.Lock()
+	defer reservedMu.Unlock()
+
+	for i := 1; i <= maxConflictSuffix; i++ {
+		candidate := path
+		if i > 1 {
+			candidate = fmt.Sprintf("%s-%d%s", base, i, ext)
+		}
+
+		if _, ok := reservedPaths[candidate]; ok {
+			if onConflict == ConflictSkip {
+				return "", false, errDownloadInProgress
+			}
+
+			continue
+		}
+
+		info, err := os.Stat(candidate)
+		if err == nil {
+			if onConflict != ConflictSkip {
+				continue
+			}
+			if !info.Mode().IsRegular() {
+				return "", false, fmt.Errorf("%s is not a file", candidate)
+			}
+
+			return candidate, true, nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return "", false, fmt.Errorf("failed to check file: %w", err)
+		}
+
+		reservedPaths[candidate] = struct{}{}
+
+		return candidate, false, nil
+	}
+
+	return "", false, fmt.Errorf("no free file name for %s", path)
+}
+
+// Values of SavedDownloadResponse.ChecksumFile.
+const (
+	ChecksumFileMissing  = "missing"
+	ChecksumFileMismatch = "mismatch"
+)
+
+// keptChecksum hashes a file kept with ConflictSkip and compares it with its checksum file. It returns the
+// checksum and, unless the checksum file matches, ChecksumFileMissing or ChecksumFileMismatch.
+func keptChecksum(path string) ([]byte, string, error) {
+	sum, err := util.FileSHA256(path)
+	if err != nil {
+		return nil, "", err
+	}
+
+	expected, err := util.ReadChecksumSidecar(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return sum, ChecksumFileMissing, nil
+	}
+	if err != nil || !bytes.Equal(sum, expected) {
+		return sum, ChecksumFileMismatch, nil
+	}
+
+	return sum, "", nil
+}
+
+// saveDownload downloads the package into the download directory of the server and responds with its path. The
+// transfer is canceled when ctx is done.
+func saveDownload(ctx context.Context, w http.ResponseWriter, acc appstore.Account, app appstore.App, externalVersionID string, versionSelector appstore.VersionSelector, onConflict string) {
+	if onConflict == "" {
+		onConflict = ConflictSkip
+	}
+
+	dir := dependencies.Downloads
+	if err := os.MkdirAll(dir.Path, 0755); err != nil {
+		dependencies.Logger.Error().Err(err).Str("path", dir.Path).Msg("Failed to create download directory")
+		respondError(w, http.StatusInternalServerError, "Failed to create download directory")
+		return
+	}
+
+	result, err := dependencies.AppStore.DownloadStream(appstore.DownloadStreamInput{
+		Account:           acc,
+		App:               app,
+		ExternalVersionID: externalVersionID,
+		Version:           versionSelector,
+	})
+	if errors.Is(err, appstore.ErrRangesNotSupported) {
+		dependencies.Logger.Log().Msg("CDN does not support range requests, downloading to a temporary file")
+		saveDownloadFromFile(w, acc, app, externalVersionID, versionSelector, onConflict)
+		return
+	}
+	if err != nil {
+		dependencies.Logger.Error().Err(err).Msg("Download failed")
+		statusCode, message := mapAppStoreErrorToHTTPStatus(err)
+		respondError(w, statusCode, message)
+		return
+	}
+
+	// The name is known before the transfer, so that a skipped package is not downloaded.
+	path, kept, ok := reserveDownload(w, app, result.Metadata, result.ExternalVersionID, onConflict)
+	if !ok {
+		return
+	}
+	if kept {
+		respondKeptDownload(w, path, result.ExternalVersionID)
+		return
+	}
+
+	tmpFile, err := os.CreateTemp(dir.Path, ".ipatool-*.ipa.part")
+	if err != nil {
+		releaseDownload(path, onConflict)
+		dependencies.Logger.Error().Err(err).Msg("Failed to create temporary file")
+		respondError(w, http.StatusInternalServerError, "Failed to create temporary file")
+		return
+	}
+	tmpPath := tmpFile.Name()
+
+	written, err := result.Package.WriteToContext(ctx, tmpFile)
+	if closeErr := tmpFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		releaseDownload(path, onConflict)
+		removeTempFile(tmpPath)
+		dependencies.Logger.Error().Err(err).Int64("written", written).Msg("Download failed")
+		respondError(w, http.StatusBadGateway, "Failed to download package")
+		return
+	}
+
+	recordDigest(result.Package.ContentID(), result.Package.SHA256())
+	commitDownload(w, tmpPath, path, result.Package.SHA256(), result.ExternalVersionID, onConflict)
+}
+
+// saveDownloadFromFile is saveDownload for CDNs without range requests: the package is downloaded before its name
+// is known.
+func saveDownloadFromFile(w http.ResponseWriter, acc appstore.Account, app appstore.App, externalVersionID string, versionSelector appstore.VersionSelector, onConflict string) {
+	tmpFile, err := os.CreateTemp(dependencies.Downloads.Path, ".ipatool-*.ipa.part")
+	if err != nil {
+		dependencies.Logger.Error().Err(err).Msg("Failed to create temporary file")
+		respondError(w, http.StatusInternalServerError, "Failed to create temporary file")
+		return
+	}
+	tmpPath := tmpFile.Name()
+	tmpFile.Close()
+
+	result, err := dependencies.AppStore.Download(appstore.DownloadInput{
+		Account:           acc,
+		App:               app,
+		ExternalVersionID: externalVersionID,
+		Version:           versionSelector,
+		OutputPath:        tmpPath,
+	})
+	if err != nil {
+		removeTempFile(tmpPath)
+		dependencies.Logger.Error().Err(err).Msg("Download failed")
+		statusCode, message := mapAppStoreErrorToHTTPStatus(err)
+		respondError(w, statusCode, message)
+		return
+	}
+
+	path, kept, ok := reserveDownload(w, app, result.Metadata, result.ExternalVersionID, onConflict)
+	if !ok || kept {
+		removeTempFile(result.DestinationPath)
+	}
+	if !ok {
+		return
+	}
+	if kept {
+		respondKeptDownload(w, path, result.ExternalVersionID)
+		return
+	}
+
+	sum, err := util.FileSHA256(result.DestinationPath)
+	if err != nil {
+		releaseDownload(path, onConflict)
+		removeTempFile(result.DestinationPath)
+		dependencies.Logger.Error().Err(err).Msg("Failed to compute checksum")
+		respondError(w, http.StatusInternalServerError, "Failed to compute checksum")
+		return
+	}
+
+	commitDownload(w, result.DestinationPath, path, sum, result.ExternalVersionID, onConflict)
+}
+
+// reserveDownload names the package and applies the conflict policy. It returns true as kept if an existing file
+// is kept instead. It responds with an error and returns false if that fails.
+func reserveDownload(w http.ResponseWriter, app appstore.App, metadata map[string]interface{}, externalVersionID, onConflict string) (string, bool, bool) {
+	path, err := dependencies.Downloads.render(templateValues(app, metadata, externalVersionID))
+	if err != nil {
+		dependencies.Logger.Error().Err(err).Msg("Failed to name package")
+		respondError(w, http.StatusInternalServerError, "Failed to name package")
+		return "", false, false
+	}
+
+	reserved, kept, err := reserve(path, onConflict)
+	if errors.Is(err, errDownloadInProgress) {
+		respondError(w, http.StatusConflict, "The package is being saved by another request")
+		return "", false, false
+	}
+	if err != nil {
+		dependencies.Logger.Error().Err(err).Str("path", path).Msg("Failed to reserve file name")
+		respondError(w, http.StatusInternalServerError, "Failed to save package")
+		return "", false, false
+	}
+
+	return reserved, kept, true
+}
+
+// releaseDownload releases the path reserve returned for a package.
+func releaseDownload(path, onConflict string) {
+	if onConflict == ConflictOverwrite {
+		return
+	}
+
+	reservedMu.Lock()
+	delete(reservedPaths, path)
+	reservedMu.Unlock()
+}
+
+// commitDownload moves the downloaded package to its path, writes its checksum file and responds with its path.
+func commitDownload(w http.ResponseWriter, tmpPath, path string, sum []byte, externalVersionID, onConflict string) {
+	if err := os.Rename(tmpPath, path); err != nil {
+		releaseDownload(path, onConflict)
+		removeTempFile(tmpPath)
+		dependencies.Logger.Error().Err(err).Str("path", path).Msg("Failed to move package")
+		respondError(w, http.StatusInternalServerError, "Failed to save package")
+		return
+	}
+
+	if err := util.WriteChecksumSidecar(path, sum); err != nil {
+		dependencies.Logger.Error().Err(err).Str("path", path).Msg("Failed to write checksum file")
+	}
+	releaseDownload(path, onConflict)
+
+	dependencies.Logger.Log().Str("path", path).Msg("Package saved on the server")
+	respondSavedDownload(w, SavedDownloadResponse{Path: path, SHA256: fmt.Sprintf("%x", sum), ExternalVersionID: externalVersionID})
+}
+
+// respondKeptDownload responds with a file kept with ConflictSkip, and reports whether it matches its checksum file.
+func respondKeptDownload(w http.ResponseWriter, path, externalVersionID string) {
+	sum, checksumFile, err := keptChecksum(path)
+	if err != nil {
+		dependencies.Logger.Error().Err(err).Str("path", path).Msg("Failed to compute checksum")
+		respondError(w, http.StatusInternalServerError, "Failed to compute checksum")
+		return
+	}
+	if checksumFile != "" {
+		dependencies.Logger.Log().Str("path", path).Str("checksum_file", checksumFile).Msg("Kept a file that does not match its checksum file")
+	}
+
+	respondSavedDownload(w, SavedDownloadResponse{
+		Path:              path,
+		SHA256:            fmt.Sprintf("%x", sum),
+		ExternalVersionID: externalVersionID,
+		Skipped:           true,
+		ChecksumFile:      checksumFile,
+	})
+}
+
+// respondSavedDownload completes the response with the size of the file: 201 Created for a saved package, 200 OK
+// for a kept one.
+func respondSavedDownload(w http.ResponseWriter, out SavedDownloadResponse) {
+	info, err := os.Stat(out.Path)
+	if err != nil {
+		dependencies.Logger.Error().Err(err).Str("path", out.Path).Msg("Failed to stat saved package")
+		respondError(w, http.StatusInternalServerError, "Failed to get file information")
+		return
+	}
+
+	statusCode := http.StatusCreated
+	if out.Skipped {
+		statusCode = http.StatusOK
+	}
+
+	out.Success = true
+	out.SizeBytes = info.Size()
+
+	respondJSON(w, statusCode, out)
+}
@@ -0,0 +1,122 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/majd/ipatool/v2/pkg/appstore"
+	"github.com/majd/ipatool/v2/pkg/util"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Download directory", func() {
+	DescribeTable("validates templates",
+		func(template string, valid bool) {
+			err := validateDownloadTemplate(template)
+			if valid {
+				Expect(err).ToNot(HaveOccurred())
+			} else {
+				Expect(err).To(HaveOccurred())
+			}
+		},
+		Entry("default", DefaultDownloadTemplate, true),
+		Entry("flat", "{bundle_id}_{app_id}_{external_version_id}.ipa", true),
+		Entry("unknown placeholder", "{name}/{build}.ipa", false),
+		Entry("not an ipa", "{name}/{bundle_id}.zip", false),
+		Entry("absolute", "/tmp/{bundle_id}.ipa", false),
+		Entry("parent directory", "../{bundle_id}.ipa", false),
+	)
+
+	It("names packages with sanitized values", func() {
+		dir := DownloadDirectory{Path: "/srv/ipa", Template: DefaultDownloadTemplate}
+
+		values := templateValues(appstore.App{ID: 42}, map[string]interface{}{
+			"itemName":                 "../../etc: Notes?",
+			"softwareVersionBundleId":  "com.example.notes",
+			"bundleShortVersionString": "..",
+		}, "800000002")
+		Expect(values["app_id"]).To(Equal("42"))
+
+		path, err := dir.render(values)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(path).To(Equal(filepath.Join("/srv/ipa", "_.._etc_ Notes_", "com.example.notes-unknown-800000002.ipa")))
+	})
+
+	Describe("reserving paths", func() {
+		var path string
+
+		BeforeEach(func() {
+			path = filepath.Join(GinkgoT().TempDir(), "Notes", "notes.ipa")
+		})
+
+		save := func(path string, data string) {
+			Expect(os.WriteFile(path, []byte(data), 0644)).To(Succeed())
+
+			sum, err := util.FileSHA256(path)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(util.WriteChecksumSidecar(path, sum)).To(Succeed())
+		}
+
+		It("reserves the path until it is released", func() {
+			reserved, kept, err := reserve(path, ConflictSkip)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(reserved).To(Equal(path))
+			Expect(kept).To(BeFalse())
+			Expect(filepath.Dir(path)).To(BeADirectory())
+			Expect(path).ToNot(BeAnExistingFile())
+
+			_, _, err = reserve(path, ConflictSkip)
+			Expect(err).To(MatchError(errDownloadInProgress))
+
+			suffixed, _, err := reserve(path, ConflictSuffix)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(suffixed).To(Equal(filepath.Join(filepath.Dir(path), "notes-2.ipa")))
+
+			releaseDownload(path, ConflictSkip)
+			releaseDownload(suffixed, ConflictSuffix)
+
+			reserved, _, err = reserve(path, ConflictSkip)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(reserved).To(Equal(path))
+			releaseDownload(reserved, ConflictSkip)
+		})
+
+		It("keeps any existing file", func() {
+			Expect(os.MkdirAll(filepath.Dir(path), 0755)).To(Succeed())
+			Expect(os.WriteFile(path, nil, 0644)).To(Succeed())
+
+			reserved, kept, err := reserve(path, ConflictSkip)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(reserved).To(Equal(path))
+			Expect(kept).To(BeTrue())
+
+			reserved, _, err = reserve(path, ConflictSuffix)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(reserved).To(Equal(filepath.Join(filepath.Dir(path), "notes-2.ipa")))
+			releaseDownload(reserved, ConflictSuffix)
+		})
+
+		DescribeTable("compares a kept file with its checksum file",
+			func(prepare func(), checksumFile string) {
+				Expect(os.MkdirAll(filepath.Dir(path), 0755)).To(Succeed())
+				prepare()
+
+				sum, status, err := keptChecksum(path)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(sum).To(Equal(util.Must(util.FileSHA256(path))))
+				Expect(status).To(Equal(checksumFile))
+			},
+			Entry("saved", func() {
+				save(path, "package")
+			}, ""),
+			Entry("without checksum file", func() {
+				Expect(os.WriteFile(path, []byte("package"), 0644)).To(Succeed())
+			}, ChecksumFileMissing),
+			Entry("changed since it was saved", func() {
+				save(path, "package")
+				Expect(os.WriteFile(path, []byte("partial"), 0644)).To(Succeed())
+			}, ChecksumFileMismatch),
+		)
+	})
+})
@@ -0,0 +1,165 @@
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/majd/ipatool/v2/pkg/kvstore"
+	"github.com/majd/ipatool/v2/pkg/log"
+	"github.com/majd/ipatool/v2/pkg/util/machine"
+)
+
+// newDigestStore opens the store of the SHA-256 checksums of delivered packages in the config directory. A package
+// streamed from the CDN is only hashed while it is written, so its checksum is known in advance, and If-None-Match
+// honored, from the second delivery on. The store is optional, so failing to open it only logs a warning.
+func newDigestStore(machine machine.Machine, logger log.Logger) *kvstore.Store {
+	store, err := kvstore.Open(filepath.Join(machine.HomeDirectory(), ConfigDirectoryName, DigestsFileName))
+	if err != nil {
+		logger.Error().Err(err).Msg("Failed to open the digest store, continuing without it")
+
+		return nil
+	}
+
+	return store
+}
+
+// knownDigest returns the checksum recorded for the content ID of a package.
+func knownDigest(contentID string) ([]byte, bool) {
+	if dependencies.Digests == nil || contentID == "" {
+		return nil, false
+	}
+
+	var value string
+	if !dependencies.Digests.Get(contentID, &value) {
+		return nil, false
+	}
+
+	sum, err := hex.DecodeString(value)
+	if err != nil {
+		return nil, false
+	}
+
+	return sum, true
+}
+
+func recordDigest(contentID string, sum []byte) {
+	if dependencies.Digests == nil || contentID == "" || sum == nil {
+		return
+	}
+
+	if err := dependencies.Digests.Set(contentID, hex.EncodeToString(sum)); err != nil {
+		dependencies.Logger.Error().Err(err).Msg("Failed to record digest")
+	}
+}
+
+// setPackageHeaders identifies the delivered package. The metadata is the content of iTunesMetadata.plist.
+func setPackageHeaders(w http.ResponseWriter, metadata map[string]interface{}, externalVersionID string) {
+	if externalVersionID == "" {
+		if id, ok := metadata["softwareVersionExternalIdentifier"]; ok {
+			externalVersionID = fmt.Sprintf("%v", id)
+		}
+	}
+
+	for header, value := range map[string]interface{}{
+		"X-Bundle-Id":           metadata["softwareVersionBundleId"],
+		"X-Bundle-Version":      metadata["bundleShortVersionString"],
+		"X-External-Version-Id": externalVersionID,
+	} {
+		if value == nil || value == "" {
+			continue
+		}
+
+		w.Header().Set(header, fmt.Sprintf("%v", value))
+	}
+}
+
+// setDigestHeaders sends the SHA-256 checksum of the package as Repr-Digest (RFC 9530), Digest (RFC 3230) and a
+// strong ETag.
+func setDigestHeaders(w http.ResponseWriter, sum []byte) {
+	encoded := base64.StdEncoding.EncodeToString(sum)
+
+	w.Header().Set("Repr-Digest", fmt.Sprintf("sha-256=:%s:", encoded))
+	w.Header().Set("Digest", "SHA-256="+encoded)
+	w.Header().Set("ETag", etag(sum))
+}
+
+func etag(sum []byte) string {
+	return fmt.Sprintf("%q", hex.EncodeToString(sum))
+}
+
+// etagMatches reports whether If-None-Match matches the checksum, using the weak comparison of RFC 9110.
+func etagMatches(r *http.Request, sum []byte) bool {
+	header := r.Header.Get("If-None-Match")
+	if header == "" {
+		return false
+	}
+
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag(sum) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// servePackageFile sends a package saved on the server, or responds as respondETagMatched if the client has it
+// already. The package is hashed before it is sent, so that its checksum is sent as headers along with its
+// Content-Length. It returns false if the package was not sent in full.
+func servePackageFile(w http.ResponseWriter, r *http.Request, file *os.File, filename string, size int64) bool {
+	digest := sha256.New()
+
+	if _, err := io.CopyBuffer(digest, file, make([]byte, 4*1024*1024)); err != nil {
+		dependencies.Logger.Error().Err(err).Str("path", file.Name()).Msg("Failed to compute checksum")
+		respondError(w, http.StatusInternalServerError, "Failed to read file")
+		return false
+	}
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		dependencies.Logger.Error().Err(err).Str("path", file.Name()).Msg("Failed to rewind file")
+		respondError(w, http.StatusInternalServerError, "Failed to read file")
+		return false
+	}
+
+	sum := digest.Sum(nil)
+	setDigestHeaders(w, sum)
+	if etagMatches(r, sum) {
+		respondETagMatched(w, r)
+		return false
+	}
+
+	setDownloadHeaders(w, filename, size)
+
+	if _, err := io.CopyBuffer(w, file, make([]byte, 4*1024*1024)); err != nil {
+		dependencies.Logger.Error().Err(err).Msg("Error streaming file")
+		abortResponse()
+	}
+
+	return true
+}
+
+// abortResponse resets the connection after the headers of a response were sent, so that the client sees an
+// error rather than a response that ended early. It does not return.
+func abortResponse() {
+	panic(http.ErrAbortHandler)
+}
+
+// respondETagMatched tells the client that its copy of the package is current: 304 Not Modified for GET and HEAD,
+// 412 Precondition Failed for other methods, as RFC 9110 requires.
+func respondETagMatched(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		respondError(w, http.StatusPreconditionFailed, "The package matches If-None-Match")
+		return
+	}
+
+	w.Header().Set("Cache-Control", "private, no-cache")
+	w.WriteHeader(http.StatusNotModified)
+}
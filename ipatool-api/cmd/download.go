@@ -0,0 +1,238 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/majd/ipatool/v2/pkg/appstore"
+	"github.com/schollz/progressbar/v3"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+// DownloadResponse is the command-line result of a download.
+type DownloadResponse struct {
+	Success    bool   `json:"success"`
+	OutputPath string `json:"output_path"`
+}
+
+// versionFlags are the flags that select the version to download.
+type versionFlags struct {
+	externalVersionID string
+	displayVersion    string
+	asOf              string
+	previous          int
+	compatibleWith    string
+}
+
+func (f *versionFlags) register(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&f.externalVersionID, "external-version-id", "", "external version identifier of the target version (default: latest)")
+	cmd.Flags().StringVar(&f.displayVersion, "display-version", "", "display version of the target version, e.g. 5.2.1")
+	cmd.Flags().StringVar(&f.asOf, "as-of", "", "select the version that was current on this date (YYYY-MM-DD or RFC 3339)")
+	cmd.Flags().IntVar(&f.previous, "previous", 0, "select the N-th version before the latest one")
+	cmd.Flags().StringVar(&f.compatibleWith, "compatible-with", "", "select the newest version that runs on this iOS version, e.g. 15.7")
+	cmd.MarkFlagsMutuallyExclusive("external-version-id", "display-version", "as-of", "previous", "compatible-with")
+}
+
+// isZero reports whether no version was selected.
+func (f *versionFlags) isZero() bool {
+	return *f == versionFlags{}
+}
+
+// resolve returns the external version identifier selected by the flags. Empty means the latest version.
+func (f *versionFlags) resolve(acc appstore.Account, app appstore.App) (string, error) {
+	selector, err := parseVersionSelector(f.externalVersionID, f.displayVersion, f.asOf, f.previous, f.compatibleWith)
+	if err != nil {
+		return "", err
+	}
+
+	if selector.IsZero() {
+		return f.externalVersionID, nil
+	}
+
+	output, err := dependencies.AppStore.ResolveVersion(appstore.ResolveVersionInput{
+		Account:  acc,
+		App:      app,
+		Selector: selector,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve version: %w", err)
+	}
+
+	return output.ExternalVersionID, nil
+}
+
+func downloadCmd() *cobra.Command {
+	var (
+		appID          int64
+		bundleID       string
+		version        versionFlags
+		outputPath     string
+		acquireLicense bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "download",
+		Short: "Download (encrypted) iOS app packages from the App Store",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := validateExternalVersionID(version.externalVersionID); err != nil {
+				return err
+			}
+
+			acc, err := currentAccount()
+			if err != nil {
+				return err
+			}
+
+			app, err := resolveApp(acc, appID, bundleID)
+			if err != nil {
+				return err
+			}
+
+			// The license is needed to list the versions a selector is resolved against.
+			if acquireLicense {
+				if err := purchaseIfNeeded(acc, app); err != nil {
+					return err
+				}
+			}
+
+			externalVersionID, err := version.resolve(acc, app)
+			if err != nil {
+				return err
+			}
+
+			if outputPath == "" {
+				outputPath = generateFilename(app, externalVersionID)
+			}
+
+			output, err := downloadApp(acc, app, externalVersionID, outputPath)
+			if err != nil {
+				return err
+			}
+
+			return printResult(cmd, DownloadResponse{Success: true, OutputPath: output.DestinationPath},
+				[]string{"output"}, [][]string{{output.DestinationPath}})
+		},
+	}
+
+	addAppFlags(cmd, &appID, &bundleID)
+	version.register(cmd)
+	cmd.Flags().StringVarP(&outputPath, "output", "o", "", "the destination path of the downloaded app package")
+	cmd.Flags().BoolVar(&acquireLicense, "purchase", false, "obtain a license for the app if needed")
+
+	return cmd
+}
+
+func installCmd() *cobra.Command {
+	var (
+		appID          int64
+		bundleID       string
+		version        versionFlags
+		deviceUDID     string
+		acquireLicense bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "install",
+		Short: "Download an app and install it on a USB-connected device",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := validateExternalVersionID(version.externalVersionID); err != nil {
+				return err
+			}
+
+			acc, err := currentAccount()
+			if err != nil {
+				return err
+			}
+
+			app, err := resolveApp(acc, appID, bundleID)
+			if err != nil {
+				return err
+			}
+
+			// The license is needed to list the versions a selector is resolved against.
+			if acquireLicense {
+				if err := purchaseIfNeeded(acc, app); err != nil {
+					return err
+				}
+			}
+
+			// Without a version, install the newest version the device can run.
+			deviceOSVersion := ""
+			if version.isZero() {
+				deviceOSVersion = compatibleVersionForDevice(cmd.Context(), strings.TrimSpace(deviceUDID), &version.compatibleWith)
+			}
+
+			externalVersionID, err := version.resolve(acc, app)
+			if err != nil {
+				return err
+			}
+
+			dir, err := os.MkdirTemp("", "ipatool-install-*")
+			if err != nil {
+				return fmt.Errorf("failed to create temporary directory: %w", err)
+			}
+			defer os.RemoveAll(dir)
+
+			output, err := downloadApp(acc, app, externalVersionID, filepath.Join(dir, generateFilename(app, externalVersionID)))
+			if err != nil {
+				return err
+			}
+
+			if err := runInstallCommand(cmd.Context(), output.DestinationPath, strings.TrimSpace(deviceUDID)); err != nil {
+				return fmt.Errorf("install to device failed: %w", err)
+			}
+
+			return printResult(cmd, InstallResponse{
+				Success:           true,
+				Message:           "Installed successfully",
+				ExternalVersionID: externalVersionID,
+				DeviceOSVersion:   deviceOSVersion,
+			}, nil, [][]string{{"Installed successfully."}})
+		},
+	}
+
+	addAppFlags(cmd, &appID, &bundleID)
+	version.register(cmd)
+	cmd.Flags().StringVar(&deviceUDID, "device-udid", "", "UDID of the target device (default: first connected device)")
+	cmd.Flags().BoolVar(&acquireLicense, "purchase", false, "obtain a license for the app if needed")
+
+	return cmd
+}
+
+// downloadApp downloads the app to the output path.
+// A progress bar is shown on interactive terminals unless JSON output is requested.
+func downloadApp(acc appstore.Account, app appstore.App, externalVersionID, outputPath string) (appstore.DownloadOutput, error) {
+	var progress *progressbar.ProgressBar
+	if outputFormat == OutputFormatTable && term.IsTerminal(int(os.Stderr.Fd())) {
+		progress = progressbar.NewOptions64(1,
+			progressbar.OptionSetDescription("downloading"),
+			progressbar.OptionSetWriter(os.Stderr),
+			progressbar.OptionShowBytes(true),
+			progressbar.OptionSetWidth(20),
+			progressbar.OptionFullWidth(),
+			progressbar.OptionThrottle(65_000_000),
+			progressbar.OptionShowCount(),
+			progressbar.OptionClearOnFinish(),
+			progressbar.OptionSpinnerType(14),
+			progressbar.OptionSetRenderBlankState(true),
+			progressbar.OptionSetElapsedTime(false),
+			progressbar.OptionSetPredictTime(false),
+		)
+	}
+
+	output, err := dependencies.AppStore.Download(appstore.DownloadInput{
+		Account:           acc,
+		App:               app,
+		ExternalVersionID: externalVersionID,
+		OutputPath:        outputPath,
+		Progress:          progress,
+	})
+	if err != nil {
+		return appstore.DownloadOutput{}, fmt.Errorf("failed to download app: %w", err)
+	}
+
+	return output, nil
+}
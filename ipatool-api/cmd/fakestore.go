@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/majd/ipatool/v2/pkg/fakestore"
+)
+
+// RunFakeAppStore starts a local fake App Store seeded with fakestore.DefaultConfig.
+// Point the server at it with IPATOOL_APPSTORE_URL for offline development.
+func RunFakeAppStore(port int) error {
+	listener, actualPort, err := tryListen(fmt.Sprintf(":%d", port))
+	if err != nil {
+		return fmt.Errorf("failed to start fake App Store: %w", err)
+	}
+
+	httpServer := &http.Server{
+		Handler:           fakestore.New(fakestore.DefaultConfig()),
+		ReadHeaderTimeout: 30 * time.Second,
+	}
+
+	fmt.Fprintf(os.Stdout, "Fake App Store running on port %d\n", actualPort)
+	fmt.Fprintf(os.Stdout, "Start the server with IPATOOL_APPSTORE_URL=http://127.0.0.1:%d\n", actualPort)
+	fmt.Fprintf(os.Stdout, "Accounts: %s / %s, %s / %s (auth code %s)\n",
+		fakestore.DefaultEmail, fakestore.DefaultPassword,
+		fakestore.Default2FAEmail, fakestore.DefaultPassword, fakestore.DefaultAuthCode)
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- httpServer.Serve(listener)
+	}()
+
+	select {
+	case err := <-errChan:
+		return fmt.Errorf("fake App Store error: %w", err)
+	case <-sigChan:
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := httpServer.Shutdown(ctx); err != nil {
+		return fmt.Errorf("error shutting down fake App Store: %w", err)
+	}
+
+	return nil
+}
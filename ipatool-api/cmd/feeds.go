@@ -0,0 +1,183 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/majd/ipatool/v2/pkg/appstore"
+	"github.com/majd/ipatool/v2/pkg/feed"
+	"github.com/majd/ipatool/v2/pkg/kvstore"
+	"github.com/majd/ipatool/v2/pkg/log"
+	"github.com/majd/ipatool/v2/pkg/util/machine"
+)
+
+// MinFeedTokenLength is the minimum length of a feed token.
+const MinFeedTokenLength = 16
+
+var feedNameRegex = regexp.MustCompile(`^[a-zA-Z0-9_\-]+$`)
+
+// FeedConfig is a feed defined in the feeds file.
+type FeedConfig struct {
+	Name  string `json:"name"`
+	Title string `json:"title,omitempty"`
+	// Token authenticates feed readers, which cannot send X-API-Key, as the token query parameter.
+	Token     string   `json:"token"`
+	BundleIDs []string `json:"bundle_ids"`
+}
+
+// FeedsFile is the content of the feeds file.
+type FeedsFile struct {
+	Feeds []FeedConfig `json:"feeds"`
+}
+
+// Feeds are the configured feeds and the tracker observing their apps.
+type Feeds struct {
+	Configs map[string]FeedConfig
+	// Tracker is nil if no feeds are configured.
+	Tracker *feed.Tracker
+}
+
+// newFeeds loads the feeds from IPATOOL_FEEDS_FILE (default: feeds.json in the config directory). Without the
+// file, no feeds are served. The versions seen per app are kept in the feed state file of the config directory;
+// apps are checked again at most every IPATOOL_FEED_REFRESH_INTERVAL (e.g. "15m").
+func newFeeds(machine machine.Machine, logger log.Logger, appStore appstore.AppStore) (Feeds, error) {
+	configDirectory := filepath.Join(machine.HomeDirectory(), ConfigDirectoryName)
+
+	path := os.Getenv("IPATOOL_FEEDS_FILE")
+	if path == "" {
+		path = filepath.Join(configDirectory, FeedsFileName)
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return Feeds{}, nil
+	}
+	if err != nil {
+		return Feeds{}, fmt.Errorf("failed to read feeds file: %w", err)
+	}
+
+	var file FeedsFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return Feeds{}, fmt.Errorf("invalid feeds file %s: %w", path, err)
+	}
+
+	configs, err := parseFeedConfigs(file.Feeds)
+	if err != nil {
+		return Feeds{}, fmt.Errorf("invalid feeds file %s: %w", path, err)
+	}
+
+	var refreshInterval time.Duration
+	if value := os.Getenv("IPATOOL_FEED_REFRESH_INTERVAL"); value != "" {
+		refreshInterval, err = time.ParseDuration(value)
+		if err != nil || refreshInterval < time.Minute {
+			return Feeds{}, fmt.Errorf("invalid IPATOOL_FEED_REFRESH_INTERVAL: %q (minimum 1m)", value)
+		}
+	}
+
+	store, err := kvstore.Open(filepath.Join(configDirectory, FeedStateFileName))
+	if err != nil {
+		return Feeds{}, fmt.Errorf("failed to open the feed state: %w", err)
+	}
+
+	return Feeds{
+		Configs: configs,
+		Tracker: feed.NewTracker(feed.Args{
+			AppStore:        appStore,
+			Store:           store,
+			Logger:          logger,
+			RefreshInterval: refreshInterval,
+		}),
+	}, nil
+}
+
+// parseFeedConfigs validates the feeds and indexes them by name.
+func parseFeedConfigs(feeds []FeedConfig) (map[string]FeedConfig, error) {
+	configs := map[string]FeedConfig{}
+
+	for _, config := range feeds {
+		if !feedNameRegex.MatchString(config.Name) {
+			return nil, fmt.Errorf("invalid feed name %q", config.Name)
+		}
+		if _, ok := configs[config.Name]; ok {
+			return nil, fmt.Errorf("duplicate feed name %q", config.Name)
+		}
+		if len(config.Token) < MinFeedTokenLength {
+			return nil, fmt.Errorf("token of feed %q is too short (min %d characters)", config.Name, MinFeedTokenLength)
+		}
+		if len(config.BundleIDs) == 0 {
+			return nil, fmt.Errorf("feed %q has no bundle_ids", config.Name)
+		}
+		for _, bundleID := range config.BundleIDs {
+			if err := validateBundleID(bundleID); err != nil {
+				return nil, fmt.Errorf("feed %q: %w", config.Name, err)
+			}
+		}
+
+		configs[config.Name] = config
+	}
+
+	return configs, nil
+}
+
+func handleFeed(w http.ResponseWriter, r *http.Request) {
+	config, ok := dependencies.Feeds.Configs[mux.Vars(r)["name"]]
+	if !ok {
+		respondError(w, http.StatusNotFound, "Feed not found")
+		return
+	}
+
+	// Security: Constant-time comparison of the per-feed token
+	token := r.URL.Query().Get("token")
+	if subtle.ConstantTimeCompare([]byte(token), []byte(config.Token)) != 1 {
+		respondError(w, http.StatusUnauthorized, "Invalid feed token")
+		return
+	}
+
+	// Feeds are read with the account logged in on the server.
+	accountInfo, err := dependencies.AppStore.AccountInfo()
+	if err != nil {
+		respondError(w, http.StatusServiceUnavailable, "No account is logged in on the server")
+		return
+	}
+
+	title := config.Title
+	if title == "" {
+		title = fmt.Sprintf("New versions (%s)", config.Name)
+	}
+
+	versions := dependencies.Feeds.Tracker.Versions(accountInfo.Account, config.BundleIDs)
+
+	var body bytes.Buffer
+	err = feed.WriteAtom(&body, feed.Meta{
+		ID:      fmt.Sprintf("urn:ipatool:feed:%s", config.Name),
+		Title:   title,
+		SelfURL: feedSelfURL(r),
+	}, versions)
+	if err != nil {
+		dependencies.Logger.Error().Err(err).Str("feed", config.Name).Msg("Failed to render feed")
+		respondError(w, http.StatusInternalServerError, "Failed to render feed")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(body.Bytes())
+}
+
+// feedSelfURL returns the URL of the feed without the token.
+func feedSelfURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+
+	return fmt.Sprintf("%s://%s%s", scheme, r.Host, r.URL.Path)
+}
@@ -0,0 +1,107 @@
+package cmd
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/majd/ipatool/v2/pkg/appstore"
+	"github.com/majd/ipatool/v2/pkg/util/machine"
+	"github.com/majd/ipatool/v2/pkg/util/operatingsystem"
+)
+
+var deviceGUIDRegex = regexp.MustCompile(`^[0-9A-F]{12,40}$`)
+
+// normalizeDeviceGUID uppercases the GUID and strips MAC-style separators.
+func normalizeDeviceGUID(guid string) (string, error) {
+	guid = strings.ToUpper(strings.TrimSpace(guid))
+	guid = strings.NewReplacer(":", "", "-", "").Replace(guid)
+
+	if !deviceGUIDRegex.MatchString(guid) {
+		return "", fmt.Errorf("invalid device GUID %q (expected 12-40 hexadecimal characters)", guid)
+	}
+
+	return guid, nil
+}
+
+// resolveDeviceGUID returns the device GUID used for all App Store requests.
+// A configured GUID (IPATOOL_DEVICE_GUID) wins; otherwise the GUID persisted under the
+// config directory is reused. On first run a GUID is generated from the MAC address,
+// or randomly if no interface has one, and persisted so Apple keeps seeing the same device.
+func resolveDeviceGUID(configured string, machine machine.Machine, operatingSystem operatingsystem.OperatingSystem) (string, error) {
+	if configured != "" {
+		return normalizeDeviceGUID(configured)
+	}
+
+	path := filepath.Join(machine.HomeDirectory(), ConfigDirectoryName, DeviceGUIDFileName)
+
+	data, err := readDeviceGUIDFile(operatingSystem, path)
+	if err == nil {
+		guid, err := normalizeDeviceGUID(string(data))
+		if err != nil {
+			return "", fmt.Errorf("failed to read persisted device GUID from %s: %w", path, err)
+		}
+
+		return guid, nil
+	}
+
+	if !operatingSystem.IsNotExist(err) {
+		return "", fmt.Errorf("failed to read device GUID file: %w", err)
+	}
+
+	guid, err := generateDeviceGUID(machine)
+	if err != nil {
+		return "", err
+	}
+
+	if err := writeDeviceGUIDFile(operatingSystem, path, guid); err != nil {
+		return "", fmt.Errorf("failed to persist device GUID: %w", err)
+	}
+
+	return guid, nil
+}
+
+func readDeviceGUIDFile(operatingSystem operatingsystem.OperatingSystem, path string) ([]byte, error) {
+	file, err := operatingSystem.OpenFile(path, os.O_RDONLY, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return io.ReadAll(file)
+}
+
+func writeDeviceGUIDFile(operatingSystem operatingsystem.OperatingSystem, path, guid string) error {
+	file, err := operatingSystem.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+
+	_, err = file.WriteString(guid + "\n")
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+
+	return err
+}
+
+// generateDeviceGUID derives a GUID from the MAC address, falling back to random bytes.
+func generateDeviceGUID(machine machine.Machine) (string, error) {
+	if macAddr, err := machine.MacAddress(); err == nil {
+		if guid, err := normalizeDeviceGUID(appstore.GUIDFromMacAddress(macAddr)); err == nil {
+			return guid, nil
+		}
+	}
+
+	buf := make([]byte, 6)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate device GUID: %w", err)
+	}
+
+	return strings.ToUpper(hex.EncodeToString(buf)), nil
+}
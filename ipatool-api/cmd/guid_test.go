@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+
+	"github.com/majd/ipatool/v2/pkg/util/machine"
+	"github.com/majd/ipatool/v2/pkg/util/operatingsystem"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"go.uber.org/mock/gomock"
+)
+
+var _ = Describe("Device GUID", func() {
+	var (
+		ctrl        *gomock.Controller
+		mockMachine *machine.MockMachine
+		home        string
+		guidPath    string
+	)
+
+	BeforeEach(func() {
+		ctrl = gomock.NewController(GinkgoT())
+		mockMachine = machine.NewMockMachine(ctrl)
+
+		home = GinkgoT().TempDir()
+		guidPath = filepath.Join(home, ConfigDirectoryName, DeviceGUIDFileName)
+		Expect(os.MkdirAll(filepath.Dir(guidPath), 0700)).To(Succeed())
+
+		mockMachine.EXPECT().HomeDirectory().Return(home).AnyTimes()
+	})
+
+	// stored is the GUID in the file afterwards: empty if there is no file, "*" if it is the resolved GUID.
+	DescribeTable("resolves the GUID in order of precedence",
+		func(configured, persisted, macAddress, expected, stored string) {
+			if persisted != "" {
+				Expect(os.WriteFile(guidPath, []byte(persisted), 0600)).To(Succeed())
+			}
+
+			if macAddress != "" {
+				mockMachine.EXPECT().MacAddress().Return(macAddress, nil).MaxTimes(1)
+			} else {
+				mockMachine.EXPECT().MacAddress().Return("", errors.New("no interface")).MaxTimes(1)
+			}
+
+			guid, err := resolveDeviceGUID(configured, mockMachine, operatingsystem.New())
+			Expect(err).ToNot(HaveOccurred())
+
+			if expected == "" {
+				Expect(guid).To(MatchRegexp(`^[0-9A-F]{12}$`))
+			} else {
+				Expect(guid).To(Equal(expected))
+			}
+
+			data, err := os.ReadFile(guidPath)
+			switch stored {
+			case "":
+				Expect(os.IsNotExist(err)).To(BeTrue())
+			case "*":
+				Expect(err).ToNot(HaveOccurred())
+				Expect(normalizeDeviceGUID(string(data))).To(Equal(guid))
+			default:
+				Expect(err).ToNot(HaveOccurred())
+				Expect(normalizeDeviceGUID(string(data))).To(Equal(stored))
+			}
+		},
+		Entry("configured GUID wins over the persisted one", "aa:bb:cc:dd:ee:ff", "0123456789AB", "", "AABBCCDDEEFF", "0123456789AB"),
+		Entry("configured GUID is not persisted", "aabbccddeeff", "", "", "AABBCCDDEEFF", ""),
+		Entry("persisted GUID wins over the MAC address", "", "0123456789AB\n", "11:22:33:44:55:66", "0123456789AB", "*"),
+		Entry("MAC address is used and persisted on first run", "", "", "11:22:33:44:55:66", "112233445566", "*"),
+		Entry("random GUID is persisted without a MAC address", "", "", "", "", "*"),
+	)
+
+	DescribeTable("rejects invalid GUIDs",
+		func(configured, persisted string) {
+			if persisted != "" {
+				Expect(os.WriteFile(guidPath, []byte(persisted), 0600)).To(Succeed())
+			}
+
+			_, err := resolveDeviceGUID(configured, mockMachine, operatingsystem.New())
+			Expect(err).To(MatchError(ContainSubstring("invalid device GUID")))
+		},
+		Entry("configured GUID with non-hex characters", "not-a-guid", ""),
+		Entry("configured GUID that is too short", "ABCDEF", ""),
+		Entry("configured GUID that is too long", "0123456789ABCDEF0123456789ABCDEF0123456789", ""),
+		Entry("corrupt persisted GUID", "", "garbage"),
+	)
+
+	It("reads and persists the GUID through the operating system", func() {
+		mockOS := operatingsystem.NewMockOperatingSystem(ctrl)
+		readErr := errors.New("permission denied")
+
+		mockOS.EXPECT().
+			OpenFile(guidPath, os.O_RDONLY, gomock.Any()).
+			Return(nil, readErr)
+
+		mockOS.EXPECT().
+			IsNotExist(readErr).
+			Return(false)
+
+		_, err := resolveDeviceGUID("", mockMachine, mockOS)
+		Expect(err).To(MatchError(ContainSubstring("permission denied")))
+	})
+})
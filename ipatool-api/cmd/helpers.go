@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"math/rand"
 	"net/http"
+	"net/url"
 	"strings"
 	"time"
 
@@ -26,6 +27,29 @@ func buildAppFromRequest(appID int64, bundleID string) appstore.App {
 	return appstore.App{BundleID: bundleID}
 }
 
+// storefrontLocale returns the ISO country code and the language of a storefront header value.
+// Both are empty if the storefront is unknown.
+func storefrontLocale(storeFront string) (string, string) {
+	sf, err := appstore.ParseStorefront(storeFront)
+	if err != nil {
+		return "", ""
+	}
+
+	return sf.CountryCode, sf.Language()
+}
+
+// redactProxy hides proxy credentials before returning the proxy URL to clients.
+func redactProxy(proxy string) string {
+	if proxy == "" {
+		return ""
+	}
+	proxyURL, err := url.Parse(proxy)
+	if err != nil {
+		return ""
+	}
+	return proxyURL.Redacted()
+}
+
 func generateFilename(app appstore.App, versionID string) string {
 	var filename string
 	if app.BundleID != "" {
@@ -71,7 +95,8 @@ func setDownloadHeaders(w http.ResponseWriter, filename string, fileSize int64)
 	w.Header().Set("Content-Length", fmt.Sprintf("%d", fileSize))
 	w.Header().Set("Content-Encoding", "identity")
 	w.Header().Set("Connection", "keep-alive")
-	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+	// Clients may keep the package but must revalidate it with If-None-Match.
+	w.Header().Set("Cache-Control", "private, no-cache")
 	w.Header().Set("Pragma", "no-cache")
 	w.Header().Set("Expires", "0")
 }
@@ -16,19 +16,29 @@ import (
 )
 
 // InstallRequest is the request body for POST /api/v1/install.
-// Same as download; device_udid is optional (first connected device if empty).
+// Same as download; device_udid is optional (first connected device if empty). Without a version, the
+// newest version compatible with the device's iOS version is installed.
 type InstallRequest struct {
 	AppID             int64  `json:"app_id,omitempty"`
 	BundleID          string `json:"bundle_id,omitempty"`
 	ExternalVersionID string `json:"external_version_id,omitempty"`
-	AutoPurchase      bool   `json:"auto_purchase,omitempty"`
-	DeviceUDID        string `json:"device_udid,omitempty"`
+	// Version, AsOf, Previous and CompatibleWith select a version instead of ExternalVersionID.
+	Version        string `json:"version,omitempty"`
+	AsOf           string `json:"as_of,omitempty"`
+	Previous       int    `json:"previous,omitempty"`
+	CompatibleWith string `json:"compatible_with,omitempty"`
+	AutoPurchase   bool   `json:"auto_purchase,omitempty"`
+	DeviceUDID     string `json:"device_udid,omitempty"`
 }
 
 // InstallResponse is the response for the install endpoint.
 type InstallResponse struct {
 	Success bool   `json:"success"`
 	Message string `json:"message,omitempty"`
+	// ExternalVersionID is the installed version, if it was not the latest one.
+	ExternalVersionID string `json:"external_version_id,omitempty"`
+	// DeviceOSVersion is the iOS version reported by the device, if it was used to select the version.
+	DeviceOSVersion string `json:"device_os_version,omitempty"`
 }
 
 // getInstallCommand returns the install command (default: ideviceinstaller).
@@ -40,6 +50,15 @@ func getInstallCommand() string {
 	return "ideviceinstaller"
 }
 
+// getDeviceInfoCommand returns the command that reports device properties (default: ideviceinfo).
+// Override with IPATOOL_DEVICE_INFO_CMD environment variable.
+func getDeviceInfoCommand() string {
+	if cmd := os.Getenv("IPATOOL_DEVICE_INFO_CMD"); cmd != "" {
+		return cmd
+	}
+	return "ideviceinfo"
+}
+
 func handleInstall(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithTimeout(r.Context(), 15*time.Minute)
 	defer cancel()
@@ -61,6 +80,12 @@ func handleInstall(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	versionSelector, err := parseVersionSelector(req.ExternalVersionID, req.Version, req.AsOf, req.Previous, req.CompatibleWith)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
 	accountInfo, ok := getAccountInfo(r)
 	if !ok {
 		respondError(w, http.StatusUnauthorized, "Authentication required")
@@ -116,10 +141,16 @@ func handleInstall(w http.ResponseWriter, r *http.Request) {
 		}
 	}()
 
+	deviceOSVersion := ""
+	if req.ExternalVersionID == "" && versionSelector.IsZero() {
+		deviceOSVersion = compatibleVersionForDevice(r.Context(), strings.TrimSpace(req.DeviceUDID), &versionSelector.CompatibleWith)
+	}
+
 	result, err := dependencies.AppStore.Download(appstore.DownloadInput{
 		Account:           accountInfo.Account,
 		App:               app,
 		ExternalVersionID: req.ExternalVersionID,
+		Version:           versionSelector,
 		OutputPath:        tmpPath,
 	})
 	if err != nil {
@@ -134,7 +165,7 @@ func handleInstall(w http.ResponseWriter, r *http.Request) {
 		ipaPath = result.DestinationPath
 	}
 
-	if err := runInstallCommand(ipaPath, strings.TrimSpace(req.DeviceUDID)); err != nil {
+	if err := runInstallCommand(r.Context(), ipaPath, strings.TrimSpace(req.DeviceUDID)); err != nil {
 		dependencies.Logger.Error().Err(err).Str("path", ipaPath).Msg("Install: device install failed")
 		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Install to device failed: %v", err))
 		return
@@ -142,19 +173,63 @@ func handleInstall(w http.ResponseWriter, r *http.Request) {
 
 	dependencies.Logger.Log().Str("bundleID", app.BundleID).Str("path", ipaPath).Msg("Install to device succeeded")
 	respondSuccess(w, InstallResponse{
-		Success: true,
-		Message: "Installed successfully",
+		Success:           true,
+		Message:           "Installed successfully",
+		ExternalVersionID: result.ExternalVersionID,
+		DeviceOSVersion:   deviceOSVersion,
 	})
 }
 
-func runInstallCommand(ipaPath string, deviceUDID string) error {
+// compatibleVersionForDevice sets compatibleWith to the iOS version of the device and returns it. If the
+// device cannot be queried, compatibleWith is left unchanged, so that the latest version is installed.
+func compatibleVersionForDevice(ctx context.Context, deviceUDID string, compatibleWith *string) string {
+	osVersion, err := deviceOSVersion(ctx, deviceUDID)
+	if err != nil {
+		dependencies.Logger.Log().Err(err).Msg("Install: could not read the device's iOS version, installing the latest version")
+		return ""
+	}
+
+	*compatibleWith = osVersion
+	return osVersion
+}
+
+// deviceOSVersion returns the iOS version reported by the device, e.g. "15.7". The command is killed after
+// DeviceInfoTimeout or when ctx is done.
+func deviceOSVersion(ctx context.Context, deviceUDID string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, DeviceInfoTimeout)
+	defer cancel()
+
+	cmdName := getDeviceInfoCommand()
+	args := []string{"-k", "ProductVersion"}
+	if deviceUDID != "" {
+		args = append([]string{"-u", deviceUDID}, args...)
+	}
+
+	output, err := exec.CommandContext(ctx, cmdName, args...).Output()
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", cmdName, err)
+	}
+
+	osVersion := strings.TrimSpace(string(output))
+	if !osVersionRegex.MatchString(osVersion) {
+		return "", fmt.Errorf("%s reported an invalid iOS version: %q", cmdName, osVersion)
+	}
+	return osVersion, nil
+}
+
+// runInstallCommand installs the package on the device. The command is killed after InstallCommandTimeout or
+// when ctx is done.
+func runInstallCommand(ctx context.Context, ipaPath string, deviceUDID string) error {
+	ctx, cancel := context.WithTimeout(ctx, InstallCommandTimeout)
+	defer cancel()
+
 	cmdName := getInstallCommand()
 	args := []string{"install", ipaPath}
 	if deviceUDID != "" {
 		args = append([]string{"-u", deviceUDID}, args...)
 	}
 
-	cmd := exec.Command(cmdName, args...)
+	cmd := exec.CommandContext(ctx, cmdName, args...)
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	if err := cmd.Run(); err != nil {
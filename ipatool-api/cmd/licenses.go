@@ -0,0 +1,106 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/majd/ipatool/v2/pkg/appstore"
+)
+
+// LicenseCheckRequest is the request body for POST /api/v1/licenses/check.
+type LicenseCheckRequest struct {
+	AppIDs    []int64  `json:"app_ids,omitempty"`
+	BundleIDs []string `json:"bundle_ids,omitempty"`
+}
+
+// LicenseInfo is the license status of a single app: owned, not_owned or unknown.
+type LicenseInfo struct {
+	AppID    int64  `json:"app_id,omitempty"`
+	BundleID string `json:"bundle_id,omitempty"`
+	Name     string `json:"name,omitempty"`
+	Status   string `json:"status"`
+	// Error is set if the status is unknown.
+	Error string `json:"error,omitempty"`
+}
+
+// LicenseCheckResponse is the response for POST /api/v1/licenses/check. Licenses are in request order,
+// app IDs first.
+type LicenseCheckResponse struct {
+	OwnedCount int           `json:"owned_count"`
+	Licenses   []LicenseInfo `json:"licenses"`
+}
+
+func handleLicenseCheck(w http.ResponseWriter, r *http.Request) {
+	var req LicenseCheckRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	total := len(req.AppIDs) + len(req.BundleIDs)
+	if total == 0 {
+		respondError(w, http.StatusBadRequest, "app_ids or bundle_ids is required")
+		return
+	}
+	if total > MaxLicenseChecks {
+		respondError(w, http.StatusBadRequest, fmt.Sprintf("Cannot check more than %d apps at once", MaxLicenseChecks))
+		return
+	}
+
+	apps := make([]appstore.App, 0, total)
+	for _, appID := range req.AppIDs {
+		if appID <= 0 {
+			respondError(w, http.StatusBadRequest, fmt.Sprintf("Invalid app ID in app_ids: %d", appID))
+			return
+		}
+		apps = append(apps, appstore.App{ID: appID})
+	}
+	for _, bundleID := range req.BundleIDs {
+		if err := validateBundleID(bundleID); err != nil {
+			respondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		apps = append(apps, appstore.App{BundleID: bundleID})
+	}
+
+	accountInfo, ok := getAccountInfo(r)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	result, err := dependencies.AppStore.CheckLicenses(appstore.CheckLicensesInput{
+		Account: accountInfo.Account,
+		Apps:    apps,
+	})
+	if err != nil {
+		statusCode, message := mapAppStoreErrorToHTTPStatus(err)
+		respondError(w, statusCode, message)
+		return
+	}
+
+	response := LicenseCheckResponse{Licenses: make([]LicenseInfo, len(result.Licenses))}
+
+	for i, license := range result.Licenses {
+		info := LicenseInfo{
+			AppID:    license.App.ID,
+			BundleID: license.App.BundleID,
+			Name:     license.App.Name,
+			Status:   string(license.Status),
+		}
+
+		if license.Status == appstore.LicenseStatusOwned {
+			response.OwnedCount++
+		}
+
+		if license.Err != nil {
+			dependencies.Logger.Verbose().Err(license.Err).Int64("app_id", license.App.ID).Str("bundle_id", license.App.BundleID).Msg("License check failed")
+			_, info.Error = mapAppStoreErrorToHTTPStatus(license.Err)
+		}
+
+		response.Licenses[i] = info
+	}
+
+	respondSuccess(w, response)
+}
@@ -0,0 +1,115 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/majd/ipatool/v2/pkg/appstore"
+	"github.com/spf13/cobra"
+)
+
+func listVersionsCmd() *cobra.Command {
+	var (
+		appID    int64
+		bundleID string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "list-versions",
+		Short: "List the external version identifiers of an app",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			acc, err := currentAccount()
+			if err != nil {
+				return err
+			}
+
+			app, err := resolveApp(acc, appID, bundleID)
+			if err != nil {
+				return err
+			}
+
+			output, err := dependencies.AppStore.ListVersions(appstore.ListVersionsInput{Account: acc, App: app})
+			if err != nil {
+				return fmt.Errorf("failed to list versions: %w", err)
+			}
+
+			rows := make([][]string, len(output.ExternalVersionIdentifiers))
+			for i, id := range output.ExternalVersionIdentifiers {
+				latest := ""
+				if id == output.LatestExternalVersionID {
+					latest = "yes"
+				}
+
+				rows[i] = []string{id, latest}
+			}
+
+			return printResult(cmd, ListVersionsResponse{
+				BundleID:           app.BundleID,
+				ExternalVersionIDs: output.ExternalVersionIdentifiers,
+				Success:            true,
+			}, []string{"external version id", "latest"}, rows)
+		},
+	}
+
+	addAppFlags(cmd, &appID, &bundleID)
+
+	return cmd
+}
+
+func metadataCmd() *cobra.Command {
+	var (
+		appID       int64
+		bundleID    string
+		versionID   string
+		bypassCache bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "metadata",
+		Short: "Show the metadata of a specific app version",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := validateVersionID(versionID); err != nil {
+				return err
+			}
+
+			acc, err := currentAccount()
+			if err != nil {
+				return err
+			}
+
+			app, err := resolveApp(acc, appID, bundleID)
+			if err != nil {
+				return err
+			}
+
+			output, err := dependencies.AppStore.GetVersionMetadata(appstore.GetVersionMetadataInput{
+				Account:     acc,
+				App:         app,
+				VersionID:   versionID,
+				BypassCache: bypassCache,
+			})
+			if err != nil {
+				return fmt.Errorf("failed to get version metadata: %w", err)
+			}
+
+			response := VersionMetadataResponse{
+				Success:           true,
+				ExternalVersionID: versionID,
+				DisplayVersion:    output.DisplayVersion,
+				ReleaseDate:       output.ReleaseDate.Format(time.RFC3339),
+			}
+
+			return printResult(cmd, response, []string{"external version id", "version", "release date"}, [][]string{
+				{response.ExternalVersionID, response.DisplayVersion, response.ReleaseDate},
+			})
+		},
+	}
+
+	addAppFlags(cmd, &appID, &bundleID)
+	cmd.Flags().StringVar(&versionID, "external-version-id", "", "external version identifier of the target version")
+	cmd.Flags().BoolVar(&bypassCache, "bypass-cache", false, "fetch the metadata from the App Store even if it is cached")
+
+	_ = cmd.MarkFlagRequired("external-version-id")
+
+	return cmd
+}
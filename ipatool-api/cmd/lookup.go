@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/majd/ipatool/v2/pkg/appstore"
+)
+
+// LookupResponse is the response for GET /api/v1/lookup.
+type LookupResponse struct {
+	Count int       `json:"count"`
+	Apps  []AppInfo `json:"apps"`
+}
+
+func handleLookup(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	bundleID := query.Get("bundle_id")
+	appIDStr := query.Get("app_id")
+	idsStr := query.Get("ids")
+
+	input := appstore.LookupInput{CountryCode: query.Get("country")}
+
+	if err := validateCountryCode(input.CountryCode); err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if idsStr != "" {
+		if bundleID != "" || appIDStr != "" {
+			respondError(w, http.StatusBadRequest, "ids cannot be combined with app_id or bundle_id")
+			return
+		}
+
+		appIDs, err := parseAppIDs(idsStr)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		input.AppIDs = appIDs
+	} else {
+		if err := validateAppIDOrBundleID(appIDStr, bundleID); err != nil {
+			respondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		if appIDStr != "" {
+			input.AppID, _ = strconv.ParseInt(appIDStr, 10, 64)
+		} else {
+			input.BundleID = bundleID
+		}
+	}
+
+	accountInfo, ok := getAccountInfo(r)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+	input.Account = accountInfo.Account
+
+	result, err := dependencies.AppStore.Lookup(input)
+	if err != nil {
+		statusCode, message := mapAppStoreErrorToHTTPStatus(err)
+		respondError(w, statusCode, message)
+		return
+	}
+
+	apps := make([]AppInfo, len(result.Apps))
+	for i, app := range result.Apps {
+		apps[i] = appToAppInfo(app)
+	}
+
+	respondSuccess(w, LookupResponse{
+		Count: len(apps),
+		Apps:  apps,
+	})
+}
+
+// parseAppIDs parses a comma-separated list of app IDs.
+func parseAppIDs(value string) ([]int64, error) {
+	parts := strings.Split(value, ",")
+	if len(parts) > MaxLimit {
+		return nil, fmt.Errorf("ids cannot contain more than %d app IDs", MaxLimit)
+	}
+
+	appIDs := make([]int64, 0, len(parts))
+	for _, part := range parts {
+		appID, err := strconv.ParseInt(strings.TrimSpace(part), 10, 64)
+		if err != nil || appID <= 0 {
+			return nil, fmt.Errorf("invalid app ID in ids: %q", part)
+		}
+		appIDs = append(appIDs, appID)
+	}
+
+	return appIDs, nil
+}
@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/majd/ipatool/v2/pkg/kvstore"
+	"github.com/majd/ipatool/v2/pkg/log"
+	"github.com/majd/ipatool/v2/pkg/util/machine"
+)
+
+// MetadataCacheStatsResponse is the response for GET /api/v1/cache/stats.
+type MetadataCacheStatsResponse struct {
+	Enabled   bool   `json:"enabled"`
+	Path      string `json:"path,omitempty"`
+	Entries   int    `json:"entries"`
+	SizeBytes int64  `json:"size_bytes"`
+	Hits      uint64 `json:"hits"`
+	Misses    uint64 `json:"misses"`
+}
+
+// MetadataCachePurgeResponse is the response for DELETE /api/v1/cache.
+type MetadataCachePurgeResponse struct {
+	Success       bool `json:"success"`
+	PurgedEntries int  `json:"purged_entries"`
+}
+
+// newMetadataCache opens the persistent version metadata cache in the config directory.
+// IPATOOL_METADATA_CACHE=off disables it. The cache is optional, so failing to open it only logs a warning.
+func newMetadataCache(machine machine.Machine, logger log.Logger) *kvstore.Store {
+	switch strings.ToLower(os.Getenv("IPATOOL_METADATA_CACHE")) {
+	case "off", "false", "0":
+		return nil
+	}
+
+	store, err := kvstore.Open(filepath.Join(machine.HomeDirectory(), ConfigDirectoryName, MetadataCacheFileName))
+	if err != nil {
+		logger.Error().Err(err).Msg("Failed to open the metadata cache, continuing without it")
+
+		return nil
+	}
+
+	return store
+}
+
+func metadataCacheStats() MetadataCacheStatsResponse {
+	if dependencies.MetadataCache == nil {
+		return MetadataCacheStatsResponse{}
+	}
+
+	stats := dependencies.MetadataCache.Stats()
+
+	return MetadataCacheStatsResponse{
+		Enabled:   true,
+		Path:      stats.Path,
+		Entries:   stats.Entries,
+		SizeBytes: stats.SizeBytes,
+		Hits:      stats.Hits,
+		Misses:    stats.Misses,
+	}
+}
+
+func handleMetadataCacheStats(w http.ResponseWriter, r *http.Request) {
+	respondSuccess(w, metadataCacheStats())
+}
+
+func handleMetadataCachePurge(w http.ResponseWriter, r *http.Request) {
+	if dependencies.MetadataCache == nil {
+		respondError(w, http.StatusNotFound, "Metadata cache is disabled")
+		return
+	}
+
+	entries := dependencies.MetadataCache.Stats().Entries
+	if err := dependencies.MetadataCache.Purge(); err != nil {
+		dependencies.Logger.Error().Err(err).Msg("Failed to purge the metadata cache")
+		respondError(w, http.StatusInternalServerError, "Failed to purge the metadata cache")
+		return
+	}
+
+	respondSuccess(w, MetadataCachePurgeResponse{Success: true, PurgedEntries: entries})
+}
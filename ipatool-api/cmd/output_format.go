@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+// OutputFormat selects how command-line results are printed.
+type OutputFormat int
+
+const (
+	OutputFormatTable OutputFormat = iota
+	OutputFormatJSON
+)
+
+var outputFormatNames = map[OutputFormat]string{
+	OutputFormatTable: "table",
+	OutputFormatJSON:  "json",
+}
+
+func (f *OutputFormat) String() string {
+	return outputFormatNames[*f]
+}
+
+func (f *OutputFormat) Set(value string) error {
+	for format, name := range outputFormatNames {
+		if strings.EqualFold(value, name) {
+			*f = format
+
+			return nil
+		}
+	}
+
+	return fmt.Errorf("must be one of: table, json")
+}
+
+func (f *OutputFormat) Type() string {
+	return "format"
+}
+
+// outputFormat is set by the --format flag of the root command.
+var outputFormat = OutputFormatTable
+
+// printResult writes a command result to the command's output, either as indented JSON of data
+// or as a table with the specified header and rows.
+func printResult(cmd *cobra.Command, data interface{}, header []string, rows [][]string) error {
+	out := cmd.OutOrStdout()
+
+	if outputFormat == OutputFormatJSON {
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+
+		if err := encoder.Encode(data); err != nil {
+			return fmt.Errorf("failed to encode output: %w", err)
+		}
+
+		return nil
+	}
+
+	return printTable(out, header, rows)
+}
+
+func printTable(out io.Writer, header []string, rows [][]string) error {
+	writer := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+
+	if len(header) > 0 {
+		fmt.Fprintln(writer, strings.ToUpper(strings.Join(header, "\t")))
+	}
+
+	for _, row := range rows {
+		fmt.Fprintln(writer, strings.Join(row, "\t"))
+	}
+
+	if err := writer.Flush(); err != nil {
+		return fmt.Errorf("failed to write output: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/majd/ipatool/v2/pkg/appstore"
+	"github.com/spf13/cobra"
+)
+
+func purchaseCmd() *cobra.Command {
+	var (
+		appID    int64
+		bundleID string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "purchase",
+		Short: "Obtain a license for the app from the App Store",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			acc, err := currentAccount()
+			if err != nil {
+				return err
+			}
+
+			app, err := resolveApp(acc, appID, bundleID)
+			if err != nil {
+				return err
+			}
+
+			if err := dependencies.AppStore.Purchase(appstore.PurchaseInput{Account: acc, App: app}); err != nil {
+				return fmt.Errorf("failed to purchase app: %w", err)
+			}
+
+			return printResult(cmd, PurchaseResponse{
+				Success: true,
+				Message: "License purchased successfully",
+			}, nil, [][]string{{"License purchased successfully."}})
+		},
+	}
+
+	addAppFlags(cmd, &appID, &bundleID)
+
+	return cmd
+}
@@ -57,6 +57,15 @@ func mapAppStoreErrorToHTTPStatus(err error) (int, string) {
 			Interface("metadata", appstoreErr.Metadata).
 			Msg("Purchase error with metadata")
 	}
+	if errors.Is(err, appstore.ErrAppNotFound) {
+		return http.StatusNotFound, "App not found."
+	}
+	if errors.Is(err, appstore.ErrVersionNotFound) {
+		return http.StatusNotFound, "No version matches the selector."
+	}
+	if errors.Is(err, appstore.ErrAuthCodeRequired) {
+		return http.StatusUnauthorized, "Two-factor authentication code is required."
+	}
 	if errors.Is(err, appstore.ErrPasswordTokenExpired) {
 		return http.StatusUnauthorized, "Authentication expired. Please login again."
 	}
@@ -69,6 +78,9 @@ func mapAppStoreErrorToHTTPStatus(err error) (int, string) {
 	if errors.Is(err, appstore.ErrTemporarilyUnavailable) {
 		return http.StatusServiceUnavailable, "Service temporarily unavailable. Please try again later."
 	}
+	if errors.Is(err, appstore.ErrIntegrityCheckFailed) {
+		return http.StatusBadGateway, "Downloaded file is corrupted. Please try again later."
+	}
 
 	if strings.Contains(errMsg, "password token is expired") || strings.Contains(errMsg, "authentication") {
 		return http.StatusUnauthorized, "Authentication required. Please login first."
@@ -0,0 +1,189 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/majd/ipatool/v2/pkg/appstore"
+	"github.com/spf13/cobra"
+)
+
+// Execute runs the command line and returns the process exit code.
+func Execute() int {
+	root := newRootCmd()
+	root.SetArgs(legacyArgs(os.Args[1:]))
+
+	if err := root.Execute(); err != nil {
+		return 1
+	}
+
+	return 0
+}
+
+// legacyArgs maps the original server-only invocation (no arguments, or `-port`/`-api-key` flags)
+// to the serve command, so that existing deployments keep working.
+func legacyArgs(args []string) []string {
+	if len(args) == 0 {
+		return []string{"serve"}
+	}
+
+	name := strings.SplitN(strings.TrimLeft(args[0], "-"), "=", 2)[0]
+	if !strings.HasPrefix(args[0], "-") || (name != "port" && name != "api-key") {
+		return args
+	}
+
+	mapped := []string{"serve"}
+
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "-") && !strings.HasPrefix(arg, "--") && len(arg) > 2 {
+			arg = "-" + arg
+		}
+
+		mapped = append(mapped, arg)
+	}
+
+	return mapped
+}
+
+func newRootCmd() *cobra.Command {
+	var verbose bool
+
+	cmd := &cobra.Command{
+		Use:           "ipaserver",
+		Short:         "HTTP API server and command-line client for the App Store",
+		Version:       version,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		PersistentPreRun: func(cmd *cobra.Command, args []string) {
+			// Dependencies may already be set up by an embedding program or a test.
+			if dependencies.AppStore == nil {
+				initCLI(verbose)
+			}
+		},
+	}
+
+	outputFormat = OutputFormatTable
+	cmd.PersistentFlags().VarP(&outputFormat, "format", "f", "output format (table, json)")
+	cmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "enables verbose logs")
+
+	cmd.AddCommand(serveCmd())
+	cmd.AddCommand(fakeAppStoreCmd())
+	cmd.AddCommand(authCmd())
+	cmd.AddCommand(searchCmd())
+	cmd.AddCommand(lookupCmd())
+	cmd.AddCommand(purchaseCmd())
+	cmd.AddCommand(listVersionsCmd())
+	cmd.AddCommand(metadataCmd())
+	cmd.AddCommand(cacheCmd())
+	cmd.AddCommand(downloadCmd())
+	cmd.AddCommand(installCmd())
+
+	cmd.SetFlagErrorFunc(func(cmd *cobra.Command, err error) error {
+		printError(cmd, err)
+
+		return err
+	})
+
+	// Errors are printed here rather than by cobra, so that JSON output stays machine-readable.
+	for _, child := range cmd.Commands() {
+		wrapRunE(child)
+	}
+
+	return cmd
+}
+
+func wrapRunE(cmd *cobra.Command) {
+	for _, child := range cmd.Commands() {
+		wrapRunE(child)
+	}
+
+	if cmd.RunE == nil {
+		return
+	}
+
+	runE := cmd.RunE
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		err := runE(cmd, args)
+		if err != nil {
+			printError(cmd, err)
+		}
+
+		return err
+	}
+}
+
+func printError(cmd *cobra.Command, err error) {
+	if outputFormat == OutputFormatJSON {
+		statusCode, message := mapAppStoreErrorToHTTPStatus(err)
+		if statusCode == http.StatusInternalServerError || statusCode == http.StatusNotFound {
+			message = err.Error()
+		}
+
+		_ = printResult(cmd, ErrorResponse{Error: http.StatusText(statusCode), Message: message, Code: statusCode}, nil, nil)
+
+		return
+	}
+
+	fmt.Fprintf(cmd.ErrOrStderr(), "Error: %v\n", err)
+}
+
+// addAppFlags registers the --app-id and --bundle-identifier flags used to select an app.
+func addAppFlags(cmd *cobra.Command, appID *int64, bundleID *string) {
+	cmd.Flags().Int64VarP(appID, "app-id", "i", 0, "ID of the target iOS app")
+	cmd.Flags().StringVarP(bundleID, "bundle-identifier", "b", "", "bundle identifier of the target iOS app")
+	cmd.MarkFlagsOneRequired("app-id", "bundle-identifier")
+	cmd.MarkFlagsMutuallyExclusive("app-id", "bundle-identifier")
+}
+
+// currentAccount returns the account stored by `auth login`.
+func currentAccount() (appstore.Account, error) {
+	info, err := dependencies.AppStore.AccountInfo()
+	if err != nil {
+		return appstore.Account{}, fmt.Errorf("not logged in, run `ipaserver auth login` first: %w", err)
+	}
+
+	return info.Account, nil
+}
+
+// resolveApp returns the app with the specified ID, or looks it up by bundle identifier.
+func resolveApp(acc appstore.Account, appID int64, bundleID string) (appstore.App, error) {
+	if err := validateAppIDOrBundleID(appIDString(appID), bundleID); err != nil {
+		return appstore.App{}, err
+	}
+
+	if appID != 0 {
+		return appstore.App{ID: appID}, nil
+	}
+
+	lookup, err := dependencies.AppStore.Lookup(appstore.LookupInput{
+		Account:  acc,
+		BundleID: bundleID,
+	})
+	if err != nil {
+		return appstore.App{}, fmt.Errorf("failed to look up %s: %w", bundleID, err)
+	}
+
+	return lookup.App, nil
+}
+
+// purchaseIfNeeded obtains a license for the app, ignoring the error returned when it is already owned.
+func purchaseIfNeeded(acc appstore.Account, app appstore.App) error {
+	err := dependencies.AppStore.Purchase(appstore.PurchaseInput{Account: acc, App: app})
+	if err != nil && !errors.Is(err, appstore.ErrLicenseAlreadyExists) {
+		return fmt.Errorf("failed to purchase app: %w", err)
+	}
+
+	return nil
+}
+
+func appIDString(appID int64) string {
+	if appID == 0 {
+		return ""
+	}
+
+	return strconv.FormatInt(appID, 10)
+}
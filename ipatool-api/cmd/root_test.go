@@ -0,0 +1,161 @@
+package cmd
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+
+	"github.com/majd/ipatool/v2/pkg/fakestore"
+	"github.com/majd/ipatool/v2/pkg/util"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Command line", func() {
+	var (
+		fake  *fakestore.Server
+		store *httptest.Server
+	)
+
+	BeforeEach(func() {
+		fake = fakestore.New(fakestore.DefaultConfig())
+		store = httptest.NewServer(fake)
+
+		setTestDependencies(store.URL)
+	})
+
+	AfterEach(func() {
+		store.Close()
+	})
+
+	run := func(args ...string) (string, string, error) {
+		stdout := new(bytes.Buffer)
+		stderr := new(bytes.Buffer)
+
+		root := newRootCmd()
+		root.SetArgs(args)
+		root.SetOut(stdout)
+		root.SetErr(stderr)
+
+		err := root.Execute()
+
+		return stdout.String(), stderr.String(), err
+	}
+
+	login := func() {
+		_, _, err := run("auth", "login", "-e", fakestore.DefaultEmail, "-p", fakestore.DefaultPassword)
+		Expect(err).ToNot(HaveOccurred())
+	}
+
+	It("maps the legacy server flags to the serve command", func() {
+		Expect(legacyArgs(nil)).To(Equal([]string{"serve"}))
+		Expect(legacyArgs([]string{"-port", "9090", "-api-key", "secret"})).
+			To(Equal([]string{"serve", "--port", "9090", "--api-key", "secret"}))
+		Expect(legacyArgs([]string{"--port=9090"})).To(Equal([]string{"serve", "--port=9090"}))
+		Expect(legacyArgs([]string{"search", "notes"})).To(Equal([]string{"search", "notes"}))
+		Expect(legacyArgs([]string{"--help"})).To(Equal([]string{"--help"}))
+	})
+
+	It("logs in and prints account info as a table", func() {
+		stdout, _, err := run("auth", "login", "-e", fakestore.DefaultEmail, "-p", fakestore.DefaultPassword)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(stdout).To(ContainSubstring("EMAIL"))
+		Expect(stdout).To(ContainSubstring(fakestore.DefaultEmail))
+
+		stdout, _, err = run("auth", "info")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(stdout).To(ContainSubstring("0123456789AB"))
+	})
+
+	It("requires the auth code for two-factor accounts when not interactive", func() {
+		_, stderr, err := run("auth", "login", "-e", fakestore.Default2FAEmail, "-p", fakestore.DefaultPassword)
+		Expect(err).To(HaveOccurred())
+		Expect(stderr).To(ContainSubstring("--auth-code"))
+
+		_, _, err = run("auth", "login", "-e", fakestore.Default2FAEmail, "-p", fakestore.DefaultPassword, "--auth-code", fakestore.DefaultAuthCode)
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	It("prints search results as JSON", func() {
+		login()
+
+		stdout, _, err := run("search", "example", "--limit", "10", "--format", "json")
+		Expect(err).ToNot(HaveOccurred())
+
+		var out SearchResponse
+		Expect(json.Unmarshal([]byte(stdout), &out)).To(Succeed())
+		Expect(out.Count).To(Equal(3))
+		Expect(out.Apps[0].BundleID).To(Equal("com.example.notes"))
+	})
+
+	It("prints errors as JSON", func() {
+		login()
+
+		stdout, _, err := run("list-versions", "-b", "com.example.notes", "-f", "json")
+		Expect(err).To(HaveOccurred())
+
+		var out ErrorResponse
+		Expect(json.Unmarshal([]byte(stdout), &out)).To(Succeed())
+		Expect(out.Code).To(Equal(403))
+	})
+
+	It("purchases, lists versions and downloads", func() {
+		login()
+
+		_, _, err := run("purchase", "-b", "com.example.notes")
+		Expect(err).ToNot(HaveOccurred())
+
+		stdout, _, err := run("list-versions", "-i", "1000000101")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(stdout).To(MatchRegexp(`800000003\s+yes`))
+
+		stdout, _, err = run("metadata", "-i", "1000000101", "--external-version-id", "800000001", "-f", "json")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(stdout).To(ContainSubstring(`"display_version": "1.0.0"`))
+
+		stdout, _, err = run("cache", "stats", "-f", "json")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(stdout).To(ContainSubstring(`"entries": 2`))
+
+		stdout, _, err = run("cache", "purge")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(stdout).To(ContainSubstring("Purged 2 entries."))
+
+		output := filepath.Join(GinkgoT().TempDir(), "notes.ipa")
+		_, _, err = run("download", "-b", "com.example.notes", "--purchase", "-o", output)
+		Expect(err).ToNot(HaveOccurred())
+
+		reader, err := zip.OpenReader(output)
+		Expect(err).ToNot(HaveOccurred())
+		defer reader.Close()
+
+		names := []string{}
+		for _, file := range reader.File {
+			names = append(names, file.Name)
+		}
+
+		Expect(names).To(ContainElement("Payload/Notes.app/SC_Info/Notes.sinf"))
+	})
+
+	It("downloads the version matching a selector", func() {
+		login()
+
+		dir := GinkgoT().TempDir()
+		DeferCleanup(os.Chdir, util.Must(os.Getwd()))
+		Expect(os.Chdir(dir)).To(Succeed())
+
+		stdout, _, err := run("download", "-b", "com.example.notes", "--purchase", "--display-version", "1.1.0", "-f", "json")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(stdout).To(ContainSubstring(`"output_path": "com-example-notes-800000002.ipa"`))
+
+		_, _, err = run("download", "-b", "com.example.notes", "--previous", "1", "--as-of", "2024-01-01")
+		Expect(err).To(HaveOccurred())
+
+		stdout, _, err = run("download", "-b", "com.example.notes", "--compatible-with", "15.7", "-f", "json")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(stdout).To(ContainSubstring(`"output_path": "com-example-notes-800000002.ipa"`))
+	})
+})
@@ -0,0 +1,125 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/majd/ipatool/v2/pkg/appstore"
+	"github.com/spf13/cobra"
+)
+
+func searchCmd() *cobra.Command {
+	var (
+		limit       int64
+		countryCode string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "search <term>",
+		Short: "Search for iOS apps available on the App Store",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := validateTerm(args[0]); err != nil {
+				return err
+			}
+
+			if _, err := validateLimit(strconv.FormatInt(limit, 10)); err != nil {
+				return err
+			}
+
+			if err := validateCountryCode(countryCode); err != nil {
+				return err
+			}
+
+			acc, err := currentAccount()
+			if err != nil {
+				return err
+			}
+
+			output, err := dependencies.AppStore.Search(appstore.SearchInput{
+				Account:     acc,
+				Term:        args[0],
+				Limit:       limit,
+				CountryCode: countryCode,
+			})
+			if err != nil {
+				return fmt.Errorf("failed to search: %w", err)
+			}
+
+			apps := make([]AppInfo, len(output.Results))
+			for i, app := range output.Results {
+				apps[i] = appToAppInfo(app)
+			}
+
+			return printResult(cmd, SearchResponse{Count: output.Count, Apps: apps}, appTableHeader, appTableRows(apps...))
+		},
+	}
+
+	cmd.Flags().Int64VarP(&limit, "limit", "l", 5, "maximum amount of search results to retrieve")
+	cmd.Flags().StringVarP(&countryCode, "country", "c", "", "two-letter country code of the storefront to search (default: the account's)")
+
+	return cmd
+}
+
+func lookupCmd() *cobra.Command {
+	var (
+		appID       int64
+		bundleID    string
+		countryCode string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "lookup",
+		Short: "Look up an iOS app on the App Store",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := validateAppIDOrBundleID(appIDString(appID), bundleID); err != nil {
+				return err
+			}
+
+			if err := validateCountryCode(countryCode); err != nil {
+				return err
+			}
+
+			acc, err := currentAccount()
+			if err != nil {
+				return err
+			}
+
+			output, err := dependencies.AppStore.Lookup(appstore.LookupInput{
+				Account:     acc,
+				BundleID:    bundleID,
+				AppID:       appID,
+				CountryCode: countryCode,
+			})
+			if err != nil {
+				return fmt.Errorf("failed to look up app: %w", err)
+			}
+
+			info := appToAppInfo(output.App)
+
+			return printResult(cmd, info, appTableHeader, appTableRows(info))
+		},
+	}
+
+	addAppFlags(cmd, &appID, &bundleID)
+	cmd.Flags().StringVarP(&countryCode, "country", "c", "", "two-letter country code of the storefront (default: the account's)")
+
+	return cmd
+}
+
+var appTableHeader = []string{"id", "bundle id", "name", "version", "price"}
+
+func appTableRows(apps ...AppInfo) [][]string {
+	rows := make([][]string, len(apps))
+	for i, app := range apps {
+		rows[i] = []string{
+			strconv.FormatInt(app.TrackID, 10),
+			app.BundleID,
+			app.Name,
+			app.Version,
+			strconv.FormatFloat(app.Price, 'f', -1, 64),
+		}
+	}
+
+	return rows
+}
@@ -3,6 +3,7 @@ package cmd
 import (
 	"net/http"
 	"os"
+	"regexp"
 	"strings"
 	"sync"
 	"time"
@@ -16,6 +17,8 @@ var (
 	debugMode = getEnvOrDefault("DEBUG", "false") == "true"
 	// Session timeout in hours
 	sessionTimeoutHours = 24
+	// Matches the value of token query parameters
+	tokenQueryRegex = regexp.MustCompile(`([?&]token=)[^&]*`)
 )
 
 // Rate limiter
@@ -46,6 +49,14 @@ var globalRateLimiter = &rateLimiter{
 			maxRequests: 10, // 10 downloads per window
 			window:      1 * time.Hour,
 		},
+		"/api/v1/patch": {
+			maxRequests: 10, // 10 uploads per window
+			window:      1 * time.Hour,
+		},
+		"/api/v1/sinf/verify": {
+			maxRequests: 10, // 10 uploads per window
+			window:      1 * time.Hour,
+		},
 		"default": {
 			maxRequests: 100, // 100 requests per window
 			window:      1 * time.Minute,
@@ -182,6 +193,11 @@ func maskSensitiveData(s string) string {
 		s = maskJSONField(s, "auth_code")
 	}
 
+	// Mask feed tokens in query strings
+	if strings.Contains(s, "token=") {
+		s = tokenQueryRegex.ReplaceAllString(s, "${1}***")
+	}
+
 	// Mask API keys
 	if strings.Contains(strings.ToLower(s), "api") && strings.Contains(strings.ToLower(s), "key") {
 		s = maskJSONField(s, "api_key")
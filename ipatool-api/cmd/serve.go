@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+func serveCmd() *cobra.Command {
+	var (
+		port   int
+		apiKey string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "serve",
+		Short: "Start the HTTP API server",
+		// The server sets up its own dependencies with JSON logging.
+		PersistentPreRun: func(cmd *cobra.Command, args []string) {},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return RunServer(port, apiKey)
+		},
+	}
+
+	cmd.Flags().IntVarP(&port, "port", "p", 8080, "HTTP server port")
+	cmd.Flags().StringVar(&apiKey, "api-key", "", "API key for authentication (optional)")
+
+	return cmd
+}
+
+func fakeAppStoreCmd() *cobra.Command {
+	var port int
+
+	cmd := &cobra.Command{
+		Use:              "fake-appstore",
+		Short:            "Start a local fake App Store for offline development",
+		PersistentPreRun: func(cmd *cobra.Command, args []string) {},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return RunFakeAppStore(port)
+		},
+	}
+
+	cmd.Flags().IntVarP(&port, "port", "p", 9000, "HTTP port of the fake App Store")
+
+	return cmd
+}
@@ -5,7 +5,6 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
 	"net"
 	"net/http"
 	"os"
@@ -28,7 +27,9 @@ var version = "dev"
 // The server uses JSON logging format and non-interactive keychain access.
 func RunServer(port int, apiKey string) error {
 	// Initialize server dependencies with verbose logging enabled
-	initServer(true)
+	if err := initServer(true); err != nil {
+		return err
+	}
 
 	return runServer(port, apiKey)
 }
@@ -36,37 +37,7 @@ func RunServer(port int, apiKey string) error {
 // runServer configures and starts the HTTP server with all API endpoints.
 // If the specified port is in use, it automatically uses a random available port.
 func runServer(port int, apiKey string) error {
-	router := mux.NewRouter()
-	router.StrictSlash(true) // allow /api/v1/install and /api/v1/install/
-	api := router.PathPrefix("/api/v1").Subrouter()
-
-	if apiKey != "" {
-		api.Use(apiKeyMiddleware(apiKey))
-	}
-	api.Use(corsMiddleware)
-	api.Use(rateLimitMiddleware)
-	api.Use(loggingMiddleware(dependencies.Logger))
-	api.Use(bodySizeLimitMiddleware)
-
-	protectedAPI := api.PathPrefix("").Subrouter()
-	protectedAPI.Use(accountInfoMiddleware)
-
-	auth := api.PathPrefix("/auth").Subrouter()
-	auth.HandleFunc("/login", handleAuthLogin).Methods("POST")
-	auth.HandleFunc("/info", handleAuthInfo).Methods("GET")
-	auth.HandleFunc("/revoke", handleAuthRevoke).Methods("POST")
-
-	protectedAPI.HandleFunc("/search", handleSearch).Methods("GET")
-	protectedAPI.HandleFunc("/purchase", handlePurchase).Methods("POST")
-	protectedAPI.HandleFunc("/versions", handleListVersions).Methods("GET")
-	protectedAPI.HandleFunc("/metadata", handleVersionMetadata).Methods("GET")
-	protectedAPI.HandleFunc("/download", handleDownload).Methods("POST")
-	protectedAPI.HandleFunc("/install", handleInstall).Methods("POST")
-
-	// Health check and root endpoints (no authentication required)
-	router.HandleFunc("/health", handleHealth).Methods("GET")
-	router.HandleFunc("/", handleRoot).Methods("GET")
-	router.NotFoundHandler = http.HandlerFunc(handleNotFound)
+	router := newRouter(apiKey)
 
 	// Configure HTTP server with appropriate timeouts for large file downloads
 	addr := fmt.Sprintf(":%d", port)
@@ -101,6 +72,10 @@ func runServer(port int, apiKey string) error {
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
 
+	watchlistCtx, stopWatchlist := context.WithCancel(context.Background())
+	defer stopWatchlist()
+	go dependencies.Watchlist.Run(watchlistCtx)
+
 	go func() {
 		dependencies.Logger.Log().Msgf("Starting ipatool HTTP server on port %d", actualPort)
 		if apiKey != "" {
@@ -126,6 +101,70 @@ func runServer(port int, apiKey string) error {
 	return nil
 }
 
+// newRouter returns the router serving all API endpoints.
+func newRouter(apiKey string) *mux.Router {
+	router := mux.NewRouter()
+	router.StrictSlash(true) // allow /api/v1/install and /api/v1/install/
+	api := router.PathPrefix("/api/v1").Subrouter()
+
+	if apiKey != "" {
+		api.Use(apiKeyMiddleware(apiKey))
+	}
+	api.Use(corsMiddleware)
+	api.Use(rateLimitMiddleware)
+	api.Use(loggingMiddleware(dependencies.Logger))
+	api.Use(bodySizeLimitMiddleware)
+
+	protectedAPI := api.PathPrefix("").Subrouter()
+	protectedAPI.Use(accountInfoMiddleware)
+
+	auth := api.PathPrefix("/auth").Subrouter()
+	auth.HandleFunc("/login", handleAuthLogin).Methods("POST")
+	auth.HandleFunc("/info", handleAuthInfo).Methods("GET")
+	auth.HandleFunc("/revoke", handleAuthRevoke).Methods("POST")
+
+	api.HandleFunc("/storefronts", handleStorefronts).Methods("GET")
+	api.HandleFunc("/cache/stats", handleMetadataCacheStats).Methods("GET")
+	api.HandleFunc("/cache", handleMetadataCachePurge).Methods("DELETE")
+	// The account is only needed to compare the SINFs with those of the account.
+	api.HandleFunc("/sinf/verify", handleVerifySinf).Methods("POST")
+
+	protectedAPI.HandleFunc("/search", handleSearch).Methods("GET")
+	protectedAPI.HandleFunc("/lookup", handleLookup).Methods("GET")
+	protectedAPI.HandleFunc("/availability", handleAvailability).Methods("GET")
+	protectedAPI.HandleFunc("/purchase", handlePurchase).Methods("POST")
+	protectedAPI.HandleFunc("/licenses/check", handleLicenseCheck).Methods("POST")
+	protectedAPI.HandleFunc("/versions", handleListVersions).Methods("GET")
+	protectedAPI.HandleFunc("/metadata", handleVersionMetadata).Methods("GET")
+	protectedAPI.HandleFunc("/download", handleDownload).Methods("POST")
+	protectedAPI.HandleFunc("/install", handleInstall).Methods("POST")
+	protectedAPI.HandleFunc("/sinf", handleSinf).Methods("GET")
+	protectedAPI.HandleFunc("/patch", handlePatch).Methods("POST")
+
+	// The watcher downloads with the account logged in on the server, so every watchlist route requires it,
+	// including those that only read or remove entries.
+	protectedAPI.HandleFunc("/watchlist", handleWatchlist).Methods("GET")
+	protectedAPI.HandleFunc("/watchlist", handleWatchlistAdd).Methods("POST")
+	protectedAPI.HandleFunc("/watchlist/events", handleWatchlistEvents).Methods("GET")
+	protectedAPI.HandleFunc("/watchlist/check", handleWatchlistCheck).Methods("POST")
+	protectedAPI.HandleFunc("/watchlist/{app_id:[0-9]+}", handleWatchlistGet).Methods("GET")
+	protectedAPI.HandleFunc("/watchlist/{app_id:[0-9]+}", handleWatchlistUpdate).Methods("PATCH")
+	protectedAPI.HandleFunc("/watchlist/{app_id:[0-9]+}", handleWatchlistRemove).Methods("DELETE")
+
+	// Feeds are authenticated with their own token, because feed readers cannot send X-API-Key.
+	feeds := router.PathPrefix("/feeds").Subrouter()
+	feeds.Use(rateLimitMiddleware)
+	feeds.Use(loggingMiddleware(dependencies.Logger))
+	feeds.HandleFunc("/{name:[a-zA-Z0-9_-]+}.atom", handleFeed).Methods("GET")
+
+	// Health check and root endpoints (no authentication required)
+	router.HandleFunc("/health", handleHealth).Methods("GET")
+	router.HandleFunc("/", handleRoot).Methods("GET")
+	router.NotFoundHandler = http.HandlerFunc(handleNotFound)
+
+	return router
+}
+
 // tryListen attempts to listen on the specified address.
 // If the port is in use, it automatically uses a random available port.
 // Returns the listener, the actual port used, and any error.
@@ -171,6 +210,7 @@ type AuthLoginRequest struct {
 	Email    string `json:"email"`
 	Password string `json:"password"`
 	AuthCode string `json:"auth_code,omitempty"`
+	Proxy    string `json:"proxy,omitempty"`
 }
 
 // AuthLoginResponse represents a login response.
@@ -179,6 +219,8 @@ type AuthLoginResponse struct {
 	Email       string `json:"email,omitempty"`
 	Name        string `json:"name,omitempty"`
 	CountryCode string `json:"country_code,omitempty"`
+	Language    string `json:"language,omitempty"`
+	Storefront  string `json:"storefront,omitempty"`
 }
 
 // AuthInfoResponse represents account information response.
@@ -186,6 +228,10 @@ type AuthInfoResponse struct {
 	Email       string `json:"email,omitempty"`
 	Name        string `json:"name,omitempty"`
 	CountryCode string `json:"country_code,omitempty"`
+	Language    string `json:"language,omitempty"`
+	Storefront  string `json:"storefront,omitempty"`
+	DeviceGUID  string `json:"device_guid,omitempty"`
+	Proxy       string `json:"proxy,omitempty"`
 }
 
 // SearchResponse represents a search results response.
@@ -201,6 +247,23 @@ type AppInfo struct {
 	Version    string  `json:"version,omitempty"`
 	Price      float64 `json:"price,omitempty"`
 	ArtworkURL string  `json:"artwork_url,omitempty"`
+
+	FileSizeBytes             int64    `json:"file_size_bytes,omitempty"`
+	MinimumOSVersion          string   `json:"minimum_os_version,omitempty"`
+	SupportedDevices          []string `json:"supported_devices,omitempty"`
+	SellerName                string   `json:"seller_name,omitempty"`
+	ArtistID                  int64    `json:"artist_id,omitempty"`
+	ArtistName                string   `json:"artist_name,omitempty"`
+	Genre                     string   `json:"genre,omitempty"`
+	AverageUserRating         float64  `json:"average_user_rating,omitempty"`
+	UserRatingCount           int64    `json:"user_rating_count,omitempty"`
+	ContentAdvisoryRating     string   `json:"content_advisory_rating,omitempty"`
+	Currency                  string   `json:"currency,omitempty"`
+	FormattedPrice            string   `json:"formatted_price,omitempty"`
+	ReleaseNotes              string   `json:"release_notes,omitempty"`
+	CurrentVersionReleaseDate string   `json:"current_version_release_date,omitempty"`
+	ScreenshotURLs            []string `json:"screenshot_urls,omitempty"`
+	IPadScreenshotURLs        []string `json:"ipad_screenshot_urls,omitempty"`
 }
 
 func appToAppInfo(app appstore.App) AppInfo {
@@ -220,11 +283,29 @@ func appToAppInfo(app appstore.App) AppInfo {
 		Version:    app.Version,
 		Price:      app.Price,
 		ArtworkURL: artworkURL,
+
+		FileSizeBytes:             app.FileSize(),
+		MinimumOSVersion:          app.MinimumOSVersion,
+		SupportedDevices:          app.SupportedDevices,
+		SellerName:                app.SellerName,
+		ArtistID:                  app.ArtistID,
+		ArtistName:                app.ArtistName,
+		Genre:                     app.PrimaryGenreName,
+		AverageUserRating:         app.AverageUserRating,
+		UserRatingCount:           app.UserRatingCount,
+		ContentAdvisoryRating:     app.ContentAdvisoryRating,
+		Currency:                  app.Currency,
+		FormattedPrice:            app.FormattedPrice,
+		ReleaseNotes:              app.ReleaseNotes,
+		CurrentVersionReleaseDate: app.CurrentVersionReleaseDate,
+		ScreenshotURLs:            app.ScreenshotURLs,
+		IPadScreenshotURLs:        app.IPadScreenshotURLs,
 	}
 }
 
 type PurchaseRequest struct {
-	BundleID string `json:"bundle_id"`
+	AppID    int64  `json:"app_id,omitempty"`
+	BundleID string `json:"bundle_id,omitempty"`
 }
 
 type PurchaseResponse struct {
@@ -236,6 +317,20 @@ type ListVersionsResponse struct {
 	BundleID           string   `json:"bundle_id,omitempty"`
 	ExternalVersionIDs []string `json:"external_version_identifiers"`
 	Success            bool     `json:"success"`
+	// Total, Limit, Offset and Versions are only set with include=metadata.
+	Total    int           `json:"total,omitempty"`
+	Limit    int64         `json:"limit,omitempty"`
+	Offset   int64         `json:"offset,omitempty"`
+	Versions []VersionInfo `json:"versions,omitempty"`
+}
+
+// VersionInfo is a version with its resolved metadata. Error is set if the metadata could not be resolved.
+type VersionInfo struct {
+	ExternalVersionID string `json:"external_version_id"`
+	DisplayVersion    string `json:"display_version,omitempty"`
+	ReleaseDate       string `json:"release_date,omitempty"`
+	Latest            bool   `json:"latest,omitempty"`
+	Error             string `json:"error,omitempty"`
 }
 
 type VersionMetadataResponse struct {
@@ -249,14 +344,28 @@ type DownloadRequest struct {
 	AppID             int64  `json:"app_id,omitempty"`
 	BundleID          string `json:"bundle_id,omitempty"`
 	ExternalVersionID string `json:"external_version_id,omitempty"`
-	AutoPurchase      bool   `json:"auto_purchase,omitempty"`
+	// Version, AsOf, Previous and CompatibleWith select a version instead of ExternalVersionID.
+	Version        string `json:"version,omitempty"`
+	AsOf           string `json:"as_of,omitempty"`
+	Previous       int    `json:"previous,omitempty"`
+	CompatibleWith string `json:"compatible_with,omitempty"`
+	AutoPurchase   bool   `json:"auto_purchase,omitempty"`
+	// Destination is "client" (default) to stream the package, or "server" to save it in the download directory.
+	Destination string `json:"destination,omitempty"`
+	// OnConflict is "skip" (default), "overwrite" or "suffix" when the file already exists on the server.
+	OnConflict string `json:"on_conflict,omitempty"`
 }
 
 func handleHealth(w http.ResponseWriter, r *http.Request) {
-	respondSuccess(w, map[string]string{
+	response := map[string]interface{}{
 		"status":  "ok",
 		"service": "ipatool-api",
-	})
+	}
+	if dependencies.RetryMetrics != nil {
+		response["http_retries"] = dependencies.RetryMetrics.Stats()
+	}
+
+	respondSuccess(w, response)
 }
 
 func handleRoot(w http.ResponseWriter, r *http.Request) {
@@ -299,11 +408,16 @@ func handleAuthLogin(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 	}
+	if err := validateProxy(req.Proxy); err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
 
 	result, err := dependencies.AppStore.Login(appstore.LoginInput{
 		Email:    req.Email,
 		Password: req.Password,
 		AuthCode: req.AuthCode,
+		Proxy:    req.Proxy,
 	})
 	if err != nil {
 		statusCode, message := mapAppStoreErrorToHTTPStatus(err)
@@ -311,11 +425,14 @@ func handleAuthLogin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	countryCode, language := storefrontLocale(result.Account.StoreFront)
 	response := AuthLoginResponse{
 		Success:     true,
 		Email:       result.Account.Email,
 		Name:        result.Account.Name,
-		CountryCode: result.Account.StoreFront,
+		CountryCode: countryCode,
+		Language:    language,
+		Storefront:  result.Account.StoreFront,
 	}
 
 	respondSuccess(w, response)
@@ -329,10 +446,15 @@ func handleAuthInfo(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	countryCode, language := storefrontLocale(info.Account.StoreFront)
 	response := AuthInfoResponse{
 		Email:       info.Account.Email,
 		Name:        info.Account.Name,
-		CountryCode: info.Account.StoreFront,
+		CountryCode: countryCode,
+		Language:    language,
+		Storefront:  info.Account.StoreFront,
+		DeviceGUID:  dependencies.DeviceGUID,
+		Proxy:       redactProxy(info.Account.Proxy),
 	}
 
 	respondSuccess(w, response)
@@ -403,7 +525,7 @@ func handlePurchase(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := validateBundleID(req.BundleID); err != nil {
+	if err := validateAppIDOrBundleID(appIDString(req.AppID), req.BundleID); err != nil {
 		respondError(w, http.StatusBadRequest, err.Error())
 		return
 	}
@@ -414,7 +536,22 @@ func handlePurchase(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	app := appstore.App{BundleID: req.BundleID}
+	// The store buys an app by its ID, so a bundle ID is looked up first, like the purchase command does.
+	app := buildAppFromRequest(req.AppID, req.BundleID)
+	if app.ID == 0 {
+		lookupResult, err := dependencies.AppStore.Lookup(appstore.LookupInput{
+			Account:  accountInfo.Account,
+			BundleID: req.BundleID,
+		})
+		if err != nil {
+			dependencies.Logger.Error().Err(err).Str("bundleID", req.BundleID).Msg("Lookup failed")
+			statusCode, message := mapAppStoreErrorToHTTPStatus(err)
+			respondError(w, statusCode, message)
+			return
+		}
+		app = lookupResult.App
+	}
+
 	err := dependencies.AppStore.Purchase(appstore.PurchaseInput{
 		Account: accountInfo.Account,
 		App:     app,
@@ -446,6 +583,30 @@ func handleListVersions(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	includeMetadata, err := validateInclude(r.URL.Query().Get("include"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	bypassCache, err := validateBypassCache(r.URL.Query().Get("bypass_cache"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var limit, offset int64
+	if includeMetadata {
+		if limit, err = validateLimit(r.URL.Query().Get("limit")); err != nil {
+			respondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if offset, err = validateOffset(r.URL.Query().Get("offset")); err != nil {
+			respondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
 	accountInfo, ok := getAccountInfo(r)
 	if !ok {
 		respondError(w, http.StatusUnauthorized, "Authentication required")
@@ -469,6 +630,17 @@ func handleListVersions(w http.ResponseWriter, r *http.Request) {
 		app = appstore.App{ID: appID}
 	}
 
+	if includeMetadata {
+		handleVersionHistory(w, appstore.VersionHistoryInput{
+			Account:     accountInfo.Account,
+			App:         app,
+			Limit:       int(limit),
+			Offset:      int(offset),
+			BypassCache: bypassCache,
+		})
+		return
+	}
+
 	result, err := dependencies.AppStore.ListVersions(appstore.ListVersionsInput{
 		Account: accountInfo.Account,
 		App:     app,
@@ -486,6 +658,46 @@ func handleListVersions(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// handleVersionHistory responds with a page of versions, newest first, with their metadata resolved.
+func handleVersionHistory(w http.ResponseWriter, input appstore.VersionHistoryInput) {
+	result, err := dependencies.AppStore.VersionHistory(input)
+	if err != nil {
+		statusCode, message := mapAppStoreErrorToHTTPStatus(err)
+		respondError(w, statusCode, message)
+		return
+	}
+
+	response := ListVersionsResponse{
+		BundleID:           input.App.BundleID,
+		ExternalVersionIDs: make([]string, len(result.Versions)),
+		Success:            true,
+		Total:              result.Total,
+		Limit:              int64(input.Limit),
+		Offset:             int64(input.Offset),
+		Versions:           make([]VersionInfo, len(result.Versions)),
+	}
+
+	for i, version := range result.Versions {
+		info := VersionInfo{
+			ExternalVersionID: version.ExternalVersionID,
+			Latest:            version.ExternalVersionID == result.LatestExternalVersionID,
+		}
+
+		if version.Err != nil {
+			dependencies.Logger.Verbose().Err(version.Err).Str("version_id", version.ExternalVersionID).Msg("Version metadata lookup failed")
+			_, info.Error = mapAppStoreErrorToHTTPStatus(version.Err)
+		} else {
+			info.DisplayVersion = version.DisplayVersion
+			info.ReleaseDate = version.ReleaseDate.Format(time.RFC3339)
+		}
+
+		response.ExternalVersionIDs[i] = version.ExternalVersionID
+		response.Versions[i] = info
+	}
+
+	respondSuccess(w, response)
+}
+
 func handleVersionMetadata(w http.ResponseWriter, r *http.Request) {
 	versionID := r.URL.Query().Get("version_id")
 	bundleID := r.URL.Query().Get("bundle_id")
@@ -501,6 +713,12 @@ func handleVersionMetadata(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	bypassCache, err := validateBypassCache(r.URL.Query().Get("bypass_cache"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
 	accountInfo, ok := getAccountInfo(r)
 	if !ok {
 		respondError(w, http.StatusUnauthorized, "Authentication required")
@@ -516,9 +734,10 @@ func handleVersionMetadata(w http.ResponseWriter, r *http.Request) {
 	}
 
 	result, err := dependencies.AppStore.GetVersionMetadata(appstore.GetVersionMetadataInput{
-		Account:   accountInfo.Account,
-		App:       app,
-		VersionID: versionID,
+		Account:     accountInfo.Account,
+		App:         app,
+		VersionID:   versionID,
+		BypassCache: bypassCache,
 	})
 	if err != nil {
 		statusCode, message := mapAppStoreErrorToHTTPStatus(err)
@@ -534,11 +753,9 @@ func handleVersionMetadata(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// handleDownload transfers the package for as long as the client stays connected, within the write timeout of
+// the server.
 func handleDownload(w http.ResponseWriter, r *http.Request) {
-	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Minute)
-	defer cancel()
-	r = r.WithContext(ctx)
-
 	var req DownloadRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		respondError(w, http.StatusBadRequest, "Invalid request body")
@@ -555,6 +772,22 @@ func handleDownload(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := validateDestination(req.Destination, req.OnConflict); err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// compatible_with is also accepted as a query parameter, e.g. /download?compatible_with=15.7.
+	if req.CompatibleWith == "" {
+		req.CompatibleWith = r.URL.Query().Get("compatible_with")
+	}
+
+	versionSelector, err := parseVersionSelector(req.ExternalVersionID, req.Version, req.AsOf, req.Previous, req.CompatibleWith)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
 	accountInfo, ok := getAccountInfo(r)
 	if !ok {
 		respondError(w, http.StatusUnauthorized, "Authentication required")
@@ -595,6 +828,62 @@ func handleDownload(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	if req.Destination == DestinationServer {
+		saveDownload(r.Context(), w, accountInfo.Account, app, req.ExternalVersionID, versionSelector, req.OnConflict)
+		return
+	}
+
+	// The package is patched while it is downloaded, so that streaming to the client starts right away.
+	result, err := dependencies.AppStore.DownloadStream(appstore.DownloadStreamInput{
+		Account:           accountInfo.Account,
+		App:               app,
+		ExternalVersionID: req.ExternalVersionID,
+		Version:           versionSelector,
+	})
+	if errors.Is(err, appstore.ErrRangesNotSupported) {
+		dependencies.Logger.Log().Msg("CDN does not support range requests, downloading to a temporary file")
+		serveDownloadFromFile(w, r, accountInfo.Account, app, req.ExternalVersionID, versionSelector)
+		return
+	}
+	if err != nil {
+		dependencies.Logger.Error().Err(err).Msg("Download failed")
+		statusCode, message := mapAppStoreErrorToHTTPStatus(err)
+		respondError(w, statusCode, message)
+		return
+	}
+
+	// The package is hashed while it is written, so its checksum is only sent if it was delivered before.
+	setPackageHeaders(w, result.Metadata, result.ExternalVersionID)
+	contentID := result.Package.ContentID()
+	sum, known := knownDigest(contentID)
+	if known {
+		setDigestHeaders(w, sum)
+		if etagMatches(r, sum) {
+			respondETagMatched(w, r)
+			return
+		}
+	}
+
+	filename := generateFilename(app, result.ExternalVersionID)
+	setDownloadHeaders(w, filename, result.Package.Size())
+
+	// Headers are sent; a failure resets the connection, so that the client does not keep a truncated package.
+	written, err := result.Package.WriteToContext(r.Context(), w)
+	if err != nil {
+		dependencies.Logger.Error().Err(err).Int64("written", written).Msg("Error streaming file")
+		abortResponse()
+	}
+
+	recordDigest(contentID, result.Package.SHA256())
+
+	dependencies.Logger.Log().
+		Str("filename", filename).
+		Int64("size", written).
+		Msg("File downloaded and streamed successfully")
+}
+
+// serveDownloadFromFile downloads the package to a temporary file and then streams it to the client.
+func serveDownloadFromFile(w http.ResponseWriter, r *http.Request, acc appstore.Account, app appstore.App, externalVersionID string, versionSelector appstore.VersionSelector) {
 	tmpFile, err := os.CreateTemp("", "ipatool-*.ipa")
 	if err != nil {
 		dependencies.Logger.Error().Err(err).Msg("Failed to create temporary file")
@@ -611,9 +900,10 @@ func handleDownload(w http.ResponseWriter, r *http.Request) {
 	}()
 
 	result, err := dependencies.AppStore.Download(appstore.DownloadInput{
-		Account:           accountInfo.Account,
+		Account:           acc,
 		App:               app,
-		ExternalVersionID: req.ExternalVersionID,
+		ExternalVersionID: externalVersionID,
+		Version:           versionSelector,
 		OutputPath:        tmpPath,
 	})
 	if err != nil {
@@ -623,6 +913,8 @@ func handleDownload(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	setPackageHeaders(w, result.Metadata, result.ExternalVersionID)
+
 	file, err := os.Open(result.DestinationPath)
 	if err != nil {
 		dependencies.Logger.Error().Err(err).Str("path", result.DestinationPath).Msg("Failed to open downloaded file")
@@ -631,7 +923,7 @@ func handleDownload(w http.ResponseWriter, r *http.Request) {
 	}
 	defer file.Close()
 
-	filename := generateFilename(app, req.ExternalVersionID)
+	filename := generateFilename(app, result.ExternalVersionID)
 
 	fileInfo, err := file.Stat()
 	if err != nil {
@@ -640,18 +932,7 @@ func handleDownload(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	setDownloadHeaders(w, filename, fileInfo.Size())
-
-	buffer := make([]byte, 4*1024*1024)
-	if _, err := io.CopyBuffer(w, file, buffer); err != nil {
-		dependencies.Logger.Error().Err(err).Msg("Error streaming file")
-		if err == io.ErrClosedPipe || err == io.EOF {
-			return
-		}
-		if strings.Contains(err.Error(), "timeout") || strings.Contains(err.Error(), "broken pipe") {
-			dependencies.Logger.Log().Err(err).Msg("Client disconnected or timeout during file streaming")
-			return
-		}
+	if !servePackageFile(w, r, file, filename, fileInfo.Size()) {
 		return
 	}
 
@@ -756,7 +1037,7 @@ func corsMiddleware(next http.Handler) http.Handler {
 			// If origin not allowed, don't set CORS headers (browser will block)
 		}
 
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PATCH, DELETE, OPTIONS")
 		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, X-API-Key")
 		w.Header().Set("Access-Control-Max-Age", "3600")
 
@@ -799,6 +1080,9 @@ func bodySizeLimitMiddleware(next http.Handler) http.Handler {
 		if strings.HasPrefix(path, "/api/v1/download") {
 			// Download endpoint might need larger body for metadata
 			maxSize = 10 * 1024 * 1024 // 10MB
+		} else if strings.HasPrefix(path, "/api/v1/patch") || strings.HasPrefix(path, "/api/v1/sinf/verify") {
+			// Patch and verify endpoints receive a whole IPA
+			maxSize = MaxPatchUploadSize
 		} else if strings.HasPrefix(path, "/api/v1/auth/login") {
 			// Login endpoint should be small
 			maxSize = 2 * 1024 // 2KB
@@ -890,6 +1174,8 @@ func isCriticalEndpoint(path string) bool {
 		"/api/v1/search",
 		"/api/v1/purchase",
 		"/api/v1/download",
+		"/api/v1/sinf",
+		"/api/v1/patch",
 		"/api/v1/versions",
 		"/api/v1/metadata",
 	}
@@ -912,3 +1198,8 @@ func (rw *responseWriter) WriteHeader(code int) {
 	rw.statusCode = code
 	rw.ResponseWriter.WriteHeader(code)
 }
+
+// Unwrap lets http.ResponseController reach the underlying connection.
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
@@ -0,0 +1,809 @@
+package cmd
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/majd/ipatool/v2/pkg/fakestore"
+	"github.com/majd/ipatool/v2/pkg/feed"
+	"github.com/majd/ipatool/v2/pkg/kvstore"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"howett.net/plist"
+)
+
+const testAPIKey = "test-api-key"
+
+// clientCounter gives every spec its own client IP, so that specs don't share rate limits or sessions.
+var clientCounter = 0
+
+var _ = Describe("Server", func() {
+	var (
+		fake     *fakestore.Server
+		store    *httptest.Server
+		api      *httptest.Server
+		clientIP string
+	)
+
+	BeforeEach(func() {
+		fake = fakestore.New(fakestore.DefaultConfig())
+		store = httptest.NewServer(fake)
+
+		setTestDependencies(store.URL)
+
+		api = httptest.NewServer(newRouter(testAPIKey))
+
+		clientCounter++
+		clientIP = fmt.Sprintf("192.0.2.%d", clientCounter)
+	})
+
+	AfterEach(func() {
+		api.Close()
+		store.Close()
+	})
+
+	do := func(method, path string, body interface{}) *http.Response {
+		var reader io.Reader
+
+		if body != nil {
+			data, err := json.Marshal(body)
+			Expect(err).ToNot(HaveOccurred())
+
+			reader = bytes.NewReader(data)
+		}
+
+		req, err := http.NewRequest(method, api.URL+path, reader)
+		Expect(err).ToNot(HaveOccurred())
+
+		req.Header.Set("X-API-Key", testAPIKey)
+		req.Header.Set("X-Forwarded-For", clientIP)
+		req.Header.Set("Content-Type", "application/json")
+
+		res, err := http.DefaultClient.Do(req)
+		Expect(err).ToNot(HaveOccurred())
+
+		return res
+	}
+
+	decode := func(res *http.Response, statusCode int, target interface{}) {
+		defer res.Body.Close()
+
+		body, err := io.ReadAll(res.Body)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(res.StatusCode).To(Equal(statusCode), string(body))
+
+		if target != nil {
+			Expect(json.Unmarshal(body, target)).To(Succeed())
+		}
+	}
+
+	login := func() {
+		var out AuthLoginResponse
+		decode(do("POST", "/api/v1/auth/login", AuthLoginRequest{
+			Email:    fakestore.DefaultEmail,
+			Password: fakestore.DefaultPassword,
+		}), http.StatusOK, &out)
+		Expect(out.Success).To(BeTrue())
+	}
+
+	It("rejects requests without the API key", func() {
+		res, err := http.Get(api.URL + "/api/v1/auth/info")
+		Expect(err).ToNot(HaveOccurred())
+		decode(res, http.StatusUnauthorized, nil)
+	})
+
+	It("logs in and returns account info", func() {
+		var out AuthLoginResponse
+		decode(do("POST", "/api/v1/auth/login", AuthLoginRequest{
+			Email:    fakestore.DefaultEmail,
+			Password: fakestore.DefaultPassword,
+		}), http.StatusOK, &out)
+		Expect(out.Email).To(Equal(fakestore.DefaultEmail))
+		Expect(out.Name).To(Equal("Test User"))
+		Expect(out.CountryCode).To(Equal("US"))
+		Expect(out.Language).To(Equal("en-US"))
+		Expect(out.Storefront).To(Equal("143441-1,29"))
+
+		var info AuthInfoResponse
+		decode(do("GET", "/api/v1/auth/info", nil), http.StatusOK, &info)
+		Expect(info.Email).To(Equal(fakestore.DefaultEmail))
+		Expect(info.DeviceGUID).To(Equal("0123456789AB"))
+
+		decode(do("POST", "/api/v1/auth/revoke", nil), http.StatusOK, nil)
+	})
+
+	It("lists storefronts without a logged-in account", func() {
+		var out StorefrontsResponse
+		decode(do("GET", "/api/v1/storefronts", nil), http.StatusOK, &out)
+		Expect(out.Count).To(Equal(len(out.Storefronts)))
+		Expect(out.Storefronts).To(ContainElement(StorefrontInfo{
+			CountryCode:  "US",
+			Name:         "United States",
+			Currency:     "USD",
+			StorefrontID: "143441",
+		}))
+	})
+
+	It("asks for the auth code of two-factor accounts", func() {
+		var errOut ErrorResponse
+		decode(do("POST", "/api/v1/auth/login", AuthLoginRequest{
+			Email:    fakestore.Default2FAEmail,
+			Password: fakestore.DefaultPassword,
+		}), http.StatusUnauthorized, &errOut)
+		Expect(errOut.Message).To(ContainSubstring("Two-factor"))
+
+		var out AuthLoginResponse
+		decode(do("POST", "/api/v1/auth/login", AuthLoginRequest{
+			Email:    fakestore.Default2FAEmail,
+			Password: fakestore.DefaultPassword,
+			AuthCode: fakestore.DefaultAuthCode,
+		}), http.StatusOK, &out)
+		Expect(out.Email).To(Equal(fakestore.Default2FAEmail))
+	})
+
+	It("searches, purchases and lists versions", func() {
+		login()
+
+		var search SearchResponse
+		decode(do("GET", "/api/v1/search?term=notes&limit=5", nil), http.StatusOK, &search)
+		Expect(search.Count).To(Equal(1))
+		Expect(search.Apps[0].BundleID).To(Equal("com.example.notes"))
+
+		decode(do("GET", "/api/v1/versions?bundle_id=com.example.notes", nil), http.StatusForbidden, nil)
+
+		var purchase PurchaseResponse
+		decode(do("POST", "/api/v1/purchase", PurchaseRequest{BundleID: "com.example.notes"}), http.StatusOK, &purchase)
+		Expect(purchase.Success).To(BeTrue())
+		Expect(fake.HasLicense(fakestore.DefaultEmail, 1000000101)).To(BeTrue())
+
+		decode(do("POST", "/api/v1/purchase", PurchaseRequest{}), http.StatusBadRequest, nil)
+
+		var versions ListVersionsResponse
+		decode(do("GET", "/api/v1/versions?bundle_id=com.example.notes", nil), http.StatusOK, &versions)
+		Expect(versions.ExternalVersionIDs).To(Equal([]string{"800000001", "800000002", "800000003"}))
+
+		var metadata VersionMetadataResponse
+		decode(do("GET", "/api/v1/metadata?app_id=1000000101&version_id=800000001", nil), http.StatusOK, &metadata)
+		Expect(metadata.DisplayVersion).To(Equal("1.0.0"))
+	})
+
+	It("lists versions with their metadata, newest first", func() {
+		login()
+		fake.GrantLicense(fakestore.DefaultEmail, 1000000101)
+
+		var out ListVersionsResponse
+		decode(do("GET", "/api/v1/versions?bundle_id=com.example.notes&include=metadata&limit=2", nil), http.StatusOK, &out)
+		Expect(out.Total).To(Equal(3))
+		Expect(out.ExternalVersionIDs).To(Equal([]string{"800000003", "800000002"}))
+		Expect(out.Versions[0].DisplayVersion).To(Equal("2.0.0"))
+		Expect(out.Versions[0].ReleaseDate).To(HavePrefix("2024-06-15"))
+		Expect(out.Versions[0].Latest).To(BeTrue())
+		Expect(out.Versions[1].DisplayVersion).To(Equal("1.1.0"))
+
+		var page ListVersionsResponse
+		decode(do("GET", "/api/v1/versions?bundle_id=com.example.notes&include=metadata&limit=2&offset=2", nil), http.StatusOK, &page)
+		Expect(page.Versions).To(HaveLen(1))
+		Expect(page.Versions[0].DisplayVersion).To(Equal("1.0.0"))
+		Expect(page.Versions[0].Latest).To(BeFalse())
+
+		decode(do("GET", "/api/v1/versions?bundle_id=com.example.notes&include=metadata&offset=-1", nil), http.StatusBadRequest, nil)
+		decode(do("GET", "/api/v1/versions?bundle_id=com.example.notes&include=ratings", nil), http.StatusBadRequest, nil)
+	})
+
+	It("caches version metadata until purged", func() {
+		login()
+		fake.GrantLicense(fakestore.DefaultEmail, 1000000101)
+
+		decode(do("GET", "/api/v1/versions?app_id=1000000101&include=metadata", nil), http.StatusOK, nil)
+		// One request lists the versions and resolves the latest one, two resolve the others.
+		Expect(fake.Requests(fakestore.PathDownload)).To(Equal(3))
+
+		var out ListVersionsResponse
+		decode(do("GET", "/api/v1/versions?app_id=1000000101&include=metadata", nil), http.StatusOK, &out)
+		Expect(fake.Requests(fakestore.PathDownload)).To(Equal(4))
+		Expect(out.Versions[2].DisplayVersion).To(Equal("1.0.0"))
+
+		decode(do("GET", "/api/v1/metadata?app_id=1000000101&version_id=800000001&bypass_cache=true", nil), http.StatusOK, nil)
+		Expect(fake.Requests(fakestore.PathDownload)).To(Equal(5))
+
+		var stats MetadataCacheStatsResponse
+		decode(do("GET", "/api/v1/cache/stats", nil), http.StatusOK, &stats)
+		Expect(stats.Enabled).To(BeTrue())
+		Expect(stats.Entries).To(Equal(3))
+		Expect(stats.Hits).To(Equal(uint64(4)))
+
+		var purge MetadataCachePurgeResponse
+		decode(do("DELETE", "/api/v1/cache", nil), http.StatusOK, &purge)
+		Expect(purge.PurgedEntries).To(Equal(3))
+
+		decode(do("GET", "/api/v1/metadata?app_id=1000000101&version_id=800000001", nil), http.StatusOK, nil)
+		Expect(fake.Requests(fakestore.PathDownload)).To(Equal(6))
+	})
+
+	It("looks apps up by bundle ID, app ID or several IDs", func() {
+		login()
+
+		var out LookupResponse
+		decode(do("GET", "/api/v1/lookup?bundle_id=com.example.notes", nil), http.StatusOK, &out)
+		Expect(out.Count).To(Equal(1))
+		Expect(out.Apps[0].TrackID).To(Equal(int64(1000000101)))
+		Expect(out.Apps[0].FileSizeBytes).To(BeNumerically(">", 0))
+		Expect(out.Apps[0].SellerName).To(Equal("Example Inc."))
+		Expect(out.Apps[0].Genre).To(Equal("Productivity"))
+		Expect(out.Apps[0].MinimumOSVersion).To(Equal("16.0"))
+		Expect(out.Apps[0].FormattedPrice).To(Equal("Free"))
+		Expect(out.Apps[0].CurrentVersionReleaseDate).To(Equal("2024-06-15T00:00:00Z"))
+
+		decode(do("GET", "/api/v1/lookup?ids=1000000101,1000000103", nil), http.StatusOK, &out)
+		Expect(out.Count).To(Equal(2))
+		Expect(out.Apps[1].BundleID).To(Equal("com.example.arcade"))
+		Expect(fake.Requests(fakestore.PathLookup)).To(Equal(2))
+
+		decode(do("GET", "/api/v1/lookup?app_id=1000000102&country=DE", nil), http.StatusNotFound, nil)
+		decode(do("GET", "/api/v1/lookup?app_id=1000000102&country=GB", nil), http.StatusOK, &out)
+		decode(do("GET", "/api/v1/lookup?ids=1,x", nil), http.StatusBadRequest, nil)
+	})
+
+	It("reports the storefronts an app is sold in", func() {
+		login()
+
+		var out AvailabilityResponse
+		decode(do("GET", "/api/v1/availability?bundle_id=com.example.radio&countries=US,GB,DE", nil), http.StatusOK, &out)
+		Expect(out.AppID).To(Equal(int64(1000000102)))
+		Expect(out.AvailableCount).To(Equal(2))
+		Expect(out.Countries[1].CountryCode).To(Equal("GB"))
+		Expect(out.Countries[1].Currency).To(Equal("GBP"))
+		Expect(*out.Countries[1].Price).To(BeZero())
+		Expect(out.Countries[2].Available).To(BeFalse())
+		Expect(out.Countries[2].Price).To(BeNil())
+	})
+
+	It("checks the licenses of several apps", func() {
+		login()
+		fake.GrantLicense(fakestore.DefaultEmail, 1000000101)
+
+		var out LicenseCheckResponse
+		decode(do("POST", "/api/v1/licenses/check", LicenseCheckRequest{
+			AppIDs:    []int64{1000000101},
+			BundleIDs: []string{"com.example.radio", "com.example.missing"},
+		}), http.StatusOK, &out)
+		Expect(out.OwnedCount).To(Equal(1))
+		Expect(out.Licenses).To(HaveLen(3))
+		Expect(out.Licenses[0].Status).To(Equal("owned"))
+		Expect(out.Licenses[1].AppID).To(Equal(int64(1000000102)))
+		Expect(out.Licenses[1].Status).To(Equal("not_owned"))
+		Expect(out.Licenses[2].Status).To(Equal("unknown"))
+		Expect(out.Licenses[2].Error).To(Equal("App not found."))
+
+		decode(do("POST", "/api/v1/purchase", PurchaseRequest{AppID: 1000000102}), http.StatusOK, nil)
+
+		decode(do("POST", "/api/v1/licenses/check", LicenseCheckRequest{AppIDs: []int64{1000000102}}), http.StatusOK, &out)
+		Expect(out.Licenses[0].Status).To(Equal("owned"))
+
+		decode(do("POST", "/api/v1/licenses/check", LicenseCheckRequest{}), http.StatusBadRequest, nil)
+	})
+
+	It("downloads a patched IPA", func() {
+		login()
+
+		res := do("POST", "/api/v1/download", DownloadRequest{
+			BundleID:          "com.example.notes",
+			ExternalVersionID: "800000002",
+			AutoPurchase:      true,
+		})
+		defer res.Body.Close()
+		Expect(res.StatusCode).To(Equal(http.StatusOK))
+
+		data, err := io.ReadAll(res.Body)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(res.ContentLength).To(Equal(int64(len(data))))
+
+		reader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+		Expect(err).ToNot(HaveOccurred())
+
+		names := []string{}
+		for _, file := range reader.File {
+			names = append(names, file.Name)
+		}
+
+		Expect(names).To(ContainElements("Payload/Notes.app/SC_Info/Notes.sinf", "iTunesMetadata.plist"))
+		Expect(fake.HasLicense(fakestore.DefaultEmail, 1000000101)).To(BeTrue())
+	})
+
+	It("identifies downloaded IPAs by their checksum", func() {
+		login()
+		fake.GrantLicense(fakestore.DefaultEmail, 1000000101)
+
+		download := func(etag string) (*http.Response, []byte) {
+			data, err := json.Marshal(DownloadRequest{AppID: 1000000101, ExternalVersionID: "800000002"})
+			Expect(err).ToNot(HaveOccurred())
+
+			req, err := http.NewRequest("POST", api.URL+"/api/v1/download", bytes.NewReader(data))
+			Expect(err).ToNot(HaveOccurred())
+			req.Header.Set("X-API-Key", testAPIKey)
+			req.Header.Set("X-Forwarded-For", clientIP)
+			req.Header.Set("Content-Type", "application/json")
+			if etag != "" {
+				req.Header.Set("If-None-Match", etag)
+			}
+
+			res, err := http.DefaultClient.Do(req)
+			Expect(err).ToNot(HaveOccurred())
+			defer res.Body.Close()
+
+			body, err := io.ReadAll(res.Body)
+			Expect(err).ToNot(HaveOccurred())
+
+			return res, body
+		}
+
+		// The checksum of a streamed IPA is known once it has been delivered.
+		res, first := download("")
+		Expect(res.StatusCode).To(Equal(http.StatusOK))
+		Expect(res.ContentLength).To(Equal(int64(len(first))))
+		Expect(res.Header.Get("ETag")).To(BeEmpty())
+		Expect(res.Header.Get("X-Bundle-Id")).To(Equal("com.example.notes"))
+		Expect(res.Header.Get("X-Bundle-Version")).To(Equal("1.1.0"))
+		Expect(res.Header.Get("X-External-Version-Id")).To(Equal("800000002"))
+
+		sum := sha256.Sum256(first)
+		etag := fmt.Sprintf("%q", hex.EncodeToString(sum[:]))
+
+		res, second := download("")
+		Expect(res.StatusCode).To(Equal(http.StatusOK))
+		Expect(second).To(Equal(first))
+		Expect(res.ContentLength).To(Equal(int64(len(second))))
+		Expect(res.Header.Get("ETag")).To(Equal(etag))
+		Expect(res.Header.Get("Repr-Digest")).To(Equal("sha-256=:" + base64.StdEncoding.EncodeToString(sum[:]) + ":"))
+
+		// Downloads are POST requests, so a matching If-None-Match fails the precondition.
+		res, _ = download(etag)
+		Expect(res.StatusCode).To(Equal(http.StatusPreconditionFailed))
+		Expect(res.Header.Get("ETag")).To(Equal(etag))
+
+		res, _ = download(`"other"`)
+		Expect(res.StatusCode).To(Equal(http.StatusOK))
+	})
+
+	It("resets the connection when a streamed IPA turns out to be corrupted", func() {
+		login()
+		fake.GrantLicense(fakestore.DefaultEmail, 1000000101)
+
+		// The first byte of the package is flipped, so that it fails the MD5 check after it has been sent.
+		store.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if strings.HasPrefix(r.URL.Path, fakestore.PathCDN) && strings.HasPrefix(r.Header.Get("Range"), "bytes=0-") {
+				w = &corruptingWriter{ResponseWriter: w}
+			}
+
+			fake.ServeHTTP(w, r)
+		})
+
+		res := do("POST", "/api/v1/download", DownloadRequest{AppID: 1000000101, ExternalVersionID: "800000002"})
+		defer res.Body.Close()
+		Expect(res.StatusCode).To(Equal(http.StatusOK))
+
+		data, err := io.ReadAll(res.Body)
+		Expect(err).To(MatchError(io.ErrUnexpectedEOF))
+		Expect(int64(len(data))).To(BeNumerically("<", res.ContentLength))
+	})
+
+	It("saves downloads in the download directory of the server", func() {
+		login()
+		fake.GrantLicense(fakestore.DefaultEmail, 1000000101)
+
+		save := func(onConflict string, statusCode int) SavedDownloadResponse {
+			var out SavedDownloadResponse
+			decode(do("POST", "/api/v1/download", DownloadRequest{
+				AppID:             1000000101,
+				ExternalVersionID: "800000002",
+				Destination:       DestinationServer,
+				OnConflict:        onConflict,
+			}), statusCode, &out)
+			Expect(out.Success).To(BeTrue())
+
+			return out
+		}
+
+		path := filepath.Join(dependencies.Downloads.Path, "Notes", "com.example.notes-1.1.0-800000002.ipa")
+
+		out := save("", http.StatusCreated)
+		Expect(out.Path).To(Equal(path))
+		Expect(out.Skipped).To(BeFalse())
+
+		data, err := os.ReadFile(path)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(out.SizeBytes).To(Equal(int64(len(data))))
+
+		sum := sha256.Sum256(data)
+		Expect(out.SHA256).To(Equal(hex.EncodeToString(sum[:])))
+		Expect(os.ReadFile(path + ".sha256")).To(BeEquivalentTo(out.SHA256 + "  com.example.notes-1.1.0-800000002.ipa\n"))
+
+		out = save(ConflictSkip, http.StatusOK)
+		Expect(out.Path).To(Equal(path))
+		Expect(out.Skipped).To(BeTrue())
+		Expect(out.SizeBytes).To(Equal(int64(len(data))))
+		Expect(out.SHA256).To(Equal(hex.EncodeToString(sum[:])))
+		Expect(out.ChecksumFile).To(BeEmpty())
+
+		out = save(ConflictSuffix, http.StatusCreated)
+		Expect(out.Path).To(Equal(strings.TrimSuffix(path, ".ipa") + "-2.ipa"))
+		Expect(out.Path).To(BeAnExistingFile())
+
+		out = save(ConflictOverwrite, http.StatusCreated)
+		Expect(out.Path).To(Equal(path))
+		Expect(out.Skipped).To(BeFalse())
+
+		// A file that was not saved by the server is kept as well.
+		Expect(os.Remove(path + ".sha256")).To(Succeed())
+		out = save(ConflictSkip, http.StatusOK)
+		Expect(out.Skipped).To(BeTrue())
+		Expect(out.ChecksumFile).To(Equal(ChecksumFileMissing))
+		Expect(os.ReadFile(path)).To(Equal(data))
+
+		decode(do("POST", "/api/v1/download", DownloadRequest{AppID: 1000000101, Destination: "nas"}), http.StatusBadRequest, nil)
+		decode(do("POST", "/api/v1/download", DownloadRequest{AppID: 1000000101, OnConflict: ConflictSkip}), http.StatusBadRequest, nil)
+	})
+
+	It("downloads the version matching a selector", func() {
+		login()
+		fake.GrantLicense(fakestore.DefaultEmail, 1000000101)
+
+		download := func(req DownloadRequest) string {
+			req.BundleID = "com.example.notes"
+			res := do("POST", "/api/v1/download", req)
+			defer res.Body.Close()
+			Expect(res.StatusCode).To(Equal(http.StatusOK))
+
+			return res.Header.Get("Content-Disposition")
+		}
+
+		Expect(download(DownloadRequest{Version: "1.0.0"})).To(ContainSubstring(`filename="com-example-notes-800000001.ipa"`))
+		Expect(download(DownloadRequest{AsOf: "2023-03-01"})).To(ContainSubstring("800000002"))
+		Expect(download(DownloadRequest{Previous: 2})).To(ContainSubstring("800000001"))
+
+		decode(do("POST", "/api/v1/download", DownloadRequest{BundleID: "com.example.notes", Version: "3.0.0"}), http.StatusNotFound, nil)
+		decode(do("POST", "/api/v1/download", DownloadRequest{BundleID: "com.example.notes", AsOf: "2020-01-01"}), http.StatusNotFound, nil)
+		decode(do("POST", "/api/v1/download", DownloadRequest{BundleID: "com.example.notes", AsOf: "March 1st"}), http.StatusBadRequest, nil)
+		decode(do("POST", "/api/v1/download", DownloadRequest{BundleID: "com.example.notes", Version: "1.0.0", Previous: 1}), http.StatusBadRequest, nil)
+	})
+
+	It("downloads the newest version compatible with an iOS version", func() {
+		login()
+		fake.GrantLicense(fakestore.DefaultEmail, 1000000101)
+
+		res := do("POST", "/api/v1/download?compatible_with=15.7", DownloadRequest{BundleID: "com.example.notes"})
+		defer res.Body.Close()
+		Expect(res.StatusCode).To(Equal(http.StatusOK))
+		Expect(res.Header.Get("Content-Disposition")).To(ContainSubstring("800000002"))
+
+		decode(do("POST", "/api/v1/download", DownloadRequest{BundleID: "com.example.notes", CompatibleWith: "11"}), http.StatusNotFound, nil)
+		decode(do("POST", "/api/v1/download", DownloadRequest{BundleID: "com.example.notes", CompatibleWith: "iOS 15"}), http.StatusBadRequest, nil)
+		decode(do("POST", "/api/v1/download", DownloadRequest{BundleID: "com.example.notes", CompatibleWith: "15.7", Previous: 1}), http.StatusBadRequest, nil)
+	})
+
+	It("installs through the configured install command", func() {
+		Expect(os.Setenv("IPATOOL_INSTALL_CMD", "true")).To(Succeed())
+		DeferCleanup(os.Unsetenv, "IPATOOL_INSTALL_CMD")
+		Expect(os.Setenv("IPATOOL_DEVICE_INFO_CMD", "false")).To(Succeed())
+		DeferCleanup(os.Unsetenv, "IPATOOL_DEVICE_INFO_CMD")
+
+		login()
+		fake.GrantLicense(fakestore.DefaultEmail, 1000000102)
+
+		var out InstallResponse
+		decode(do("POST", "/api/v1/install", InstallRequest{BundleID: "com.example.radio"}), http.StatusOK, &out)
+		Expect(out.Success).To(BeTrue())
+		Expect(out.DeviceOSVersion).To(BeEmpty())
+	})
+
+	It("installs the newest version the device supports", func() {
+		deviceInfo := filepath.Join(GinkgoT().TempDir(), "ideviceinfo")
+		Expect(os.WriteFile(deviceInfo, []byte("#!/bin/sh\necho 14.2\n"), 0o755)).To(Succeed())
+
+		Expect(os.Setenv("IPATOOL_INSTALL_CMD", "true")).To(Succeed())
+		DeferCleanup(os.Unsetenv, "IPATOOL_INSTALL_CMD")
+		Expect(os.Setenv("IPATOOL_DEVICE_INFO_CMD", deviceInfo)).To(Succeed())
+		DeferCleanup(os.Unsetenv, "IPATOOL_DEVICE_INFO_CMD")
+
+		login()
+		fake.GrantLicense(fakestore.DefaultEmail, 1000000101)
+
+		var out InstallResponse
+		decode(do("POST", "/api/v1/install", InstallRequest{BundleID: "com.example.notes"}), http.StatusOK, &out)
+		Expect(out.DeviceOSVersion).To(Equal("14.2"))
+		Expect(out.ExternalVersionID).To(Equal("800000002"))
+	})
+
+	It("returns the sinfs of an owned app", func() {
+		login()
+
+		decode(do("GET", "/api/v1/sinf?app_id=1000000101", nil), http.StatusForbidden, nil)
+		decode(do("GET", "/api/v1/sinf", nil), http.StatusBadRequest, nil)
+
+		fake.GrantLicense(fakestore.DefaultEmail, 1000000101)
+
+		var out SinfResponse
+		decode(do("GET", "/api/v1/sinf?app_id=1000000101&external_version_id=800000002", nil), http.StatusOK, &out)
+		Expect(out.Sinfs).To(HaveLen(1))
+		Expect(out.Sinfs[0].Data).To(Equal(fakestore.Sinf("1000000001", 1000000101)))
+		Expect(out.Metadata).To(HaveKeyWithValue("apple-id", fakestore.DefaultEmail))
+		Expect(out.Metadata).To(HaveKeyWithValue("bundleShortVersionString", "1.1.0"))
+	})
+
+	It("replicates the sinfs of the account into uploaded IPAs", func() {
+		login()
+		fake.GrantLicense(fakestore.DefaultEmail, 1000000101)
+
+		var ipa bytes.Buffer
+		writer := zip.NewWriter(&ipa)
+		w, err := writer.Create("Payload/Notes.app/Info.plist")
+		Expect(err).ToNot(HaveOccurred())
+		info, err := plist.Marshal(map[string]interface{}{"CFBundleExecutable": "Notes"}, plist.BinaryFormat)
+		Expect(err).ToNot(HaveOccurred())
+		_, err = w.Write(info)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(writer.Close()).To(Succeed())
+
+		upload := func(contentType string, body []byte) *http.Response {
+			req, err := http.NewRequest("POST", api.URL+"/api/v1/patch?app_id=1000000101", bytes.NewReader(body))
+			Expect(err).ToNot(HaveOccurred())
+			req.Header.Set("X-API-Key", testAPIKey)
+			req.Header.Set("X-Forwarded-For", clientIP)
+			req.Header.Set("Content-Type", contentType)
+
+			res, err := http.DefaultClient.Do(req)
+			Expect(err).ToNot(HaveOccurred())
+
+			return res
+		}
+
+		sinf := func(res *http.Response) string {
+			defer res.Body.Close()
+			Expect(res.StatusCode).To(Equal(http.StatusOK))
+
+			data, err := io.ReadAll(res.Body)
+			Expect(err).ToNot(HaveOccurred())
+
+			sum := sha256.Sum256(data)
+			Expect(res.ContentLength).To(Equal(int64(len(data))))
+			Expect(res.Header.Get("Repr-Digest")).To(Equal("sha-256=:" + base64.StdEncoding.EncodeToString(sum[:]) + ":"))
+
+			reader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+			Expect(err).ToNot(HaveOccurred())
+
+			file, err := reader.Open("Payload/Notes.app/SC_Info/Notes.sinf")
+			Expect(err).ToNot(HaveOccurred())
+			defer file.Close()
+
+			content, err := io.ReadAll(file)
+			Expect(err).ToNot(HaveOccurred())
+
+			return string(content)
+		}
+
+		expected := string(fakestore.Sinf("1000000001", 1000000101))
+		Expect(sinf(upload("application/octet-stream", ipa.Bytes()))).To(Equal(expected))
+
+		var form bytes.Buffer
+		multipartWriter := multipart.NewWriter(&form)
+		part, err := multipartWriter.CreateFormFile("file", "Notes.ipa")
+		Expect(err).ToNot(HaveOccurred())
+		_, err = part.Write(ipa.Bytes())
+		Expect(err).ToNot(HaveOccurred())
+		Expect(multipartWriter.Close()).To(Succeed())
+
+		res := upload(multipartWriter.FormDataContentType(), form.Bytes())
+		Expect(res.Header.Get("Content-Disposition")).To(ContainSubstring(`filename="Notes.ipa"`))
+		Expect(sinf(res)).To(Equal(expected))
+
+		decode(upload("application/octet-stream", []byte("not a zip")), http.StatusBadRequest, nil)
+	})
+
+	It("verifies the sinfs of uploaded IPAs", func() {
+		login()
+
+		res := do("POST", "/api/v1/download", DownloadRequest{AppID: 1000000101, AutoPurchase: true})
+		Expect(res.StatusCode).To(Equal(http.StatusOK))
+		ipa, err := io.ReadAll(res.Body)
+		res.Body.Close()
+		Expect(err).ToNot(HaveOccurred())
+
+		send := func(query string, body []byte) *http.Response {
+			req, err := http.NewRequest("POST", api.URL+"/api/v1/sinf/verify"+query, bytes.NewReader(body))
+			Expect(err).ToNot(HaveOccurred())
+			req.Header.Set("X-API-Key", testAPIKey)
+			req.Header.Set("X-Forwarded-For", clientIP)
+			req.Header.Set("Content-Type", "application/octet-stream")
+
+			res, err := http.DefaultClient.Do(req)
+			Expect(err).ToNot(HaveOccurred())
+
+			return res
+		}
+
+		verify := func(query string, body []byte) VerifySinfResponse {
+			var out VerifySinfResponse
+			decode(send(query, body), http.StatusOK, &out)
+
+			return out
+		}
+
+		out := verify("?app_id=1000000101", ipa)
+		Expect(out.Valid).To(BeTrue(), fmt.Sprint(out.Problems))
+		Expect(out.Bundles).To(HaveLen(1))
+		Expect(out.Bundles[0].Kind).To(Equal("app"))
+		Expect(out.Bundles[0].Sinfs).To(HaveLen(1))
+		Expect(out.Bundles[0].Sinfs[0].Status).To(Equal("ok"))
+
+		// The manifest still lists the sinf of an IPA without it.
+		reader, err := zip.NewReader(bytes.NewReader(ipa), int64(len(ipa)))
+		Expect(err).ToNot(HaveOccurred())
+
+		var stripped bytes.Buffer
+		writer := zip.NewWriter(&stripped)
+		for _, file := range reader.File {
+			if strings.HasSuffix(file.Name, ".sinf") {
+				continue
+			}
+			Expect(writer.Copy(file)).To(Succeed())
+		}
+		Expect(writer.Close()).To(Succeed())
+
+		out = verify("", stripped.Bytes())
+		Expect(out.Valid).To(BeFalse())
+		Expect(out.Bundles[0].Sinfs[0].Status).To(Equal("missing"))
+		Expect(out.Problems).To(HaveLen(1))
+
+		// Only the comparison with the SINFs of the account needs an account.
+		decode(do("POST", "/api/v1/auth/revoke", nil), http.StatusOK, nil)
+
+		out = verify("", ipa)
+		Expect(out.Valid).To(BeTrue(), fmt.Sprint(out.Problems))
+
+		res = send("?app_id=1000000101", ipa)
+		Expect(res.Body.Close()).To(Succeed())
+		Expect(res.StatusCode).ToNot(Equal(http.StatusOK))
+	})
+
+	It("watches apps and downloads new versions", func() {
+		// The watchlist requires the account logged in on the server.
+		res := do("DELETE", "/api/v1/watchlist/1000000101?delete_files=true", nil)
+		Expect(res.Body.Close()).To(Succeed())
+		Expect(res.StatusCode).ToNot(Equal(http.StatusOK))
+
+		login()
+		fake.GrantLicense(fakestore.DefaultEmail, 1000000101)
+
+		var item WatchlistItem
+		decode(do("POST", "/api/v1/watchlist", WatchlistAddRequest{BundleID: "com.example.notes", KeepVersions: 1}), http.StatusCreated, &item)
+		Expect(item.AppID).To(Equal(int64(1000000101)))
+		Expect(item.Name).To(Equal("Notes"))
+		Expect(item.KeepVersions).To(Equal(1))
+
+		decode(do("POST", "/api/v1/watchlist", WatchlistAddRequest{AppID: 1000000101}), http.StatusConflict, nil)
+		decode(do("POST", "/api/v1/watchlist", WatchlistAddRequest{AppID: 1000000101, KeepVersions: -1}), http.StatusBadRequest, nil)
+
+		dependencies.Watchlist.CheckAll()
+		fake.ReleaseVersion(1000000101, fakestore.Version{ExternalVersionID: "800000004", DisplayVersion: "2.1.0", BundleVersion: "210"})
+		decode(do("POST", "/api/v1/watchlist/check", nil), http.StatusAccepted, nil)
+		dependencies.Watchlist.CheckAll()
+
+		var list WatchlistResponse
+		decode(do("GET", "/api/v1/watchlist", nil), http.StatusOK, &list)
+		Expect(list.Interval).To(Equal("6h0m0s"))
+		Expect(list.Items).To(HaveLen(1))
+		Expect(list.Items[0].LastSeenExternalVersionID).To(Equal("800000004"))
+		Expect(list.Items[0].Downloads).To(HaveLen(1))
+		Expect(list.Items[0].Downloads[0].Path).To(BeAnExistingFile())
+
+		var events WatchlistEventsResponse
+		decode(do("GET", "/api/v1/watchlist/events", nil), http.StatusOK, &events)
+		Expect(events.Events).To(HaveLen(2))
+		Expect(events.Events[0].Type).To(Equal("new_version"))
+		Expect(events.Events[0].ExternalVersionID).To(Equal("800000004"))
+		Expect(events.Events[0].PreviousExternalVersionID).To(Equal("800000003"))
+
+		decode(do("PATCH", "/api/v1/watchlist/1000000101", WatchlistUpdateRequest{KeepVersions: 3}), http.StatusOK, &item)
+		Expect(item.KeepVersions).To(Equal(3))
+
+		decode(do("DELETE", "/api/v1/watchlist/1000000101?delete_files=true", nil), http.StatusOK, nil)
+		Expect(list.Items[0].Downloads[0].Path).ToNot(BeAnExistingFile())
+		decode(do("GET", "/api/v1/watchlist/1000000101", nil), http.StatusNotFound, nil)
+	})
+
+	It("serves an Atom feed of new versions with a per-feed token", func() {
+		configs, err := parseFeedConfigs([]FeedConfig{{
+			Name:      "updates",
+			Token:     "0123456789abcdef",
+			BundleIDs: []string{"com.example.notes"},
+		}})
+		Expect(err).ToNot(HaveOccurred())
+
+		feedState, err := kvstore.Open(filepath.Join(GinkgoT().TempDir(), FeedStateFileName))
+		Expect(err).ToNot(HaveOccurred())
+		dependencies.Feeds = Feeds{
+			Configs: configs,
+			Tracker: feed.NewTracker(feed.Args{AppStore: dependencies.AppStore, Store: feedState, Logger: dependencies.Logger, RefreshInterval: time.Nanosecond}),
+		}
+
+		get := func(path string) (int, string) {
+			res, err := http.Get(api.URL + path)
+			Expect(err).ToNot(HaveOccurred())
+			defer res.Body.Close()
+
+			body, err := io.ReadAll(res.Body)
+			Expect(err).ToNot(HaveOccurred())
+
+			return res.StatusCode, string(body)
+		}
+
+		status, _ := get("/feeds/updates.atom?token=0123456789abcdef")
+		Expect(status).To(Equal(http.StatusServiceUnavailable))
+
+		login()
+		fake.GrantLicense(fakestore.DefaultEmail, 1000000101)
+
+		status, _ = get("/feeds/updates.atom?token=wrong")
+		Expect(status).To(Equal(http.StatusUnauthorized))
+		status, _ = get("/feeds/other.atom?token=0123456789abcdef")
+		Expect(status).To(Equal(http.StatusNotFound))
+
+		status, body := get("/feeds/updates.atom?token=0123456789abcdef")
+		Expect(status).To(Equal(http.StatusOK))
+		Expect(body).To(ContainSubstring("<title>Notes 2.0.0</title>"))
+
+		fake.ReleaseVersion(1000000101, fakestore.Version{ExternalVersionID: "800000004", DisplayVersion: "2.1.0", BundleVersion: "210", ReleaseNotes: "Bug fixes"})
+
+		res, err := http.Get(api.URL + "/feeds/updates.atom?token=0123456789abcdef")
+		Expect(err).ToNot(HaveOccurred())
+		defer res.Body.Close()
+		Expect(res.Header.Get("Content-Type")).To(HavePrefix("application/atom+xml"))
+
+		data, err := io.ReadAll(res.Body)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(data)).To(ContainSubstring("urn:ipatool:app:1000000101:version:800000004"))
+		Expect(string(data)).To(ContainSubstring("Bug fixes"))
+		Expect(string(data)).To(ContainSubstring(`href="` + api.URL + `/feeds/updates.atom"`))
+
+		_, err = parseFeedConfigs([]FeedConfig{{Name: "short", Token: "secret", BundleIDs: []string{"com.example.notes"}}})
+		Expect(err).To(MatchError(ContainSubstring("too short")))
+		_, err = parseFeedConfigs([]FeedConfig{{Name: "../x", Token: "0123456789abcdef", BundleIDs: []string{"com.example.notes"}}})
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("maps expired tokens to an error response", func() {
+		login()
+		fake.GrantLicense(fakestore.DefaultEmail, 1000000101)
+		fake.ExpireTokens()
+
+		decode(do("GET", "/api/v1/versions?app_id=1000000101", nil), http.StatusUnauthorized, nil)
+	})
+})
+
+// corruptingWriter flips the first byte written to it.
+type corruptingWriter struct {
+	http.ResponseWriter
+	corrupted bool
+}
+
+func (w *corruptingWriter) Write(p []byte) (int, error) {
+	if !w.corrupted && len(p) > 0 {
+		w.corrupted = true
+		p = append([]byte{p[0] ^ 0xFF}, p[1:]...)
+	}
+
+	return w.ResponseWriter.Write(p)
+}
@@ -0,0 +1,338 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/majd/ipatool/v2/pkg/appstore"
+)
+
+// SinfResponse is the response for GET /api/v1/sinf.
+type SinfResponse struct {
+	Success           bool       `json:"success"`
+	AppID             int64      `json:"app_id"`
+	ExternalVersionID string     `json:"external_version_id,omitempty"`
+	Sinfs             []SinfInfo `json:"sinfs"`
+	// Metadata is the content of iTunesMetadata.plist, bound to the account.
+	Metadata map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// SinfInfo is a sinf; Data is base64 encoded in JSON.
+type SinfInfo struct {
+	ID   int64  `json:"id"`
+	Data []byte `json:"data"`
+}
+
+// parseSinfQuery reads the app_id and external_version_id query parameters of the sinf endpoints.
+func parseSinfQuery(r *http.Request) (appstore.App, string, error) {
+	appIDStr := r.URL.Query().Get("app_id")
+	if appIDStr == "" {
+		return appstore.App{}, "", fmt.Errorf("app_id is required")
+	}
+	appID, err := strconv.ParseInt(appIDStr, 10, 64)
+	if err != nil || appID <= 0 {
+		return appstore.App{}, "", fmt.Errorf("Invalid app_id")
+	}
+
+	externalVersionID := r.URL.Query().Get("external_version_id")
+	if err := validateExternalVersionID(externalVersionID); err != nil {
+		return appstore.App{}, "", err
+	}
+
+	return appstore.App{ID: appID}, externalVersionID, nil
+}
+
+func handleSinf(w http.ResponseWriter, r *http.Request) {
+	app, externalVersionID, err := parseSinfQuery(r)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	accountInfo, ok := getAccountInfo(r)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	result, err := dependencies.AppStore.GetSinfs(appstore.GetSinfsInput{
+		Account:           accountInfo.Account,
+		App:               app,
+		ExternalVersionID: externalVersionID,
+	})
+	if err != nil {
+		statusCode, message := mapAppStoreErrorToHTTPStatus(err)
+		respondError(w, statusCode, message)
+		return
+	}
+
+	sinfs := make([]SinfInfo, 0, len(result.Sinfs))
+	for _, sinf := range result.Sinfs {
+		sinfs = append(sinfs, SinfInfo{ID: sinf.ID, Data: sinf.Data})
+	}
+
+	respondSuccess(w, SinfResponse{
+		Success:           true,
+		AppID:             app.ID,
+		ExternalVersionID: externalVersionID,
+		Sinfs:             sinfs,
+		Metadata:          result.Metadata,
+	})
+}
+
+// handlePatch replicates the sinfs of the account into an uploaded IPA and returns it.
+func handlePatch(w http.ResponseWriter, r *http.Request) {
+	app, externalVersionID, err := parseSinfQuery(r)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	accountInfo, ok := getAccountInfo(r)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	// The sinfs are requested first, so that an upload is not received for an app the account does not own.
+	result, err := dependencies.AppStore.GetSinfs(appstore.GetSinfsInput{
+		Account:           accountInfo.Account,
+		App:               app,
+		ExternalVersionID: externalVersionID,
+	})
+	if err != nil {
+		statusCode, message := mapAppStoreErrorToHTTPStatus(err)
+		respondError(w, statusCode, message)
+		return
+	}
+	if len(result.Sinfs) == 0 {
+		respondError(w, http.StatusBadGateway, "The App Store did not return any sinf for this app")
+		return
+	}
+
+	tmpPath, uploadName, ok := receiveIPA(w, r)
+	if !ok {
+		return
+	}
+	defer removeTempFile(tmpPath)
+
+	err = dependencies.AppStore.ReplicateSinf(appstore.ReplicateSinfInput{
+		Sinfs:       result.Sinfs,
+		PackagePath: tmpPath,
+	})
+	if err != nil {
+		dependencies.Logger.Error().Err(err).Msg("Failed to replicate sinf")
+		respondError(w, http.StatusBadRequest, "Uploaded file is not a valid IPA")
+		return
+	}
+
+	file, err := os.Open(tmpPath)
+	if err != nil {
+		dependencies.Logger.Error().Err(err).Str("path", tmpPath).Msg("Failed to open patched file")
+		respondError(w, http.StatusInternalServerError, "Failed to open patched file")
+		return
+	}
+	defer file.Close()
+
+	fileInfo, err := file.Stat()
+	if err != nil {
+		dependencies.Logger.Error().Err(err).Str("path", tmpPath).Msg("Failed to stat patched file")
+		respondError(w, http.StatusInternalServerError, "Failed to get file information")
+		return
+	}
+
+	filename := generateFilename(app, externalVersionID)
+	if uploadName != "" {
+		filename = uploadName
+	}
+	if !servePackageFile(w, r, file, filename, fileInfo.Size()) {
+		return
+	}
+
+	dependencies.Logger.Log().
+		Int64("app_id", app.ID).
+		Int64("size", fileInfo.Size()).
+		Msg("Uploaded file patched and streamed successfully")
+}
+
+// VerifySinfResponse is the response for POST /api/v1/sinf/verify.
+type VerifySinfResponse struct {
+	Success bool             `json:"success"`
+	Valid   bool             `json:"valid"`
+	Bundles []SinfBundleInfo `json:"bundles"`
+	// Problems explains why a device may refuse to launch the app.
+	Problems []string `json:"problems"`
+}
+
+type SinfBundleInfo struct {
+	Path  string          `json:"path"`
+	Kind  string          `json:"kind"`
+	Sinfs []SinfCheckInfo `json:"sinfs"`
+}
+
+type SinfCheckInfo struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	Status string `json:"status"`
+}
+
+// handleVerifySinf reports whether the sinfs of an uploaded IPA are in place. With app_id, they are also compared
+// with the sinfs of the account.
+func handleVerifySinf(w http.ResponseWriter, r *http.Request) {
+	// Without app_id, the IPA is checked on its own and no account is needed.
+	if r.URL.Query().Get("app_id") != "" {
+		accountInfoMiddleware(http.HandlerFunc(verifySinf)).ServeHTTP(w, r)
+		return
+	}
+
+	verifySinf(w, r)
+}
+
+func verifySinf(w http.ResponseWriter, r *http.Request) {
+	var sinfs []appstore.Sinf
+	if accountInfo, ok := getAccountInfo(r); ok {
+		app, externalVersionID, err := parseSinfQuery(r)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		result, err := dependencies.AppStore.GetSinfs(appstore.GetSinfsInput{
+			Account:           accountInfo.Account,
+			App:               app,
+			ExternalVersionID: externalVersionID,
+		})
+		if err != nil {
+			statusCode, message := mapAppStoreErrorToHTTPStatus(err)
+			respondError(w, statusCode, message)
+			return
+		}
+		sinfs = result.Sinfs
+	}
+
+	tmpPath, _, ok := receiveIPA(w, r)
+	if !ok {
+		return
+	}
+	defer removeTempFile(tmpPath)
+
+	result, err := dependencies.AppStore.VerifySinf(appstore.VerifySinfInput{
+		PackagePath: tmpPath,
+		Sinfs:       sinfs,
+	})
+	if err != nil {
+		dependencies.Logger.Error().Err(err).Msg("Failed to verify sinf")
+		respondError(w, http.StatusBadRequest, "Uploaded file is not a valid IPA")
+		return
+	}
+
+	bundles := make([]SinfBundleInfo, 0, len(result.Bundles))
+	for _, bundle := range result.Bundles {
+		checks := make([]SinfCheckInfo, 0, len(bundle.Sinfs))
+		for _, check := range bundle.Sinfs {
+			checks = append(checks, SinfCheckInfo{Path: check.Path, Size: check.Size, Status: string(check.Status)})
+		}
+		bundles = append(bundles, SinfBundleInfo{Path: bundle.Path, Kind: string(bundle.Kind), Sinfs: checks})
+	}
+
+	problems := result.Problems
+	if problems == nil {
+		problems = []string{}
+	}
+
+	respondSuccess(w, VerifySinfResponse{
+		Success:  true,
+		Valid:    result.Valid(),
+		Bundles:  bundles,
+		Problems: problems,
+	})
+}
+
+// receiveIPA saves the uploaded IPA to a temporary file and returns its path and the file name sent by the
+// client. It responds with an error and returns false if the upload fails; otherwise the caller removes the file.
+func receiveIPA(w http.ResponseWriter, r *http.Request) (string, string, bool) {
+	if err := http.NewResponseController(w).SetReadDeadline(time.Now().Add(PatchUploadTimeout)); err != nil {
+		dependencies.Logger.Verbose().Err(err).Msg("Failed to extend read deadline for upload")
+	}
+
+	upload, uploadName, err := uploadedIPA(r)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return "", "", false
+	}
+
+	tmpFile, err := os.CreateTemp("", "ipatool-upload-*.ipa")
+	if err != nil {
+		dependencies.Logger.Error().Err(err).Msg("Failed to create temporary file")
+		respondError(w, http.StatusInternalServerError, "Failed to create temporary file")
+		return "", "", false
+	}
+	tmpPath := tmpFile.Name()
+
+	size, err := io.Copy(tmpFile, upload)
+	tmpFile.Close()
+	if err != nil {
+		removeTempFile(tmpPath)
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			respondError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("Uploaded file is too large (max %d bytes)", maxBytesErr.Limit))
+			return "", "", false
+		}
+		dependencies.Logger.Error().Err(err).Msg("Failed to receive upload")
+		respondError(w, http.StatusBadRequest, "Failed to read uploaded file")
+		return "", "", false
+	}
+	if size == 0 {
+		removeTempFile(tmpPath)
+		respondError(w, http.StatusBadRequest, "Uploaded file is empty")
+		return "", "", false
+	}
+
+	return tmpPath, uploadName, true
+}
+
+func removeTempFile(path string) {
+	if err := os.Remove(path); err != nil {
+		dependencies.Logger.Error().Err(err).Str("path", path).Msg("Failed to remove temporary file")
+	}
+}
+
+// uploadedIPA returns the uploaded IPA and its file name, if the client sent one. The IPA is either the body of
+// the request or the "file" part of a multipart/form-data body.
+func uploadedIPA(r *http.Request) (io.Reader, string, error) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "multipart/form-data" {
+		return r.Body, "", nil
+	}
+
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid multipart body")
+	}
+
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return nil, "", fmt.Errorf("file part is required")
+		}
+		if err != nil {
+			return nil, "", fmt.Errorf("invalid multipart body")
+		}
+
+		if part.FormName() == "file" {
+			name := ""
+			if part.FileName() != "" && strings.HasSuffix(strings.ToLower(part.FileName()), ".ipa") {
+				name = sanitizeFilename(filepath.Base(part.FileName()))
+			}
+			return part, name, nil
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package cmd
+
+import (
+	"net/http"
+
+	"github.com/majd/ipatool/v2/pkg/appstore"
+)
+
+// StorefrontInfo describes a country with an App Store storefront.
+type StorefrontInfo struct {
+	CountryCode  string `json:"country_code"`
+	Name         string `json:"name"`
+	Currency     string `json:"currency"`
+	StorefrontID string `json:"storefront_id"`
+}
+
+// StorefrontsResponse is the response for GET /api/v1/storefronts.
+type StorefrontsResponse struct {
+	Count       int              `json:"count"`
+	Storefronts []StorefrontInfo `json:"storefronts"`
+}
+
+// handleStorefronts lists all supported storefront countries, sorted by country code.
+// It does not require a logged-in account.
+func handleStorefronts(w http.ResponseWriter, r *http.Request) {
+	countries := appstore.Countries()
+
+	storefronts := make([]StorefrontInfo, len(countries))
+	for i, country := range countries {
+		storefronts[i] = StorefrontInfo{
+			CountryCode:  country.Code,
+			Name:         country.Name,
+			Currency:     country.Currency,
+			StorefrontID: country.StorefrontID,
+		}
+	}
+
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+	respondSuccess(w, StorefrontsResponse{
+		Count:       len(storefronts),
+		Storefronts: storefronts,
+	})
+}
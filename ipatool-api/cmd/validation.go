@@ -5,7 +5,11 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 	"unicode"
+
+	"github.com/majd/ipatool/v2/pkg/appstore"
+	ipahttp "github.com/majd/ipatool/v2/pkg/http"
 )
 
 // Validation constants
@@ -17,13 +21,17 @@ const (
 	MaxBundleIDLength  = 200
 	MaxVersionIDLength = 100
 	CountryCodeLength  = 2
+	MaxProxyLength     = 500
+	MaxLicenseChecks   = 100
+	MaxKeepVersions    = 50
 )
 
 // Validation patterns
 var (
-	emailRegex    = regexp.MustCompile(`^[a-zA-Z0-9._%+\-]+@[a-zA-Z0-9.\-]+\.[a-zA-Z]{2,}$`)
-	bundleIDRegex = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9\-_\.]*[a-zA-Z0-9]$|^[a-zA-Z0-9]+$`)
-	versionRegex  = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9\-_\.]*$`)
+	emailRegex     = regexp.MustCompile(`^[a-zA-Z0-9._%+\-]+@[a-zA-Z0-9.\-]+\.[a-zA-Z]{2,}$`)
+	bundleIDRegex  = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9\-_\.]*[a-zA-Z0-9]$|^[a-zA-Z0-9]+$`)
+	versionRegex   = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9\-_\.]*$`)
+	osVersionRegex = regexp.MustCompile(`^[0-9]+(\.[0-9]+){0,3}$`)
 )
 
 // Validation helpers
@@ -87,6 +95,91 @@ func validateLimit(limitStr string) (int64, error) {
 	return limit, nil
 }
 
+func validateOffset(offsetStr string) (int64, error) {
+	if offsetStr == "" {
+		return 0, nil
+	}
+	offset, err := strconv.ParseInt(offsetStr, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid offset parameter")
+	}
+	if offset < 0 {
+		return 0, fmt.Errorf("offset parameter cannot be negative")
+	}
+	return offset, nil
+}
+
+// validateKeepVersions validates the number of versions kept for a watched app. 0 means the default.
+func validateKeepVersions(keepVersions int) error {
+	if keepVersions < 0 || keepVersions > MaxKeepVersions {
+		return fmt.Errorf("keep_versions must be between 0 and %d", MaxKeepVersions)
+	}
+	return nil
+}
+
+// parseVersionSelector validates the version fields of a download or install request. At most one of
+// external_version_id, version, as_of, previous and compatible_with can be set. A date without a time
+// selects the version that was current at the end of that day (UTC).
+func parseVersionSelector(externalVersionID, version, asOf string, previous int, compatibleWith string) (appstore.VersionSelector, error) {
+	set := 0
+	for _, isSet := range []bool{externalVersionID != "", version != "", asOf != "", previous != 0, compatibleWith != ""} {
+		if isSet {
+			set++
+		}
+	}
+	if set > 1 {
+		return appstore.VersionSelector{}, fmt.Errorf("only one of external_version_id, version, as_of, previous and compatible_with can be set")
+	}
+
+	selector := appstore.VersionSelector{DisplayVersion: version, Previous: previous, CompatibleWith: compatibleWith}
+
+	if compatibleWith != "" && !osVersionRegex.MatchString(compatibleWith) {
+		return appstore.VersionSelector{}, fmt.Errorf("invalid compatible_with format (expected an iOS version like 15.7)")
+	}
+
+	if version != "" {
+		if len(version) > MaxVersionIDLength || !versionRegex.MatchString(version) {
+			return appstore.VersionSelector{}, fmt.Errorf("invalid version format")
+		}
+	}
+	if previous < 0 {
+		return appstore.VersionSelector{}, fmt.Errorf("previous cannot be negative")
+	}
+	if asOf != "" {
+		if date, err := time.Parse(time.DateOnly, asOf); err == nil {
+			selector.AsOf = date.Add(24*time.Hour - time.Nanosecond)
+		} else if selector.AsOf, err = time.Parse(time.RFC3339, asOf); err != nil {
+			return appstore.VersionSelector{}, fmt.Errorf("invalid as_of format (expected YYYY-MM-DD or RFC 3339)")
+		}
+	}
+
+	return selector, nil
+}
+
+// validateBypassCache parses the optional bypass_cache parameter.
+func validateBypassCache(value string) (bool, error) {
+	if value == "" {
+		return false, nil
+	}
+	bypass, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("invalid bypass_cache parameter")
+	}
+	return bypass, nil
+}
+
+// validateInclude reports whether include asks for version metadata, the only supported value.
+func validateInclude(include string) (bool, error) {
+	switch include {
+	case "":
+		return false, nil
+	case "metadata":
+		return true, nil
+	default:
+		return false, fmt.Errorf("unsupported include parameter: %q", include)
+	}
+}
+
 func validateCountryCode(country string) error {
 	if country == "" {
 		return nil
@@ -153,6 +246,19 @@ func validateAppIDOrBundleID(appIDStr string, bundleID string) error {
 	return nil
 }
 
+func validateProxy(proxy string) error {
+	if proxy == "" {
+		return nil
+	}
+	if len(proxy) > MaxProxyLength {
+		return fmt.Errorf("proxy is too long (max %d characters)", MaxProxyLength)
+	}
+	if _, err := ipahttp.ParseProxyURL(proxy); err != nil {
+		return fmt.Errorf("invalid proxy (expected http://, https://, socks5:// or socks5h:// URL)")
+	}
+	return nil
+}
+
 func validateExternalVersionID(versionID string) error {
 	if versionID == "" {
 		return nil
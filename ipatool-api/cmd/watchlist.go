@@ -0,0 +1,394 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/majd/ipatool/v2/pkg/appstore"
+	"github.com/majd/ipatool/v2/pkg/log"
+	"github.com/majd/ipatool/v2/pkg/util"
+	"github.com/majd/ipatool/v2/pkg/util/machine"
+	"github.com/majd/ipatool/v2/pkg/watchlist"
+)
+
+// WatchlistAddRequest is the request body for POST /api/v1/watchlist.
+type WatchlistAddRequest struct {
+	AppID    int64  `json:"app_id,omitempty"`
+	BundleID string `json:"bundle_id,omitempty"`
+	// KeepVersions is the number of downloaded versions to keep (default: IPATOOL_WATCHLIST_KEEP).
+	KeepVersions int `json:"keep_versions,omitempty"`
+}
+
+// WatchlistUpdateRequest is the request body for PATCH /api/v1/watchlist/{app_id}.
+type WatchlistUpdateRequest struct {
+	// KeepVersions is the number of downloaded versions to keep. 0 restores the default.
+	KeepVersions int `json:"keep_versions"`
+}
+
+// WatchlistDownload is a version of a watched app saved on the server.
+type WatchlistDownload struct {
+	ExternalVersionID string    `json:"external_version_id"`
+	Path              string    `json:"path"`
+	SizeBytes         int64     `json:"size_bytes"`
+	SHA256            string    `json:"sha256,omitempty"`
+	DownloadedAt      time.Time `json:"downloaded_at"`
+}
+
+// WatchlistItem is an app on the watchlist.
+type WatchlistItem struct {
+	AppID                     int64               `json:"app_id"`
+	BundleID                  string              `json:"bundle_id"`
+	Name                      string              `json:"name,omitempty"`
+	KeepVersions              int                 `json:"keep_versions"`
+	AddedAt                   time.Time           `json:"added_at"`
+	LastSeenExternalVersionID string              `json:"last_seen_external_version_id,omitempty"`
+	LastCheckedAt             *time.Time          `json:"last_checked_at,omitempty"`
+	LastError                 string              `json:"last_error,omitempty"`
+	Downloads                 []WatchlistDownload `json:"downloads"`
+}
+
+// WatchlistResponse is the response for GET /api/v1/watchlist.
+type WatchlistResponse struct {
+	Directory  string          `json:"directory"`
+	Interval   string          `json:"interval"`
+	QuietHours string          `json:"quiet_hours,omitempty"`
+	Items      []WatchlistItem `json:"items"`
+}
+
+// WatchlistCheckResponse is the response for POST /api/v1/watchlist/check.
+type WatchlistCheckResponse struct {
+	Success bool   `json:"success"`
+	Message string `json:"message,omitempty"`
+}
+
+// WatchlistEvent is a new version of a watched app, as listed by GET /api/v1/watchlist/events
+// and posted to IPATOOL_WATCHLIST_WEBHOOK.
+type WatchlistEvent struct {
+	Type                      string    `json:"type"`
+	Time                      time.Time `json:"time"`
+	AppID                     int64     `json:"app_id"`
+	BundleID                  string    `json:"bundle_id"`
+	Name                      string    `json:"name,omitempty"`
+	ExternalVersionID         string    `json:"external_version_id"`
+	PreviousExternalVersionID string    `json:"previous_external_version_id,omitempty"`
+	Path                      string    `json:"path,omitempty"`
+	Error                     string    `json:"error,omitempty"`
+}
+
+// WatchlistEventsResponse is the response for GET /api/v1/watchlist/events.
+type WatchlistEventsResponse struct {
+	Events []WatchlistEvent `json:"events"`
+}
+
+// newWatchlist opens the watchlist in the config directory and configures its watcher from the environment:
+// IPATOOL_WATCHLIST_DIR (download directory), IPATOOL_WATCHLIST_INTERVAL (e.g. "6h"),
+// IPATOOL_WATCHLIST_QUIET_HOURS (e.g. "22:00-07:00", local time), IPATOOL_WATCHLIST_KEEP (versions kept per app)
+// and IPATOOL_WATCHLIST_WEBHOOK (URL every event is posted to).
+func newWatchlist(machine machine.Machine, logger log.Logger, appStore appstore.AppStore) (*watchlist.Watcher, error) {
+	args := watchlist.Args{
+		AppStore:  appStore,
+		Logger:    logger,
+		Directory: filepath.Join(machine.HomeDirectory(), ConfigDirectoryName, WatchlistDirectoryName),
+	}
+
+	if value := os.Getenv("IPATOOL_WATCHLIST_DIR"); value != "" {
+		args.Directory = value
+	}
+
+	if value := os.Getenv("IPATOOL_WATCHLIST_INTERVAL"); value != "" {
+		interval, err := time.ParseDuration(value)
+		if err != nil || interval < time.Minute {
+			return nil, fmt.Errorf("invalid IPATOOL_WATCHLIST_INTERVAL: %q (minimum 1m)", value)
+		}
+		args.Interval = interval
+	}
+
+	quietHours, err := watchlist.ParseQuietHours(os.Getenv("IPATOOL_WATCHLIST_QUIET_HOURS"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid IPATOOL_WATCHLIST_QUIET_HOURS: %w", err)
+	}
+	args.QuietHours = quietHours
+
+	if value := os.Getenv("IPATOOL_WATCHLIST_KEEP"); value != "" {
+		keep, err := strconv.Atoi(value)
+		if err != nil || keep < 1 || keep > MaxKeepVersions {
+			return nil, fmt.Errorf("invalid IPATOOL_WATCHLIST_KEEP: %q (1-%d)", value, MaxKeepVersions)
+		}
+		args.KeepVersions = keep
+	}
+
+	webhookURL := os.Getenv("IPATOOL_WATCHLIST_WEBHOOK")
+	if webhookURL != "" {
+		parsed, err := url.Parse(webhookURL)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return nil, fmt.Errorf("invalid IPATOOL_WATCHLIST_WEBHOOK: %q", webhookURL)
+		}
+	}
+
+	args.OnEvent = func(event watchlist.Event) {
+		logEvent := logger.Log()
+		if event.Err != nil {
+			logEvent = logger.Error().Err(event.Err)
+		}
+		logEvent.Str("type", string(event.Type)).Int64("appID", event.AppID).Str("externalVersionID", event.ExternalVersionID).Str("path", event.Path).Msg("Watchlist event")
+
+		if webhookURL != "" {
+			go postWatchlistEvent(webhookURL, newWatchlistEvent(event), logger)
+		}
+	}
+
+	args.Store, err = watchlist.Open(filepath.Join(machine.HomeDirectory(), ConfigDirectoryName, WatchlistFileName))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open the watchlist: %w", err)
+	}
+
+	return watchlist.New(args), nil
+}
+
+// postWatchlistEvent posts the event as JSON to the webhook. Failures are only logged.
+func postWatchlistEvent(webhookURL string, event WatchlistEvent, logger log.Logger) {
+	body, err := json.Marshal(event)
+	if err != nil {
+		logger.Error().Err(err).Msg("Failed to encode watchlist event")
+		return
+	}
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	res, err := client.Post(webhookURL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		logger.Error().Err(err).Msg("Failed to post watchlist event")
+		return
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= 300 {
+		logger.Error().Int("status", res.StatusCode).Msg("Watchlist webhook rejected the event")
+	}
+}
+
+func newWatchlistEvent(event watchlist.Event) WatchlistEvent {
+	out := WatchlistEvent{
+		Type:                      string(event.Type),
+		Time:                      event.Time,
+		AppID:                     event.AppID,
+		BundleID:                  event.BundleID,
+		Name:                      event.Name,
+		ExternalVersionID:         event.ExternalVersionID,
+		PreviousExternalVersionID: event.PreviousExternalVersionID,
+		Path:                      event.Path,
+	}
+	if event.Err != nil {
+		_, out.Error = mapAppStoreErrorToHTTPStatus(event.Err)
+	}
+
+	return out
+}
+
+func newWatchlistItem(item watchlist.Item) WatchlistItem {
+	out := WatchlistItem{
+		AppID:                     item.AppID,
+		BundleID:                  item.BundleID,
+		Name:                      item.Name,
+		KeepVersions:              dependencies.Watchlist.KeepVersions(item),
+		AddedAt:                   item.AddedAt,
+		LastSeenExternalVersionID: item.LastSeenExternalVersionID,
+		LastError:                 item.LastError,
+		Downloads:                 []WatchlistDownload{},
+	}
+	if !item.LastCheckedAt.IsZero() {
+		out.LastCheckedAt = &item.LastCheckedAt
+	}
+	for _, download := range item.Downloads {
+		out.Downloads = append(out.Downloads, WatchlistDownload(download))
+	}
+
+	return out
+}
+
+// watchlistAppID parses the app_id path variable.
+func watchlistAppID(r *http.Request) (int64, error) {
+	appID, err := strconv.ParseInt(mux.Vars(r)["app_id"], 10, 64)
+	if err != nil || appID <= 0 {
+		return 0, fmt.Errorf("invalid app_id")
+	}
+	return appID, nil
+}
+
+func respondWatchlistError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, watchlist.ErrNotWatched):
+		respondError(w, http.StatusNotFound, "App is not on the watchlist")
+	case errors.Is(err, watchlist.ErrAlreadyWatched):
+		respondError(w, http.StatusConflict, "App is already on the watchlist")
+	default:
+		dependencies.Logger.Error().Err(err).Msg("Watchlist update failed")
+		respondError(w, http.StatusInternalServerError, "Failed to update the watchlist")
+	}
+}
+
+func handleWatchlist(w http.ResponseWriter, r *http.Request) {
+	watcher := dependencies.Watchlist
+
+	response := WatchlistResponse{
+		Directory:  watcher.Directory(),
+		Interval:   watcher.Interval().String(),
+		QuietHours: watcher.QuietHours().String(),
+		Items:      []WatchlistItem{},
+	}
+	for _, item := range watcher.Store().List() {
+		response.Items = append(response.Items, newWatchlistItem(item))
+	}
+
+	respondSuccess(w, response)
+}
+
+func handleWatchlistAdd(w http.ResponseWriter, r *http.Request) {
+	var req WatchlistAddRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := validateAppIDOrBundleID(appIDString(req.AppID), req.BundleID); err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := validateKeepVersions(req.KeepVersions); err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	accountInfo, ok := getAccountInfo(r)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	// The lookup fills in the bundle identifier and name that downloads and events are labeled with.
+	lookup, err := dependencies.AppStore.Lookup(appstore.LookupInput{
+		Account:  accountInfo.Account,
+		AppID:    req.AppID,
+		BundleID: req.BundleID,
+	})
+	if err != nil {
+		statusCode, message := mapAppStoreErrorToHTTPStatus(err)
+		respondError(w, statusCode, message)
+		return
+	}
+
+	item := watchlist.Item{
+		AppID:        lookup.App.ID,
+		BundleID:     lookup.App.BundleID,
+		Name:         lookup.App.Name,
+		KeepVersions: req.KeepVersions,
+		AddedAt:      time.Now(),
+	}
+	if err := dependencies.Watchlist.Store().Add(item); err != nil {
+		respondWatchlistError(w, err)
+		return
+	}
+
+	// Download the current version right away instead of waiting for the next scheduled check.
+	dependencies.Watchlist.Trigger()
+
+	respondJSON(w, http.StatusCreated, newWatchlistItem(item))
+}
+
+func handleWatchlistGet(w http.ResponseWriter, r *http.Request) {
+	appID, err := watchlistAppID(r)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	item, err := dependencies.Watchlist.Store().Get(appID)
+	if err != nil {
+		respondWatchlistError(w, err)
+		return
+	}
+
+	respondSuccess(w, newWatchlistItem(item))
+}
+
+func handleWatchlistUpdate(w http.ResponseWriter, r *http.Request) {
+	appID, err := watchlistAppID(r)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var req WatchlistUpdateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := validateKeepVersions(req.KeepVersions); err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Versions beyond the new limit are removed on the next new version.
+	item, err := dependencies.Watchlist.Store().Update(appID, func(item *watchlist.Item) {
+		item.KeepVersions = req.KeepVersions
+	})
+	if err != nil {
+		respondWatchlistError(w, err)
+		return
+	}
+
+	respondSuccess(w, newWatchlistItem(item))
+}
+
+func handleWatchlistRemove(w http.ResponseWriter, r *http.Request) {
+	appID, err := watchlistAppID(r)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	deleteFiles := r.URL.Query().Get("delete_files") == "true"
+
+	item, err := dependencies.Watchlist.Store().Remove(appID)
+	if err != nil {
+		respondWatchlistError(w, err)
+		return
+	}
+
+	if deleteFiles {
+		for _, download := range item.Downloads {
+			for _, path := range []string{download.Path, download.Path + util.ChecksumSidecarSuffix} {
+				if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+					dependencies.Logger.Error().Err(err).Str("path", path).Msg("Failed to remove watchlist download")
+				}
+			}
+		}
+	}
+
+	respondSuccess(w, newWatchlistItem(item))
+}
+
+func handleWatchlistCheck(w http.ResponseWriter, r *http.Request) {
+	dependencies.Watchlist.Trigger()
+
+	respondJSON(w, http.StatusAccepted, WatchlistCheckResponse{Success: true, Message: "Watchlist check started"})
+}
+
+func handleWatchlistEvents(w http.ResponseWriter, r *http.Request) {
+	response := WatchlistEventsResponse{Events: []WatchlistEvent{}}
+	for _, event := range dependencies.Watchlist.Events() {
+		response.Events = append(response.Events, newWatchlistEvent(event))
+	}
+
+	respondSuccess(w, response)
+}
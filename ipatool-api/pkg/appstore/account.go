@@ -7,4 +7,5 @@ type Account struct {
 	Name                string `json:"name,omitempty"`
 	StoreFront          string `json:"storeFront,omitempty"`
 	Password            string `json:"password,omitempty"`
+	Proxy               string `json:"proxy,omitempty"`
 }
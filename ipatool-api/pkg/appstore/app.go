@@ -1,6 +1,8 @@
 package appstore
 
 import (
+	"strconv"
+
 	"github.com/rs/zerolog"
 )
 
@@ -13,6 +15,35 @@ type App struct {
 	ArtworkURL512 string  `json:"artworkUrl512,omitempty"`
 	ArtworkURL100 string  `json:"artworkUrl100,omitempty"`
 	ArtworkURL60  string  `json:"artworkUrl60,omitempty"`
+
+	// FileSizeBytes is the size of the latest version. Apple returns it as a string.
+	FileSizeBytes         string   `json:"fileSizeBytes,omitempty"`
+	MinimumOSVersion      string   `json:"minimumOsVersion,omitempty"`
+	SupportedDevices      []string `json:"supportedDevices,omitempty"`
+	SellerName            string   `json:"sellerName,omitempty"`
+	ArtistID              int64    `json:"artistId,omitempty"`
+	ArtistName            string   `json:"artistName,omitempty"`
+	PrimaryGenreName      string   `json:"primaryGenreName,omitempty"`
+	AverageUserRating     float64  `json:"averageUserRating,omitempty"`
+	UserRatingCount       int64    `json:"userRatingCount,omitempty"`
+	ContentAdvisoryRating string   `json:"contentAdvisoryRating,omitempty"`
+	Currency              string   `json:"currency,omitempty"`
+	FormattedPrice        string   `json:"formattedPrice,omitempty"`
+	ReleaseNotes          string   `json:"releaseNotes,omitempty"`
+	// CurrentVersionReleaseDate is an RFC 3339 timestamp, kept as a string so that odd values do not fail decoding.
+	CurrentVersionReleaseDate string   `json:"currentVersionReleaseDate,omitempty"`
+	ScreenshotURLs            []string `json:"screenshotUrls,omitempty"`
+	IPadScreenshotURLs        []string `json:"ipadScreenshotUrls,omitempty"`
+}
+
+// FileSize returns the size of the latest version in bytes, or 0 if unknown.
+func (a App) FileSize() int64 {
+	size, err := strconv.ParseInt(a.FileSizeBytes, 10, 64)
+	if err != nil {
+		return 0
+	}
+
+	return size
 }
 
 type VersionHistoryInfo struct {
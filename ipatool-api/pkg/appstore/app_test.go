@@ -10,6 +10,33 @@ import (
 )
 
 var _ = Describe("App", func() {
+	It("decodes iTunes results", func() {
+		var app App
+		err := json.Unmarshal([]byte(`{
+			"trackId": 42,
+			"fileSizeBytes": "1048576",
+			"minimumOsVersion": "15.0",
+			"supportedDevices": ["iPhone15-iPhone15"],
+			"sellerName": "Seller",
+			"artistId": 7,
+			"primaryGenreName": "Games",
+			"averageUserRating": 4.5,
+			"contentAdvisoryRating": "4+",
+			"currency": "USD",
+			"formattedPrice": "Free",
+			"releaseNotes": "Bug fixes",
+			"currentVersionReleaseDate": "2024-06-15T07:00:00Z",
+			"screenshotUrls": ["https://example.com/1.png"]
+		}`), &app)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(app.FileSize()).To(Equal(int64(1048576)))
+		Expect(app.SupportedDevices).To(ConsistOf("iPhone15-iPhone15"))
+		Expect(app.ArtistID).To(Equal(int64(7)))
+		Expect(app.AverageUserRating).To(Equal(4.5))
+		Expect(app.ScreenshotURLs).To(HaveLen(1))
+		Expect(App{}.FileSize()).To(BeZero())
+	})
+
 	It("marshals apps array", func() {
 		apps := Apps{
 			{
@@ -1,8 +1,12 @@
 package appstore
 
 import (
+	"net/url"
+	"time"
+
 	"github.com/majd/ipatool/v2/pkg/http"
 	"github.com/majd/ipatool/v2/pkg/keychain"
+	"github.com/majd/ipatool/v2/pkg/log"
 	"github.com/majd/ipatool/v2/pkg/util/machine"
 	"github.com/majd/ipatool/v2/pkg/util/operatingsystem"
 )
@@ -14,8 +18,12 @@ type AppStore interface {
 	AccountInfo() (AccountInfoOutput, error)
 	// Revoke revokes the active credentials.
 	Revoke() error
-	// Lookup looks apps up based on the specified bundle identifier.
+	// Lookup looks apps up based on the specified bundle identifier or app ID.
 	Lookup(input LookupInput) (LookupOutput, error)
+	// Availability reports in which storefronts the specified app is sold.
+	Availability(input AvailabilityInput) (AvailabilityOutput, error)
+	// CheckLicenses reports whether the account owns licenses for the specified apps.
+	CheckLicenses(input CheckLicensesInput) (CheckLicensesOutput, error)
 	// Search searches the App Store for apps matching the specified term.
 	Search(input SearchInput) (SearchOutput, error)
 	// Purchase acquires a license for the desired app.
@@ -23,12 +31,22 @@ type AppStore interface {
 	Purchase(input PurchaseInput) error
 	// Download downloads the IPA package from the App Store to the desired location.
 	Download(input DownloadInput) (DownloadOutput, error)
+	// DownloadStream prepares the IPA package to be written to a stream while it is downloaded.
+	DownloadStream(input DownloadStreamInput) (DownloadStreamOutput, error)
 	// ReplicateSinf replicates the sinf for the IPA package.
 	ReplicateSinf(input ReplicateSinfInput) error
+	// GetSinfs returns the sinfs and metadata of the specified version without downloading its package.
+	GetSinfs(input GetSinfsInput) (GetSinfsOutput, error)
+	// VerifySinf checks that the sinfs of the IPA package are in place.
+	VerifySinf(input VerifySinfInput) (VerifySinfOutput, error)
 	// VersionHistory lists the available versions of the specified app.
 	ListVersions(input ListVersionsInput) (ListVersionsOutput, error)
 	// GetVersionMetadata returns the metadata for the specified version.
 	GetVersionMetadata(input GetVersionMetadataInput) (GetVersionMetadataOutput, error)
+	// VersionHistory lists the versions of the specified app newest first, with their metadata.
+	VersionHistory(input VersionHistoryInput) (VersionHistoryOutput, error)
+	// ResolveVersion finds the external version identifier of the version matching a selector.
+	ResolveVersion(input ResolveVersionInput) (ResolveVersionOutput, error)
 }
 
 type appstore struct {
@@ -40,6 +58,18 @@ type appstore struct {
 	httpClient     http.Client[interface{}]
 	machine        machine.Machine
 	os             operatingsystem.OperatingSystem
+	deviceGUID     string
+	retryPolicy    http.RetryPolicy
+	endpoints      Endpoints
+	metadataCache  MetadataCache
+	logger         log.Logger
+
+	downloadConnections  int
+	downloadChunkSize    int64
+	downloadStallTimeout time.Duration
+
+	availabilityCache *ttlCache[CountryAvailability]
+	licenseCache      *ttlCache[LicenseStatus]
 }
 
 type Args struct {
@@ -47,11 +77,33 @@ type Args struct {
 	CookieJar       http.CookieJar
 	OperatingSystem operatingsystem.OperatingSystem
 	Machine         machine.Machine
+	// DeviceGUID is the GUID reported to Apple. Requests that identify the device fail without it.
+	DeviceGUID string
+	// Proxy is the default proxy for accounts that do not configure their own.
+	Proxy *url.URL
+	// Retry is applied to idempotent App Store requests and CDN transfers.
+	Retry http.RetryPolicy
+	// Endpoints overrides the App Store base URLs. Empty fields use Apple's production hosts.
+	Endpoints Endpoints
+	// MetadataCache persists version metadata across restarts. Nil disables it.
+	MetadataCache MetadataCache
+	// Logger logs versions that are skipped while resolving a version. Nil disables it.
+	Logger log.Logger
+	// DownloadConnections is the number of concurrent range requests of a CDN transfer. Zero or one
+	// downloads the file with a single request.
+	DownloadConnections int
+	// DownloadChunkSize is the size of the ranges requested concurrently. Zero uses DefaultDownloadChunkSize.
+	DownloadChunkSize int64
+	// DownloadStallTimeout is how long a CDN transfer may go without receiving data before it is resumed
+	// with a fresh download URL. Zero uses DefaultDownloadStallTimeout.
+	DownloadStallTimeout time.Duration
 }
 
 func NewAppStore(args Args) AppStore {
 	clientArgs := http.Args{
 		CookieJar: args.CookieJar,
+		Proxy:     args.Proxy,
+		Retry:     args.Retry,
 	}
 
 	return &appstore{
@@ -63,5 +115,17 @@ func NewAppStore(args Args) AppStore {
 		httpClient:     http.NewClient[interface{}](clientArgs),
 		machine:        args.Machine,
 		os:             args.OperatingSystem,
+		deviceGUID:     args.DeviceGUID,
+		retryPolicy:    args.Retry,
+		endpoints:      args.Endpoints,
+		metadataCache:  args.MetadataCache,
+		logger:         args.Logger,
+
+		downloadConnections:  args.DownloadConnections,
+		downloadChunkSize:    args.DownloadChunkSize,
+		downloadStallTimeout: args.DownloadStallTimeout,
+
+		availabilityCache: newTTLCache[CountryAvailability](availabilityCacheTTL),
+		licenseCache:      newTTLCache[LicenseStatus](licenseCacheTTL),
 	}
 }
@@ -0,0 +1,148 @@
+package appstore
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultAvailabilityConcurrency is the number of storefronts looked up in parallel.
+	DefaultAvailabilityConcurrency = 8
+	// availabilityCacheTTL is how long the lookup result for a storefront is reused.
+	availabilityCacheTTL = time.Hour
+)
+
+type AvailabilityInput struct {
+	Account  Account
+	BundleID string
+	AppID    int64
+	// CountryCodes limits the check to these storefronts. Empty means all storefronts.
+	CountryCodes []string
+	// Concurrency bounds the number of parallel lookups. Zero means DefaultAvailabilityConcurrency.
+	Concurrency int
+}
+
+// CountryAvailability is the result of looking the app up in a single storefront.
+type CountryAvailability struct {
+	Country   Country
+	Available bool
+	// App is the app as sold in this storefront. Only set if available.
+	App App
+	// Err is set if the lookup failed, in which case availability is unknown.
+	Err error
+}
+
+type AvailabilityOutput struct {
+	// App is the app as sold in the first storefront it is available in.
+	App       App
+	Countries []CountryAvailability
+}
+
+// Availability looks the app up in every storefront, so that an app that does not exist can be told apart
+// from one that is not sold in the account's country. Results are cached per storefront for an hour.
+func (t *appstore) Availability(input AvailabilityInput) (AvailabilityOutput, error) {
+	countries, err := availabilityCountries(input.CountryCodes)
+	if err != nil {
+		return AvailabilityOutput{}, err
+	}
+
+	concurrency := input.Concurrency
+	if concurrency <= 0 {
+		concurrency = DefaultAvailabilityConcurrency
+	}
+
+	results := make([]CountryAvailability, len(countries))
+	semaphore := make(chan struct{}, concurrency)
+
+	var wg sync.WaitGroup
+
+	for i, country := range countries {
+		wg.Add(1)
+
+		go func(i int, country Country) {
+			defer wg.Done()
+
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+
+			results[i] = t.countryAvailability(input, country)
+		}(i, country)
+	}
+
+	wg.Wait()
+
+	output := AvailabilityOutput{Countries: results}
+
+	for _, result := range results {
+		if result.Available {
+			output.App = result.App
+
+			break
+		}
+	}
+
+	return output, nil
+}
+
+func (t *appstore) countryAvailability(input AvailabilityInput, country Country) CountryAvailability {
+	key := availabilityCacheKey(input.BundleID, input.AppID, country.Code)
+
+	if cached, ok := t.availabilityCache.get(key); ok {
+		return cached
+	}
+
+	result := CountryAvailability{Country: country}
+
+	output, err := t.Lookup(LookupInput{
+		Account:     input.Account,
+		BundleID:    input.BundleID,
+		AppID:       input.AppID,
+		CountryCode: country.Code,
+	})
+
+	switch {
+	case err == nil:
+		result.Available = true
+		result.App = output.App
+	case errors.Is(err, ErrAppNotFound):
+		result.Available = false
+	default:
+		// Failed lookups are not cached, so that they are retried on the next check.
+		result.Err = err
+
+		return result
+	}
+
+	t.availabilityCache.set(key, result)
+
+	return result
+}
+
+func availabilityCountries(codes []string) ([]Country, error) {
+	if len(codes) == 0 {
+		return Countries(), nil
+	}
+
+	countries := make([]Country, 0, len(codes))
+
+	for _, code := range codes {
+		country, ok := CountryByCode(code)
+		if !ok {
+			return nil, errors.New("invalid country code: " + code)
+		}
+
+		countries = append(countries, country)
+	}
+
+	return countries, nil
+}
+
+func availabilityCacheKey(bundleID string, appID int64, countryCode string) string {
+	if appID != 0 {
+		return strconv.FormatInt(appID, 10) + "/" + countryCode
+	}
+
+	return bundleID + "/" + countryCode
+}
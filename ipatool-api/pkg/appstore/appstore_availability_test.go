@@ -0,0 +1,114 @@
+package appstore
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/majd/ipatool/v2/pkg/http"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"go.uber.org/mock/gomock"
+)
+
+var _ = Describe("AppStore (Availability)", func() {
+	var (
+		ctrl       *gomock.Controller
+		mockClient *http.MockClient[searchResult]
+		as         AppStore
+		acc        = Account{StoreFront: "143441-1,29"}
+		testApp    = App{ID: 1, BundleID: "app.bundle.id", Version: "1.0"}
+	)
+
+	BeforeEach(func() {
+		ctrl = gomock.NewController(GinkgoT())
+		mockClient = http.NewMockClient[searchResult](ctrl)
+		as = &appstore{
+			searchClient:      mockClient,
+			availabilityCache: newTTLCache[CountryAvailability](availabilityCacheTTL),
+		}
+	})
+
+	AfterEach(func() {
+		ctrl.Finish()
+	})
+
+	// respond returns the app only for lookups in the specified country.
+	respond := func(country string) func(http.Request) (http.Result[searchResult], error) {
+		return func(req http.Request) (http.Result[searchResult], error) {
+			if strings.Contains(req.URL, "country="+country) {
+				return http.Result[searchResult]{StatusCode: 200, Data: searchResult{Count: 1, Results: []App{testApp}}}, nil
+			}
+
+			return http.Result[searchResult]{StatusCode: 200, Data: searchResult{}}, nil
+		}
+	}
+
+	It("reports the storefronts the app is sold in", func() {
+		mockClient.EXPECT().
+			Send(gomock.Any()).
+			DoAndReturn(respond("GB")).
+			Times(3)
+
+		out, err := as.Availability(AvailabilityInput{Account: acc, BundleID: testApp.BundleID, CountryCodes: []string{"US", "GB", "de"}})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(out.App).To(Equal(testApp))
+		Expect(out.Countries).To(HaveLen(3))
+		Expect(out.Countries[0].Available).To(BeFalse())
+		Expect(out.Countries[1].Available).To(BeTrue())
+		Expect(out.Countries[1].Country.Currency).To(Equal("GBP"))
+		Expect(out.Countries[2].Country.Code).To(Equal("DE"))
+	})
+
+	It("looks apps up by ID", func() {
+		mockClient.EXPECT().
+			Send(gomock.Any()).
+			DoAndReturn(func(req http.Request) (http.Result[searchResult], error) {
+				Expect(req.URL).To(ContainSubstring("id=1"))
+				Expect(req.URL).ToNot(ContainSubstring("bundleId"))
+
+				return http.Result[searchResult]{StatusCode: 200, Data: searchResult{}}, nil
+			})
+
+		_, err := as.Availability(AvailabilityInput{Account: acc, AppID: 1, CountryCodes: []string{"US"}})
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	It("caches results per storefront", func() {
+		mockClient.EXPECT().
+			Send(gomock.Any()).
+			DoAndReturn(respond("US")).
+			Times(2)
+
+		input := AvailabilityInput{Account: acc, BundleID: testApp.BundleID, CountryCodes: []string{"US", "GB"}}
+
+		_, err := as.Availability(input)
+		Expect(err).ToNot(HaveOccurred())
+
+		out, err := as.Availability(input)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(out.Countries[0].Available).To(BeTrue())
+		Expect(out.Countries[1].Available).To(BeFalse())
+	})
+
+	It("does not cache failed lookups", func() {
+		mockClient.EXPECT().
+			Send(gomock.Any()).
+			Return(http.Result[searchResult]{}, errors.New("network error")).
+			Times(2)
+
+		input := AvailabilityInput{Account: acc, BundleID: testApp.BundleID, CountryCodes: []string{"US"}}
+
+		out, err := as.Availability(input)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(out.Countries[0].Err).To(HaveOccurred())
+		Expect(out.Countries[0].Available).To(BeFalse())
+
+		_, err = as.Availability(input)
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	It("rejects unknown countries", func() {
+		_, err := as.Availability(AvailabilityInput{Account: acc, BundleID: testApp.BundleID, CountryCodes: []string{"XX"}})
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,122 @@
+package appstore
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultCheckLicensesConcurrency is the number of apps checked in parallel.
+	DefaultCheckLicensesConcurrency = 4
+	// licenseCacheTTL is how long the license status of an app is reused. Kept short because
+	// licenses can be acquired outside of this tool.
+	licenseCacheTTL = 5 * time.Minute
+)
+
+// LicenseStatus describes whether an account owns a license for an app.
+type LicenseStatus string
+
+const (
+	LicenseStatusOwned    LicenseStatus = "owned"
+	LicenseStatusNotOwned LicenseStatus = "not_owned"
+	LicenseStatusUnknown  LicenseStatus = "unknown"
+)
+
+type CheckLicensesInput struct {
+	Account Account
+	// Apps are the apps to check. Apps without an ID are looked up by bundle identifier first.
+	Apps []App
+	// Concurrency bounds the number of parallel checks. Zero means DefaultCheckLicensesConcurrency.
+	Concurrency int
+}
+
+// LicenseCheck is the result of checking the license for a single app.
+type LicenseCheck struct {
+	App    App
+	Status LicenseStatus
+	// Err is set if the check failed, in which case the status is unknown.
+	Err error
+}
+
+type CheckLicensesOutput struct {
+	// Licenses are in the same order as the apps in the input.
+	Licenses []LicenseCheck
+}
+
+// CheckLicenses asks the App Store for the versions of each app, which only succeeds if the account owns
+// a license for it. Known statuses are cached per account and app for a few minutes.
+func (t *appstore) CheckLicenses(input CheckLicensesInput) (CheckLicensesOutput, error) {
+	concurrency := input.Concurrency
+	if concurrency <= 0 {
+		concurrency = DefaultCheckLicensesConcurrency
+	}
+
+	results := make([]LicenseCheck, len(input.Apps))
+	semaphore := make(chan struct{}, concurrency)
+
+	var wg sync.WaitGroup
+
+	for i, app := range input.Apps {
+		wg.Add(1)
+
+		go func(i int, app App) {
+			defer wg.Done()
+
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+
+			results[i] = t.checkLicense(input.Account, app)
+		}(i, app)
+	}
+
+	wg.Wait()
+
+	return CheckLicensesOutput{Licenses: results}, nil
+}
+
+func (t *appstore) checkLicense(acc Account, app App) LicenseCheck {
+	result := LicenseCheck{App: app, Status: LicenseStatusUnknown}
+
+	if app.ID == 0 {
+		output, err := t.Lookup(LookupInput{Account: acc, BundleID: app.BundleID})
+		if err != nil {
+			result.Err = err
+
+			return result
+		}
+
+		result.App = output.App
+	}
+
+	key := licenseCacheKey(acc, result.App.ID)
+
+	if status, ok := t.licenseCache.get(key); ok {
+		result.Status = status
+
+		return result
+	}
+
+	_, err := t.ListVersions(ListVersionsInput{Account: acc, App: result.App})
+
+	switch {
+	case err == nil:
+		result.Status = LicenseStatusOwned
+	case errors.Is(err, ErrLicenseRequired):
+		result.Status = LicenseStatusNotOwned
+	default:
+		// Failed checks are not cached, so that they are retried on the next check.
+		result.Err = err
+
+		return result
+	}
+
+	t.licenseCache.set(key, result.Status)
+
+	return result
+}
+
+func licenseCacheKey(acc Account, appID int64) string {
+	return acc.DirectoryServicesID + "/" + strconv.FormatInt(appID, 10)
+}
@@ -0,0 +1,142 @@
+package appstore
+
+import (
+	"errors"
+
+	"github.com/majd/ipatool/v2/pkg/http"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"go.uber.org/mock/gomock"
+)
+
+var _ = Describe("AppStore (CheckLicenses)", func() {
+	var (
+		ctrl               *gomock.Controller
+		mockDownloadClient *http.MockClient[downloadResult]
+		mockSearchClient   *http.MockClient[searchResult]
+		as                 AppStore
+		acc                = Account{DirectoryServicesID: "1", StoreFront: "143441-1,29"}
+		ownedApp           = App{ID: 1, BundleID: "owned.bundle.id"}
+		notOwnedApp        = App{ID: 2, BundleID: "not.owned.bundle.id"}
+	)
+
+	BeforeEach(func() {
+		ctrl = gomock.NewController(GinkgoT())
+		mockDownloadClient = http.NewMockClient[downloadResult](ctrl)
+		mockSearchClient = http.NewMockClient[searchResult](ctrl)
+		as = &appstore{
+			downloadClient: mockDownloadClient,
+			searchClient:   mockSearchClient,
+			deviceGUID:     "GUID",
+			licenseCache:   newTTLCache[LicenseStatus](licenseCacheTTL),
+		}
+	})
+
+	AfterEach(func() {
+		ctrl.Finish()
+	})
+
+	// respond grants access to the versions of the owned app only.
+	respond := func(req http.Request) (http.Result[downloadResult], error) {
+		payload, _ := req.Payload.(*http.XMLPayload)
+		if payload.Content["salableAdamId"] != ownedApp.ID {
+			return http.Result[downloadResult]{Data: downloadResult{FailureType: FailureTypeLicenseNotFound}}, nil
+		}
+
+		return http.Result[downloadResult]{
+			Data: downloadResult{
+				Items: []downloadItemResult{
+					{
+						Metadata: map[string]interface{}{
+							"softwareVersionExternalIdentifiers": []interface{}{"1"},
+							"softwareVersionExternalIdentifier":  "1",
+						},
+					},
+				},
+			},
+		}, nil
+	}
+
+	It("reports the license status of each app", func() {
+		mockDownloadClient.EXPECT().
+			Send(gomock.Any()).
+			DoAndReturn(respond).
+			Times(2)
+
+		out, err := as.CheckLicenses(CheckLicensesInput{Account: acc, Apps: []App{ownedApp, notOwnedApp}})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(out.Licenses).To(HaveLen(2))
+		Expect(out.Licenses[0].Status).To(Equal(LicenseStatusOwned))
+		Expect(out.Licenses[1].Status).To(Equal(LicenseStatusNotOwned))
+		Expect(out.Licenses[1].Err).ToNot(HaveOccurred())
+	})
+
+	It("looks apps without an ID up by bundle identifier", func() {
+		mockSearchClient.EXPECT().
+			Send(gomock.Any()).
+			DoAndReturn(func(req http.Request) (http.Result[searchResult], error) {
+				Expect(req.URL).To(ContainSubstring("bundleId=owned.bundle.id"))
+
+				return http.Result[searchResult]{StatusCode: 200, Data: searchResult{Count: 1, Results: []App{ownedApp}}}, nil
+			})
+
+		mockDownloadClient.EXPECT().
+			Send(gomock.Any()).
+			DoAndReturn(respond)
+
+		out, err := as.CheckLicenses(CheckLicensesInput{Account: acc, Apps: []App{{BundleID: ownedApp.BundleID}}})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(out.Licenses[0].App).To(Equal(ownedApp))
+		Expect(out.Licenses[0].Status).To(Equal(LicenseStatusOwned))
+	})
+
+	It("reports unknown for apps that cannot be found", func() {
+		mockSearchClient.EXPECT().
+			Send(gomock.Any()).
+			Return(http.Result[searchResult]{StatusCode: 200, Data: searchResult{}}, nil)
+
+		out, err := as.CheckLicenses(CheckLicensesInput{Account: acc, Apps: []App{{BundleID: "missing.bundle.id"}}})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(out.Licenses[0].Status).To(Equal(LicenseStatusUnknown))
+		Expect(out.Licenses[0].Err).To(MatchError(ErrAppNotFound))
+	})
+
+	It("caches known statuses but not failures", func() {
+		mockDownloadClient.EXPECT().
+			Send(gomock.Any()).
+			Return(http.Result[downloadResult]{}, errors.New("connection reset"))
+
+		mockDownloadClient.EXPECT().
+			Send(gomock.Any()).
+			DoAndReturn(respond).
+			Times(1)
+
+		input := CheckLicensesInput{Account: acc, Apps: []App{notOwnedApp}}
+
+		out, err := as.CheckLicenses(input)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(out.Licenses[0].Status).To(Equal(LicenseStatusUnknown))
+		Expect(out.Licenses[0].Err).To(MatchError(ContainSubstring("connection reset")))
+
+		for range 2 {
+			out, err = as.CheckLicenses(input)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(out.Licenses[0].Status).To(Equal(LicenseStatusNotOwned))
+		}
+	})
+
+	It("does not share cached statuses between accounts", func() {
+		mockDownloadClient.EXPECT().
+			Send(gomock.Any()).
+			DoAndReturn(respond).
+			Times(2)
+
+		other := Account{DirectoryServicesID: "2", StoreFront: acc.StoreFront}
+
+		for _, account := range []Account{acc, other} {
+			out, err := as.CheckLicenses(CheckLicensesInput{Account: account, Apps: []App{ownedApp}})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(out.Licenses[0].Status).To(Equal(LicenseStatusOwned))
+		}
+	})
+})
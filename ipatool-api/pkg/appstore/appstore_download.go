@@ -2,72 +2,79 @@ package appstore
 
 import (
 	"archive/zip"
+	"crypto/md5"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
+	gohttp "net/http"
 	"os"
 	"strconv"
 	"strings"
 
 	"github.com/majd/ipatool/v2/pkg/http"
 	"github.com/schollz/progressbar/v3"
-	"howett.net/plist"
 )
 
+// downloadIntegrityAttempts is the number of times a package is downloaded before a checksum mismatch is reported.
+const downloadIntegrityAttempts = 3
+
 var (
-	ErrLicenseRequired = errors.New("license is required")
+	ErrLicenseRequired      = errors.New("license is required")
+	ErrIntegrityCheckFailed = errors.New("downloaded file does not match the checksum of the store")
 )
 
+// IntegrityError is returned when the downloaded package still does not match the MD5 checksum sent by the
+// store after all attempts. It matches ErrIntegrityCheckFailed.
+type IntegrityError struct {
+	Expected string
+	Actual   string
+	Attempts int
+}
+
+func (e *IntegrityError) Error() string {
+	return fmt.Sprintf("%s after %d attempts (expected md5 %s, got %s)", ErrIntegrityCheckFailed, e.Attempts, e.Expected, e.Actual)
+}
+
+func (e *IntegrityError) Is(target error) bool {
+	return target == ErrIntegrityCheckFailed
+}
+
 type DownloadInput struct {
 	Account           Account
 	App               App
 	OutputPath        string
 	Progress          *progressbar.ProgressBar
 	ExternalVersionID string
+	// Version selects the version to download if ExternalVersionID is empty. Zero means the latest version.
+	Version VersionSelector
 }
 
 type DownloadOutput struct {
 	DestinationPath string
 	Sinfs           []Sinf
+	// Metadata is the content of iTunesMetadata.plist.
+	Metadata map[string]interface{}
+	// ExternalVersionID is the requested or resolved version. Empty if the latest version was downloaded.
+	ExternalVersionID string
 }
 
 func (t *appstore) Download(input DownloadInput) (DownloadOutput, error) {
-	macAddr, err := t.machine.MacAddress()
+	guid, err := t.guid()
 	if err != nil {
-		return DownloadOutput{}, fmt.Errorf("failed to get mac address: %w", err)
+		return DownloadOutput{}, err
 	}
 
-	guid := strings.ReplaceAll(strings.ToUpper(macAddr), ":", "")
-
-	req := t.downloadRequest(input.Account, input.App, guid, input.ExternalVersionID)
-
-	res, err := t.downloadClient.Send(req)
+	input.ExternalVersionID, err = t.resolveDownloadVersion(input.Account, input.App, input.ExternalVersionID, input.Version)
 	if err != nil {
-		return DownloadOutput{}, fmt.Errorf("failed to send http request: %w", err)
+		return DownloadOutput{}, err
 	}
 
-	if res.Data.FailureType == FailureTypePasswordTokenExpired {
-		return DownloadOutput{}, ErrPasswordTokenExpired
-	}
-
-	if res.Data.FailureType == FailureTypeLicenseNotFound {
-		return DownloadOutput{}, ErrLicenseRequired
-	}
-
-	if res.Data.FailureType != "" && res.Data.CustomerMessage != "" {
-		return DownloadOutput{}, NewErrorWithMetadata(fmt.Errorf("received error: %s", res.Data.CustomerMessage), res)
-	}
-
-	if res.Data.FailureType != "" {
-		return DownloadOutput{}, NewErrorWithMetadata(fmt.Errorf("received error: %s", res.Data.FailureType), res)
-	}
-
-	if len(res.Data.Items) == 0 {
-		return DownloadOutput{}, NewErrorWithMetadata(errors.New("invalid response"), res)
+	item, err := t.requestDownloadItem(input.Account, input.App, guid, input.ExternalVersionID)
+	if err != nil {
+		return DownloadOutput{}, err
 	}
 
-	item := res.Data.Items[0]
-
 	version := "unknown"
 
 	// Read the version from the item metadata
@@ -80,27 +87,78 @@ func (t *appstore) Download(input DownloadInput) (DownloadOutput, error) {
 		return DownloadOutput{}, fmt.Errorf("failed to resolve destination path: %w", err)
 	}
 
-	err = t.downloadFile(item.URL, fmt.Sprintf("%s.tmp", destination), input.Progress)
+	source := t.newDownloadSource(item, input.Account, input.App, guid, input.ExternalVersionID)
+
+	err = t.downloadVerifiedFile(source, item.HashMD5, fmt.Sprintf("%s.tmp", destination), input.Account.Proxy, input.Progress)
 	if err != nil {
 		return DownloadOutput{}, fmt.Errorf("failed to download file: %w", err)
 	}
 
-	err = t.applyPatches(item, input.Account, fmt.Sprintf("%s.tmp", destination), destination)
+	err = t.applyPatches(item, input.Account, fmt.Sprintf("%s.tmp", destination))
 	if err != nil {
 		return DownloadOutput{}, fmt.Errorf("failed to apply patches: %w", err)
 	}
 
-	err = t.os.Remove(fmt.Sprintf("%s.tmp", destination))
+	err = t.os.Rename(fmt.Sprintf("%s.tmp", destination), destination)
 	if err != nil {
-		return DownloadOutput{}, fmt.Errorf("failed to remove file: %w", err)
+		return DownloadOutput{}, fmt.Errorf("failed to move file: %w", err)
 	}
 
 	return DownloadOutput{
-		DestinationPath: destination,
-		Sinfs:           item.Sinfs,
+		DestinationPath:   destination,
+		Sinfs:             item.Sinfs,
+		Metadata:          item.Metadata,
+		ExternalVersionID: input.ExternalVersionID,
 	}, nil
 }
 
+// resolveDownloadVersion returns the external version identifier to download: the one requested, or the one
+// matching the selector.
+func (t *appstore) resolveDownloadVersion(acc Account, app App, externalVersionID string, selector VersionSelector) (string, error) {
+	if externalVersionID != "" || selector.IsZero() {
+		return externalVersionID, nil
+	}
+
+	resolved, err := t.ResolveVersion(ResolveVersionInput{Account: acc, App: app, Selector: selector})
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve version: %w", err)
+	}
+
+	return resolved.ExternalVersionID, nil
+}
+
+// requestDownloadItem requests a download ticket, which holds the signed URL of the package on the CDN.
+func (t *appstore) requestDownloadItem(acc Account, app App, guid, externalVersionID string) (downloadItemResult, error) {
+	req := t.downloadRequest(acc, app, guid, externalVersionID)
+
+	res, err := t.downloadClient.Send(req)
+	if err != nil {
+		return downloadItemResult{}, fmt.Errorf("failed to send http request: %w", err)
+	}
+
+	if res.Data.FailureType == FailureTypePasswordTokenExpired {
+		return downloadItemResult{}, ErrPasswordTokenExpired
+	}
+
+	if res.Data.FailureType == FailureTypeLicenseNotFound {
+		return downloadItemResult{}, ErrLicenseRequired
+	}
+
+	if res.Data.FailureType != "" && res.Data.CustomerMessage != "" {
+		return downloadItemResult{}, NewErrorWithMetadata(fmt.Errorf("received error: %s", res.Data.CustomerMessage), res)
+	}
+
+	if res.Data.FailureType != "" {
+		return downloadItemResult{}, NewErrorWithMetadata(fmt.Errorf("received error: %s", res.Data.FailureType), res)
+	}
+
+	if len(res.Data.Items) == 0 {
+		return downloadItemResult{}, NewErrorWithMetadata(errors.New("invalid response"), res)
+	}
+
+	return res.Data.Items[0], nil
+}
+
 type downloadItemResult struct {
 	HashMD5  string                 `plist:"md5,omitempty"`
 	URL      string                 `plist:"URL,omitempty"`
@@ -114,12 +172,94 @@ type downloadResult struct {
 	Items           []downloadItemResult `plist:"songList,omitempty"`
 }
 
-func (t *appstore) downloadFile(src, dst string, progress *progressbar.ProgressBar) error {
+func (t *appstore) downloadFile(source *downloadSource, dst, proxy string, progress *progressbar.ProgressBar) error {
+	if t.downloadConnections > 1 {
+		err := t.downloadFileChunked(source, dst, proxy, progress)
+		if !errors.Is(err, ErrRangesNotSupported) {
+			return err
+		}
+	}
+
+	err := t.restoreInterruptedPatch(dst)
+	if err != nil {
+		return err
+	}
+
+	err = t.discardChunkGaps(dst)
+	if err != nil {
+		return err
+	}
+
+	// Every attempt resumes from the current size of the destination file.
+	return t.retryPolicy.Do("download file", func() error {
+		url := source.URL()
+
+		return source.retryable(url, t.downloadFileAttempt(url, dst, proxy, progress))
+	})
+}
+
+// downloadVerifiedFile downloads the package and compares it with the MD5 checksum sent by the store.
+// A corrupted file is discarded and downloaded again, since it cannot be told which part of it is wrong.
+// Items without a checksum are not verified.
+func (t *appstore) downloadVerifiedFile(source *downloadSource, hashMD5, dst, proxy string, progress *progressbar.ProgressBar) error {
+	for attempt := 1; ; attempt++ {
+		err := t.downloadFile(source, dst, proxy, progress)
+		if err != nil {
+			return err
+		}
+
+		if hashMD5 == "" {
+			return nil
+		}
+
+		actual, err := t.fileMD5(dst)
+		if err != nil {
+			return fmt.Errorf("failed to compute checksum: %w", err)
+		}
+
+		if strings.EqualFold(actual, hashMD5) {
+			return nil
+		}
+
+		err = t.os.Remove(dst)
+		if err != nil {
+			return fmt.Errorf("failed to remove corrupted file: %w", err)
+		}
+
+		if attempt >= downloadIntegrityAttempts {
+			return &IntegrityError{Expected: strings.ToLower(hashMD5), Actual: actual, Attempts: attempt}
+		}
+	}
+}
+
+func (t *appstore) fileMD5(path string) (string, error) {
+	file, err := t.os.OpenFile(path, os.O_RDONLY, 0)
+	if err != nil {
+		return "", fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	hash := md5.New()
+
+	_, err = io.Copy(hash, file)
+	if err != nil {
+		return "", fmt.Errorf("failed to read file: %w", err)
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func (t *appstore) downloadFileAttempt(src, dst, proxy string, progress *progressbar.ProgressBar) error {
 	req, err := t.httpClient.NewRequest("GET", src, nil)
 	if err != nil {
 		return fmt.Errorf("failed to create request: %w", err)
 	}
 
+	req, err = http.WithProxy(req, proxy)
+	if err != nil {
+		return fmt.Errorf("failed to configure proxy: %w", err)
+	}
+
 	file, err := t.os.OpenFile(dst, os.O_CREATE|os.O_RDWR, 0644)
 	if err != nil {
 		return fmt.Errorf("failed to open file: %w", err)
@@ -132,44 +272,115 @@ func (t *appstore) downloadFile(src, dst string, progress *progressbar.ProgressB
 		return fmt.Errorf("failed to get file info: %w", err)
 	}
 
-	if req != nil && stat != nil {
-		req.Header.Add("range", fmt.Sprintf("bytes=%d-", stat.Size()))
+	var offset int64
+
+	if stat != nil {
+		offset = stat.Size()
+	}
+
+	if req != nil {
+		req.Header.Add("range", fmt.Sprintf("bytes=%d-", offset))
 	}
 
-	res, err := t.httpClient.Do(req)
+	// The watchdog context derives from the request context, which carries the proxy.
+	ctx, watchdog, stop := t.newStallWatchdog(req.Context())
+	defer stop()
+
+	res, err := t.httpClient.Do(req.WithContext(ctx))
 	if err != nil {
-		return fmt.Errorf("request failed: %w", err)
+		return http.NewTransientError(watchdog.Err(fmt.Errorf("request failed: %w", err)), 0)
 	}
 	defer res.Body.Close()
 
-	if progress != nil {
-		progress.ChangeMax64(res.ContentLength + stat.Size())
-		err = progress.Set64(stat.Size())
+	if http.IsTransientStatus(res.StatusCode) {
+		return http.NewTransientError(
+			fmt.Errorf("received status code %d", res.StatusCode),
+			http.ParseRetryAfter(res.Header.Get("Retry-After")),
+		)
+	}
+
+	// The file is already complete when the server rejects a range starting at its end. Any other rejected range
+	// means that the local file does not match the remote one.
+	if res.StatusCode == gohttp.StatusRequestedRangeNotSatisfiable {
+		size, err := parseContentRangeSize(res.Header.Get("Content-Range"))
+		if err != nil || size != offset {
+			return fmt.Errorf("range starting at %d rejected with content range %q", offset, res.Header.Get("Content-Range"))
+		}
+
+		return nil
+	}
 
+	if isExpiredStatus(res.StatusCode) {
+		return fmt.Errorf("%w: received status code %d", errDownloadURLExpired, res.StatusCode)
+	}
+
+	if res.StatusCode >= gohttp.StatusBadRequest {
+		return fmt.Errorf("received status code %d", res.StatusCode)
+	}
+
+	// Only a partial response continues the file. A server that ignores the range sends the whole package again.
+	if res.StatusCode != gohttp.StatusPartialContent && offset > 0 {
+		err = file.Truncate(0)
 		if err != nil {
-			return fmt.Errorf("can not set bar progress: %w", err)
+			return fmt.Errorf("can not truncate file: %w", err)
 		}
 
-		_, err = file.Seek(0, io.SeekEnd)
+		offset = 0
+	}
+
+	_, err = file.Seek(offset, io.SeekStart)
+	if err != nil {
+		return fmt.Errorf("can not seek file: %w", err)
+	}
+
+	var writer io.Writer = file
+
+	if progress != nil {
+		progress.ChangeMax64(res.ContentLength + offset)
+		err = progress.Set64(offset)
+
 		if err != nil {
-			return fmt.Errorf("can not seek file: %w", err)
+			return fmt.Errorf("can not set bar progress: %w", err)
 		}
 
-		_, err = io.Copy(io.MultiWriter(file, progress), res.Body)
-	} else {
-		_, err = io.Copy(file, res.Body)
+		writer = io.MultiWriter(file, progress)
 	}
 
+	body := &readErrorReader{reader: watchdog.Reader(res.Body)}
+
+	_, err = io.Copy(writer, body)
 	if err != nil {
+		if body.err != nil {
+			return http.NewTransientError(watchdog.Err(fmt.Errorf("failed to read response body: %w", err)), 0)
+		}
+
 		return fmt.Errorf("failed to write file: %w", err)
 	}
 
 	return nil
 }
 
-func (*appstore) downloadRequest(acc Account, app App, guid string, externalVersionID string) http.Request {
-	host := fmt.Sprintf("%s-%s", PrivateAppStoreAPIDomainPrefixWithoutAuthCode, PrivateAppStoreAPIDomain)
+// readErrorReader records read errors so that interrupted transfers can be told apart from local write errors.
+type readErrorReader struct {
+	reader io.Reader
+	err    error
+}
+
+func (r *readErrorReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	if err != nil && !errors.Is(err, io.EOF) {
+		r.err = err
+	}
 
+	return n, err //nolint:wrapcheck
+}
+
+// IsTransientFailure reports whether the store asked to try again later.
+func (r downloadResult) IsTransientFailure() bool {
+	return r.FailureType == FailureTypeTemporarilyUnavailable
+}
+
+func (t *appstore) downloadRequest(acc Account, app App, guid string, externalVersionID string) http.Request {
 	payload := map[string]interface{}{
 		"creditDisplay": "",
 		"guid":          guid,
@@ -181,7 +392,7 @@ func (*appstore) downloadRequest(acc Account, app App, guid string, externalVers
 	}
 
 	return http.Request{
-		URL:            fmt.Sprintf("https://%s%s?guid=%s", host, PrivateAppStoreAPIPathDownload, guid),
+		URL:            fmt.Sprintf("%s%s?guid=%s", t.endpoints.withDefaults().PrivateAppStoreDownloadAPI, PrivateAppStoreAPIPathDownload, guid),
 		Method:         http.MethodPOST,
 		ResponseFormat: http.ResponseFormatXML,
 		Headers: map[string]string{
@@ -192,6 +403,8 @@ func (*appstore) downloadRequest(acc Account, app App, guid string, externalVers
 		Payload: &http.XMLPayload{
 			Content: payload,
 		},
+		Proxy:      acc.Proxy,
+		Idempotent: true,
 	}
 }
 
@@ -250,70 +463,15 @@ func (t *appstore) isDirectory(path string) (bool, error) {
 	return info.IsDir(), nil
 }
 
-func (t *appstore) applyPatches(item downloadItemResult, acc Account, src, dst string) error {
-	srcZip, err := zip.OpenReader(src)
-	if err != nil {
-		return fmt.Errorf("failed to open zip reader: %w", err)
-	}
-	defer srcZip.Close()
-
-	dstFile, err := t.os.OpenFile(dst, os.O_CREATE|os.O_WRONLY, 0644)
+// applyPatches adds the sinfs and iTunesMetadata.plist to the downloaded package in place.
+func (t *appstore) applyPatches(item downloadItemResult, acc Account, path string) error {
+	file, err := t.os.OpenFile(path, os.O_RDWR, 0644)
 	if err != nil {
 		return fmt.Errorf("failed to open file: %w", err)
 	}
+	defer file.Close()
 
-	dstZip := zip.NewWriter(dstFile)
-	defer dstZip.Close()
-
-	err = t.replicateZip(srcZip, dstZip)
-	if err != nil {
-		return fmt.Errorf("failed to replicate zip: %w", err)
-	}
-
-	// Replicate SINF so the device can run the app (FairPlay DRM)
-	if len(item.Sinfs) > 0 {
-		bundleName, err := t.readBundleName(srcZip)
-		if err != nil {
-			return fmt.Errorf("failed to read bundle name for SINF: %w", err)
-		}
-		manifest, _ := t.readManifestPlist(srcZip)
-		info, _ := t.readInfoPlist(srcZip)
-		if manifest != nil {
-			err = t.replicateSinfFromManifest(*manifest, dstZip, item.Sinfs, bundleName)
-		} else if info != nil {
-			err = t.replicateSinfFromInfo(*info, dstZip, item.Sinfs, bundleName)
-		}
-		if err != nil {
-			return fmt.Errorf("failed to replicate sinf: %w", err)
-		}
-	}
-
-	err = t.writeMetadata(item.Metadata, acc, dstZip)
-	if err != nil {
-		return fmt.Errorf("failed to write metadata: %w", err)
-	}
-
-	return nil
-}
-
-func (t *appstore) writeMetadata(metadata map[string]interface{}, acc Account, zip *zip.Writer) error {
-	metadata["apple-id"] = acc.Email
-	metadata["userName"] = acc.Email
-
-	metadataFile, err := zip.Create("iTunesMetadata.plist")
-	if err != nil {
-		return fmt.Errorf("failed to create file: %w", err)
-	}
-
-	data, err := plist.Marshal(metadata, plist.BinaryFormat)
-	if err != nil {
-		return fmt.Errorf("failed to marshal data: %w", err)
-	}
-
-	_, err = metadataFile.Write(data)
-	if err != nil {
-		return fmt.Errorf("failed to write data: %w", err)
-	}
-
-	return nil
+	return t.patchFile(file, func(reader *zip.Reader) ([]archiveEntry, error) {
+		return t.packagePatches(reader, item, acc)
+	})
 }
@@ -0,0 +1,157 @@
+package appstore
+
+import (
+	"archive/zip"
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"strings"
+)
+
+type DownloadStreamInput struct {
+	Account           Account
+	App               App
+	ExternalVersionID string
+	// Version selects the version to download if ExternalVersionID is empty. Zero means the latest version.
+	Version VersionSelector
+}
+
+type DownloadStreamOutput struct {
+	// Package writes the patched package while it is downloaded.
+	Package *PackageStream
+	Sinfs   []Sinf
+	// Metadata is the content of iTunesMetadata.plist.
+	Metadata map[string]interface{}
+	// ExternalVersionID is the requested or resolved version. Empty if the latest version is downloaded.
+	ExternalVersionID string
+}
+
+// PackageStream is a patched package that is written while it is downloaded from the CDN.
+type PackageStream struct {
+	appstore *appstore
+	source   *downloadSource
+	proxy    string
+	hashMD5  string
+	patch    packagePatch
+	sha256   []byte
+}
+
+// DownloadStream reads the central directory of the package with range requests and prepares its patches, so
+// that the size of the patched package is known before its first byte is written. It returns
+// ErrRangesNotSupported if the CDN does not honor range requests; the package must then be downloaded with
+// Download.
+func (t *appstore) DownloadStream(input DownloadStreamInput) (DownloadStreamOutput, error) {
+	guid, err := t.guid()
+	if err != nil {
+		return DownloadStreamOutput{}, err
+	}
+
+	input.ExternalVersionID, err = t.resolveDownloadVersion(input.Account, input.App, input.ExternalVersionID, input.Version)
+	if err != nil {
+		return DownloadStreamOutput{}, err
+	}
+
+	item, err := t.requestDownloadItem(input.Account, input.App, guid, input.ExternalVersionID)
+	if err != nil {
+		return DownloadStreamOutput{}, err
+	}
+
+	file, err := t.openRemoteFile(item.URL, input.Account.Proxy)
+	if err != nil {
+		return DownloadStreamOutput{}, fmt.Errorf("failed to open package: %w", err)
+	}
+
+	patch, err := newPackagePatch(file, file.Size(), false, func(reader *zip.Reader) ([]archiveEntry, error) {
+		return t.packagePatches(reader, item, input.Account)
+	})
+	if err != nil {
+		return DownloadStreamOutput{}, fmt.Errorf("failed to prepare patches: %w", err)
+	}
+
+	return DownloadStreamOutput{
+		Package: &PackageStream{
+			appstore: t,
+			source:   t.newDownloadSource(item, input.Account, input.App, guid, input.ExternalVersionID),
+			proxy:    input.Account.Proxy,
+			hashMD5:  item.HashMD5,
+			patch:    patch,
+		},
+		Sinfs:             item.Sinfs,
+		Metadata:          item.Metadata,
+		ExternalVersionID: input.ExternalVersionID,
+	}, nil
+}
+
+// Size returns the size of the patched package.
+func (s *PackageStream) Size() int64 {
+	return s.patch.Size()
+}
+
+// ContentID identifies the bytes of the patched package before they are written: the same package patched for
+// the same account has the same ID. It is empty if the store did not send the checksum of the package.
+func (s *PackageStream) ContentID() string {
+	if s.hashMD5 == "" {
+		return ""
+	}
+
+	digest := sha256.New()
+	fmt.Fprintf(digest, "%s:%d:", strings.ToLower(s.hashMD5), s.patch.tail.offset)
+	digest.Write(s.patch.suffix)
+
+	return hex.EncodeToString(digest.Sum(nil))
+}
+
+// SHA256 returns the SHA-256 checksum of the patched package once WriteTo succeeded, nil before.
+func (s *PackageStream) SHA256() []byte {
+	return s.sha256
+}
+
+// WriteToContext downloads the local file entries of the package and writes them as they arrive, followed by
+// the sinfs, iTunesMetadata.plist and the new central directory. Failed, stalled and expired transfers resume
+// where they stopped; the transfer is canceled when ctx is done, e.g. because the client disconnected. The
+// package is verified against the MD5 checksum of the store before the central directory is written, so that a
+// corrupted package is never completed.
+func (s *PackageStream) WriteToContext(ctx context.Context, w io.Writer) (int64, error) {
+	var (
+		written  int64
+		digest   hash.Hash = md5.New()
+		checksum hash.Hash = sha256.New()
+		writer             = io.MultiWriter(w, digest, checksum)
+		end                = s.patch.tail.offset - 1
+	)
+
+	if end >= 0 {
+		err := s.appstore.retryPolicy.Do("stream file", func() error {
+			url := s.source.URL()
+			n, err := s.appstore.copyRange(ctx, url, s.proxy, written, end, writer)
+			written += n
+
+			return s.source.retryable(url, err)
+		})
+		if err != nil {
+			return written, fmt.Errorf("failed to download file: %w", err)
+		}
+	}
+
+	if s.hashMD5 != "" {
+		digest.Write(s.patch.tail.raw)
+
+		if actual := hex.EncodeToString(digest.Sum(nil)); !strings.EqualFold(actual, s.hashMD5) {
+			return written, &IntegrityError{Expected: strings.ToLower(s.hashMD5), Actual: actual, Attempts: 1}
+		}
+	}
+
+	n, err := w.Write(s.patch.suffix)
+	if err != nil {
+		return written + int64(n), fmt.Errorf("failed to write patches: %w", err)
+	}
+
+	checksum.Write(s.patch.suffix)
+	s.sha256 = checksum.Sum(nil)
+
+	return written + int64(n), nil
+}
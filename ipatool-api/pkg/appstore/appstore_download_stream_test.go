@@ -0,0 +1,161 @@
+package appstore
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	gohttp "net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/majd/ipatool/v2/pkg/http"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"go.uber.org/mock/gomock"
+	"howett.net/plist"
+)
+
+var _ = Describe("AppStore (DownloadStream)", func() {
+	var (
+		ctrl               *gomock.Controller
+		mockDownloadClient *http.MockClient[downloadResult]
+		cdn                *httptest.Server
+		handler            gohttp.HandlerFunc
+		pkg                []byte
+		hashMD5            string
+		as                 *appstore
+	)
+
+	BeforeEach(func() {
+		buf := new(bytes.Buffer)
+		writer := zip.NewWriter(buf)
+
+		info, err := plist.Marshal(map[string]interface{}{"CFBundleExecutable": "App"}, plist.BinaryFormat)
+		Expect(err).ToNot(HaveOccurred())
+
+		for name, data := range map[string][]byte{
+			"Payload/App.app/Info.plist": info,
+			"Payload/App.app/App":        bytes.Repeat([]byte{0xCF}, 1024*1024),
+		} {
+			w, err := writer.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Store})
+			Expect(err).ToNot(HaveOccurred())
+			_, err = w.Write(data)
+			Expect(err).ToNot(HaveOccurred())
+		}
+		Expect(writer.Close()).To(Succeed())
+
+		pkg = buf.Bytes()
+		sum := md5.Sum(pkg)
+		hashMD5 = hex.EncodeToString(sum[:])
+
+		handler = func(w gohttp.ResponseWriter, r *gohttp.Request) {
+			gohttp.ServeContent(w, r, "app.ipa", time.Time{}, bytes.NewReader(pkg))
+		}
+		cdn = httptest.NewServer(gohttp.HandlerFunc(func(w gohttp.ResponseWriter, r *gohttp.Request) {
+			handler(w, r)
+		}))
+		DeferCleanup(cdn.Close)
+
+		ctrl = gomock.NewController(GinkgoT())
+		mockDownloadClient = http.NewMockClient[downloadResult](ctrl)
+		as = &appstore{
+			downloadClient: mockDownloadClient,
+			httpClient:     http.NewClient[interface{}](http.Args{}),
+			deviceGUID:     "GUID",
+		}
+
+		mockDownloadClient.EXPECT().
+			Send(gomock.Any()).
+			AnyTimes().
+			DoAndReturn(func(http.Request) (http.Result[downloadResult], error) {
+				return http.Result[downloadResult]{Data: downloadResult{Items: []downloadItemResult{{
+					URL:      cdn.URL,
+					HashMD5:  hashMD5,
+					Sinfs:    []Sinf{{Data: []byte("sinf")}},
+					Metadata: map[string]interface{}{"bundleShortVersionString": "1.0"},
+				}}}}, nil
+			})
+	})
+
+	It("writes the patched package", func() {
+		out, err := as.DownloadStream(DownloadStreamInput{Account: Account{Email: "test@example.com"}})
+		Expect(err).ToNot(HaveOccurred())
+
+		var buf bytes.Buffer
+		n, err := out.Package.WriteToContext(context.Background(), &buf)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(n).To(Equal(out.Package.Size()))
+		Expect(int64(buf.Len())).To(Equal(out.Package.Size()))
+
+		checksum := sha256.Sum256(buf.Bytes())
+		Expect(out.Package.SHA256()).To(Equal(checksum[:]))
+
+		reader, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+		Expect(err).ToNot(HaveOccurred())
+
+		contents := map[string]string{}
+		for _, file := range reader.File {
+			src, err := file.Open()
+			Expect(err).ToNot(HaveOccurred())
+			data, err := io.ReadAll(src)
+			Expect(err).ToNot(HaveOccurred())
+			contents[file.Name] = string(data)
+		}
+
+		Expect(contents).To(HaveLen(4))
+		Expect(contents["Payload/App.app/SC_Info/App.sinf"]).To(Equal("sinf"))
+		Expect(contents["Payload/App.app/App"]).To(HaveLen(1024 * 1024))
+
+		var metadata map[string]interface{}
+		_, err = plist.Unmarshal([]byte(contents["iTunesMetadata.plist"]), &metadata)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(metadata).To(HaveKeyWithValue("apple-id", "test@example.com"))
+	})
+
+	It("patches a package to the same bytes every time", func() {
+		first, err := as.DownloadStream(DownloadStreamInput{Account: Account{Email: "test@example.com"}})
+		Expect(err).ToNot(HaveOccurred())
+
+		second, err := as.DownloadStream(DownloadStreamInput{Account: Account{Email: "test@example.com"}})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(first.Package.ContentID()).ToNot(BeEmpty())
+		Expect(second.Package.ContentID()).To(Equal(first.Package.ContentID()))
+
+		other, err := as.DownloadStream(DownloadStreamInput{Account: Account{Email: "other@example.com"}})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(other.Package.ContentID()).ToNot(Equal(first.Package.ContentID()))
+	})
+
+	When("the package does not match the checksum", func() {
+		BeforeEach(func() {
+			hashMD5 = "00000000000000000000000000000000"
+		})
+
+		It("does not complete the package", func() {
+			out, err := as.DownloadStream(DownloadStreamInput{})
+			Expect(err).ToNot(HaveOccurred())
+
+			var buf bytes.Buffer
+			n, err := out.Package.WriteToContext(context.Background(), &buf)
+			Expect(err).To(MatchError(ErrIntegrityCheckFailed))
+			Expect(n).To(BeNumerically("<", out.Package.Size()))
+		})
+	})
+
+	When("the CDN does not support range requests", func() {
+		BeforeEach(func() {
+			handler = func(w gohttp.ResponseWriter, _ *gohttp.Request) {
+				_, _ = w.Write(pkg)
+			}
+		})
+
+		It("returns error", func() {
+			_, err := as.DownloadStream(DownloadStreamInput{})
+			Expect(err).To(MatchError(ErrRangesNotSupported))
+		})
+	})
+})
@@ -60,18 +60,46 @@ var _ = Describe("AppStore (Download)", func() {
 			httpClient:     mockHTTPClient,
 			machine:        mockMachine,
 			os:             mockOS,
+			deviceGUID:     "GUID",
 		}
+
+		// No download is left over by a chunked download.
+		mockOS.EXPECT().
+			OpenFile(gomock.Cond(func(name any) bool { return strings.HasSuffix(name.(string), chunkStateSuffix) }), os.O_RDONLY, gomock.Any()).
+			Return(nil, os.ErrNotExist).
+			AnyTimes()
+
+		// The recovery file of a patch is kept next to the package.
+		isPatchRecovery := gomock.Cond(func(name any) bool { return strings.Contains(name.(string), patchRecoverySuffix) })
+
+		mockOS.EXPECT().
+			OpenFile(isPatchRecovery, gomock.Any(), gomock.Any()).
+			DoAndReturn(os.OpenFile).
+			AnyTimes()
+
+		mockOS.EXPECT().
+			Rename(isPatchRecovery, gomock.Any()).
+			DoAndReturn(os.Rename).
+			AnyTimes()
+
+		mockOS.EXPECT().
+			Remove(isPatchRecovery).
+			DoAndReturn(os.Remove).
+			AnyTimes()
+
+		mockOS.EXPECT().
+			IsNotExist(gomock.Cond(func(err any) bool { return os.IsNotExist(err.(error)) })).
+			Return(true).
+			AnyTimes()
 	})
 
 	AfterEach(func() {
 		ctrl.Finish()
 	})
 
-	When("fails to read MAC address", func() {
+	When("device GUID is not configured", func() {
 		BeforeEach(func() {
-			mockMachine.EXPECT().
-				MacAddress().
-				Return("", errors.New(""))
+			as.(*appstore).deviceGUID = ""
 		})
 
 		It("returns error", func() {
@@ -82,10 +110,6 @@ var _ = Describe("AppStore (Download)", func() {
 
 	When("request fails", func() {
 		BeforeEach(func() {
-			mockMachine.EXPECT().
-				MacAddress().
-				Return("", nil)
-
 			mockDownloadClient.EXPECT().
 				Send(gomock.Any()).
 				Return(http.Result[downloadResult]{}, errors.New(""))
@@ -99,10 +123,6 @@ var _ = Describe("AppStore (Download)", func() {
 
 	When("password token is expired", func() {
 		BeforeEach(func() {
-			mockMachine.EXPECT().
-				MacAddress().
-				Return("", nil)
-
 			mockDownloadClient.EXPECT().
 				Send(gomock.Any()).
 				Return(http.Result[downloadResult]{
@@ -120,10 +140,6 @@ var _ = Describe("AppStore (Download)", func() {
 
 	When("license is missing", func() {
 		BeforeEach(func() {
-			mockMachine.EXPECT().
-				MacAddress().
-				Return("", nil)
-
 			mockDownloadClient.EXPECT().
 				Send(gomock.Any()).
 				Return(http.Result[downloadResult]{
@@ -140,12 +156,6 @@ var _ = Describe("AppStore (Download)", func() {
 	})
 
 	When("store API returns error", func() {
-		BeforeEach(func() {
-			mockMachine.EXPECT().
-				MacAddress().
-				Return("", nil)
-		})
-
 		When("response contains customer message", func() {
 			BeforeEach(func() {
 				mockDownloadClient.EXPECT().
@@ -184,10 +194,6 @@ var _ = Describe("AppStore (Download)", func() {
 
 	When("store API returns no items", func() {
 		BeforeEach(func() {
-			mockMachine.EXPECT().
-				MacAddress().
-				Return("", nil)
-
 			mockDownloadClient.EXPECT().
 				Send(gomock.Any()).
 				Return(http.Result[downloadResult]{
@@ -205,10 +211,6 @@ var _ = Describe("AppStore (Download)", func() {
 
 	When("fails to resolve output path", func() {
 		BeforeEach(func() {
-			mockMachine.EXPECT().
-				MacAddress().
-				Return("", nil)
-
 			mockDownloadClient.EXPECT().
 				Send(gomock.Any()).
 				Return(http.Result[downloadResult]{
@@ -237,10 +239,6 @@ var _ = Describe("AppStore (Download)", func() {
 				Getwd().
 				Return("", nil)
 
-			mockMachine.EXPECT().
-				MacAddress().
-				Return("", nil)
-
 			mockDownloadClient.EXPECT().
 				Send(gomock.Any()).
 				Return(http.Result[downloadResult]{
@@ -357,6 +355,271 @@ var _ = Describe("AppStore (Download)", func() {
 
 	})
 
+	When("transfer is interrupted", func() {
+		var dir string
+
+		BeforeEach(func() {
+			dir = GinkgoT().TempDir()
+
+			as.(*appstore).retryPolicy = http.RetryPolicy{Attempts: 2}
+
+			mockDownloadClient.EXPECT().
+				Send(gomock.Any()).
+				Return(http.Result[downloadResult]{
+					Data: downloadResult{
+						Items: []downloadItemResult{
+							{
+								Metadata: map[string]interface{}{
+									"bundleShortVersionString": "xyz",
+								},
+							},
+						},
+					},
+				}, nil)
+
+			mockOS.EXPECT().
+				Getwd().
+				Return(dir, nil)
+
+			mockHTTPClient.EXPECT().
+				NewRequest("GET", gomock.Any(), nil).
+				DoAndReturn(func(method, url string, _ io.Reader) (*gohttp.Request, error) {
+					return gohttp.NewRequest(method, url, nil)
+				}).
+				Times(2)
+
+			mockOS.EXPECT().
+				OpenFile(gomock.Any(), gomock.Any(), gomock.Any()).
+				DoAndReturn(os.OpenFile).
+				Times(3)
+
+			mockOS.EXPECT().
+				Stat(gomock.Any()).
+				DoAndReturn(os.Stat).
+				Times(2)
+
+			gomock.InOrder(
+				mockHTTPClient.EXPECT().
+					Do(gomock.Any()).
+					Return(&gohttp.Response{
+						StatusCode: gohttp.StatusServiceUnavailable,
+						Body:       io.NopCloser(strings.NewReader("")),
+					}, nil),
+				mockHTTPClient.EXPECT().
+					Do(gomock.Any()).
+					Return(&gohttp.Response{
+						StatusCode: gohttp.StatusOK,
+						Body:       io.NopCloser(strings.NewReader("ping")),
+					}, nil),
+			)
+		})
+
+		It("retries the transfer", func() {
+			_, err := as.Download(DownloadInput{})
+			Expect(err).To(HaveOccurred())
+
+			testData, err := os.ReadFile(fmt.Sprintf("%s/xyz.ipa.tmp", dir))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(testData)).To(Equal("ping"))
+		})
+	})
+
+	When("server rejects the requested range", func() {
+		var (
+			dst          string
+			contentRange string
+		)
+
+		BeforeEach(func() {
+			dst = fmt.Sprintf("%s/app.ipa.tmp", GinkgoT().TempDir())
+			Expect(os.WriteFile(dst, []byte("ping"), 0644)).To(Succeed())
+
+			mockHTTPClient.EXPECT().
+				NewRequest("GET", gomock.Any(), nil).
+				DoAndReturn(func(method, url string, _ io.Reader) (*gohttp.Request, error) {
+					return gohttp.NewRequest(method, url, nil)
+				})
+
+			mockOS.EXPECT().
+				OpenFile(gomock.Any(), gomock.Any(), gomock.Any()).
+				DoAndReturn(os.OpenFile)
+
+			mockOS.EXPECT().
+				Stat(gomock.Any()).
+				DoAndReturn(os.Stat)
+
+			mockHTTPClient.EXPECT().
+				Do(gomock.Any()).
+				DoAndReturn(func(req *gohttp.Request) (*gohttp.Response, error) {
+					Expect(req.Header.Get("Range")).To(Equal("bytes=4-"))
+
+					return &gohttp.Response{
+						StatusCode: gohttp.StatusRequestedRangeNotSatisfiable,
+						Header:     gohttp.Header{"Content-Range": []string{contentRange}},
+						Body:       io.NopCloser(strings.NewReader("")),
+					}, nil
+				})
+		})
+
+		When("local file has the size of the remote file", func() {
+			BeforeEach(func() {
+				contentRange = "bytes */4"
+			})
+
+			It("keeps the complete file", func() {
+				err := as.(*appstore).downloadFileAttempt("https://example.com/app.ipa", dst, "", nil)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(os.ReadFile(dst)).To(BeEquivalentTo("ping"))
+			})
+		})
+
+		When("local file does not have the size of the remote file", func() {
+			BeforeEach(func() {
+				contentRange = "bytes */2"
+			})
+
+			It("returns error", func() {
+				err := as.(*appstore).downloadFileAttempt("https://example.com/app.ipa", dst, "", nil)
+				Expect(err).To(MatchError(ContainSubstring("range starting at 4 rejected")))
+				Expect(os.ReadFile(dst)).To(BeEquivalentTo("ping"))
+			})
+		})
+
+		When("response has no content range", func() {
+			BeforeEach(func() {
+				contentRange = ""
+			})
+
+			It("returns error", func() {
+				err := as.(*appstore).downloadFileAttempt("https://example.com/app.ipa", dst, "", nil)
+				Expect(err).To(HaveOccurred())
+			})
+		})
+	})
+
+	When("downloaded file does not match the checksum", func() {
+		var (
+			dir       string
+			responses []string
+			readErr   error
+		)
+
+		BeforeEach(func() {
+			dir = GinkgoT().TempDir()
+			readErr = nil
+
+			mockDownloadClient.EXPECT().
+				Send(gomock.Any()).
+				Return(http.Result[downloadResult]{
+					Data: downloadResult{
+						Items: []downloadItemResult{
+							{
+								// MD5 of "ping"
+								HashMD5: "DF911F0151F9EF021D410B4BE5060972",
+								Metadata: map[string]interface{}{
+									"bundleShortVersionString": "xyz",
+								},
+							},
+						},
+					},
+				}, nil)
+
+			mockOS.EXPECT().
+				Getwd().
+				Return(dir, nil)
+
+			mockHTTPClient.EXPECT().
+				NewRequest("GET", gomock.Any(), nil).
+				DoAndReturn(func(method, url string, _ io.Reader) (*gohttp.Request, error) {
+					return gohttp.NewRequest(method, url, nil)
+				}).
+				AnyTimes()
+
+			mockOS.EXPECT().
+				OpenFile(gomock.Any(), gomock.Any(), gomock.Any()).
+				DoAndReturn(func(name string, flag int, perm os.FileMode) (*os.File, error) {
+					if flag == os.O_RDONLY && readErr != nil {
+						return nil, readErr
+					}
+
+					return os.OpenFile(name, flag, perm)
+				}).
+				AnyTimes()
+
+			mockOS.EXPECT().
+				Stat(gomock.Any()).
+				DoAndReturn(os.Stat).
+				AnyTimes()
+
+			mockOS.EXPECT().
+				Remove(gomock.Any()).
+				DoAndReturn(os.Remove).
+				AnyTimes()
+
+			mockHTTPClient.EXPECT().
+				Do(gomock.Any()).
+				DoAndReturn(func(*gohttp.Request) (*gohttp.Response, error) {
+					body := responses[0]
+					responses = responses[1:]
+
+					return &gohttp.Response{
+						StatusCode: gohttp.StatusOK,
+						Body:       io.NopCloser(strings.NewReader(body)),
+					}, nil
+				}).
+				AnyTimes()
+		})
+
+		When("a later attempt succeeds", func() {
+			BeforeEach(func() {
+				responses = []string{"pong", "ping"}
+			})
+
+			It("downloads the file again", func() {
+				_, err := as.Download(DownloadInput{})
+				Expect(err).To(HaveOccurred())
+				Expect(err).ToNot(MatchError(ErrIntegrityCheckFailed))
+				Expect(responses).To(BeEmpty())
+
+				testData, err := os.ReadFile(fmt.Sprintf("%s/xyz.ipa.tmp", dir))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(string(testData)).To(Equal("ping"))
+			})
+		})
+
+		When("the downloaded file cannot be read", func() {
+			BeforeEach(func() {
+				responses = []string{"ping"}
+				readErr = errors.New("read denied")
+			})
+
+			It("returns error", func() {
+				_, err := as.Download(DownloadInput{})
+				Expect(err).To(MatchError(ContainSubstring("failed to compute checksum")))
+				Expect(err).To(MatchError(ContainSubstring("read denied")))
+			})
+		})
+
+		When("every attempt is corrupted", func() {
+			BeforeEach(func() {
+				responses = []string{"pong", "pong", "pong"}
+			})
+
+			It("returns integrity error", func() {
+				_, err := as.Download(DownloadInput{})
+				Expect(err).To(MatchError(ErrIntegrityCheckFailed))
+
+				var integrityErr *IntegrityError
+				Expect(errors.As(err, &integrityErr)).To(BeTrue())
+				Expect(integrityErr.Attempts).To(Equal(downloadIntegrityAttempts))
+				Expect(integrityErr.Actual).To(Equal("6fdb087aa3fbfbcb8287a593a0919e61"))
+
+				_, err = os.Stat(fmt.Sprintf("%s/xyz.ipa.tmp", dir))
+				Expect(os.IsNotExist(err)).To(BeTrue())
+			})
+		})
+	})
+
 	When("successfully downloads file", func() {
 		var testFile *os.File
 
@@ -365,10 +628,6 @@ var _ = Describe("AppStore (Download)", func() {
 			testFile, err = os.CreateTemp("", "test_file")
 			Expect(err).ToNot(HaveOccurred())
 
-			mockMachine.EXPECT().
-				MacAddress().
-				Return("", nil)
-
 			mockDownloadClient.EXPECT().
 				Send(gomock.Any()).
 				Return(http.Result[downloadResult]{
@@ -418,6 +677,10 @@ var _ = Describe("AppStore (Download)", func() {
 				Getwd().
 				Return("", nil)
 
+			mockOS.EXPECT().
+				OpenFile(gomock.Any(), gomock.Any(), gomock.Any()).
+				Return(nil, errors.New(""))
+
 			_, err := as.Download(DownloadInput{})
 			Expect(err).To(HaveOccurred())
 
@@ -449,7 +712,7 @@ var _ = Describe("AppStore (Download)", func() {
 					Return(nil, nil)
 
 				mockOS.EXPECT().
-					Remove(tmpFile.Name()).
+					Rename(tmpFile.Name(), outputPath).
 					Return(nil)
 
 				zipFile := zip.NewWriter(tmpFile)
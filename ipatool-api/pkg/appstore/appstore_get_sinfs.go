@@ -0,0 +1,32 @@
+package appstore
+
+type GetSinfsInput struct {
+	Account           Account
+	App               App
+	ExternalVersionID string
+}
+
+type GetSinfsOutput struct {
+	Sinfs []Sinf
+	// Metadata is the content of iTunesMetadata.plist as it is written to downloaded packages.
+	Metadata map[string]interface{}
+}
+
+// GetSinfs requests the download ticket of the specified version and returns its sinfs and metadata, without
+// downloading the package. The account must own a license for the app.
+func (t *appstore) GetSinfs(input GetSinfsInput) (GetSinfsOutput, error) {
+	guid, err := t.guid()
+	if err != nil {
+		return GetSinfsOutput{}, err
+	}
+
+	item, err := t.requestDownloadItem(input.Account, input.App, guid, input.ExternalVersionID)
+	if err != nil {
+		return GetSinfsOutput{}, err
+	}
+
+	return GetSinfsOutput{
+		Sinfs:    item.Sinfs,
+		Metadata: accountMetadata(item.Metadata, input.Account),
+	}, nil
+}
@@ -0,0 +1,89 @@
+package appstore
+
+import (
+	"errors"
+
+	"github.com/majd/ipatool/v2/pkg/http"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"go.uber.org/mock/gomock"
+)
+
+var _ = Describe("AppStore (GetSinfs)", func() {
+	var (
+		ctrl               *gomock.Controller
+		mockDownloadClient *http.MockClient[downloadResult]
+		as                 AppStore
+	)
+
+	BeforeEach(func() {
+		ctrl = gomock.NewController(GinkgoT())
+		mockDownloadClient = http.NewMockClient[downloadResult](ctrl)
+		as = &appstore{
+			downloadClient: mockDownloadClient,
+			deviceGUID:     "GUID",
+		}
+	})
+
+	AfterEach(func() {
+		ctrl.Finish()
+	})
+
+	When("request fails", func() {
+		BeforeEach(func() {
+			mockDownloadClient.EXPECT().
+				Send(gomock.Any()).
+				Return(http.Result[downloadResult]{}, errors.New("request error"))
+		})
+
+		It("returns error", func() {
+			_, err := as.GetSinfs(GetSinfsInput{})
+			Expect(err).To(MatchError(ContainSubstring("failed to send http request")))
+		})
+	})
+
+	When("license is missing", func() {
+		BeforeEach(func() {
+			mockDownloadClient.EXPECT().
+				Send(gomock.Any()).
+				Return(http.Result[downloadResult]{
+					Data: downloadResult{
+						FailureType: FailureTypeLicenseNotFound,
+					},
+				}, nil)
+		})
+
+		It("returns error", func() {
+			_, err := as.GetSinfs(GetSinfsInput{})
+			Expect(err).To(Equal(ErrLicenseRequired))
+		})
+	})
+
+	When("request succeeds", func() {
+		BeforeEach(func() {
+			mockDownloadClient.EXPECT().
+				Send(gomock.Any()).
+				Return(http.Result[downloadResult]{
+					Data: downloadResult{
+						Items: []downloadItemResult{
+							{
+								URL:   "https://example.com/app.ipa",
+								Sinfs: []Sinf{{ID: 1, Data: []byte("sinf")}},
+								Metadata: map[string]interface{}{
+									"bundleShortVersionString": "1.0",
+								},
+							},
+						},
+					},
+				}, nil)
+		})
+
+		It("returns the sinfs and the metadata bound to the account", func() {
+			out, err := as.GetSinfs(GetSinfsInput{Account: Account{Email: "test@example.com"}})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(out.Sinfs).To(Equal([]Sinf{{ID: 1, Data: []byte("sinf")}}))
+			Expect(out.Metadata).To(HaveKeyWithValue("bundleShortVersionString", "1.0"))
+			Expect(out.Metadata).To(HaveKeyWithValue("apple-id", "test@example.com"))
+		})
+	})
+})
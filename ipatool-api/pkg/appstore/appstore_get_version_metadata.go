@@ -3,7 +3,6 @@ package appstore
 import (
 	"errors"
 	"fmt"
-	"strings"
 	"time"
 
 	"github.com/majd/ipatool/v2/pkg/http"
@@ -13,6 +12,8 @@ type GetVersionMetadataInput struct {
 	Account   Account
 	App       App
 	VersionID string
+	// BypassCache fetches the metadata from the App Store even if it is cached. The result is still cached.
+	BypassCache bool
 }
 
 type GetVersionMetadataOutput struct {
@@ -21,56 +22,71 @@ type GetVersionMetadataOutput struct {
 }
 
 func (t *appstore) GetVersionMetadata(input GetVersionMetadataInput) (GetVersionMetadataOutput, error) {
-	macAddr, err := t.machine.MacAddress()
+	if !input.BypassCache {
+		if cached, ok := t.cachedVersionMetadata(input.App.ID, input.VersionID); ok {
+			return cached, nil
+		}
+	}
+
+	item, err := t.versionItem(input.Account, input.App, input.VersionID)
 	if err != nil {
-		return GetVersionMetadataOutput{}, fmt.Errorf("failed to get mac address: %w", err)
+		return GetVersionMetadataOutput{}, err
+	}
+
+	releaseDate, err := time.Parse(time.RFC3339, fmt.Sprintf("%v", item.Metadata["releaseDate"]))
+	if err != nil {
+		return GetVersionMetadataOutput{}, fmt.Errorf("failed to parse release date: %w", err)
 	}
 
-	guid := strings.ReplaceAll(strings.ToUpper(macAddr), ":", "")
+	output := GetVersionMetadataOutput{
+		DisplayVersion: fmt.Sprintf("%v", item.Metadata["bundleShortVersionString"]),
+		ReleaseDate:    releaseDate,
+	}
+
+	t.cacheVersionMetadata(input.App.ID, input.VersionID, output)
+
+	return output, nil
+}
 
-	req := t.getVersionMetadataRequest(input.Account, input.App, guid, input.VersionID)
+// versionItem requests the download ticket of the specified version, which describes it without
+// downloading it.
+func (t *appstore) versionItem(acc Account, app App, versionID string) (downloadItemResult, error) {
+	guid, err := t.guid()
+	if err != nil {
+		return downloadItemResult{}, err
+	}
+
+	req := t.getVersionMetadataRequest(acc, app, guid, versionID)
 	res, err := t.downloadClient.Send(req)
 
 	if err != nil {
-		return GetVersionMetadataOutput{}, fmt.Errorf("failed to send http request: %w", err)
+		return downloadItemResult{}, fmt.Errorf("failed to send http request: %w", err)
 	}
 
 	if res.Data.FailureType == FailureTypePasswordTokenExpired {
-		return GetVersionMetadataOutput{}, ErrPasswordTokenExpired
+		return downloadItemResult{}, ErrPasswordTokenExpired
 	}
 
 	if res.Data.FailureType == FailureTypeLicenseNotFound {
-		return GetVersionMetadataOutput{}, ErrLicenseRequired
+		return downloadItemResult{}, ErrLicenseRequired
 	}
 
 	if res.Data.FailureType != "" && res.Data.CustomerMessage != "" {
-		return GetVersionMetadataOutput{}, NewErrorWithMetadata(fmt.Errorf("received error: %s", res.Data.CustomerMessage), res)
+		return downloadItemResult{}, NewErrorWithMetadata(fmt.Errorf("received error: %s", res.Data.CustomerMessage), res)
 	}
 
 	if res.Data.FailureType != "" {
-		return GetVersionMetadataOutput{}, NewErrorWithMetadata(fmt.Errorf("received error: %s", res.Data.FailureType), res)
+		return downloadItemResult{}, NewErrorWithMetadata(fmt.Errorf("received error: %s", res.Data.FailureType), res)
 	}
 
 	if len(res.Data.Items) == 0 {
-		return GetVersionMetadataOutput{}, NewErrorWithMetadata(errors.New("invalid response"), res)
-	}
-
-	item := res.Data.Items[0]
-
-	releaseDate, err := time.Parse(time.RFC3339, fmt.Sprintf("%v", item.Metadata["releaseDate"]))
-	if err != nil {
-		return GetVersionMetadataOutput{}, fmt.Errorf("failed to parse release date: %w", err)
+		return downloadItemResult{}, NewErrorWithMetadata(errors.New("invalid response"), res)
 	}
 
-	return GetVersionMetadataOutput{
-		DisplayVersion: fmt.Sprintf("%v", item.Metadata["bundleShortVersionString"]),
-		ReleaseDate:    releaseDate,
-	}, nil
+	return res.Data.Items[0], nil
 }
 
 func (t *appstore) getVersionMetadataRequest(acc Account, app App, guid string, version string) http.Request {
-	host := fmt.Sprintf("%s-%s", PrivateAppStoreAPIDomainPrefixWithoutAuthCode, PrivateAppStoreAPIDomain)
-
 	payload := map[string]interface{}{
 		"creditDisplay":     "",
 		"guid":              guid,
@@ -79,7 +95,7 @@ func (t *appstore) getVersionMetadataRequest(acc Account, app App, guid string,
 	}
 
 	return http.Request{
-		URL:            fmt.Sprintf("https://%s%s?guid=%s", host, PrivateAppStoreAPIPathDownload, guid),
+		URL:            fmt.Sprintf("%s%s?guid=%s", t.endpoints.withDefaults().PrivateAppStoreDownloadAPI, PrivateAppStoreAPIPathDownload, guid),
 		Method:         http.MethodPOST,
 		ResponseFormat: http.ResponseFormatXML,
 		Headers: map[string]string{
@@ -90,5 +106,7 @@ func (t *appstore) getVersionMetadataRequest(acc Account, app App, guid string,
 		Payload: &http.XMLPayload{
 			Content: payload,
 		},
+		Proxy:      acc.Proxy,
+		Idempotent: true,
 	}
 }
@@ -26,6 +26,7 @@ var _ = Describe("AppStore (GetVersionMetadata)", func() {
 		as = &appstore{
 			machine:        mockMachine,
 			downloadClient: mockDownloadClient,
+			deviceGUID:     "GUID",
 		}
 	})
 
@@ -33,26 +34,20 @@ var _ = Describe("AppStore (GetVersionMetadata)", func() {
 		ctrl.Finish()
 	})
 
-	When("fails to get MAC address", func() {
+	When("device GUID is not configured", func() {
 		BeforeEach(func() {
-			mockMachine.EXPECT().
-				MacAddress().
-				Return("", errors.New("mac error"))
+			as.(*appstore).deviceGUID = ""
 		})
 
 		It("returns error", func() {
 			_, err := as.GetVersionMetadata(GetVersionMetadataInput{})
 			Expect(err).To(HaveOccurred())
-			Expect(err.Error()).To(ContainSubstring("failed to get mac address"))
+			Expect(err.Error()).To(ContainSubstring("device GUID is not configured"))
 		})
 	})
 
 	When("request fails", func() {
 		BeforeEach(func() {
-			mockMachine.EXPECT().
-				MacAddress().
-				Return("00:11:22:33:44:55", nil)
-
 			mockDownloadClient.EXPECT().
 				Send(gomock.Any()).
 				Return(http.Result[downloadResult]{}, errors.New("request error"))
@@ -67,10 +62,6 @@ var _ = Describe("AppStore (GetVersionMetadata)", func() {
 
 	When("password token is expired", func() {
 		BeforeEach(func() {
-			mockMachine.EXPECT().
-				MacAddress().
-				Return("00:11:22:33:44:55", nil)
-
 			mockDownloadClient.EXPECT().
 				Send(gomock.Any()).
 				Return(http.Result[downloadResult]{
@@ -88,10 +79,6 @@ var _ = Describe("AppStore (GetVersionMetadata)", func() {
 
 	When("license is missing", func() {
 		BeforeEach(func() {
-			mockMachine.EXPECT().
-				MacAddress().
-				Return("00:11:22:33:44:55", nil)
-
 			mockDownloadClient.EXPECT().
 				Send(gomock.Any()).
 				Return(http.Result[downloadResult]{
@@ -108,12 +95,6 @@ var _ = Describe("AppStore (GetVersionMetadata)", func() {
 	})
 
 	When("store API returns error", func() {
-		BeforeEach(func() {
-			mockMachine.EXPECT().
-				MacAddress().
-				Return("00:11:22:33:44:55", nil)
-		})
-
 		When("response contains customer message", func() {
 			BeforeEach(func() {
 				mockDownloadClient.EXPECT().
@@ -154,10 +135,6 @@ var _ = Describe("AppStore (GetVersionMetadata)", func() {
 
 	When("store API returns no items", func() {
 		BeforeEach(func() {
-			mockMachine.EXPECT().
-				MacAddress().
-				Return("00:11:22:33:44:55", nil)
-
 			mockDownloadClient.EXPECT().
 				Send(gomock.Any()).
 				Return(http.Result[downloadResult]{
@@ -176,10 +153,6 @@ var _ = Describe("AppStore (GetVersionMetadata)", func() {
 
 	When("fails to parse release date", func() {
 		BeforeEach(func() {
-			mockMachine.EXPECT().
-				MacAddress().
-				Return("00:11:22:33:44:55", nil)
-
 			mockDownloadClient.EXPECT().
 				Send(gomock.Any()).
 				Return(http.Result[downloadResult]{
@@ -204,10 +177,6 @@ var _ = Describe("AppStore (GetVersionMetadata)", func() {
 
 	When("successfully gets version metadata", func() {
 		BeforeEach(func() {
-			mockMachine.EXPECT().
-				MacAddress().
-				Return("00:11:22:33:44:55", nil)
-
 			mockDownloadClient.EXPECT().
 				Send(gomock.Any()).
 				Return(http.Result[downloadResult]{
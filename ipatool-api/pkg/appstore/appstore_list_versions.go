@@ -3,7 +3,7 @@ package appstore
 import (
 	"errors"
 	"fmt"
-	"strings"
+	"time"
 
 	"github.com/majd/ipatool/v2/pkg/http"
 )
@@ -19,13 +19,11 @@ type ListVersionsOutput struct {
 }
 
 func (t *appstore) ListVersions(input ListVersionsInput) (ListVersionsOutput, error) {
-	macAddr, err := t.machine.MacAddress()
+	guid, err := t.guid()
 	if err != nil {
-		return ListVersionsOutput{}, fmt.Errorf("failed to get mac address: %w", err)
+		return ListVersionsOutput{}, err
 	}
 
-	guid := strings.ReplaceAll(strings.ToUpper(macAddr), ":", "")
-
 	req := t.listVersionsRequest(input.Account, input.App, guid)
 	res, err := t.downloadClient.Send(req)
 
@@ -70,15 +68,25 @@ func (t *appstore) ListVersions(input ListVersionsInput) (ListVersionsOutput, er
 		return ListVersionsOutput{}, NewErrorWithMetadata(fmt.Errorf("failed to get latest version from item metadata"), item.Metadata)
 	}
 
-	return ListVersionsOutput{
+	output := ListVersionsOutput{
 		ExternalVersionIdentifiers: externalVersionIdentifiers,
 		LatestExternalVersionID:    fmt.Sprintf("%v", latestExternalVersionID),
-	}, nil
+	}
+
+	// The item describes the latest version, so its metadata comes for free.
+	if releaseDate, err := time.Parse(time.RFC3339, fmt.Sprintf("%v", item.Metadata["releaseDate"])); err == nil {
+		if displayVersion, ok := item.Metadata["bundleShortVersionString"].(string); ok {
+			t.cacheVersionMetadata(input.App.ID, output.LatestExternalVersionID, GetVersionMetadataOutput{
+				DisplayVersion: displayVersion,
+				ReleaseDate:    releaseDate,
+			})
+		}
+	}
+
+	return output, nil
 }
 
 func (t *appstore) listVersionsRequest(acc Account, app App, guid string) http.Request {
-	host := fmt.Sprintf("%s-%s", PrivateAppStoreAPIDomainPrefixWithoutAuthCode, PrivateAppStoreAPIDomain)
-
 	payload := map[string]interface{}{
 		"creditDisplay": "",
 		"guid":          guid,
@@ -86,7 +94,7 @@ func (t *appstore) listVersionsRequest(acc Account, app App, guid string) http.R
 	}
 
 	return http.Request{
-		URL:            fmt.Sprintf("https://%s%s?guid=%s", host, PrivateAppStoreAPIPathDownload, guid),
+		URL:            fmt.Sprintf("%s%s?guid=%s", t.endpoints.withDefaults().PrivateAppStoreDownloadAPI, PrivateAppStoreAPIPathDownload, guid),
 		Method:         http.MethodPOST,
 		ResponseFormat: http.ResponseFormatXML,
 		Headers: map[string]string{
@@ -97,5 +105,7 @@ func (t *appstore) listVersionsRequest(acc Account, app App, guid string) http.R
 		Payload: &http.XMLPayload{
 			Content: payload,
 		},
+		Proxy:      acc.Proxy,
+		Idempotent: true,
 	}
 }
@@ -25,6 +25,7 @@ var _ = Describe("AppStore (ListVersions)", func() {
 		as = &appstore{
 			downloadClient: mockDownloadClient,
 			machine:        mockMachine,
+			deviceGUID:     "GUID",
 		}
 	})
 
@@ -32,11 +33,9 @@ var _ = Describe("AppStore (ListVersions)", func() {
 		ctrl.Finish()
 	})
 
-	When("fails to get MAC address", func() {
+	When("device GUID is not configured", func() {
 		BeforeEach(func() {
-			mockMachine.EXPECT().
-				MacAddress().
-				Return("", errors.New(""))
+			as.(*appstore).deviceGUID = ""
 		})
 
 		It("returns error", func() {
@@ -47,10 +46,6 @@ var _ = Describe("AppStore (ListVersions)", func() {
 
 	When("request fails", func() {
 		BeforeEach(func() {
-			mockMachine.EXPECT().
-				MacAddress().
-				Return("00:00:00:00:00:00", nil)
-
 			mockDownloadClient.EXPECT().
 				Send(gomock.Any()).
 				Return(http.Result[downloadResult]{}, errors.New(""))
@@ -64,10 +59,6 @@ var _ = Describe("AppStore (ListVersions)", func() {
 
 	When("password token is expired", func() {
 		BeforeEach(func() {
-			mockMachine.EXPECT().
-				MacAddress().
-				Return("00:00:00:00:00:00", nil)
-
 			mockDownloadClient.EXPECT().
 				Send(gomock.Any()).
 				Return(http.Result[downloadResult]{
@@ -85,10 +76,6 @@ var _ = Describe("AppStore (ListVersions)", func() {
 
 	When("license is required", func() {
 		BeforeEach(func() {
-			mockMachine.EXPECT().
-				MacAddress().
-				Return("00:00:00:00:00:00", nil)
-
 			mockDownloadClient.EXPECT().
 				Send(gomock.Any()).
 				Return(http.Result[downloadResult]{
@@ -106,10 +93,6 @@ var _ = Describe("AppStore (ListVersions)", func() {
 
 	When("store API returns error with customer message", func() {
 		BeforeEach(func() {
-			mockMachine.EXPECT().
-				MacAddress().
-				Return("00:00:00:00:00:00", nil)
-
 			mockDownloadClient.EXPECT().
 				Send(gomock.Any()).
 				Return(http.Result[downloadResult]{
@@ -129,10 +112,6 @@ var _ = Describe("AppStore (ListVersions)", func() {
 
 	When("store API returns error without customer message", func() {
 		BeforeEach(func() {
-			mockMachine.EXPECT().
-				MacAddress().
-				Return("00:00:00:00:00:00", nil)
-
 			mockDownloadClient.EXPECT().
 				Send(gomock.Any()).
 				Return(http.Result[downloadResult]{
@@ -151,10 +130,6 @@ var _ = Describe("AppStore (ListVersions)", func() {
 
 	When("store API returns no items", func() {
 		BeforeEach(func() {
-			mockMachine.EXPECT().
-				MacAddress().
-				Return("00:00:00:00:00:00", nil)
-
 			mockDownloadClient.EXPECT().
 				Send(gomock.Any()).
 				Return(http.Result[downloadResult]{
@@ -172,10 +147,6 @@ var _ = Describe("AppStore (ListVersions)", func() {
 
 	When("version identifiers not found in metadata", func() {
 		BeforeEach(func() {
-			mockMachine.EXPECT().
-				MacAddress().
-				Return("00:00:00:00:00:00", nil)
-
 			mockDownloadClient.EXPECT().
 				Send(gomock.Any()).
 				Return(http.Result[downloadResult]{
@@ -200,10 +171,6 @@ var _ = Describe("AppStore (ListVersions)", func() {
 
 	When("latest version not found in metadata", func() {
 		BeforeEach(func() {
-			mockMachine.EXPECT().
-				MacAddress().
-				Return("00:00:00:00:00:00", nil)
-
 			mockDownloadClient.EXPECT().
 				Send(gomock.Any()).
 				Return(http.Result[downloadResult]{
@@ -234,10 +201,6 @@ var _ = Describe("AppStore (ListVersions)", func() {
 		)
 
 		BeforeEach(func() {
-			mockMachine.EXPECT().
-				MacAddress().
-				Return("00:00:00:00:00:00", nil)
-
 			mockDownloadClient.EXPECT().
 				Send(gomock.Any()).
 				Return(http.Result[downloadResult]{
@@ -20,6 +20,8 @@ type LoginInput struct {
 	Email    string
 	Password string
 	AuthCode string
+	// Proxy routes the login and all subsequent requests of the account through the specified proxy.
+	Proxy string
 }
 
 type LoginOutput struct {
@@ -27,14 +29,12 @@ type LoginOutput struct {
 }
 
 func (t *appstore) Login(input LoginInput) (LoginOutput, error) {
-	macAddr, err := t.machine.MacAddress()
+	guid, err := t.guid()
 	if err != nil {
-		return LoginOutput{}, fmt.Errorf("failed to get mac address: %w", err)
+		return LoginOutput{}, err
 	}
 
-	guid := strings.ReplaceAll(strings.ToUpper(macAddr), ":", "")
-
-	acc, err := t.login(input.Email, input.Password, input.AuthCode, guid)
+	acc, err := t.login(input.Email, input.Password, input.AuthCode, input.Proxy, guid)
 	if err != nil {
 		return LoginOutput{}, err
 	}
@@ -62,7 +62,7 @@ type loginResult struct {
 	PasswordToken       string             `plist:"passwordToken,omitempty"`
 }
 
-func (t *appstore) login(email, password, authCode, guid string) (Account, error) {
+func (t *appstore) login(email, password, authCode, proxy, guid string) (Account, error) {
 	redirect := ""
 
 	var (
@@ -74,6 +74,7 @@ func (t *appstore) login(email, password, authCode, guid string) (Account, error
 
 	for attempt := 1; retry && attempt <= 4; attempt++ {
 		request := t.loginRequest(email, password, authCode, guid, attempt)
+		request.Proxy = proxy
 		request.URL, _ = util.IfEmpty(redirect, request.URL), ""
 		res, err = t.loginClient.Send(request)
 
@@ -103,6 +104,7 @@ func (t *appstore) login(email, password, authCode, guid string) (Account, error
 		DirectoryServicesID: res.Data.DirectoryServicesID,
 		StoreFront:          sf,
 		Password:            password,
+		Proxy:               proxy,
 	}
 
 	data, err := json.Marshal(acc)
@@ -153,7 +155,7 @@ func (t *appstore) parseLoginResponse(res *http.Result[loginResult], attempt int
 func (t *appstore) loginRequest(email, password, authCode, guid string, attempt int) http.Request {
 	return http.Request{
 		Method:         http.MethodPOST,
-		URL:            fmt.Sprintf("https://%s%s", PrivateAppStoreAPIDomain, PrivateAppStoreAPIPathAuthenticate),
+		URL:            fmt.Sprintf("%s%s", t.endpoints.withDefaults().PrivateAppStoreAPI, PrivateAppStoreAPIPathAuthenticate),
 		ResponseFormat: http.ResponseFormatXML,
 		Headers: map[string]string{
 			"Content-Type": "application/x-www-form-urlencoded",
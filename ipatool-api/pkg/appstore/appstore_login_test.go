@@ -39,6 +39,7 @@ var _ = Describe("AppStore (Login)", func() {
 			keychain:    mockKeychain,
 			loginClient: mockClient,
 			machine:     mockMachine,
+			deviceGUID:  "GUID",
 		}
 	})
 
@@ -46,11 +47,9 @@ var _ = Describe("AppStore (Login)", func() {
 		ctrl.Finish()
 	})
 
-	When("fails to read Machine's MAC address", func() {
+	When("device GUID is not configured", func() {
 		BeforeEach(func() {
-			mockMachine.EXPECT().
-				MacAddress().
-				Return("", errors.New(""))
+			as.(*appstore).deviceGUID = ""
 		})
 
 		It("returns error", func() {
@@ -61,13 +60,7 @@ var _ = Describe("AppStore (Login)", func() {
 		})
 	})
 
-	When("successfully reads machine's MAC address", func() {
-		BeforeEach(func() {
-			mockMachine.EXPECT().
-				MacAddress().
-				Return("00:00:00:00:00:00", nil)
-		})
-
+	When("device GUID is configured", func() {
 		When("client returns error", func() {
 			BeforeEach(func() {
 				mockClient.EXPECT().
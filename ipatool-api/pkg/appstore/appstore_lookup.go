@@ -5,26 +5,45 @@ import (
 	"fmt"
 	gohttp "net/http"
 	"net/url"
+	"strconv"
+	"strings"
 
 	"github.com/majd/ipatool/v2/pkg/http"
 )
 
+var ErrAppNotFound = errors.New("app not found")
+
 type LookupInput struct {
 	Account  Account
 	BundleID string
+	// AppID looks the app up by its ID instead of the bundle identifier.
+	AppID int64
+	// AppIDs looks several apps up by ID in a single request. Takes precedence over AppID and BundleID.
+	AppIDs []int64
+	// CountryCode overrides the storefront of the account.
+	CountryCode string
 }
 
 type LookupOutput struct {
+	// App is the first app found.
 	App App
+	// Apps are all apps found, in the order returned by the App Store.
+	Apps []App
 }
 
 func (t *appstore) Lookup(input LookupInput) (LookupOutput, error) {
-	countryCode, err := countryCodeFromStoreFront(input.Account.StoreFront)
+	countryCode, err := lookupCountryCode(input.Account, input.CountryCode)
 	if err != nil {
-		return LookupOutput{}, fmt.Errorf("failed to resolve the country code: %w", err)
+		return LookupOutput{}, err
+	}
+
+	appIDs := input.AppIDs
+	if len(appIDs) == 0 && input.AppID != 0 {
+		appIDs = []int64{input.AppID}
 	}
 
-	request := t.lookupRequest(input.BundleID, countryCode)
+	request := t.lookupRequest(input.BundleID, appIDs, countryCode)
+	request.Proxy = input.Account.Proxy
 
 	res, err := t.searchClient.Send(request)
 	if err != nil {
@@ -36,29 +55,61 @@ func (t *appstore) Lookup(input LookupInput) (LookupOutput, error) {
 	}
 
 	if len(res.Data.Results) == 0 {
-		return LookupOutput{}, errors.New("app not found")
+		return LookupOutput{}, ErrAppNotFound
 	}
 
 	return LookupOutput{
-		App: res.Data.Results[0],
+		App:  res.Data.Results[0],
+		Apps: res.Data.Results,
 	}, nil
 }
 
-func (t *appstore) lookupRequest(bundleID, countryCode string) http.Request {
+// lookupCountryCode returns the country code to look apps up in: the override if specified,
+// otherwise the country of the account's storefront.
+func lookupCountryCode(acc Account, override string) (string, error) {
+	if override != "" {
+		country, ok := CountryByCode(override)
+		if !ok {
+			return "", fmt.Errorf("invalid country code: %s", override)
+		}
+
+		return country.Code, nil
+	}
+
+	countryCode, err := countryCodeFromStoreFront(acc.StoreFront)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve the country code: %w", err)
+	}
+
+	return countryCode, nil
+}
+
+func (t *appstore) lookupRequest(bundleID string, appIDs []int64, countryCode string) http.Request {
 	return http.Request{
-		URL:            t.lookupURL(bundleID, countryCode),
+		URL:            t.lookupURL(bundleID, appIDs, countryCode),
 		Method:         http.MethodGET,
 		ResponseFormat: http.ResponseFormatJSON,
 	}
 }
 
-func (t *appstore) lookupURL(bundleID, countryCode string) string {
+func (t *appstore) lookupURL(bundleID string, appIDs []int64, countryCode string) string {
 	params := url.Values{}
 	params.Add("entity", "software,iPadSoftware")
-	params.Add("limit", "1")
+	params.Add("limit", strconv.Itoa(max(len(appIDs), 1)))
 	params.Add("media", "software")
-	params.Add("bundleId", bundleID)
-	params.Add("country", countryCode)
 
-	return fmt.Sprintf("https://%s%s?%s", iTunesAPIDomain, iTunesAPIPathLookup, params.Encode())
+	if len(appIDs) > 0 {
+		ids := make([]string, len(appIDs))
+		for i, id := range appIDs {
+			ids[i] = strconv.FormatInt(id, 10)
+		}
+
+		params.Add("id", strings.Join(ids, ","))
+	} else {
+		params.Add("bundleId", bundleID)
+	}
+
+	params.Add("country", strings.ToUpper(countryCode))
+
+	return fmt.Sprintf("%s%s?%s", t.endpoints.withDefaults().ITunesAPI, iTunesAPIPathLookup, params.Encode())
 }
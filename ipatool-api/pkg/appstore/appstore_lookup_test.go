@@ -80,7 +80,38 @@ var _ = Describe("AppStore (Lookup)", func() {
 					},
 				})
 				Expect(err).ToNot(HaveOccurred())
-				Expect(app).To(Equal(LookupOutput{App: testApp}))
+				Expect(app).To(Equal(LookupOutput{App: testApp, Apps: []App{testApp}}))
+			})
+		})
+
+		When("looking up several apps by ID", func() {
+			BeforeEach(func() {
+				mockClient.EXPECT().
+					Send(gomock.Any()).
+					DoAndReturn(func(req http.Request) (http.Result[searchResult], error) {
+						Expect(req.URL).To(ContainSubstring("id=1%2C2"))
+						Expect(req.URL).To(ContainSubstring("limit=2"))
+						Expect(req.URL).To(ContainSubstring("country=GB"))
+
+						return http.Result[searchResult]{
+							StatusCode: 200,
+							Data: searchResult{
+								Count:   2,
+								Results: []App{{ID: 1}, {ID: 2}},
+							},
+						}, nil
+					})
+			})
+
+			It("returns all apps from a single request", func() {
+				out, err := as.Lookup(LookupInput{
+					Account:     Account{StoreFront: "143441"},
+					AppIDs:      []int64{1, 2},
+					CountryCode: "gb",
+				})
+				Expect(err).ToNot(HaveOccurred())
+				Expect(out.Apps).To(HaveLen(2))
+				Expect(out.App.ID).To(Equal(int64(1)))
 			})
 		})
 	})
@@ -0,0 +1,88 @@
+package appstore
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"howett.net/plist"
+)
+
+type minimumOSVersionInfo struct {
+	MinimumOSVersion string `plist:"MinimumOSVersion,omitempty"`
+}
+
+// minimumOSVersion returns the minimum iOS version required by the specified version of the app. The App
+// Store does not report it for old versions, so it is read from the Info.plist of the IPA using range
+// requests for the central directory and that single entry. Empty means no minimum.
+func (t *appstore) minimumOSVersion(acc Account, app App, versionID string) (string, error) {
+	if cached, ok := t.cachedMinimumOSVersion(app.ID, versionID); ok {
+		return cached, nil
+	}
+
+	item, err := t.versionItem(acc, app, versionID)
+	if err != nil {
+		return "", err
+	}
+
+	file, err := t.openRemoteFile(item.URL, acc.Proxy)
+	if err != nil {
+		return "", fmt.Errorf("failed to open package: %w", err)
+	}
+
+	reader, err := zip.NewReader(file, file.Size())
+	if err != nil {
+		return "", fmt.Errorf("failed to read package: %w", err)
+	}
+
+	for _, entry := range reader.File {
+		if !isAppInfoPlist(entry.Name) {
+			continue
+		}
+
+		info, err := readMinimumOSVersionInfo(entry)
+		if err != nil {
+			return "", err
+		}
+
+		t.cacheMinimumOSVersion(app.ID, versionID, info.MinimumOSVersion)
+
+		return info.MinimumOSVersion, nil
+	}
+
+	return "", fmt.Errorf("package of version %s has no Info.plist", versionID)
+}
+
+// isAppInfoPlist reports whether the zip entry is the Info.plist of the app bundle itself, rather than
+// one of its extensions, frameworks or watch app.
+func isAppInfoPlist(name string) bool {
+	parts := strings.Split(name, "/")
+
+	return len(parts) == 3 && parts[0] == "Payload" && strings.HasSuffix(parts[1], ".app") && parts[2] == "Info.plist"
+}
+
+func readMinimumOSVersionInfo(entry *zip.File) (minimumOSVersionInfo, error) {
+	src, err := entry.Open()
+	if err != nil {
+		return minimumOSVersionInfo{}, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer src.Close()
+
+	data := new(bytes.Buffer)
+
+	_, err = io.Copy(data, src)
+	if err != nil {
+		return minimumOSVersionInfo{}, fmt.Errorf("failed to copy data: %w", err)
+	}
+
+	var info minimumOSVersionInfo
+
+	_, err = plist.Unmarshal(data.Bytes(), &info)
+	if err != nil {
+		return minimumOSVersionInfo{}, fmt.Errorf("failed to unmarshal data: %w", err)
+	}
+
+	return info, nil
+}
@@ -0,0 +1,200 @@
+package appstore
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	gohttp "net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/majd/ipatool/v2/pkg/http"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"go.uber.org/mock/gomock"
+	"howett.net/plist"
+)
+
+var _ = Describe("AppStore (minimumOSVersion)", func() {
+	var (
+		ctrl               *gomock.Controller
+		mockDownloadClient *http.MockClient[downloadResult]
+		cdn                *httptest.Server
+		servedBytes        atomic.Int64
+		packages           map[string][]byte
+		versionIDs         []interface{}
+		as                 *appstore
+		testApp            = App{ID: 1}
+	)
+
+	// buildPackage returns an IPA with a large executable, whose Info.plist requires the iOS version.
+	buildPackage := func(minimumOSVersion string) []byte {
+		buf := new(bytes.Buffer)
+		writer := zip.NewWriter(buf)
+
+		add := func(name string, data []byte, method uint16) {
+			w, err := writer.CreateHeader(&zip.FileHeader{Name: name, Method: method})
+			Expect(err).ToNot(HaveOccurred())
+			_, err = w.Write(data)
+			Expect(err).ToNot(HaveOccurred())
+		}
+
+		extension, err := plist.Marshal(map[string]interface{}{"MinimumOSVersion": "99.0"}, plist.BinaryFormat)
+		Expect(err).ToNot(HaveOccurred())
+		info, err := plist.Marshal(map[string]interface{}{"MinimumOSVersion": minimumOSVersion}, plist.BinaryFormat)
+		Expect(err).ToNot(HaveOccurred())
+
+		add("Payload/App.app/PlugIns/Widget.appex/Info.plist", extension, zip.Deflate)
+		add("Payload/App.app/App", bytes.Repeat([]byte{0xCF}, 4*1024*1024), zip.Store)
+		add("Payload/App.app/Info.plist", info, zip.Deflate)
+		Expect(writer.Close()).To(Succeed())
+
+		return buf.Bytes()
+	}
+
+	BeforeEach(func() {
+		ctrl = gomock.NewController(GinkgoT())
+		mockDownloadClient = http.NewMockClient[downloadResult](ctrl)
+		servedBytes.Store(0)
+		packages = map[string][]byte{"10": buildPackage("12.0"), "20": buildPackage("15.0"), "30": buildPackage("17.0")}
+		versionIDs = []interface{}{"10", "20", "30"}
+
+		cdn = httptest.NewServer(gohttp.HandlerFunc(func(w gohttp.ResponseWriter, r *gohttp.Request) {
+			data := packages[strings.TrimPrefix(r.URL.Path, "/")]
+			counter := &countingWriter{ResponseWriter: w, count: &servedBytes}
+			gohttp.ServeContent(counter, r, "app.ipa", time.Time{}, bytes.NewReader(data))
+		}))
+
+		as = &appstore{
+			downloadClient: mockDownloadClient,
+			httpClient:     http.NewClient[interface{}](http.Args{}),
+			deviceGUID:     "GUID",
+		}
+
+		mockDownloadClient.EXPECT().
+			Send(gomock.Any()).
+			DoAndReturn(func(req http.Request) (http.Result[downloadResult], error) {
+				payload, _ := req.Payload.(*http.XMLPayload)
+
+				versionID, ok := payload.Content["externalVersionId"].(string)
+				if !ok {
+					return http.Result[downloadResult]{Data: downloadResult{Items: []downloadItemResult{{
+						Metadata: map[string]interface{}{
+							"softwareVersionExternalIdentifiers": versionIDs,
+							"softwareVersionExternalIdentifier":  versionIDs[len(versionIDs)-1],
+						},
+					}}}}, nil
+				}
+
+				return http.Result[downloadResult]{Data: downloadResult{Items: []downloadItemResult{{
+					URL: cdn.URL + "/" + versionID,
+				}}}}, nil
+			}).
+			AnyTimes()
+	})
+
+	AfterEach(func() {
+		cdn.Close()
+		ctrl.Finish()
+	})
+
+	It("reads the minimum iOS version of the app without downloading the package", func() {
+		minimumOSVersion, err := as.minimumOSVersion(Account{}, testApp, "20")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(minimumOSVersion).To(Equal("15.0"))
+		Expect(servedBytes.Load()).To(BeNumerically("<", 1024*1024))
+	})
+
+	It("selects the newest compatible version", func() {
+		out, err := as.ResolveVersion(ResolveVersionInput{App: testApp, Selector: VersionSelector{CompatibleWith: "15.7"}})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(out.ExternalVersionID).To(Equal("20"))
+		Expect(out.MinimumOSVersion).To(Equal("15.0"))
+
+		_, err = as.ResolveVersion(ResolveVersionInput{App: testApp, Selector: VersionSelector{CompatibleWith: "11.4"}})
+		Expect(err).To(MatchError(ErrVersionNotFound))
+	})
+
+	It("skips versions whose minimum iOS version cannot be read", func() {
+		packages["30"] = []byte("not a package")
+		packages["20"] = []byte("not a package")
+
+		out, err := as.ResolveVersion(ResolveVersionInput{App: testApp, Selector: VersionSelector{CompatibleWith: "17.0"}})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(out.ExternalVersionID).To(Equal("10"))
+	})
+
+	It("fails if the minimum iOS version of no version can be read", func() {
+		for id := range packages {
+			packages[id] = []byte("not a package")
+		}
+
+		_, err := as.ResolveVersion(ResolveVersionInput{App: testApp, Selector: VersionSelector{CompatibleWith: "17.0"}})
+		Expect(err).To(MatchError(ContainSubstring("failed to get minimum iOS version of version 10")))
+		Expect(err).ToNot(MatchError(ErrVersionNotFound))
+	})
+
+	It("checks a bounded number of versions", func() {
+		var requests atomic.Int64
+
+		served := cdn.Config.Handler
+		cdn.Config.Handler = gohttp.HandlerFunc(func(w gohttp.ResponseWriter, r *gohttp.Request) {
+			requests.Add(1)
+			served.ServeHTTP(w, r)
+		})
+
+		versionIDs = []interface{}{}
+		for i := 1; i <= 2*maxCompatibleVersionScan; i++ {
+			id := strconv.Itoa(i)
+			versionIDs = append(versionIDs, id)
+			packages[id] = packages["30"]
+		}
+
+		_, err := as.ResolveVersion(ResolveVersionInput{App: testApp, Selector: VersionSelector{CompatibleWith: "15.0"}})
+		Expect(err).To(MatchError(ErrVersionNotFound))
+		Expect(err).To(MatchError(ContainSubstring(fmt.Sprintf("none of the %d newest versions", maxCompatibleVersionScan))))
+
+		checked := requests.Load()
+
+		_, err = as.minimumOSVersion(Account{}, testApp, "1")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(checked).To(Equal(int64(maxCompatibleVersionScan) * (requests.Load() - checked)))
+	})
+
+	It("caches the minimum iOS version per version", func() {
+		as.metadataCache = memoryMetadataCache{}
+
+		_, err := as.minimumOSVersion(Account{}, testApp, "30")
+		Expect(err).ToNot(HaveOccurred())
+
+		served := servedBytes.Load()
+
+		minimumOSVersion, err := as.minimumOSVersion(Account{}, testApp, "30")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(minimumOSVersion).To(Equal("17.0"))
+		Expect(servedBytes.Load()).To(Equal(served))
+	})
+
+	It("fails if the server does not support range requests", func() {
+		cdn.Config.Handler = gohttp.HandlerFunc(func(w gohttp.ResponseWriter, r *gohttp.Request) {
+			_, _ = w.Write(packages["10"])
+		})
+
+		_, err := as.minimumOSVersion(Account{}, testApp, "10")
+		Expect(err).To(MatchError(ContainSubstring("range requests are not supported")))
+	})
+})
+
+type countingWriter struct {
+	gohttp.ResponseWriter
+	count *atomic.Int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.count.Add(int64(len(p)))
+
+	return w.ResponseWriter.Write(p)
+}
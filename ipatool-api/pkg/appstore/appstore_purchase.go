@@ -4,7 +4,6 @@ import (
 	"errors"
 	"fmt"
 	gohttp "net/http"
-	"strings"
 
 	"github.com/majd/ipatool/v2/pkg/http"
 )
@@ -13,6 +12,7 @@ var (
 	ErrPasswordTokenExpired   = errors.New("password token is expired")
 	ErrSubscriptionRequired   = errors.New("subscription required")
 	ErrTemporarilyUnavailable = errors.New("item is temporarily unavailable")
+	ErrLicenseAlreadyExists   = errors.New("license already exists")
 )
 
 type PurchaseInput struct {
@@ -21,13 +21,11 @@ type PurchaseInput struct {
 }
 
 func (t *appstore) Purchase(input PurchaseInput) error {
-	macAddr, err := t.machine.MacAddress()
+	guid, err := t.guid()
 	if err != nil {
-		return fmt.Errorf("failed to get mac address: %w", err)
+		return err
 	}
 
-	guid := strings.ReplaceAll(strings.ToUpper(macAddr), ":", "")
-
 	if input.App.Price > 0 {
 		return errors.New("purchasing paid apps is not supported")
 	}
@@ -40,12 +38,16 @@ func (t *appstore) Purchase(input PurchaseInput) error {
 				return fmt.Errorf("failed to purchase item with param '%s': %w", PricingParameterAppleArcade, err)
 			}
 
+			t.licenseCache.set(licenseCacheKey(input.Account, input.App.ID), LicenseStatusOwned)
+
 			return nil
 		}
 
 		return fmt.Errorf("failed to purchase item with param '%s': %w", PricingParameterAppStore, err)
 	}
 
+	t.licenseCache.set(licenseCacheKey(input.Account, input.App.ID), LicenseStatusOwned)
+
 	return nil
 }
 
@@ -92,7 +94,7 @@ func (t *appstore) purchaseWithParams(acc Account, app App, guid string, pricing
 	}
 
 	if res.StatusCode == gohttp.StatusInternalServerError {
-		return fmt.Errorf("license already exists")
+		return ErrLicenseAlreadyExists
 	}
 
 	if res.Data.JingleDocType != "purchaseSuccess" || res.Data.Status != 0 {
@@ -105,7 +107,7 @@ func (t *appstore) purchaseWithParams(acc Account, app App, guid string, pricing
 
 func (t *appstore) purchaseRequest(acc Account, app App, storeFront, guid string, pricingParameters string) http.Request {
 	return http.Request{
-		URL:            fmt.Sprintf("https://%s%s", PrivateAppStoreAPIDomain, PrivateAppStoreAPIPathPurchase),
+		URL:            fmt.Sprintf("%s%s", t.endpoints.withDefaults().PrivateAppStoreAPI, PrivateAppStoreAPIPathPurchase),
 		Method:         http.MethodPOST,
 		ResponseFormat: http.ResponseFormatXML,
 		Headers: map[string]string{
@@ -131,5 +133,6 @@ func (t *appstore) purchaseRequest(acc Account, app App, storeFront, guid string
 				"salableAdamId":             app.ID,
 			},
 		},
+		Proxy: acc.Proxy,
 	}
 }
@@ -32,6 +32,7 @@ var _ = Describe("AppStore (Purchase)", func() {
 			purchaseClient: mockPurchaseClient,
 			loginClient:    mockLoginClient,
 			machine:        mockMachine,
+			deviceGUID:     "GUID",
 		}
 	})
 
@@ -39,11 +40,9 @@ var _ = Describe("AppStore (Purchase)", func() {
 		ctrl.Finish()
 	})
 
-	When("fails to read MAC address", func() {
+	When("device GUID is not configured", func() {
 		BeforeEach(func() {
-			mockMachine.EXPECT().
-				MacAddress().
-				Return("", errors.New(""))
+			as.deviceGUID = ""
 		})
 
 		It("returns error", func() {
@@ -53,12 +52,6 @@ var _ = Describe("AppStore (Purchase)", func() {
 	})
 
 	When("app is paid", func() {
-		BeforeEach(func() {
-			mockMachine.EXPECT().
-				MacAddress().
-				Return("00:00:00:00:00:00", nil)
-		})
-
 		It("returns error", func() {
 			err := as.Purchase(PurchaseInput{
 				Account: Account{
@@ -74,10 +67,6 @@ var _ = Describe("AppStore (Purchase)", func() {
 
 	When("purchase request fails", func() {
 		BeforeEach(func() {
-			mockMachine.EXPECT().
-				MacAddress().
-				Return("00:00:00:00:00:00", nil)
-
 			mockPurchaseClient.EXPECT().
 				Send(gomock.Any()).
 				Return(http.Result[purchaseResult]{}, errors.New(""))
@@ -95,10 +84,6 @@ var _ = Describe("AppStore (Purchase)", func() {
 
 	When("password token is expired", func() {
 		BeforeEach(func() {
-			mockMachine.EXPECT().
-				MacAddress().
-				Return("00:00:00:00:00:00", nil)
-
 			mockPurchaseClient.EXPECT().
 				Send(gomock.Any()).
 				Return(http.Result[purchaseResult]{
@@ -120,10 +105,6 @@ var _ = Describe("AppStore (Purchase)", func() {
 
 	When("store API returns customer error message", func() {
 		BeforeEach(func() {
-			mockMachine.EXPECT().
-				MacAddress().
-				Return("00:00:00:00:00:00", nil)
-
 			mockPurchaseClient.EXPECT().
 				Send(gomock.Any()).
 				Return(http.Result[purchaseResult]{
@@ -146,10 +127,6 @@ var _ = Describe("AppStore (Purchase)", func() {
 
 	When("store API returns unknown error", func() {
 		BeforeEach(func() {
-			mockMachine.EXPECT().
-				MacAddress().
-				Return("00:00:00:00:00:00", nil)
-
 			mockPurchaseClient.EXPECT().
 				Send(gomock.Any()).
 				Return(http.Result[purchaseResult]{
@@ -171,10 +148,6 @@ var _ = Describe("AppStore (Purchase)", func() {
 
 	When("account already has a license for the app", func() {
 		BeforeEach(func() {
-			mockMachine.EXPECT().
-				MacAddress().
-				Return("00:00:00:00:00:00", nil)
-
 			mockPurchaseClient.EXPECT().
 				Send(gomock.Any()).
 				Return(http.Result[purchaseResult]{
@@ -195,10 +168,6 @@ var _ = Describe("AppStore (Purchase)", func() {
 
 	When("subscription is required", func() {
 		BeforeEach(func() {
-			mockMachine.EXPECT().
-				MacAddress().
-				Return("00:00:00:00:00:00", nil)
-
 			mockPurchaseClient.EXPECT().
 				Send(pricingParametersMatcher{"STDQ"}).
 				Return(http.Result[purchaseResult]{
@@ -231,10 +200,6 @@ var _ = Describe("AppStore (Purchase)", func() {
 
 	When("successfully purchases the app", func() {
 		BeforeEach(func() {
-			mockMachine.EXPECT().
-				MacAddress().
-				Return("00:00:00:00:00:00", nil)
-
 			mockPurchaseClient.EXPECT().
 				Send(pricingParametersMatcher{"STDQ"}).
 				Return(http.Result[purchaseResult]{
@@ -268,10 +233,6 @@ var _ = Describe("AppStore (Purchase)", func() {
 
 	When("purchasing the app fails", func() {
 		BeforeEach(func() {
-			mockMachine.EXPECT().
-				MacAddress().
-				Return("00:00:00:00:00:00", nil)
-
 			mockPurchaseClient.EXPECT().
 				Send(gomock.Any()).
 				Return(http.Result[purchaseResult]{
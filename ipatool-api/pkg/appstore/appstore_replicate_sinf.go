@@ -24,63 +24,46 @@ type ReplicateSinfInput struct {
 	PackagePath string
 }
 
+// ReplicateSinf adds the sinfs to the package in place. The existing entries are kept as they are and only the
+// central directory is rewritten, so the time it takes does not depend on the size of the package.
 func (t *appstore) ReplicateSinf(input ReplicateSinfInput) error {
-	zipReader, err := zip.OpenReader(input.PackagePath)
-	if err != nil {
-		return errors.New("failed to open zip reader")
-	}
-
-	tmpPath := fmt.Sprintf("%s.tmp", input.PackagePath)
-	tmpFile, err := t.os.OpenFile(tmpPath, os.O_CREATE|os.O_WRONLY, 0644)
-
+	file, err := t.os.OpenFile(input.PackagePath, os.O_RDWR, 0644)
 	if err != nil {
 		return fmt.Errorf("failed to open file: %w", err)
 	}
+	defer file.Close()
 
-	zipWriter := zip.NewWriter(tmpFile)
-
-	err = t.replicateZip(zipReader, zipWriter)
-	if err != nil {
-		return fmt.Errorf("failed to replicate zip: %w", err)
-	}
-
-	bundleName, err := t.readBundleName(zipReader)
-	if err != nil {
-		return fmt.Errorf("failed to read bundle name: %w", err)
-	}
-
-	manifest, err := t.readManifestPlist(zipReader)
-	if err != nil {
-		return fmt.Errorf("failed to read manifest plist: %w", err)
-	}
-
-	info, err := t.readInfoPlist(zipReader)
-	if err != nil {
-		return fmt.Errorf("failed to read info plist: %w", err)
-	}
+	err = t.patchFile(file, func(reader *zip.Reader) ([]archiveEntry, error) {
+		bundleName, err := t.readBundleName(reader)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read bundle name: %w", err)
+		}
 
-	if manifest != nil {
-		err = t.replicateSinfFromManifest(*manifest, zipWriter, input.Sinfs, bundleName)
-	} else {
-		err = t.replicateSinfFromInfo(*info, zipWriter, input.Sinfs, bundleName)
-	}
+		manifest, err := t.readManifestPlist(reader)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read manifest plist: %w", err)
+		}
 
-	if err != nil {
-		return fmt.Errorf("failed to replicate sinf: %w", err)
-	}
+		info, err := t.readInfoPlist(reader)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read info plist: %w", err)
+		}
 
-	zipReader.Close()
-	zipWriter.Close()
-	tmpFile.Close()
+		var entries []archiveEntry
+		if manifest != nil {
+			entries, err = t.replicateSinfFromManifest(*manifest, input.Sinfs, bundleName)
+		} else {
+			entries, err = t.replicateSinfFromInfo(*info, input.Sinfs, bundleName)
+		}
 
-	err = t.os.Remove(input.PackagePath)
-	if err != nil {
-		return fmt.Errorf("failed to remove original file: %w", err)
-	}
+		if err != nil {
+			return nil, fmt.Errorf("failed to replicate sinf: %w", err)
+		}
 
-	err = t.os.Rename(tmpPath, input.PackagePath)
+		return entries, nil
+	})
 	if err != nil {
-		return fmt.Errorf("failed to remove original file: %w", err)
+		return fmt.Errorf("failed to patch package: %w", err)
 	}
 
 	return nil
@@ -94,69 +77,33 @@ type packageInfo struct {
 	BundleExecutable string `plist:"CFBundleExecutable,omitempty"`
 }
 
-func (*appstore) replicateSinfFromManifest(manifest packageManifest, zip *zip.Writer, sinfs []Sinf, bundleName string) error {
+// replicateSinfFromManifest places the sinfs at the paths listed in SC_Info/Manifest.plist.
+func (*appstore) replicateSinfFromManifest(manifest packageManifest, sinfs []Sinf, bundleName string) ([]archiveEntry, error) {
 	zipped, err := util.Zip(sinfs, manifest.SinfPaths)
 	if err != nil {
-		return fmt.Errorf("failed to zip sinfs: %w", err)
+		return nil, fmt.Errorf("failed to zip sinfs: %w", err)
 	}
 
+	entries := make([]archiveEntry, 0, len(zipped))
 	for _, pair := range zipped {
-		sp := fmt.Sprintf("Payload/%s.app/%s", bundleName, pair.Second)
-
-		file, err := zip.Create(sp)
-		if err != nil {
-			return fmt.Errorf("failed to create file: %w", err)
-		}
-
-		_, err = file.Write(pair.First.Data)
-		if err != nil {
-			return fmt.Errorf("failed to write data: %w", err)
-		}
+		entries = append(entries, archiveEntry{
+			Name: fmt.Sprintf("Payload/%s.app/%s", bundleName, pair.Second),
+			Data: pair.First.Data,
+		})
 	}
 
-	return nil
+	return entries, nil
 }
 
-func (t *appstore) replicateSinfFromInfo(info packageInfo, zip *zip.Writer, sinfs []Sinf, bundleName string) error {
-	sp := fmt.Sprintf("Payload/%s.app/SC_Info/%s.sinf", bundleName, info.BundleExecutable)
-
-	file, err := zip.Create(sp)
-	if err != nil {
-		return fmt.Errorf("failed to create file: %w", err)
-	}
-
-	_, err = file.Write(sinfs[0].Data)
-	if err != nil {
-		return fmt.Errorf("failed to write data: %w", err)
-	}
-
-	return nil
-}
-
-func (t *appstore) replicateZip(src *zip.ReadCloser, dst *zip.Writer) error {
-	for _, file := range src.File {
-		srcFile, err := file.OpenRaw()
-		if err != nil {
-			return fmt.Errorf("failed to open raw file: %w", err)
-		}
-
-		header := file.FileHeader
-		dstFile, err := dst.CreateRaw(&header)
-
-		if err != nil {
-			return fmt.Errorf("failed to create raw file: %w", err)
-		}
-
-		_, err = io.Copy(dstFile, srcFile)
-		if err != nil {
-			return fmt.Errorf("failed to copy file: %w", err)
-		}
-	}
-
-	return nil
+// replicateSinfFromInfo places the first sinf at SC_Info/<executable>.sinf, for packages without a manifest.
+func (t *appstore) replicateSinfFromInfo(info packageInfo, sinfs []Sinf, bundleName string) ([]archiveEntry, error) {
+	return []archiveEntry{{
+		Name: fmt.Sprintf("Payload/%s.app/SC_Info/%s.sinf", bundleName, info.BundleExecutable),
+		Data: sinfs[0].Data,
+	}}, nil
 }
 
-func (*appstore) readInfoPlist(reader *zip.ReadCloser) (*packageInfo, error) {
+func (*appstore) readInfoPlist(reader *zip.Reader) (*packageInfo, error) {
 	for _, file := range reader.File {
 		if strings.Contains(file.Name, ".app/Info.plist") {
 			src, err := file.Open()
@@ -185,7 +132,7 @@ func (*appstore) readInfoPlist(reader *zip.ReadCloser) (*packageInfo, error) {
 	return nil, nil
 }
 
-func (*appstore) readManifestPlist(reader *zip.ReadCloser) (*packageManifest, error) {
+func (*appstore) readManifestPlist(reader *zip.Reader) (*packageManifest, error) {
 	for _, file := range reader.File {
 		if strings.HasSuffix(file.Name, ".app/SC_Info/Manifest.plist") {
 			src, err := file.Open()
@@ -214,7 +161,7 @@ func (*appstore) readManifestPlist(reader *zip.ReadCloser) (*packageManifest, er
 	return nil, nil
 }
 
-func (*appstore) readBundleName(reader *zip.ReadCloser) (string, error) {
+func (*appstore) readBundleName(reader *zip.Reader) (string, error) {
 	var bundleName string
 
 	for _, file := range reader.File {
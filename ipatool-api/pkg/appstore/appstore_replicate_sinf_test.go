@@ -2,9 +2,11 @@ package appstore
 
 import (
 	"archive/zip"
+	"bytes"
 	"errors"
-	"fmt"
+	"io"
 	"os"
+	"strings"
 
 	"github.com/majd/ipatool/v2/pkg/http"
 	"github.com/majd/ipatool/v2/pkg/keychain"
@@ -50,6 +52,29 @@ var _ = Describe("AppStore (ReplicateSinf)", func() {
 			os:             mockOS,
 		}
 
+		// The recovery file of a patch is kept next to the package.
+		isPatchRecovery := gomock.Cond(func(name any) bool { return strings.Contains(name.(string), patchRecoverySuffix) })
+
+		mockOS.EXPECT().
+			OpenFile(isPatchRecovery, gomock.Any(), gomock.Any()).
+			DoAndReturn(os.OpenFile).
+			AnyTimes()
+
+		mockOS.EXPECT().
+			Rename(isPatchRecovery, gomock.Any()).
+			DoAndReturn(os.Rename).
+			AnyTimes()
+
+		mockOS.EXPECT().
+			Remove(isPatchRecovery).
+			DoAndReturn(os.Remove).
+			AnyTimes()
+
+		mockOS.EXPECT().
+			IsNotExist(gomock.Cond(func(err any) bool { return os.IsNotExist(err.(error)) })).
+			Return(true).
+			AnyTimes()
+
 		var err error
 		testFile, err = os.CreateTemp("", "test_file")
 		Expect(err).ToNot(HaveOccurred())
@@ -61,6 +86,21 @@ var _ = Describe("AppStore (ReplicateSinf)", func() {
 		testZip.Close()
 	})
 
+	readEntry := func(name string) string {
+		reader, err := zip.OpenReader(testFile.Name())
+		Expect(err).ToNot(HaveOccurred())
+		defer reader.Close()
+
+		file, err := reader.Open(name)
+		Expect(err).ToNot(HaveOccurred())
+		defer file.Close()
+
+		data, err := io.ReadAll(file)
+		Expect(err).ToNot(HaveOccurred())
+
+		return string(data)
+	}
+
 	AfterEach(func() {
 		err := os.Remove(testFile.Name())
 		Expect(err).ToNot(HaveOccurred())
@@ -74,14 +114,6 @@ var _ = Describe("AppStore (ReplicateSinf)", func() {
 				OpenFile(gomock.Any(), gomock.Any(), gomock.Any()).
 				DoAndReturn(os.OpenFile)
 
-			mockOS.EXPECT().
-				Remove(testFile.Name()).
-				Return(nil)
-
-			mockOS.EXPECT().
-				Rename(fmt.Sprintf("%s.tmp", testFile.Name()), testFile.Name()).
-				Return(nil)
-
 			manifest, err := plist.Marshal(packageManifest{
 				SinfPaths: []string{
 					"SC_Info/TestApp.sinf",
@@ -119,16 +151,28 @@ var _ = Describe("AppStore (ReplicateSinf)", func() {
 		})
 
 		It("replicates sinf from manifest plist", func() {
-			err := as.ReplicateSinf(ReplicateSinfInput{
+			original, err := os.ReadFile(testFile.Name())
+			Expect(err).ToNot(HaveOccurred())
+
+			err = as.ReplicateSinf(ReplicateSinfInput{
 				PackagePath: testFile.Name(),
 				Sinfs: []Sinf{
 					{
 						ID:   0,
-						Data: []byte(""),
+						Data: []byte("sinf"),
 					},
 				},
 			})
 			Expect(err).ToNot(HaveOccurred())
+			Expect(readEntry("Payload/Test.app/SC_Info/TestApp.sinf")).To(Equal("sinf"))
+
+			// The existing entries are left in place.
+			tail, err := readArchiveTail(bytes.NewReader(original), int64(len(original)))
+			Expect(err).ToNot(HaveOccurred())
+
+			patched, err := os.ReadFile(testFile.Name())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(patched[:tail.offset]).To(Equal(original[:tail.offset]))
 		})
 	})
 
@@ -138,14 +182,6 @@ var _ = Describe("AppStore (ReplicateSinf)", func() {
 				OpenFile(gomock.Any(), gomock.Any(), gomock.Any()).
 				DoAndReturn(os.OpenFile)
 
-			mockOS.EXPECT().
-				Remove(testFile.Name()).
-				Return(nil)
-
-			mockOS.EXPECT().
-				Rename(fmt.Sprintf("%s.tmp", testFile.Name()), testFile.Name()).
-				Return(nil)
-
 			w, err := testZip.Create("Payload/Test.app/Info.plist")
 			Expect(err).ToNot(HaveOccurred())
 
@@ -175,11 +211,12 @@ var _ = Describe("AppStore (ReplicateSinf)", func() {
 				Sinfs: []Sinf{
 					{
 						ID:   0,
-						Data: []byte(""),
+						Data: []byte("sinf"),
 					},
 				},
 			})
 			Expect(err).ToNot(HaveOccurred())
+			Expect(readEntry("Payload/Test.app/SC_Info/Test.sinf")).To(Equal("sinf"))
 		})
 	})
 
@@ -0,0 +1,298 @@
+package appstore
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrVersionNotFound = errors.New("no version matches the selector")
+
+// maxDisplayVersionScan bounds the number of versions scanned for a display version when the binary search
+// misses because display versions do not increase with every release.
+const maxDisplayVersionScan = 50
+
+// maxCompatibleVersionScan bounds the number of versions whose minimum iOS version is read when looking for
+// a compatible version, since every one of them costs a few range requests to the CDN.
+const maxCompatibleVersionScan = 50
+
+// VersionSelector selects a version of an app without knowing its external version identifier.
+// Exactly one field should be set.
+type VersionSelector struct {
+	// DisplayVersion selects the newest version with this display version, e.g. "5.2.1".
+	DisplayVersion string
+	// AsOf selects the version that was current at this time, i.e. the newest one released before it.
+	AsOf time.Time
+	// Previous selects the N-th version before the latest one.
+	Previous int
+	// CompatibleWith selects the newest version whose minimum iOS version is at most this iOS version.
+	CompatibleWith string
+}
+
+// IsZero reports whether no version is selected.
+func (s VersionSelector) IsZero() bool {
+	return s.DisplayVersion == "" && s.AsOf.IsZero() && s.Previous == 0 && s.CompatibleWith == ""
+}
+
+type ResolveVersionInput struct {
+	Account  Account
+	App      App
+	Selector VersionSelector
+}
+
+type ResolveVersionOutput struct {
+	ExternalVersionID string
+	// DisplayVersion, ReleaseDate and MinimumOSVersion are only set if they had to be resolved to find
+	// the version.
+	DisplayVersion   string
+	ReleaseDate      time.Time
+	MinimumOSVersion string
+}
+
+// ResolveVersion finds the external version identifier selected by the selector. Versions are ordered by
+// their identifiers, which the App Store assigns in increasing order, so that release dates and display
+// versions can be binary searched with a logarithmic number of metadata requests.
+func (t *appstore) ResolveVersion(input ResolveVersionInput) (ResolveVersionOutput, error) {
+	selector := input.Selector
+
+	if selector.Previous < 0 {
+		return ResolveVersionOutput{}, errors.New("previous must not be negative")
+	}
+
+	versions, err := t.ListVersions(ListVersionsInput{Account: input.Account, App: input.App})
+	if err != nil {
+		return ResolveVersionOutput{}, err
+	}
+
+	ids := sortVersionIDsNewestFirst(versions.ExternalVersionIdentifiers)
+	if len(ids) == 0 {
+		return ResolveVersionOutput{}, ErrVersionNotFound
+	}
+
+	// Oldest first, so that sort.Search finds the first version past the target.
+	oldestFirst := make([]string, len(ids))
+	for i, id := range ids {
+		oldestFirst[len(ids)-1-i] = id
+	}
+
+	search := versionSearch{appstore: t, account: input.Account, app: input.App, ids: oldestFirst}
+
+	switch {
+	case selector.DisplayVersion != "":
+		return search.byDisplayVersion(selector.DisplayVersion)
+	case !selector.AsOf.IsZero():
+		return search.asOf(selector.AsOf)
+	case selector.CompatibleWith != "":
+		return search.compatibleWith(selector.CompatibleWith)
+	default:
+		if selector.Previous >= len(ids) {
+			return ResolveVersionOutput{}, fmt.Errorf("%w: the app only has %d versions", ErrVersionNotFound, len(ids))
+		}
+
+		return ResolveVersionOutput{ExternalVersionID: ids[selector.Previous]}, nil
+	}
+}
+
+// versionSearch binary searches the versions of an app, oldest first, resolving metadata on demand.
+type versionSearch struct {
+	appstore *appstore
+	account  Account
+	app      App
+	ids      []string
+	err      error
+	// resolved holds the metadata resolved so far by index, so that no version is requested twice.
+	resolved map[int]GetVersionMetadataOutput
+}
+
+func (s *versionSearch) metadata(i int) GetVersionMetadataOutput {
+	if s.err != nil {
+		return GetVersionMetadataOutput{}
+	}
+
+	if metadata, ok := s.resolved[i]; ok {
+		return metadata
+	}
+
+	metadata, err := s.appstore.GetVersionMetadata(GetVersionMetadataInput{
+		Account:   s.account,
+		App:       s.app,
+		VersionID: s.ids[i],
+	})
+	if err != nil {
+		s.err = fmt.Errorf("failed to get metadata of version %s: %w", s.ids[i], err)
+
+		return metadata
+	}
+
+	if s.resolved == nil {
+		s.resolved = map[int]GetVersionMetadataOutput{}
+	}
+
+	s.resolved[i] = metadata
+
+	return metadata
+}
+
+// monotonic reports whether the display versions resolved so far, and those of the oldest and newest versions,
+// increase with the identifiers.
+func (s *versionSearch) monotonic() bool {
+	s.metadata(0)
+	s.metadata(len(s.ids) - 1)
+
+	indexes := make([]int, 0, len(s.resolved))
+	for i := range s.resolved {
+		indexes = append(indexes, i)
+	}
+
+	sort.Ints(indexes)
+
+	for k := 1; k < len(indexes); k++ {
+		if compareDisplayVersions(s.resolved[indexes[k-1]].DisplayVersion, s.resolved[indexes[k]].DisplayVersion) > 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (s *versionSearch) result(i int) (ResolveVersionOutput, error) {
+	metadata := s.metadata(i)
+	if s.err != nil {
+		return ResolveVersionOutput{}, s.err
+	}
+
+	return ResolveVersionOutput{
+		ExternalVersionID: s.ids[i],
+		DisplayVersion:    metadata.DisplayVersion,
+		ReleaseDate:       metadata.ReleaseDate,
+	}, nil
+}
+
+func (s *versionSearch) asOf(at time.Time) (ResolveVersionOutput, error) {
+	next := sort.Search(len(s.ids), func(i int) bool {
+		return s.metadata(i).ReleaseDate.After(at)
+	})
+
+	if s.err != nil {
+		return ResolveVersionOutput{}, s.err
+	}
+
+	if next == 0 {
+		return ResolveVersionOutput{}, fmt.Errorf("%w: the app was not released before %s", ErrVersionNotFound, at.Format(time.RFC3339))
+	}
+
+	return s.result(next - 1)
+}
+
+// byDisplayVersion binary searches by comparing display versions. If the search misses and the versions it
+// resolved show that display versions do not increase with every release, the newest versions are scanned
+// instead, up to maxDisplayVersionScan of them. A display version that does not exist costs a logarithmic
+// number of requests.
+func (s *versionSearch) byDisplayVersion(displayVersion string) (ResolveVersionOutput, error) {
+	next := sort.Search(len(s.ids), func(i int) bool {
+		return compareDisplayVersions(s.metadata(i).DisplayVersion, displayVersion) > 0
+	})
+
+	if s.err != nil {
+		return ResolveVersionOutput{}, s.err
+	}
+
+	if next > 0 && s.metadata(next-1).DisplayVersion == displayVersion {
+		return s.result(next - 1)
+	}
+
+	if s.monotonic() || s.err != nil {
+		if s.err != nil {
+			return ResolveVersionOutput{}, s.err
+		}
+
+		return ResolveVersionOutput{}, fmt.Errorf("%w: no version %s", ErrVersionNotFound, displayVersion)
+	}
+
+	for i := len(s.ids) - 1; i >= max(len(s.ids)-maxDisplayVersionScan, 0); i-- {
+		if s.metadata(i).DisplayVersion == displayVersion {
+			return s.result(i)
+		}
+
+		if s.err != nil {
+			return ResolveVersionOutput{}, s.err
+		}
+	}
+
+	return ResolveVersionOutput{}, fmt.Errorf("%w: no version %s among the %d newest versions", ErrVersionNotFound, displayVersion, min(len(s.ids), maxDisplayVersionScan))
+}
+
+// compatibleWith walks the versions newest first, because the minimum iOS version is read from each
+// package, and most requests are satisfied by one of the recent versions. At most maxCompatibleVersionScan
+// versions are checked. Versions whose minimum iOS version cannot be read are skipped, so that one broken
+// package does not hide the older ones.
+func (s *versionSearch) compatibleWith(osVersion string) (ResolveVersionOutput, error) {
+	var (
+		checked bool
+		lastErr error
+	)
+
+	oldest := max(len(s.ids)-maxCompatibleVersionScan, 0)
+
+	for i := len(s.ids) - 1; i >= oldest; i-- {
+		minimumOSVersion, err := s.appstore.minimumOSVersion(s.account, s.app, s.ids[i])
+		if err != nil {
+			lastErr = fmt.Errorf("failed to get minimum iOS version of version %s: %w", s.ids[i], err)
+
+			if s.appstore.logger != nil {
+				s.appstore.logger.Log().Err(err).Str("externalVersionID", s.ids[i]).Msg("Skipping version with unknown minimum iOS version")
+			}
+
+			continue
+		}
+
+		checked = true
+
+		if compareDisplayVersions(minimumOSVersion, osVersion) <= 0 {
+			return ResolveVersionOutput{ExternalVersionID: s.ids[i], MinimumOSVersion: minimumOSVersion}, nil
+		}
+	}
+
+	// Report the failure rather than a missing version when no version could be checked at all.
+	if !checked && lastErr != nil {
+		return ResolveVersionOutput{}, lastErr
+	}
+
+	return ResolveVersionOutput{}, fmt.Errorf("%w: none of the %d newest versions supports iOS %s", ErrVersionNotFound, len(s.ids)-oldest, osVersion)
+}
+
+// compareDisplayVersions compares dot-separated versions component by component, numerically where both
+// components are numbers. Missing components count as zero, so "16" equals "16.0".
+func compareDisplayVersions(a, b string) int {
+	partsA := strings.Split(a, ".")
+	partsB := strings.Split(b, ".")
+
+	for i := 0; i < max(len(partsA), len(partsB)); i++ {
+		partA, partB := "0", "0"
+
+		if i < len(partsA) {
+			partA = partsA[i]
+		}
+
+		if i < len(partsB) {
+			partB = partsB[i]
+		}
+
+		numA, errA := strconv.Atoi(partA)
+		numB, errB := strconv.Atoi(partB)
+
+		switch {
+		case partA == partB:
+			continue
+		case errA == nil && errB == nil:
+			return numA - numB
+		default:
+			return strings.Compare(partA, partB)
+		}
+	}
+
+	return 0
+}
@@ -0,0 +1,171 @@
+package appstore
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/majd/ipatool/v2/pkg/http"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"go.uber.org/mock/gomock"
+)
+
+var _ = Describe("AppStore (ResolveVersion)", func() {
+	var (
+		ctrl               *gomock.Controller
+		mockDownloadClient *http.MockClient[downloadResult]
+		as                 AppStore
+		testApp            = App{ID: 1}
+		displayVersions    []string
+		requests           int
+	)
+
+	BeforeEach(func() {
+		ctrl = gomock.NewController(GinkgoT())
+		mockDownloadClient = http.NewMockClient[downloadResult](ctrl)
+		as = &appstore{
+			downloadClient: mockDownloadClient,
+			deviceGUID:     "GUID",
+		}
+
+		// Version N has the external version identifier 100+N and was released on January N, 2024.
+		displayVersions = make([]string, 16)
+		for i := range displayVersions {
+			displayVersions[i] = fmt.Sprintf("1.%d.0", i+1)
+		}
+
+		requests = 0
+
+		mockDownloadClient.EXPECT().
+			Send(gomock.Any()).
+			DoAndReturn(func(req http.Request) (http.Result[downloadResult], error) {
+				requests++
+				payload, _ := req.Payload.(*http.XMLPayload)
+
+				versionID, ok := payload.Content["externalVersionId"].(string)
+				if !ok {
+					ids := make([]interface{}, len(displayVersions))
+					for i := range displayVersions {
+						ids[i] = strconv.Itoa(101 + i)
+					}
+
+					return http.Result[downloadResult]{Data: downloadResult{Items: []downloadItemResult{{
+						Metadata: map[string]interface{}{
+							"softwareVersionExternalIdentifiers": ids,
+							"softwareVersionExternalIdentifier":  ids[len(ids)-1],
+						},
+					}}}}, nil
+				}
+
+				n, _ := strconv.Atoi(versionID)
+				n -= 100
+
+				return http.Result[downloadResult]{Data: downloadResult{Items: []downloadItemResult{{
+					Metadata: map[string]interface{}{
+						"bundleShortVersionString": displayVersions[n-1],
+						"releaseDate":              time.Date(2024, 1, n, 12, 0, 0, 0, time.UTC).Format(time.RFC3339),
+					},
+				}}}}, nil
+			}).
+			AnyTimes()
+	})
+
+	AfterEach(func() {
+		ctrl.Finish()
+	})
+
+	resolve := func(selector VersionSelector) (ResolveVersionOutput, error) {
+		return as.ResolveVersion(ResolveVersionInput{App: testApp, Selector: selector})
+	}
+
+	It("selects the N-th previous version without resolving metadata", func() {
+		out, err := resolve(VersionSelector{Previous: 3})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(out.ExternalVersionID).To(Equal("113"))
+		Expect(requests).To(Equal(1))
+
+		_, err = resolve(VersionSelector{Previous: 16})
+		Expect(err).To(MatchError(ErrVersionNotFound))
+	})
+
+	It("binary searches the version current at a date", func() {
+		out, err := resolve(VersionSelector{AsOf: time.Date(2024, 1, 5, 23, 59, 59, 0, time.UTC)})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(out.ExternalVersionID).To(Equal("105"))
+		Expect(out.DisplayVersion).To(Equal("1.5.0"))
+		Expect(requests).To(BeNumerically("<=", 7))
+
+		out, err = resolve(VersionSelector{AsOf: time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(out.ExternalVersionID).To(Equal("116"))
+
+		_, err = resolve(VersionSelector{AsOf: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)})
+		Expect(err).To(MatchError(ErrVersionNotFound))
+	})
+
+	It("binary searches a display version", func() {
+		out, err := resolve(VersionSelector{DisplayVersion: "1.10.0"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(out.ExternalVersionID).To(Equal("110"))
+		Expect(requests).To(BeNumerically("<=", 7))
+	})
+
+	It("does not scan the versions for a display version that does not exist", func() {
+		_, err := resolve(VersionSelector{DisplayVersion: "1.10.1"})
+		Expect(err).To(MatchError(ErrVersionNotFound))
+		Expect(requests).To(BeNumerically("<=", 8))
+	})
+
+	It("selects the newest of several versions with the same display version", func() {
+		displayVersions[6] = "1.6.0"
+
+		out, err := resolve(VersionSelector{DisplayVersion: "1.6.0"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(out.ExternalVersionID).To(Equal("107"))
+	})
+
+	When("display versions do not increase", func() {
+		// Versioning restarted after the first half of the versions: 5.1.0 to 5.N.0, then 1.1.0 onwards.
+		restart := func(count int) {
+			displayVersions = make([]string, count)
+			for i := range displayVersions {
+				if i < count/2 {
+					displayVersions[i] = fmt.Sprintf("5.%d.0", i+1)
+				} else {
+					displayVersions[i] = fmt.Sprintf("1.%d.0", i+1-count/2)
+				}
+			}
+		}
+
+		It("scans the newest versions", func() {
+			restart(16)
+
+			out, err := resolve(VersionSelector{DisplayVersion: "5.3.0"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(out.ExternalVersionID).To(Equal("103"))
+		})
+
+		It("stops scanning after the newest versions", func() {
+			restart(2 * maxDisplayVersionScan)
+
+			_, err := resolve(VersionSelector{DisplayVersion: "5.3.0"})
+			Expect(err).To(MatchError(ErrVersionNotFound))
+			Expect(requests).To(BeNumerically("<=", maxDisplayVersionScan+10))
+		})
+	})
+})
+
+var _ = Describe("compareDisplayVersions", func() {
+	DescribeTable("compares versions",
+		func(a, b string, expected int) {
+			Expect(compareDisplayVersions(a, b)).To(BeNumerically("==", expected))
+		},
+		Entry("equal", "1.2.3", "1.2.3", 0),
+		Entry("numeric components", "1.10", "1.9", 1),
+		Entry("missing component", "16", "16.0", 0),
+		Entry("missing non-zero component", "16", "16.0.1", -1),
+		Entry("empty version", "", "12.0", -1),
+		Entry("non-numeric components", "1.0b", "1.0a", 1),
+	)
+})
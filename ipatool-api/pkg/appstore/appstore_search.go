@@ -29,8 +29,8 @@ func (t *appstore) Search(input SearchInput) (SearchOutput, error) {
 
 	// Use specified country code if provided, otherwise use account's storefront
 	if input.CountryCode != "" {
-		// Validate country code exists in storeFronts
-		if _, exists := storeFronts[strings.ToUpper(input.CountryCode)]; !exists {
+		// Validate country code has a storefront
+		if _, exists := CountryByCode(input.CountryCode); !exists {
 			return SearchOutput{}, fmt.Errorf("invalid country code: %s", input.CountryCode)
 		}
 		countryCode = strings.ToUpper(input.CountryCode)
@@ -42,6 +42,7 @@ func (t *appstore) Search(input SearchInput) (SearchOutput, error) {
 	}
 
 	request := t.searchRequest(input.Term, countryCode, input.Limit)
+	request.Proxy = input.Account.Proxy
 
 	res, err := t.searchClient.Send(request)
 	if err != nil {
@@ -79,5 +80,5 @@ func (t *appstore) searchURL(term, countryCode string, limit int64) string {
 	params.Add("term", term)
 	params.Add("country", countryCode)
 
-	return fmt.Sprintf("https://%s%s?%s", iTunesAPIDomain, iTunesAPIPathSearch, params.Encode())
+	return fmt.Sprintf("%s%s?%s", t.endpoints.withDefaults().ITunesAPI, iTunesAPIPathSearch, params.Encode())
 }
@@ -0,0 +1,300 @@
+package appstore
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"slices"
+	"strings"
+
+	"howett.net/plist"
+)
+
+type SinfBundleKind string
+
+const (
+	SinfBundleApp       SinfBundleKind = "app"
+	SinfBundleExtension SinfBundleKind = "extension"
+	SinfBundleWatch     SinfBundleKind = "watch"
+)
+
+type SinfStatus string
+
+const (
+	SinfStatusOK SinfStatus = "ok"
+	// SinfStatusMissing is a sinf listed in the manifest that is not in the package.
+	SinfStatusMissing SinfStatus = "missing"
+	SinfStatusEmpty   SinfStatus = "empty"
+	// SinfStatusMismatch is a sinf that differs from the sinf of the account for the same path.
+	SinfStatusMismatch SinfStatus = "mismatch"
+	// SinfStatusUnlisted is a sinf in the package that the manifest does not list. It is ignored by the device.
+	SinfStatusUnlisted SinfStatus = "unlisted"
+)
+
+type VerifySinfInput struct {
+	PackagePath string
+	// Sinfs are the sinfs of the account, in the order of the manifest. If set, the sinfs of the package are
+	// compared with them.
+	Sinfs []Sinf
+}
+
+type VerifySinfOutput struct {
+	// Bundles are the main app, its extensions and its Watch apps, main app first.
+	Bundles []SinfBundle
+	// Problems explains why the package may not launch. It is empty if every sinf is in place.
+	Problems []string
+}
+
+// Valid reports whether every sinf is in place.
+func (o VerifySinfOutput) Valid() bool {
+	return len(o.Problems) == 0
+}
+
+type SinfBundle struct {
+	Path  string
+	Kind  SinfBundleKind
+	Sinfs []SinfCheck
+}
+
+type SinfCheck struct {
+	Path   string
+	Size   int64
+	Status SinfStatus
+}
+
+// expectedSinf is a sinf the device looks for, with the sinf of the account that replicateSinf places there.
+type expectedSinf struct {
+	path string
+	sinf *Sinf
+}
+
+func (t *appstore) VerifySinf(input VerifySinfInput) (VerifySinfOutput, error) {
+	file, err := t.os.OpenFile(input.PackagePath, os.O_RDONLY, 0)
+	if err != nil {
+		return VerifySinfOutput{}, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	stat, err := file.Stat()
+	if err != nil {
+		return VerifySinfOutput{}, fmt.Errorf("failed to get file info: %w", err)
+	}
+
+	reader, err := zip.NewReader(file, stat.Size())
+	if err != nil {
+		return VerifySinfOutput{}, fmt.Errorf("failed to open zip reader: %w", err)
+	}
+
+	bundleName, err := t.readBundleName(reader)
+	if err != nil {
+		return VerifySinfOutput{}, fmt.Errorf("failed to read bundle name: %w", err)
+	}
+
+	mainPath := fmt.Sprintf("Payload/%s.app", bundleName)
+
+	expected, problems, err := t.expectedSinfs(reader, mainPath, input.Sinfs)
+	if err != nil {
+		return VerifySinfOutput{}, err
+	}
+
+	files := map[string]*zip.File{}
+	for _, f := range reader.File {
+		files[f.Name] = f
+	}
+
+	bundles := sinfBundles(reader, mainPath)
+	listed := map[string]bool{}
+
+	for _, sinf := range expected {
+		listed[sinf.path] = true
+
+		check, err := checkSinf(files[sinf.path], sinf)
+		if err != nil {
+			return VerifySinfOutput{}, err
+		}
+
+		if check.Status != SinfStatusOK {
+			problems = append(problems, fmt.Sprintf("%s is %s", sinf.path, check.Status))
+		}
+
+		addSinfCheck(bundles, check)
+	}
+
+	for _, f := range reader.File {
+		if strings.HasSuffix(f.Name, ".sinf") && strings.Contains(f.Name, "/SC_Info/") && !listed[f.Name] {
+			addSinfCheck(bundles, SinfCheck{Path: f.Name, Size: int64(f.UncompressedSize64), Status: SinfStatusUnlisted})
+		}
+	}
+
+	return VerifySinfOutput{Bundles: bundles, Problems: problems}, nil
+}
+
+// expectedSinfs returns the sinfs the package needs: the paths of SC_Info/Manifest.plist or, without a manifest,
+// SC_Info/<executable>.sinf of the main app. Sinfs of the account are paired with the paths like replicateSinf
+// pairs them; a count that does not match is reported as a problem.
+func (t *appstore) expectedSinfs(reader *zip.Reader, mainPath string, sinfs []Sinf) ([]expectedSinf, []string, error) {
+	var problems []string
+
+	// Watch apps may have a manifest of their own; the device reads the manifest of the main app.
+	var manifest *packageManifest
+
+	if slices.ContainsFunc(reader.File, func(f *zip.File) bool { return f.Name == mainPath+"/SC_Info/Manifest.plist" }) {
+		manifest = &packageManifest{}
+
+		err := readPlistFile(reader, mainPath+"/SC_Info/Manifest.plist", manifest)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read manifest plist: %w", err)
+		}
+	}
+
+	var paths []string
+
+	if manifest != nil {
+		for _, sinfPath := range manifest.SinfPaths {
+			paths = append(paths, path.Join(mainPath, sinfPath))
+		}
+
+		if len(sinfs) > 0 && len(sinfs) != len(paths) {
+			problems = append(problems, fmt.Sprintf("the account has %d sinfs for the %d paths of the manifest", len(sinfs), len(paths)))
+			sinfs = nil
+		}
+	} else {
+		var info packageInfo
+
+		err := readPlistFile(reader, mainPath+"/Info.plist", &info)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read info plist: %w", err)
+		}
+
+		paths = append(paths, fmt.Sprintf("%s/SC_Info/%s.sinf", mainPath, info.BundleExecutable))
+	}
+
+	expected := make([]expectedSinf, 0, len(paths))
+	for i, sinfPath := range paths {
+		sinf := expectedSinf{path: sinfPath}
+		if i < len(sinfs) {
+			sinf.sinf = &sinfs[i]
+		}
+
+		expected = append(expected, sinf)
+	}
+
+	return expected, problems, nil
+}
+
+func checkSinf(f *zip.File, expected expectedSinf) (SinfCheck, error) {
+	check := SinfCheck{Path: expected.path, Status: SinfStatusOK}
+
+	if f == nil {
+		check.Status = SinfStatusMissing
+
+		return check, nil
+	}
+
+	check.Size = int64(f.UncompressedSize64)
+	if check.Size == 0 {
+		check.Status = SinfStatusEmpty
+
+		return check, nil
+	}
+
+	if expected.sinf != nil {
+		data, err := readZipFile(f)
+		if err != nil {
+			return SinfCheck{}, err
+		}
+
+		if !bytes.Equal(data, expected.sinf.Data) {
+			check.Status = SinfStatusMismatch
+		}
+	}
+
+	return check, nil
+}
+
+// sinfBundles lists the bundles that can hold a sinf: every .app and .appex directory with an Info.plist.
+// Frameworks and other bundles are left out.
+func sinfBundles(reader *zip.Reader, mainPath string) []SinfBundle {
+	var bundles []SinfBundle
+
+	for _, f := range reader.File {
+		dir, ok := strings.CutSuffix(f.Name, "/Info.plist")
+		if !ok || (dir != mainPath && !strings.HasPrefix(dir, mainPath+"/")) {
+			continue
+		}
+
+		kind := SinfBundleApp
+
+		switch {
+		case strings.HasSuffix(dir, ".appex"):
+			kind = SinfBundleExtension
+		case strings.HasSuffix(dir, ".app") && strings.Contains(dir, "/Watch/"):
+			kind = SinfBundleWatch
+		case !strings.HasSuffix(dir, ".app"):
+			continue
+		}
+
+		bundles = append(bundles, SinfBundle{Path: dir, Kind: kind})
+	}
+
+	slices.SortFunc(bundles, func(a, b SinfBundle) int {
+		return strings.Compare(a.Path, b.Path)
+	})
+
+	return bundles
+}
+
+// addSinfCheck adds the check to the innermost bundle that contains the sinf, the main app by default.
+func addSinfCheck(bundles []SinfBundle, check SinfCheck) {
+	index := 0
+
+	for i, bundle := range bundles {
+		if strings.HasPrefix(check.Path, bundle.Path+"/") && len(bundle.Path) > len(bundles[index].Path) {
+			index = i
+		}
+	}
+
+	if len(bundles) > 0 {
+		bundles[index].Sinfs = append(bundles[index].Sinfs, check)
+	}
+}
+
+func readPlistFile(reader *zip.Reader, name string, v interface{}) error {
+	for _, f := range reader.File {
+		if f.Name != name {
+			continue
+		}
+
+		data, err := readZipFile(f)
+		if err != nil {
+			return err
+		}
+
+		_, err = plist.Unmarshal(data, v)
+		if err != nil {
+			return fmt.Errorf("failed to unmarshal data: %w", err)
+		}
+
+		return nil
+	}
+
+	return fmt.Errorf("%s not found", name)
+}
+
+func readZipFile(f *zip.File) ([]byte, error) {
+	src, err := f.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer src.Close()
+
+	data, err := io.ReadAll(src)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read data: %w", err)
+	}
+
+	return data, nil
+}
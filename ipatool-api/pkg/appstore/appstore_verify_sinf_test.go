@@ -0,0 +1,187 @@
+package appstore
+
+import (
+	"archive/zip"
+	"os"
+
+	"github.com/majd/ipatool/v2/pkg/util/operatingsystem"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"go.uber.org/mock/gomock"
+	"howett.net/plist"
+)
+
+var _ = Describe("AppStore (VerifySinf)", func() {
+	var (
+		ctrl     *gomock.Controller
+		mockOS   *operatingsystem.MockOperatingSystem
+		as       AppStore
+		testFile *os.File
+		testZip  *zip.Writer
+	)
+
+	write := func(name string, data []byte) {
+		w, err := testZip.Create(name)
+		Expect(err).ToNot(HaveOccurred())
+
+		_, err = w.Write(data)
+		Expect(err).ToNot(HaveOccurred())
+	}
+
+	writePlist := func(name string, v interface{}) {
+		data, err := plist.Marshal(v, plist.BinaryFormat)
+		Expect(err).ToNot(HaveOccurred())
+
+		write(name, data)
+	}
+
+	BeforeEach(func() {
+		ctrl = gomock.NewController(GinkgoT())
+		mockOS = operatingsystem.NewMockOperatingSystem(ctrl)
+		as = &appstore{
+			os: mockOS,
+		}
+
+		var err error
+		testFile, err = os.CreateTemp("", "test_file")
+		Expect(err).ToNot(HaveOccurred())
+
+		testZip = zip.NewWriter(testFile)
+
+		mockOS.EXPECT().
+			OpenFile(gomock.Any(), gomock.Any(), gomock.Any()).
+			DoAndReturn(os.OpenFile)
+
+		writePlist("Payload/Test.app/Info.plist", map[string]interface{}{"CFBundleExecutable": "Test"})
+	})
+
+	JustBeforeEach(func() {
+		Expect(testZip.Close()).To(Succeed())
+	})
+
+	AfterEach(func() {
+		Expect(os.Remove(testFile.Name())).To(Succeed())
+
+		ctrl.Finish()
+	})
+
+	When("app includes codesign manifest", func() {
+		BeforeEach(func() {
+			writePlist("Payload/Test.app/SC_Info/Manifest.plist", packageManifest{
+				SinfPaths: []string{
+					"SC_Info/Test.sinf",
+					"PlugIns/Widget.appex/SC_Info/Widget.sinf",
+					"Watch/Watch.app/SC_Info/Watch.sinf",
+				},
+			})
+			write("Payload/Test.app/SC_Info/Test.sinf", []byte("sinf-app"))
+			writePlist("Payload/Test.app/PlugIns/Widget.appex/Info.plist", map[string]interface{}{"CFBundleExecutable": "Widget"})
+			write("Payload/Test.app/PlugIns/Widget.appex/SC_Info/Widget.sinf", []byte{})
+			writePlist("Payload/Test.app/Watch/Watch.app/Info.plist", map[string]interface{}{"WKWatchKitApp": true})
+			writePlist("Payload/Test.app/Frameworks/Kit.framework/Info.plist", map[string]interface{}{})
+			write("Payload/Test.app/SC_Info/Old.sinf", []byte("old"))
+		})
+
+		It("lists the sinfs of every bundle", func() {
+			out, err := as.VerifySinf(VerifySinfInput{PackagePath: testFile.Name()})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(out.Valid()).To(BeFalse())
+
+			Expect(out.Bundles).To(Equal([]SinfBundle{
+				{
+					Path: "Payload/Test.app",
+					Kind: SinfBundleApp,
+					Sinfs: []SinfCheck{
+						{Path: "Payload/Test.app/SC_Info/Test.sinf", Size: 8, Status: SinfStatusOK},
+						{Path: "Payload/Test.app/SC_Info/Old.sinf", Size: 3, Status: SinfStatusUnlisted},
+					},
+				},
+				{
+					Path: "Payload/Test.app/PlugIns/Widget.appex",
+					Kind: SinfBundleExtension,
+					Sinfs: []SinfCheck{
+						{Path: "Payload/Test.app/PlugIns/Widget.appex/SC_Info/Widget.sinf", Status: SinfStatusEmpty},
+					},
+				},
+				{
+					Path: "Payload/Test.app/Watch/Watch.app",
+					Kind: SinfBundleWatch,
+					Sinfs: []SinfCheck{
+						{Path: "Payload/Test.app/Watch/Watch.app/SC_Info/Watch.sinf", Status: SinfStatusMissing},
+					},
+				},
+			}))
+			Expect(out.Problems).To(HaveLen(2))
+		})
+
+		It("compares the sinfs with those of the account", func() {
+			out, err := as.VerifySinf(VerifySinfInput{
+				PackagePath: testFile.Name(),
+				Sinfs:       []Sinf{{Data: []byte("other")}, {}, {}},
+			})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(out.Bundles[0].Sinfs[0].Status).To(Equal(SinfStatusMismatch))
+		})
+
+		It("reports a number of sinfs that does not match the manifest", func() {
+			out, err := as.VerifySinf(VerifySinfInput{
+				PackagePath: testFile.Name(),
+				Sinfs:       []Sinf{{Data: []byte("sinf-app")}},
+			})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(out.Problems).To(ContainElement("the account has 1 sinfs for the 3 paths of the manifest"))
+		})
+	})
+
+	When("a Watch app includes a codesign manifest before the main app", func() {
+		BeforeEach(func() {
+			writePlist("Payload/Test.app/Watch/Watch.app/Info.plist", map[string]interface{}{"WKWatchKitApp": true})
+			writePlist("Payload/Test.app/Watch/Watch.app/SC_Info/Manifest.plist", packageManifest{
+				SinfPaths: []string{"SC_Info/Watch.sinf"},
+			})
+			writePlist("Payload/Test.app/SC_Info/Manifest.plist", packageManifest{
+				SinfPaths: []string{"SC_Info/Test.sinf"},
+			})
+			write("Payload/Test.app/SC_Info/Test.sinf", []byte("sinf-app"))
+		})
+
+		It("reads the manifest of the main app", func() {
+			out, err := as.VerifySinf(VerifySinfInput{PackagePath: testFile.Name()})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(out.Valid()).To(BeTrue(), "%v", out.Problems)
+			Expect(out.Bundles[0].Sinfs).To(Equal([]SinfCheck{
+				{Path: "Payload/Test.app/SC_Info/Test.sinf", Size: 8, Status: SinfStatusOK},
+			}))
+		})
+	})
+
+	When("app does not include codesign manifest", func() {
+		BeforeEach(func() {
+			write("Payload/Test.app/SC_Info/Test.sinf", []byte("sinf"))
+		})
+
+		It("checks the sinf of the executable", func() {
+			out, err := as.VerifySinf(VerifySinfInput{
+				PackagePath: testFile.Name(),
+				Sinfs:       []Sinf{{Data: []byte("sinf")}},
+			})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(out.Valid()).To(BeTrue())
+			Expect(out.Bundles).To(HaveLen(1))
+			Expect(out.Bundles[0].Sinfs).To(Equal([]SinfCheck{
+				{Path: "Payload/Test.app/SC_Info/Test.sinf", Size: 4, Status: SinfStatusOK},
+			}))
+		})
+	})
+
+	When("file is not a zip archive", func() {
+		JustBeforeEach(func() {
+			Expect(os.WriteFile(testFile.Name(), []byte("ping"), 0644)).To(Succeed())
+		})
+
+		It("returns error", func() {
+			_, err := as.VerifySinf(VerifySinfInput{PackagePath: testFile.Name()})
+			Expect(err).To(MatchError(ContainSubstring("failed to open zip reader")))
+		})
+	})
+})
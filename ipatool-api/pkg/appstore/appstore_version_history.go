@@ -0,0 +1,140 @@
+package appstore
+
+import (
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DefaultVersionHistoryConcurrency is the number of versions whose metadata is resolved in parallel.
+const DefaultVersionHistoryConcurrency = 4
+
+type VersionHistoryInput struct {
+	Account Account
+	App     App
+	// Offset is the number of versions to skip, newest first.
+	Offset int
+	// Limit is the maximum number of versions to resolve. Zero means all versions after the offset.
+	Limit int
+	// Concurrency bounds the number of parallel metadata requests. Zero means DefaultVersionHistoryConcurrency.
+	Concurrency int
+	// BypassCache fetches the metadata of every version from the App Store even if it is cached.
+	BypassCache bool
+}
+
+// VersionInfo is a single version of an app with its resolved metadata.
+type VersionInfo struct {
+	ExternalVersionID string
+	DisplayVersion    string
+	ReleaseDate       time.Time
+	// Err is set if the metadata could not be resolved.
+	Err error
+}
+
+type VersionHistoryOutput struct {
+	// Total is the number of versions of the app, regardless of offset and limit.
+	Total                   int
+	LatestExternalVersionID string
+	// Versions is the requested page of versions, newest first.
+	Versions []VersionInfo
+}
+
+// VersionHistory lists the versions of an app newest first and resolves the display version and release
+// date of the requested page. A version whose metadata cannot be resolved is returned with its error
+// instead of failing the whole page.
+func (t *appstore) VersionHistory(input VersionHistoryInput) (VersionHistoryOutput, error) {
+	versions, err := t.ListVersions(ListVersionsInput{Account: input.Account, App: input.App})
+	if err != nil {
+		return VersionHistoryOutput{}, err
+	}
+
+	ids := sortVersionIDsNewestFirst(versions.ExternalVersionIdentifiers)
+	page := paginate(ids, input.Offset, input.Limit)
+
+	concurrency := input.Concurrency
+	if concurrency <= 0 {
+		concurrency = DefaultVersionHistoryConcurrency
+	}
+
+	results := make([]VersionInfo, len(page))
+	semaphore := make(chan struct{}, concurrency)
+
+	var wg sync.WaitGroup
+
+	for i, id := range page {
+		wg.Add(1)
+
+		go func(i int, id string) {
+			defer wg.Done()
+
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+
+			results[i] = t.versionInfo(input, id)
+		}(i, id)
+	}
+
+	wg.Wait()
+
+	return VersionHistoryOutput{
+		Total:                   len(ids),
+		LatestExternalVersionID: versions.LatestExternalVersionID,
+		Versions:                results,
+	}, nil
+}
+
+func (t *appstore) versionInfo(input VersionHistoryInput, versionID string) VersionInfo {
+	info := VersionInfo{ExternalVersionID: versionID}
+
+	metadata, err := t.GetVersionMetadata(GetVersionMetadataInput{
+		Account:     input.Account,
+		App:         input.App,
+		VersionID:   versionID,
+		BypassCache: input.BypassCache,
+	})
+	if err != nil {
+		info.Err = err
+
+		return info
+	}
+
+	info.DisplayVersion = metadata.DisplayVersion
+	info.ReleaseDate = metadata.ReleaseDate
+
+	return info
+}
+
+// sortVersionIDsNewestFirst orders external version identifiers newest first. The App Store assigns them
+// in increasing order, so this matches the release order without resolving any release dates.
+func sortVersionIDsNewestFirst(ids []string) []string {
+	sorted := make([]string, len(ids))
+	copy(sorted, ids)
+
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, errA := strconv.ParseInt(sorted[i], 10, 64)
+		b, errB := strconv.ParseInt(sorted[j], 10, 64)
+
+		if errA != nil || errB != nil {
+			return errA == nil && errB != nil
+		}
+
+		return a > b
+	})
+
+	return sorted
+}
+
+func paginate(ids []string, offset, limit int) []string {
+	if offset >= len(ids) {
+		return []string{}
+	}
+
+	ids = ids[max(offset, 0):]
+
+	if limit > 0 && limit < len(ids) {
+		ids = ids[:limit]
+	}
+
+	return ids
+}
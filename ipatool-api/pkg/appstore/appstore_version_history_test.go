@@ -0,0 +1,122 @@
+package appstore
+
+import (
+	"time"
+
+	"github.com/majd/ipatool/v2/pkg/http"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"go.uber.org/mock/gomock"
+)
+
+var _ = Describe("AppStore (VersionHistory)", func() {
+	var (
+		ctrl               *gomock.Controller
+		mockDownloadClient *http.MockClient[downloadResult]
+		as                 AppStore
+		testApp            = App{ID: 1}
+	)
+
+	BeforeEach(func() {
+		ctrl = gomock.NewController(GinkgoT())
+		mockDownloadClient = http.NewMockClient[downloadResult](ctrl)
+		as = &appstore{
+			downloadClient: mockDownloadClient,
+			deviceGUID:     "GUID",
+		}
+	})
+
+	AfterEach(func() {
+		ctrl.Finish()
+	})
+
+	// respond lists three versions, and resolves the metadata of all of them except "30".
+	respond := func(req http.Request) (http.Result[downloadResult], error) {
+		payload, _ := req.Payload.(*http.XMLPayload)
+
+		versionID, ok := payload.Content["externalVersionId"].(string)
+		if !ok {
+			return http.Result[downloadResult]{
+				Data: downloadResult{
+					Items: []downloadItemResult{
+						{
+							Metadata: map[string]interface{}{
+								"softwareVersionExternalIdentifiers": []interface{}{"10", "30", "200"},
+								"softwareVersionExternalIdentifier":  "200",
+							},
+						},
+					},
+				},
+			}, nil
+		}
+
+		if versionID == "30" {
+			return http.Result[downloadResult]{Data: downloadResult{FailureType: "5002", CustomerMessage: "An unknown error has occurred"}}, nil
+		}
+
+		return http.Result[downloadResult]{
+			Data: downloadResult{
+				Items: []downloadItemResult{
+					{
+						Metadata: map[string]interface{}{
+							"bundleShortVersionString": "v" + versionID,
+							"releaseDate":              "2024-01-01T00:00:00Z",
+						},
+					},
+				},
+			},
+		}, nil
+	}
+
+	It("resolves the metadata of all versions newest first", func() {
+		mockDownloadClient.EXPECT().
+			Send(gomock.Any()).
+			DoAndReturn(respond).
+			Times(4)
+
+		out, err := as.VersionHistory(VersionHistoryInput{App: testApp})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(out.Total).To(Equal(3))
+		Expect(out.LatestExternalVersionID).To(Equal("200"))
+		Expect(out.Versions).To(HaveLen(3))
+		Expect(out.Versions[0].ExternalVersionID).To(Equal("200"))
+		Expect(out.Versions[0].DisplayVersion).To(Equal("v200"))
+		Expect(out.Versions[0].ReleaseDate).To(Equal(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)))
+		Expect(out.Versions[1].ExternalVersionID).To(Equal("30"))
+		Expect(out.Versions[1].Err).To(MatchError(ContainSubstring("An unknown error has occurred")))
+		Expect(out.Versions[2].ExternalVersionID).To(Equal("10"))
+		Expect(out.Versions[2].Err).ToNot(HaveOccurred())
+	})
+
+	It("only resolves the requested page", func() {
+		mockDownloadClient.EXPECT().
+			Send(gomock.Any()).
+			DoAndReturn(respond).
+			Times(2)
+
+		out, err := as.VersionHistory(VersionHistoryInput{App: testApp, Offset: 2, Limit: 5})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(out.Total).To(Equal(3))
+		Expect(out.Versions).To(HaveLen(1))
+		Expect(out.Versions[0].DisplayVersion).To(Equal("v10"))
+	})
+
+	It("returns an empty page past the last version", func() {
+		mockDownloadClient.EXPECT().
+			Send(gomock.Any()).
+			DoAndReturn(respond)
+
+		out, err := as.VersionHistory(VersionHistoryInput{App: testApp, Offset: 3})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(out.Versions).To(BeEmpty())
+	})
+
+	It("fails if the versions cannot be listed", func() {
+		mockDownloadClient.EXPECT().
+			Send(gomock.Any()).
+			Return(http.Result[downloadResult]{Data: downloadResult{FailureType: FailureTypeLicenseNotFound}}, nil)
+
+		_, err := as.VersionHistory(VersionHistoryInput{App: testApp})
+		Expect(err).To(MatchError(ErrLicenseRequired))
+	})
+})
@@ -0,0 +1,67 @@
+package appstore
+
+import (
+	"sync"
+	"time"
+)
+
+// ttlCache is an in-memory cache whose entries expire after a fixed duration. A nil cache never hits.
+// Expired entries are removed when they are read, and swept at most once per duration when entries are set,
+// so that keys that are never read again don't stay forever.
+type ttlCache[V any] struct {
+	mu        sync.Mutex
+	ttl       time.Duration
+	entries   map[string]ttlCacheEntry[V]
+	nextSweep time.Time
+}
+
+type ttlCacheEntry[V any] struct {
+	value   V
+	expires time.Time
+}
+
+func newTTLCache[V any](ttl time.Duration) *ttlCache[V] {
+	return &ttlCache[V]{ttl: ttl, entries: map[string]ttlCacheEntry[V]{}}
+}
+
+func (c *ttlCache[V]) get(key string) (V, bool) {
+	var zero V
+
+	if c == nil {
+		return zero, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok || time.Now().After(entry.expires) {
+		delete(c.entries, key)
+
+		return zero, false
+	}
+
+	return entry.value, true
+}
+
+func (c *ttlCache[V]) set(key string, value V) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if now.After(c.nextSweep) {
+		for k, entry := range c.entries {
+			if now.After(entry.expires) {
+				delete(c.entries, k)
+			}
+		}
+
+		c.nextSweep = now.Add(c.ttl)
+	}
+
+	c.entries[key] = ttlCacheEntry[V]{value: value, expires: now.Add(c.ttl)}
+}
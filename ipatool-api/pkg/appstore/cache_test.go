@@ -0,0 +1,44 @@
+package appstore
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("TTL cache", func() {
+	It("returns entries until they expire", func() {
+		cache := newTTLCache[string](20 * time.Millisecond)
+		cache.set("key", "value")
+
+		value, ok := cache.get("key")
+		Expect(ok).To(BeTrue())
+		Expect(value).To(Equal("value"))
+
+		time.Sleep(30 * time.Millisecond)
+
+		_, ok = cache.get("key")
+		Expect(ok).To(BeFalse())
+	})
+
+	It("sweeps expired entries that are never read again", func() {
+		cache := newTTLCache[string](10 * time.Millisecond)
+		cache.set("first", "value")
+		cache.set("second", "value")
+
+		time.Sleep(20 * time.Millisecond)
+		cache.set("third", "value")
+
+		Expect(cache.entries).To(HaveLen(1))
+		Expect(cache.entries).To(HaveKey("third"))
+	})
+
+	It("never hits when nil", func() {
+		var cache *ttlCache[string]
+		cache.set("key", "value")
+
+		_, ok := cache.get("key")
+		Expect(ok).To(BeFalse())
+	})
+})
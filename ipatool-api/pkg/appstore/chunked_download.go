@@ -0,0 +1,466 @@
+package appstore
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	gohttp "net/http"
+	"os"
+	"slices"
+	"sync"
+
+	"github.com/majd/ipatool/v2/pkg/http"
+	"github.com/schollz/progressbar/v3"
+)
+
+// DefaultDownloadChunkSize is the size of the ranges requested by a chunked download.
+const DefaultDownloadChunkSize = 16 * 1024 * 1024
+
+// ErrRangesNotSupported is returned when the CDN does not honor range requests.
+var ErrRangesNotSupported = errors.New("range requests are not supported")
+
+// downloadChunk is a range of the file, from start to end inclusive.
+type downloadChunk struct {
+	start int64
+	end   int64
+}
+
+// downloadFileChunked downloads the file with concurrent range requests into a preallocated file. Every
+// chunk is retried on its own and resumes from the bytes it already wrote. Completed chunks are recorded in a
+// sidecar file, so that a later run resumes an interrupted download instead of starting over. It returns
+// ErrRangesNotSupported, before writing anything, if the server does not honor range requests or the file is
+// too small to be split.
+func (t *appstore) downloadFileChunked(source *downloadSource, dst, proxy string, progress *progressbar.ProgressBar) error {
+	chunkSize := t.downloadChunkSize
+	if chunkSize <= 0 {
+		chunkSize = DefaultDownloadChunkSize
+	}
+
+	size, err := t.probeFileSize(source, proxy)
+	if err != nil {
+		return err
+	}
+
+	if size <= chunkSize {
+		return ErrRangesNotSupported
+	}
+
+	// The state is saved before the file is extended, so that a file with gaps always has a sidecar.
+	state, err := t.resumeChunkState(dst, size)
+	if err != nil {
+		return err
+	}
+
+	file, err := t.os.OpenFile(dst, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open file: %w", err)
+	}
+
+	defer file.Close()
+
+	stat, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to get file info: %w", err)
+	}
+
+	// Extending the file keeps the bytes written by earlier runs.
+	if stat.Size() < size {
+		err = file.Truncate(size)
+		if err != nil {
+			return fmt.Errorf("failed to allocate file: %w", err)
+		}
+	}
+
+	if progress != nil {
+		progress.ChangeMax64(size)
+	}
+
+	// The missing chunks are listed up front, because the workers update the state.
+	var missing []downloadChunk
+
+	for start := int64(0); start < size; start += chunkSize {
+		chunk := downloadChunk{start: start, end: min(start+chunkSize, size) - 1}
+		if !state.completed(chunk) {
+			missing = append(missing, chunk)
+		} else if progress != nil {
+			_ = progress.Add64(chunk.end - chunk.start + 1)
+		}
+	}
+
+	chunks := make(chan downloadChunk)
+
+	go func() {
+		defer close(chunks)
+
+		for _, chunk := range missing {
+			chunks <- chunk
+		}
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		stateMu  sync.Mutex
+		errOnce  sync.Once
+		firstErr error
+	)
+
+	for range t.downloadConnections {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for chunk := range chunks {
+				if ctx.Err() != nil {
+					continue
+				}
+
+				err := t.downloadChunk(ctx, source, proxy, file, chunk, progress)
+				if err == nil {
+					stateMu.Lock()
+					state.complete(chunk)
+					err = t.saveChunkState(dst, state)
+					stateMu.Unlock()
+				}
+
+				if err != nil {
+					errOnce.Do(func() {
+						firstErr = err
+						cancel()
+					})
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if firstErr != nil {
+		return fmt.Errorf("failed to download range: %w", firstErr)
+	}
+
+	err = t.os.Remove(dst + chunkStateSuffix)
+	if err != nil && !t.os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove chunk state: %w", err)
+	}
+
+	return nil
+}
+
+// chunkStateSuffix names the sidecar file that records the completed chunks of a download.
+const chunkStateSuffix = ".chunks"
+
+// chunkState lists the ranges of the file, from start to end inclusive, that were downloaded completely. The
+// rest of a preallocated file is not to be trusted.
+type chunkState struct {
+	Size      int64      `json:"size"`
+	Completed [][2]int64 `json:"completed"`
+}
+
+func (s *chunkState) completed(chunk downloadChunk) bool {
+	for _, r := range s.Completed {
+		if r[0] <= chunk.start && chunk.end <= r[1] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// complete records the chunk, merging it with adjacent ranges to keep the sidecar small.
+func (s *chunkState) complete(chunk downloadChunk) {
+	ranges := append(s.Completed, [2]int64{chunk.start, chunk.end})
+	slices.SortFunc(ranges, func(a, b [2]int64) int {
+		return cmp.Compare(a[0], b[0])
+	})
+
+	merged := ranges[:1]
+
+	for _, r := range ranges[1:] {
+		last := &merged[len(merged)-1]
+		if r[0] <= last[1]+1 {
+			last[1] = max(last[1], r[1])
+
+			continue
+		}
+
+		merged = append(merged, r)
+	}
+
+	s.Completed = merged
+}
+
+// prefix returns the number of bytes downloaded from the start of the file without gaps.
+func (s *chunkState) prefix() int64 {
+	if len(s.Completed) == 0 || s.Completed[0][0] != 0 {
+		return 0
+	}
+
+	return s.Completed[0][1] + 1
+}
+
+// resumeChunkState returns the chunks of the destination file that earlier runs completed. A file without a
+// sidecar was written by a single stream, so its bytes are complete from the start. A file of another size, or
+// with an unreadable sidecar, is not known to belong to the same package and is discarded.
+func (t *appstore) resumeChunkState(dst string, size int64) (*chunkState, error) {
+	state, err := t.loadChunkState(dst)
+	if err != nil {
+		return nil, err
+	}
+
+	if state == nil {
+		state = &chunkState{Size: size}
+
+		stat, err := t.os.Stat(dst)
+
+		switch {
+		case err == nil && stat.Size() > size:
+			state.Size = stat.Size()
+		case err == nil && stat.Size() > 0:
+			state.Completed = [][2]int64{{0, stat.Size() - 1}}
+		case err != nil && !t.os.IsNotExist(err):
+			return nil, fmt.Errorf("failed to get file info: %w", err)
+		}
+	}
+
+	if state.Size != size {
+		err = t.os.Remove(dst)
+		if err != nil && !t.os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to remove outdated file: %w", err)
+		}
+
+		state = &chunkState{Size: size}
+	}
+
+	err = t.saveChunkState(dst, state)
+	if err != nil {
+		return nil, err
+	}
+
+	return state, nil
+}
+
+// loadChunkState reads the sidecar of the destination file. It returns nil if there is none, and an empty
+// state if it cannot be decoded.
+func (t *appstore) loadChunkState(dst string) (*chunkState, error) {
+	file, err := t.os.OpenFile(dst+chunkStateSuffix, os.O_RDONLY, 0)
+	if err != nil && t.os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to open chunk state: %w", err)
+	}
+
+	defer file.Close()
+
+	var state chunkState
+
+	err = json.NewDecoder(file).Decode(&state)
+	if err != nil {
+		return &chunkState{}, nil
+	}
+
+	return &state, nil
+}
+
+// saveChunkState replaces the sidecar of the destination file.
+func (t *appstore) saveChunkState(dst string, state *chunkState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("failed to encode chunk state: %w", err)
+	}
+
+	tmp := dst + chunkStateSuffix + ".tmp"
+
+	file, err := t.os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open chunk state: %w", err)
+	}
+
+	_, err = file.Write(data)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to write chunk state: %w", err)
+	}
+
+	err = t.os.Rename(tmp, dst+chunkStateSuffix)
+	if err != nil {
+		return fmt.Errorf("failed to save chunk state: %w", err)
+	}
+
+	return nil
+}
+
+// discardChunkGaps prepares a file left by a chunked download for a single stream, which appends to the file:
+// only the bytes downloaded from its start without gaps are kept.
+func (t *appstore) discardChunkGaps(dst string) error {
+	state, err := t.loadChunkState(dst)
+	if err != nil || state == nil {
+		return err
+	}
+
+	file, err := t.os.OpenFile(dst, os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open file: %w", err)
+	}
+
+	defer file.Close()
+
+	err = file.Truncate(state.prefix())
+	if err != nil {
+		return fmt.Errorf("failed to truncate file: %w", err)
+	}
+
+	err = t.os.Remove(dst + chunkStateSuffix)
+	if err != nil && !t.os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove chunk state: %w", err)
+	}
+
+	return nil
+}
+
+// probeFileSize requests the first byte of the file to learn its size and whether ranges are supported.
+func (t *appstore) probeFileSize(source *downloadSource, proxy string) (int64, error) {
+	var size int64
+
+	err := t.retryPolicy.Do("probe file", func() error {
+		url := source.URL()
+
+		ctx, watchdog, stop := t.newStallWatchdog(context.Background())
+		defer stop()
+
+		res, err := t.rangeRequest(ctx, url, proxy, "bytes=0-0")
+		if err != nil {
+			return source.retryable(url, watchdog.Err(err))
+		}
+		defer res.Body.Close()
+
+		if isExpiredStatus(res.StatusCode) {
+			return source.retryable(url, fmt.Errorf("%w: received status code %d", errDownloadURLExpired, res.StatusCode))
+		}
+
+		if res.StatusCode != gohttp.StatusPartialContent {
+			return ErrRangesNotSupported
+		}
+
+		size, err = parseContentRangeSize(res.Header.Get("Content-Range"))
+		if err != nil {
+			return ErrRangesNotSupported
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, err //nolint:wrapcheck
+	}
+
+	return size, nil
+}
+
+func (t *appstore) downloadChunk(ctx context.Context, source *downloadSource, proxy string, file *os.File, chunk downloadChunk, progress *progressbar.ProgressBar) error {
+	written := int64(0)
+
+	err := t.retryPolicy.Do("download chunk", func() error {
+		var writer io.Writer = io.NewOffsetWriter(file, chunk.start+written)
+		if progress != nil {
+			writer = io.MultiWriter(writer, progress)
+		}
+
+		url := source.URL()
+		n, err := t.copyRange(ctx, url, proxy, chunk.start+written, chunk.end, writer)
+		written += n
+
+		return source.retryable(url, err)
+	})
+	if err != nil {
+		return fmt.Errorf("%d-%d: %w", chunk.start, chunk.end, err)
+	}
+
+	return nil
+}
+
+// copyRange copies the range of the file, from start to end inclusive, to the writer and returns the number of
+// bytes copied. Interrupted, stalled and incomplete transfers fail with a transient error.
+func (t *appstore) copyRange(ctx context.Context, url, proxy string, start, end int64, w io.Writer) (int64, error) {
+	ctx, watchdog, stop := t.newStallWatchdog(ctx)
+	defer stop()
+
+	res, err := t.rangeRequest(ctx, url, proxy, fmt.Sprintf("bytes=%d-%d", start, end))
+	if err != nil {
+		return 0, watchdog.Err(err)
+	}
+	defer res.Body.Close()
+
+	if isExpiredStatus(res.StatusCode) {
+		return 0, fmt.Errorf("%w: received status code %d", errDownloadURLExpired, res.StatusCode)
+	}
+
+	if res.StatusCode != gohttp.StatusPartialContent {
+		return 0, fmt.Errorf("received status code %d", res.StatusCode)
+	}
+
+	length := end - start + 1
+	body := &readErrorReader{reader: watchdog.Reader(res.Body)}
+
+	n, err := io.Copy(watchdog.Writer(w), io.LimitReader(body, length))
+	if err != nil {
+		if body.err != nil {
+			return n, http.NewTransientError(watchdog.Err(fmt.Errorf("failed to read response body: %w", err)), 0)
+		}
+
+		return n, fmt.Errorf("failed to write data: %w", err)
+	}
+
+	if n < length {
+		return n, http.NewTransientError(fmt.Errorf("received %d of %d bytes", n, length), 0)
+	}
+
+	return n, nil
+}
+
+// rangeRequest requests the range of the file. Transient failures are returned as transient errors.
+func (t *appstore) rangeRequest(ctx context.Context, src, proxy, byteRange string) (*gohttp.Response, error) {
+	req, err := t.httpClient.NewRequest("GET", src, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	// The proxy is carried by the context, so it must be configured after the context is set.
+	req, err = http.WithProxy(req.WithContext(ctx), proxy)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure proxy: %w", err)
+	}
+
+	req.Header.Set("Range", byteRange)
+
+	res, err := t.httpClient.Do(req)
+	if err != nil {
+		// Another chunk failed; there is no point in retrying.
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("request canceled: %w", ctx.Err())
+		}
+
+		return nil, http.NewTransientError(fmt.Errorf("request failed: %w", err), 0)
+	}
+
+	if http.IsTransientStatus(res.StatusCode) {
+		res.Body.Close()
+
+		return nil, http.NewTransientError(
+			fmt.Errorf("received status code %d", res.StatusCode),
+			http.ParseRetryAfter(res.Header.Get("Retry-After")),
+		)
+	}
+
+	return res, nil
+}
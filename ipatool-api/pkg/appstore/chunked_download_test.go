@@ -0,0 +1,220 @@
+package appstore
+
+import (
+	"bytes"
+	gohttp "net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/majd/ipatool/v2/pkg/http"
+	"github.com/majd/ipatool/v2/pkg/util/operatingsystem"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("AppStore (Chunked Download)", func() {
+	var (
+		data    []byte
+		handler gohttp.HandlerFunc
+		server  *httptest.Server
+		as      *appstore
+		dst     string
+
+		mu     sync.Mutex
+		ranges []string
+	)
+
+	BeforeEach(func() {
+		data = bytes.Repeat([]byte("0123456789"), 1000)
+		ranges = nil
+
+		handler = func(w gohttp.ResponseWriter, r *gohttp.Request) {
+			gohttp.ServeContent(w, r, "app.ipa", time.Time{}, bytes.NewReader(data))
+		}
+
+		server = httptest.NewServer(gohttp.HandlerFunc(func(w gohttp.ResponseWriter, r *gohttp.Request) {
+			mu.Lock()
+			ranges = append(ranges, r.Header.Get("Range"))
+			mu.Unlock()
+
+			handler(w, r)
+		}))
+		DeferCleanup(server.Close)
+
+		as = &appstore{
+			httpClient:          http.NewClient[interface{}](http.Args{}),
+			os:                  operatingsystem.New(),
+			retryPolicy:         http.RetryPolicy{Attempts: 2},
+			downloadConnections: 4,
+			downloadChunkSize:   1024,
+		}
+		dst = filepath.Join(GinkgoT().TempDir(), "app.ipa.tmp")
+	})
+
+	It("downloads the file in concurrent ranges", func() {
+		err := as.downloadFile(&downloadSource{url: server.URL}, dst, "", nil)
+		Expect(err).ToNot(HaveOccurred())
+
+		written, err := os.ReadFile(dst)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(written).To(Equal(data))
+
+		Expect(ranges).To(HaveLen(11))
+		Expect(ranges).To(ContainElements("bytes=0-0", "bytes=0-1023", "bytes=9216-9999"))
+	})
+
+	When("the server does not honor ranges", func() {
+		BeforeEach(func() {
+			handler = func(w gohttp.ResponseWriter, _ *gohttp.Request) {
+				_, _ = w.Write(data)
+			}
+		})
+
+		It("falls back to a single stream", func() {
+			err := as.downloadFile(&downloadSource{url: server.URL}, dst, "", nil)
+			Expect(err).ToNot(HaveOccurred())
+
+			written, err := os.ReadFile(dst)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(written).To(Equal(data))
+			Expect(ranges).To(Equal([]string{"bytes=0-0", "bytes=0-"}))
+		})
+	})
+
+	When("the file fits in one chunk", func() {
+		BeforeEach(func() {
+			as.downloadChunkSize = DefaultDownloadChunkSize
+		})
+
+		It("downloads it with a single stream", func() {
+			err := as.downloadFile(&downloadSource{url: server.URL}, dst, "", nil)
+			Expect(err).ToNot(HaveOccurred())
+
+			written, err := os.ReadFile(dst)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(written).To(Equal(data))
+			Expect(ranges).To(Equal([]string{"bytes=0-0", "bytes=0-"}))
+		})
+	})
+
+	When("a chunk fails transiently", func() {
+		BeforeEach(func() {
+			var failed atomic.Bool
+
+			handler = func(w gohttp.ResponseWriter, r *gohttp.Request) {
+				if r.Header.Get("Range") == "bytes=2048-3071" && failed.CompareAndSwap(false, true) {
+					w.WriteHeader(gohttp.StatusBadGateway)
+
+					return
+				}
+
+				gohttp.ServeContent(w, r, "app.ipa", time.Time{}, bytes.NewReader(data))
+			}
+		})
+
+		It("retries the chunk", func() {
+			err := as.downloadFile(&downloadSource{url: server.URL}, dst, "", nil)
+			Expect(err).ToNot(HaveOccurred())
+
+			written, err := os.ReadFile(dst)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(written).To(Equal(data))
+			Expect(ranges).To(HaveLen(12))
+		})
+	})
+
+	When("a chunk keeps failing", func() {
+		BeforeEach(func() {
+			handler = func(w gohttp.ResponseWriter, r *gohttp.Request) {
+				if r.Header.Get("Range") == "bytes=2048-3071" {
+					w.WriteHeader(gohttp.StatusBadGateway)
+
+					return
+				}
+
+				gohttp.ServeContent(w, r, "app.ipa", time.Time{}, bytes.NewReader(data))
+			}
+		})
+
+		It("returns error", func() {
+			err := as.downloadFile(&downloadSource{url: server.URL}, dst, "", nil)
+			Expect(err).To(MatchError(ContainSubstring("failed to download range: 2048-3071")))
+		})
+
+		When("the download is run again", func() {
+			BeforeEach(func() {
+				as.downloadConnections = 1
+
+				err := as.downloadFileChunked(&downloadSource{url: server.URL}, dst, "", nil)
+				Expect(err).To(HaveOccurred())
+				Expect(dst + chunkStateSuffix).To(BeAnExistingFile())
+
+				ranges = nil
+				handler = func(w gohttp.ResponseWriter, r *gohttp.Request) {
+					gohttp.ServeContent(w, r, "app.ipa", time.Time{}, bytes.NewReader(data))
+				}
+			})
+
+			It("downloads only the missing chunks", func() {
+				err := as.downloadFileChunked(&downloadSource{url: server.URL}, dst, "", nil)
+				Expect(err).ToNot(HaveOccurred())
+
+				written, err := os.ReadFile(dst)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(written).To(Equal(data))
+				Expect(ranges).To(HaveLen(9))
+				Expect(ranges).ToNot(ContainElements("bytes=0-1023", "bytes=1024-2047"))
+				Expect(dst + chunkStateSuffix).ToNot(BeAnExistingFile())
+			})
+
+			It("continues with a single stream after the completed start of the file", func() {
+				as.downloadConnections = 0
+
+				err := as.downloadFile(&downloadSource{url: server.URL}, dst, "", nil)
+				Expect(err).ToNot(HaveOccurred())
+
+				written, err := os.ReadFile(dst)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(written).To(Equal(data))
+				Expect(ranges).To(Equal([]string{"bytes=2048-"}))
+				Expect(dst + chunkStateSuffix).ToNot(BeAnExistingFile())
+			})
+		})
+	})
+
+	When("a single stream left a partial file", func() {
+		BeforeEach(func() {
+			Expect(os.WriteFile(dst, data[:3000], 0644)).To(Succeed())
+		})
+
+		It("keeps the downloaded start of the file", func() {
+			err := as.downloadFile(&downloadSource{url: server.URL}, dst, "", nil)
+			Expect(err).ToNot(HaveOccurred())
+
+			written, err := os.ReadFile(dst)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(written).To(Equal(data))
+			Expect(ranges).To(HaveLen(9))
+			Expect(ranges).To(ContainElement("bytes=2048-3071"))
+		})
+	})
+
+	When("the partial file is larger than the package", func() {
+		BeforeEach(func() {
+			Expect(os.WriteFile(dst, bytes.Repeat([]byte("x"), 20000), 0644)).To(Succeed())
+		})
+
+		It("downloads the package again", func() {
+			err := as.downloadFile(&downloadSource{url: server.URL}, dst, "", nil)
+			Expect(err).ToNot(HaveOccurred())
+
+			written, err := os.ReadFile(dst)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(written).To(Equal(data))
+		})
+	})
+})
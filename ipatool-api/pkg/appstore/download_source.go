@@ -0,0 +1,164 @@
+package appstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	gohttp "net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/majd/ipatool/v2/pkg/http"
+)
+
+// DefaultDownloadStallTimeout is how long a CDN transfer may go without receiving data.
+const DefaultDownloadStallTimeout = 30 * time.Second
+
+var (
+	errDownloadStalled    = errors.New("download stalled")
+	errDownloadURLExpired = errors.New("download URL expired")
+)
+
+// downloadSource is the URL of a package on the CDN. The URL is signed and expires, so it can be renewed
+// with a fresh download ticket. It is safe for concurrent use.
+type downloadSource struct {
+	mu    sync.Mutex
+	url   string
+	renew func() (string, error)
+}
+
+// newDownloadSource returns the source of the item's package. A stalled or expired transfer resumes with the
+// URL of a fresh download ticket for the same version.
+func (t *appstore) newDownloadSource(item downloadItemResult, acc Account, app App, guid, externalVersionID string) *downloadSource {
+	return &downloadSource{
+		url: item.URL,
+		renew: func() (string, error) {
+			renewed, err := t.requestDownloadItem(acc, app, guid, externalVersionID)
+
+			return renewed.URL, err
+		},
+	}
+}
+
+func (s *downloadSource) URL() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.url
+}
+
+// Renew replaces the URL, unless it changed since stale was read: concurrent transfers that fail
+// with the same URL renew it once. Without a renew function, the URL is kept.
+func (s *downloadSource) Renew(stale string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.url != stale || s.renew == nil {
+		return nil
+	}
+
+	url, err := s.renew()
+	if err != nil {
+		return err
+	}
+
+	s.url = url
+
+	return nil
+}
+
+// retryable renews the URL if the transfer stalled or the URL expired, and marks the error as transient so
+// that the transfer resumes with the new URL.
+func (s *downloadSource) retryable(url string, err error) error {
+	if !errors.Is(err, errDownloadStalled) && !errors.Is(err, errDownloadURLExpired) {
+		return err
+	}
+
+	if renewErr := s.Renew(url); renewErr != nil {
+		// Not transient: the ticket is refused, e.g. because the password token expired.
+		return fmt.Errorf("%s; failed to renew download URL: %w", err, renewErr)
+	}
+
+	return http.NewTransientError(err, 0)
+}
+
+// isExpiredStatus reports whether the CDN rejected the signed URL.
+func isExpiredStatus(code int) bool {
+	return code == gohttp.StatusForbidden || code == gohttp.StatusGone
+}
+
+// stallWatchdog cancels a transfer when no data arrives within the timeout.
+type stallWatchdog struct {
+	timeout time.Duration
+	timer   *time.Timer
+	stalled atomic.Bool
+}
+
+// newStallWatchdog returns a context that is canceled when the transfer stalls, and a function that
+// stops the watchdog. The timeout starts before the request is sent, so it also covers waiting for headers.
+func (t *appstore) newStallWatchdog(ctx context.Context) (context.Context, *stallWatchdog, func()) {
+	timeout := t.downloadStallTimeout
+	if timeout <= 0 {
+		timeout = DefaultDownloadStallTimeout
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	watchdog := &stallWatchdog{timeout: timeout}
+	watchdog.timer = time.AfterFunc(timeout, func() {
+		watchdog.stalled.Store(true)
+		cancel()
+	})
+
+	return ctx, watchdog, func() {
+		watchdog.timer.Stop()
+		cancel()
+	}
+}
+
+// Reader returns a reader that resets the timeout whenever data arrives.
+func (w *stallWatchdog) Reader(reader io.Reader) io.Reader {
+	return &stallReader{reader: reader, watchdog: w}
+}
+
+// Writer returns a writer that pauses the timeout while data is written, so that a slow consumer is not
+// mistaken for a stalled transfer.
+func (w *stallWatchdog) Writer(writer io.Writer) io.Writer {
+	return &stallWriter{writer: writer, watchdog: w}
+}
+
+// Err reports err as errDownloadStalled if the watchdog canceled the transfer.
+func (w *stallWatchdog) Err(err error) error {
+	if err == nil || !w.stalled.Load() {
+		return err
+	}
+
+	return fmt.Errorf("%w: no data received for %s", errDownloadStalled, w.timeout)
+}
+
+type stallReader struct {
+	reader   io.Reader
+	watchdog *stallWatchdog
+}
+
+func (r *stallReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	if n > 0 {
+		r.watchdog.timer.Reset(r.watchdog.timeout)
+	}
+
+	return n, err //nolint:wrapcheck
+}
+
+type stallWriter struct {
+	writer   io.Writer
+	watchdog *stallWatchdog
+}
+
+func (w *stallWriter) Write(p []byte) (int, error) {
+	w.watchdog.timer.Stop()
+	defer w.watchdog.timer.Reset(w.watchdog.timeout)
+
+	return w.writer.Write(p) //nolint:wrapcheck
+}
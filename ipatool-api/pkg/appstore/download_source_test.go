@@ -0,0 +1,322 @@
+package appstore
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	gohttp "net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/majd/ipatool/v2/pkg/http"
+	"github.com/majd/ipatool/v2/pkg/util/operatingsystem"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("AppStore (Download Source)", func() {
+	var (
+		data     []byte
+		server   *httptest.Server
+		as       *appstore
+		dst      string
+		source   *downloadSource
+		renewErr error
+		renewals int
+
+		mu       sync.Mutex
+		requests []string
+	)
+
+	// stall sends the first bytes of the requested range and then hangs until the client gives up.
+	stall := func(w gohttp.ResponseWriter, r *gohttp.Request) {
+		var start int64
+		_, _ = fmt.Sscanf(r.Header.Get("Range"), "bytes=%d-", &start)
+
+		w.Header().Set("Content-Length", strconv.FormatInt(int64(len(data))-start, 10))
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, len(data)-1, len(data)))
+		w.WriteHeader(gohttp.StatusPartialContent)
+		_, _ = w.Write(data[start : start+100])
+		w.(gohttp.Flusher).Flush()
+
+		<-r.Context().Done()
+	}
+
+	BeforeEach(func() {
+		data = bytes.Repeat([]byte("0123456789"), 100)
+		requests = nil
+		renewals = 0
+		renewErr = nil
+
+		mux := gohttp.NewServeMux()
+		mux.HandleFunc("/stalled", stall)
+		mux.HandleFunc("/expired", func(w gohttp.ResponseWriter, _ *gohttp.Request) {
+			w.WriteHeader(gohttp.StatusForbidden)
+		})
+		mux.HandleFunc("/fresh", func(w gohttp.ResponseWriter, r *gohttp.Request) {
+			gohttp.ServeContent(w, r, "app.ipa", time.Time{}, bytes.NewReader(data))
+		})
+		mux.HandleFunc("/ignores-range", func(w gohttp.ResponseWriter, _ *gohttp.Request) {
+			_, _ = w.Write(data)
+		})
+
+		// The first request of the second chunk stalls.
+		var once sync.Once
+		mux.HandleFunc("/chunked", func(w gohttp.ResponseWriter, r *gohttp.Request) {
+			stalled := false
+			if r.Header.Get("Range") == "bytes=256-511" {
+				once.Do(func() { stalled = true })
+			}
+
+			if stalled {
+				<-r.Context().Done()
+
+				return
+			}
+
+			gohttp.ServeContent(w, r, "app.ipa", time.Time{}, bytes.NewReader(data))
+		})
+
+		// The package is sent in two halves, so that the client writes the first half before it reads on.
+		mux.HandleFunc("/halves", func(w gohttp.ResponseWriter, _ *gohttp.Request) {
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes 0-%d/%d", len(data)-1, len(data)))
+			w.WriteHeader(gohttp.StatusPartialContent)
+			_, _ = w.Write(data[:len(data)/2])
+			w.(gohttp.Flusher).Flush()
+			time.Sleep(10 * time.Millisecond)
+			_, _ = w.Write(data[len(data)/2:])
+		})
+
+		// Every request of the second chunk stalls.
+		mux.HandleFunc("/chunk-stalled", func(w gohttp.ResponseWriter, r *gohttp.Request) {
+			if r.Header.Get("Range") == "bytes=256-511" {
+				<-r.Context().Done()
+
+				return
+			}
+
+			gohttp.ServeContent(w, r, "app.ipa", time.Time{}, bytes.NewReader(data))
+		})
+
+		server = httptest.NewServer(gohttp.HandlerFunc(func(w gohttp.ResponseWriter, r *gohttp.Request) {
+			mu.Lock()
+			requests = append(requests, fmt.Sprintf("%s %s", r.URL.RequestURI(), r.Header.Get("Range")))
+			mu.Unlock()
+
+			mux.ServeHTTP(w, r)
+		}))
+		DeferCleanup(server.Close)
+
+		as = &appstore{
+			httpClient:           http.NewClient[interface{}](http.Args{}),
+			os:                   operatingsystem.New(),
+			retryPolicy:          http.RetryPolicy{Attempts: 3},
+			downloadStallTimeout: 100 * time.Millisecond,
+		}
+		dst = filepath.Join(GinkgoT().TempDir(), "app.ipa.tmp")
+		source = &downloadSource{
+			renew: func() (string, error) {
+				renewals++
+
+				return server.URL + "/fresh", renewErr
+			},
+		}
+	})
+
+	When("the transfer stalls", func() {
+		BeforeEach(func() {
+			source.url = server.URL + "/stalled"
+		})
+
+		It("resumes with a fresh URL", func() {
+			err := as.downloadFile(source, dst, "", nil)
+			Expect(err).ToNot(HaveOccurred())
+
+			written, err := os.ReadFile(dst)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(written).To(Equal(data))
+			Expect(renewals).To(Equal(1))
+			Expect(requests).To(Equal([]string{"/stalled bytes=0-", "/fresh bytes=100-"}))
+		})
+	})
+
+	When("the URL expired while resuming", func() {
+		BeforeEach(func() {
+			source.url = server.URL + "/expired"
+
+			err := os.WriteFile(dst, data[:300], 0644)
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("keeps appending to the partial file", func() {
+			err := as.downloadFile(source, dst, "", nil)
+			Expect(err).ToNot(HaveOccurred())
+
+			written, err := os.ReadFile(dst)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(written).To(Equal(data))
+			Expect(requests).To(Equal([]string{"/expired bytes=300-", "/fresh bytes=300-"}))
+		})
+
+		When("a fresh URL is refused", func() {
+			BeforeEach(func() {
+				renewErr = ErrPasswordTokenExpired
+			})
+
+			It("returns error", func() {
+				err := as.downloadFile(source, dst, "", nil)
+				Expect(err).To(MatchError(ErrPasswordTokenExpired))
+				Expect(errors.Is(err, errDownloadURLExpired)).To(BeFalse())
+				Expect(requests).To(HaveLen(1))
+			})
+		})
+	})
+
+	When("the server ignores the range of a partial file", func() {
+		BeforeEach(func() {
+			source.url = server.URL + "/ignores-range"
+
+			err := os.WriteFile(dst, data[:300], 0644)
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("downloads the package from the start", func() {
+			err := as.downloadFile(source, dst, "", nil)
+			Expect(err).ToNot(HaveOccurred())
+
+			written, err := os.ReadFile(dst)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(written).To(Equal(data))
+			Expect(requests).To(Equal([]string{"/ignores-range bytes=300-"}))
+		})
+	})
+
+	When("every transfer of a run stalls", func() {
+		BeforeEach(func() {
+			source.url = server.URL + "/stalled"
+			source.renew = func() (string, error) {
+				renewals++
+
+				return server.URL + "/stalled", nil
+			}
+
+			err := as.downloadFile(source, dst, "", nil)
+			Expect(err).To(MatchError(errDownloadStalled))
+
+			mu.Lock()
+			requests = nil
+			mu.Unlock()
+		})
+
+		It("appends to the partial file in the next run", func() {
+			written, err := os.ReadFile(dst)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(written).To(Equal(data[:300]))
+
+			err = as.downloadFile(&downloadSource{url: server.URL + "/fresh"}, dst, "", nil)
+			Expect(err).ToNot(HaveOccurred())
+
+			written, err = os.ReadFile(dst)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(written).To(Equal(data))
+			Expect(requests).To(Equal([]string{"/fresh bytes=300-"}))
+		})
+	})
+
+	When("a chunk stalls in every attempt of a run", func() {
+		BeforeEach(func() {
+			as.downloadConnections = 4
+			as.downloadChunkSize = 256
+			source.url = server.URL + "/chunk-stalled"
+			source.renew = func() (string, error) {
+				renewals++
+
+				return server.URL + "/chunk-stalled", nil
+			}
+
+			err := as.downloadFile(source, dst, "", nil)
+			Expect(err).To(MatchError(errDownloadStalled))
+
+			mu.Lock()
+			requests = nil
+			mu.Unlock()
+		})
+
+		It("downloads only the missing chunks in the next run", func() {
+			err := as.downloadFile(&downloadSource{url: server.URL + "/fresh"}, dst, "", nil)
+			Expect(err).ToNot(HaveOccurred())
+
+			written, err := os.ReadFile(dst)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(written).To(Equal(data))
+			Expect(requests).To(ContainElement("/fresh bytes=256-511"))
+			Expect(requests).ToNot(ContainElement("/fresh bytes=0-255"))
+		})
+	})
+
+	When("the consumer is slower than the stall timeout", func() {
+		It("does not count the time spent writing as a stall", func() {
+			var buf bytes.Buffer
+
+			writer := writerFunc(func(p []byte) (int, error) {
+				time.Sleep(3 * as.downloadStallTimeout)
+
+				return buf.Write(p)
+			})
+
+			n, err := as.copyRange(context.Background(), server.URL+"/halves", "", 0, int64(len(data))-1, writer)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(n).To(Equal(int64(len(data))))
+			Expect(buf.Bytes()).To(Equal(data))
+		})
+	})
+
+	When("the context is canceled", func() {
+		It("stops the transfer", func() {
+			as.downloadStallTimeout = time.Minute
+
+			ctx, cancel := context.WithCancel(context.Background())
+			time.AfterFunc(50*time.Millisecond, cancel)
+
+			_, err := as.copyRange(ctx, server.URL+"/stalled", "", 0, int64(len(data))-1, io.Discard)
+			Expect(err).To(HaveOccurred())
+			Expect(errors.Is(err, errDownloadStalled)).To(BeFalse())
+		})
+	})
+
+	When("a chunk stalls", func() {
+		BeforeEach(func() {
+			as.downloadConnections = 4
+			as.downloadChunkSize = 256
+			source.url = server.URL + "/chunked"
+			source.renew = func() (string, error) {
+				renewals++
+
+				return server.URL + "/chunked?renewed", nil
+			}
+		})
+
+		It("retries the chunk with a fresh URL", func() {
+			err := as.downloadFile(source, dst, "", nil)
+			Expect(err).ToNot(HaveOccurred())
+
+			written, err := os.ReadFile(dst)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(written).To(Equal(data))
+			Expect(renewals).To(Equal(1))
+			Expect(requests).To(ContainElement("/chunked?renewed bytes=256-511"))
+		})
+	})
+})
+
+type writerFunc func(p []byte) (int, error)
+
+func (f writerFunc) Write(p []byte) (int, error) {
+	return f(p)
+}
@@ -0,0 +1,63 @@
+package appstore
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Endpoints holds the base URLs (scheme and host, without trailing slash) of the App Store services.
+// Empty fields fall back to Apple's production hosts.
+type Endpoints struct {
+	// ITunesAPI serves search and lookup.
+	ITunesAPI string
+	// PrivateAppStoreAPI serves authenticate and buyProduct.
+	PrivateAppStoreAPI string
+	// PrivateAppStoreDownloadAPI serves volumeStoreDownloadProduct.
+	PrivateAppStoreDownloadAPI string
+}
+
+// DefaultEndpoints returns the endpoints of Apple's production App Store.
+func DefaultEndpoints() Endpoints {
+	return Endpoints{
+		ITunesAPI:          fmt.Sprintf("https://%s", iTunesAPIDomain),
+		PrivateAppStoreAPI: fmt.Sprintf("https://%s", PrivateAppStoreAPIDomain),
+		PrivateAppStoreDownloadAPI: fmt.Sprintf(
+			"https://%s-%s", PrivateAppStoreAPIDomainPrefixWithoutAuthCode, PrivateAppStoreAPIDomain,
+		),
+	}
+}
+
+// EndpointsWithBaseURL returns endpoints that serve every App Store service from the same base URL,
+// e.g. a fake App Store used for testing.
+func EndpointsWithBaseURL(baseURL string) Endpoints {
+	baseURL = strings.TrimSuffix(baseURL, "/")
+
+	return Endpoints{
+		ITunesAPI:                  baseURL,
+		PrivateAppStoreAPI:         baseURL,
+		PrivateAppStoreDownloadAPI: baseURL,
+	}
+}
+
+// withDefaults fills empty endpoints with the production ones.
+func (e Endpoints) withDefaults() Endpoints {
+	defaults := DefaultEndpoints()
+
+	if e.ITunesAPI == "" {
+		e.ITunesAPI = defaults.ITunesAPI
+	}
+
+	if e.PrivateAppStoreAPI == "" {
+		e.PrivateAppStoreAPI = defaults.PrivateAppStoreAPI
+	}
+
+	if e.PrivateAppStoreDownloadAPI == "" {
+		e.PrivateAppStoreDownloadAPI = defaults.PrivateAppStoreDownloadAPI
+	}
+
+	e.ITunesAPI = strings.TrimSuffix(e.ITunesAPI, "/")
+	e.PrivateAppStoreAPI = strings.TrimSuffix(e.PrivateAppStoreAPI, "/")
+	e.PrivateAppStoreDownloadAPI = strings.TrimSuffix(e.PrivateAppStoreDownloadAPI, "/")
+
+	return e
+}
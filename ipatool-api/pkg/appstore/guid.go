@@ -0,0 +1,21 @@
+package appstore
+
+import (
+	"errors"
+	"strings"
+)
+
+// GUIDFromMacAddress converts a MAC address into the GUID format expected by the App Store.
+func GUIDFromMacAddress(macAddr string) string {
+	return strings.ReplaceAll(strings.ToUpper(macAddr), ":", "")
+}
+
+// guid returns the device GUID sent with every App Store request. It is resolved once by the caller and
+// configured through Args, so that every request reports the same device.
+func (t *appstore) guid() (string, error) {
+	if t.deviceGUID == "" {
+		return "", errors.New("device GUID is not configured")
+	}
+
+	return t.deviceGUID, nil
+}
@@ -0,0 +1,52 @@
+package appstore
+
+import (
+	"github.com/majd/ipatool/v2/pkg/util/machine"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"go.uber.org/mock/gomock"
+)
+
+var _ = Describe("AppStore (GUID)", func() {
+	var (
+		ctrl        *gomock.Controller
+		mockMachine *machine.MockMachine
+	)
+
+	BeforeEach(func() {
+		ctrl = gomock.NewController(GinkgoT())
+		mockMachine = machine.NewMockMachine(ctrl)
+	})
+
+	AfterEach(func() {
+		ctrl.Finish()
+	})
+
+	It("converts MAC address to GUID", func() {
+		Expect(GUIDFromMacAddress("aa:bb:cc:00:11:22")).To(Equal("AABBCC001122"))
+	})
+
+	When("device GUID is configured", func() {
+		It("returns configured GUID without reading MAC address", func() {
+			as := &appstore{
+				machine:    mockMachine,
+				deviceGUID: "0123456789AB",
+			}
+
+			guid, err := as.guid()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(guid).To(Equal("0123456789AB"))
+		})
+	})
+
+	When("device GUID is not configured", func() {
+		It("returns error without reading MAC address", func() {
+			as := &appstore{
+				machine: mockMachine,
+			}
+
+			_, err := as.guid()
+			Expect(err).To(MatchError(ContainSubstring("device GUID is not configured")))
+		})
+	})
+})
@@ -0,0 +1,75 @@
+package appstore
+
+import (
+	"fmt"
+	"time"
+)
+
+// MetadataCache persists version metadata across restarts. The display version, release date and minimum
+// iOS version of a version never change, so entries do not expire.
+type MetadataCache interface {
+	// Get decodes the value stored under key into value and reports whether it was found.
+	Get(key string, value interface{}) bool
+	// Set stores value under key. It is called every time a value is fetched from the App Store, so storing a
+	// value that did not change should not grow the cache.
+	Set(key string, value interface{}) error
+}
+
+type cachedVersionMetadata struct {
+	DisplayVersion string    `json:"display_version"`
+	ReleaseDate    time.Time `json:"release_date"`
+}
+
+func versionMetadataCacheKey(appID int64, versionID string) string {
+	return fmt.Sprintf("version-metadata/%d/%s", appID, versionID)
+}
+
+func minimumOSVersionCacheKey(appID int64, versionID string) string {
+	return fmt.Sprintf("minimum-os-version/%d/%s", appID, versionID)
+}
+
+func (t *appstore) cachedVersionMetadata(appID int64, versionID string) (GetVersionMetadataOutput, bool) {
+	if t.metadataCache == nil || appID == 0 {
+		return GetVersionMetadataOutput{}, false
+	}
+
+	var cached cachedVersionMetadata
+	if !t.metadataCache.Get(versionMetadataCacheKey(appID, versionID), &cached) {
+		return GetVersionMetadataOutput{}, false
+	}
+
+	return GetVersionMetadataOutput{
+		DisplayVersion: cached.DisplayVersion,
+		ReleaseDate:    cached.ReleaseDate,
+	}, true
+}
+
+func (t *appstore) cacheVersionMetadata(appID int64, versionID string, metadata GetVersionMetadataOutput) {
+	if t.metadataCache == nil || appID == 0 || versionID == "" {
+		return
+	}
+
+	// The cache is best effort: failing to persist an entry only means it is fetched again.
+	_ = t.metadataCache.Set(versionMetadataCacheKey(appID, versionID), cachedVersionMetadata{
+		DisplayVersion: metadata.DisplayVersion,
+		ReleaseDate:    metadata.ReleaseDate,
+	})
+}
+
+func (t *appstore) cachedMinimumOSVersion(appID int64, versionID string) (string, bool) {
+	if t.metadataCache == nil || appID == 0 {
+		return "", false
+	}
+
+	var minimumOSVersion string
+
+	return minimumOSVersion, t.metadataCache.Get(minimumOSVersionCacheKey(appID, versionID), &minimumOSVersion)
+}
+
+func (t *appstore) cacheMinimumOSVersion(appID int64, versionID string, minimumOSVersion string) {
+	if t.metadataCache == nil || appID == 0 || versionID == "" {
+		return
+	}
+
+	_ = t.metadataCache.Set(minimumOSVersionCacheKey(appID, versionID), minimumOSVersion)
+}
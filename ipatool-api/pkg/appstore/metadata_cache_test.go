@@ -0,0 +1,127 @@
+package appstore
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/majd/ipatool/v2/pkg/http"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"go.uber.org/mock/gomock"
+)
+
+// memoryMetadataCache is an in-memory MetadataCache for tests.
+type memoryMetadataCache map[string][]byte
+
+func (c memoryMetadataCache) Get(key string, value interface{}) bool {
+	data, ok := c[key]
+
+	return ok && json.Unmarshal(data, value) == nil
+}
+
+func (c memoryMetadataCache) Set(key string, value interface{}) error {
+	data, err := json.Marshal(value)
+	c[key] = data
+
+	return err
+}
+
+var _ = Describe("AppStore (MetadataCache)", func() {
+	var (
+		ctrl               *gomock.Controller
+		mockDownloadClient *http.MockClient[downloadResult]
+		cache              memoryMetadataCache
+		as                 AppStore
+		testApp            = App{ID: 1}
+		releaseDate        = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	)
+
+	BeforeEach(func() {
+		ctrl = gomock.NewController(GinkgoT())
+		mockDownloadClient = http.NewMockClient[downloadResult](ctrl)
+		cache = memoryMetadataCache{}
+		as = &appstore{
+			downloadClient: mockDownloadClient,
+			deviceGUID:     "GUID",
+			metadataCache:  cache,
+		}
+	})
+
+	AfterEach(func() {
+		ctrl.Finish()
+	})
+
+	metadataResult := func(metadata map[string]interface{}) http.Result[downloadResult] {
+		return http.Result[downloadResult]{
+			Data: downloadResult{
+				Items: []downloadItemResult{{Metadata: metadata}},
+			},
+		}
+	}
+
+	It("fetches the metadata of a version only once", func() {
+		mockDownloadClient.EXPECT().
+			Send(gomock.Any()).
+			Return(metadataResult(map[string]interface{}{
+				"bundleShortVersionString": "1.0.0",
+				"releaseDate":              "2024-01-01T00:00:00Z",
+			}), nil)
+
+		for range 2 {
+			out, err := as.GetVersionMetadata(GetVersionMetadataInput{App: testApp, VersionID: "10"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(out.DisplayVersion).To(Equal("1.0.0"))
+			Expect(out.ReleaseDate).To(Equal(releaseDate))
+		}
+	})
+
+	It("bypasses the cache on request", func() {
+		Expect(cache.Set(versionMetadataCacheKey(testApp.ID, "10"), cachedVersionMetadata{DisplayVersion: "stale"})).To(Succeed())
+
+		mockDownloadClient.EXPECT().
+			Send(gomock.Any()).
+			Return(metadataResult(map[string]interface{}{
+				"bundleShortVersionString": "1.0.0",
+				"releaseDate":              "2024-01-01T00:00:00Z",
+			}), nil)
+
+		out, err := as.GetVersionMetadata(GetVersionMetadataInput{App: testApp, VersionID: "10", BypassCache: true})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(out.DisplayVersion).To(Equal("1.0.0"))
+
+		out, err = as.GetVersionMetadata(GetVersionMetadataInput{App: testApp, VersionID: "10"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(out.DisplayVersion).To(Equal("1.0.0"))
+	})
+
+	It("caches the metadata of the latest version when listing versions", func() {
+		mockDownloadClient.EXPECT().
+			Send(gomock.Any()).
+			Return(metadataResult(map[string]interface{}{
+				"softwareVersionExternalIdentifiers": []interface{}{"10", "20"},
+				"softwareVersionExternalIdentifier":  "20",
+				"bundleShortVersionString":           "2.0.0",
+				"releaseDate":                        "2024-01-01T00:00:00Z",
+			}), nil)
+
+		_, err := as.ListVersions(ListVersionsInput{App: testApp})
+		Expect(err).ToNot(HaveOccurred())
+
+		out, err := as.GetVersionMetadata(GetVersionMetadataInput{App: testApp, VersionID: "20"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(out.DisplayVersion).To(Equal("2.0.0"))
+	})
+
+	It("does not cache versions of apps without an ID", func() {
+		mockDownloadClient.EXPECT().
+			Send(gomock.Any()).
+			Return(metadataResult(map[string]interface{}{
+				"bundleShortVersionString": "1.0.0",
+				"releaseDate":              "2024-01-01T00:00:00Z",
+			}), nil)
+
+		_, err := as.GetVersionMetadata(GetVersionMetadataInput{App: App{BundleID: "app.bundle.id"}, VersionID: "10"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(cache).To(BeEmpty())
+	})
+})
@@ -0,0 +1,289 @@
+package appstore
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"howett.net/plist"
+)
+
+// packagePatch adds entries to a package without rewriting it: the local file entries are kept as they are,
+// followed by the new entries and a new central directory.
+type packagePatch struct {
+	tail   archiveTail
+	suffix []byte
+}
+
+// newPackagePatch reads the central directory of the package and prepares the entries to add. Only the end of
+// the package and the files read by entries are read. With reclaim, the replaced entries stored last in the
+// package, typically by an earlier patch, are overwritten instead of being left behind.
+func newPackagePatch(r io.ReaderAt, size int64, reclaim bool, entries func(reader *zip.Reader) ([]archiveEntry, error)) (packagePatch, error) {
+	tail, err := readArchiveTail(r, size)
+	if err != nil {
+		return packagePatch{}, fmt.Errorf("failed to read central directory: %w", err)
+	}
+
+	reader, err := zip.NewReader(r, size)
+	if err != nil {
+		return packagePatch{}, fmt.Errorf("failed to open zip reader: %w", err)
+	}
+
+	added, err := entries(reader)
+	if err != nil {
+		return packagePatch{}, err
+	}
+
+	if reclaim {
+		tail = tail.withoutTrailingEntries(added)
+	}
+
+	var suffix bytes.Buffer
+
+	// The entries are as old as the newest existing entry, so that a package is always patched to the same bytes.
+	err = writeArchiveSuffix(&suffix, tail, added, latestModified(reader))
+	if err != nil {
+		return packagePatch{}, err
+	}
+
+	return packagePatch{tail: tail, suffix: suffix.Bytes()}, nil
+}
+
+// patchFile adds the entries to the package file in place. The local file entries are kept; the entries and a
+// new central directory are written over the old central directory, so only the end of the file is written.
+// The bytes that are overwritten are saved to a recovery file first, so that an interrupted patch is undone by
+// the next one.
+func (t *appstore) patchFile(file *os.File, entries func(reader *zip.Reader) ([]archiveEntry, error)) error {
+	err := t.restorePatchedFile(file)
+	if err != nil {
+		return err
+	}
+
+	stat, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to get file info: %w", err)
+	}
+
+	patch, err := newPackagePatch(file, stat.Size(), true, entries)
+	if err != nil {
+		return err
+	}
+
+	original := make([]byte, stat.Size()-patch.tail.offset)
+
+	_, err = file.ReadAt(original, patch.tail.offset)
+	if err != nil {
+		return fmt.Errorf("failed to read central directory: %w", err)
+	}
+
+	err = t.savePatchRecovery(file.Name(), patch.tail.offset, original)
+	if err != nil {
+		return err
+	}
+
+	_, err = file.WriteAt(patch.suffix, patch.tail.offset)
+	if err != nil {
+		return fmt.Errorf("failed to write patches: %w", err)
+	}
+
+	// The new end of the package may be shorter than the old one.
+	err = file.Truncate(patch.Size())
+	if err != nil {
+		return fmt.Errorf("failed to truncate file: %w", err)
+	}
+
+	err = file.Sync()
+	if err != nil {
+		return fmt.Errorf("failed to sync file: %w", err)
+	}
+
+	err = t.os.Remove(file.Name() + patchRecoverySuffix)
+	if err != nil {
+		return fmt.Errorf("failed to remove patch recovery file: %w", err)
+	}
+
+	return nil
+}
+
+// patchRecoverySuffix names the file that holds the end of a package while it is patched in place.
+const patchRecoverySuffix = ".patch"
+
+// savePatchRecovery saves the offset and the original bytes of the end of the package. The file is written
+// completely before it takes its name, so that an existing recovery file is always whole.
+func (t *appstore) savePatchRecovery(path string, offset int64, original []byte) error {
+	tmp := path + patchRecoverySuffix + ".tmp"
+
+	file, err := t.os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open patch recovery file: %w", err)
+	}
+
+	_, err = file.Write(binary.LittleEndian.AppendUint64(nil, uint64(offset)))
+	if err == nil {
+		_, err = file.Write(original)
+	}
+
+	if err == nil {
+		err = file.Sync()
+	}
+
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to write patch recovery file: %w", err)
+	}
+
+	err = t.os.Rename(tmp, path+patchRecoverySuffix)
+	if err != nil {
+		return fmt.Errorf("failed to save patch recovery file: %w", err)
+	}
+
+	return nil
+}
+
+// restorePatchedFile puts back the end of a package whose patch was interrupted. It does nothing if there is no
+// recovery file.
+func (t *appstore) restorePatchedFile(file *os.File) error {
+	recovery, err := t.os.OpenFile(file.Name()+patchRecoverySuffix, os.O_RDONLY, 0)
+	if err != nil && t.os.IsNotExist(err) {
+		return nil
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to open patch recovery file: %w", err)
+	}
+
+	data, err := io.ReadAll(recovery)
+	recovery.Close()
+
+	if err != nil {
+		return fmt.Errorf("failed to read patch recovery file: %w", err)
+	}
+
+	if len(data) < 8 {
+		return fmt.Errorf("invalid patch recovery file %s", file.Name()+patchRecoverySuffix)
+	}
+
+	offset := int64(binary.LittleEndian.Uint64(data))
+	original := data[8:]
+
+	_, err = file.WriteAt(original, offset)
+	if err != nil {
+		return fmt.Errorf("failed to restore package: %w", err)
+	}
+
+	err = file.Truncate(offset + int64(len(original)))
+	if err != nil {
+		return fmt.Errorf("failed to restore package: %w", err)
+	}
+
+	err = file.Sync()
+	if err != nil {
+		return fmt.Errorf("failed to sync file: %w", err)
+	}
+
+	err = t.os.Remove(file.Name() + patchRecoverySuffix)
+	if err != nil {
+		return fmt.Errorf("failed to remove patch recovery file: %w", err)
+	}
+
+	return nil
+}
+
+// restoreInterruptedPatch restores the package file if its patch was interrupted, so that its size matches the
+// package on the CDN again.
+func (t *appstore) restoreInterruptedPatch(path string) error {
+	recovery, err := t.os.OpenFile(path+patchRecoverySuffix, os.O_RDONLY, 0)
+	if err != nil && t.os.IsNotExist(err) {
+		return nil
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to open patch recovery file: %w", err)
+	}
+
+	recovery.Close()
+
+	file, err := t.os.OpenFile(path, os.O_RDWR, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open file: %w", err)
+	}
+
+	defer file.Close()
+
+	return t.restorePatchedFile(file)
+}
+
+// packagePatches returns the files added to a downloaded package: the sinfs and iTunesMetadata.plist.
+func (t *appstore) packagePatches(reader *zip.Reader, item downloadItemResult, acc Account) ([]archiveEntry, error) {
+	var entries []archiveEntry
+
+	// Replicate SINF so the device can run the app (FairPlay DRM)
+	if len(item.Sinfs) > 0 {
+		bundleName, err := t.readBundleName(reader)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read bundle name for SINF: %w", err)
+		}
+		manifest, _ := t.readManifestPlist(reader)
+		info, _ := t.readInfoPlist(reader)
+		if manifest != nil {
+			entries, err = t.replicateSinfFromManifest(*manifest, item.Sinfs, bundleName)
+		} else if info != nil {
+			entries, err = t.replicateSinfFromInfo(*info, item.Sinfs, bundleName)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to replicate sinf: %w", err)
+		}
+	}
+
+	metadata, err := t.metadataEntry(item.Metadata, acc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to write metadata: %w", err)
+	}
+
+	return append(entries, metadata), nil
+}
+
+func latestModified(reader *zip.Reader) time.Time {
+	var latest time.Time
+
+	for _, file := range reader.File {
+		if file.Modified.After(latest) {
+			latest = file.Modified
+		}
+	}
+
+	return latest
+}
+
+// Size returns the size of the patched package.
+func (p packagePatch) Size() int64 {
+	return p.tail.offset + int64(len(p.suffix))
+}
+
+func (t *appstore) metadataEntry(metadata map[string]interface{}, acc Account) (archiveEntry, error) {
+	data, err := plist.Marshal(accountMetadata(metadata, acc), plist.BinaryFormat)
+	if err != nil {
+		return archiveEntry{}, fmt.Errorf("failed to marshal data: %w", err)
+	}
+
+	return archiveEntry{Name: "iTunesMetadata.plist", Data: data}, nil
+}
+
+// accountMetadata binds the metadata of the store to the account.
+func accountMetadata(metadata map[string]interface{}, acc Account) map[string]interface{} {
+	if metadata == nil {
+		metadata = map[string]interface{}{}
+	}
+
+	metadata["apple-id"] = acc.Email
+	metadata["userName"] = acc.Email
+
+	return metadata
+}
@@ -0,0 +1,131 @@
+package appstore
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/majd/ipatool/v2/pkg/util/operatingsystem"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Package Patch", func() {
+	var (
+		as   *appstore
+		path string
+	)
+
+	BeforeEach(func() {
+		as = &appstore{os: operatingsystem.New()}
+		path = filepath.Join(GinkgoT().TempDir(), "app.ipa")
+
+		file, err := os.Create(path)
+		Expect(err).ToNot(HaveOccurred())
+
+		writer := zip.NewWriter(file)
+
+		for name, content := range map[string]string{
+			"Payload/App.app/Info.plist": "info",
+			"Payload/App.app/App":        "binary",
+		} {
+			w, err := writer.Create(name)
+			Expect(err).ToNot(HaveOccurred())
+
+			_, err = w.Write([]byte(content))
+			Expect(err).ToNot(HaveOccurred())
+		}
+
+		Expect(writer.Close()).To(Succeed())
+		Expect(file.Close()).To(Succeed())
+	})
+
+	patch := func(entries ...archiveEntry) error {
+		file, err := os.OpenFile(path, os.O_RDWR, 0644)
+		Expect(err).ToNot(HaveOccurred())
+		defer file.Close()
+
+		return as.patchFile(file, func(*zip.Reader) ([]archiveEntry, error) {
+			return entries, nil
+		})
+	}
+
+	read := func(name string) string {
+		reader, err := zip.OpenReader(path)
+		Expect(err).ToNot(HaveOccurred())
+		defer reader.Close()
+
+		file, err := reader.Open(name)
+		Expect(err).ToNot(HaveOccurred())
+		defer file.Close()
+
+		data, err := io.ReadAll(file)
+		Expect(err).ToNot(HaveOccurred())
+
+		return string(data)
+	}
+
+	size := func() int64 {
+		stat, err := os.Stat(path)
+		Expect(err).ToNot(HaveOccurred())
+
+		return stat.Size()
+	}
+
+	It("reuses the space of the entries it replaces", func() {
+		Expect(patch(archiveEntry{Name: "Payload/App.app/SC_Info/App.sinf", Data: []byte("sinf-1")})).To(Succeed())
+		patched := size()
+
+		Expect(patch(archiveEntry{Name: "Payload/App.app/SC_Info/App.sinf", Data: []byte("sinf-2")})).To(Succeed())
+		Expect(size()).To(Equal(patched))
+		Expect(read("Payload/App.app/SC_Info/App.sinf")).To(Equal("sinf-2"))
+		Expect(read("Payload/App.app/App")).To(Equal("binary"))
+		Expect(path + patchRecoverySuffix).ToNot(BeAnExistingFile())
+	})
+
+	It("keeps the entries stored before the replaced ones", func() {
+		Expect(patch(archiveEntry{Name: "Payload/App.app/App", Data: []byte("patched")})).To(Succeed())
+		Expect(read("Payload/App.app/App")).To(Equal("patched"))
+		Expect(read("Payload/App.app/Info.plist")).To(Equal("info"))
+	})
+
+	It("restores a package whose patch was interrupted", func() {
+		original, err := os.ReadFile(path)
+		Expect(err).ToNot(HaveOccurred())
+
+		tail, err := readArchiveTail(bytes.NewReader(original), int64(len(original)))
+		Expect(err).ToNot(HaveOccurred())
+
+		// The recovery file was saved and the central directory was partly overwritten.
+		recovery := binary.LittleEndian.AppendUint64(nil, uint64(tail.offset))
+		recovery = append(recovery, original[tail.offset:]...)
+		Expect(os.WriteFile(path+patchRecoverySuffix, recovery, 0644)).To(Succeed())
+
+		damaged := append(original[:tail.offset:tail.offset], "partial"...)
+		Expect(os.WriteFile(path, damaged, 0644)).To(Succeed())
+
+		Expect(patch(archiveEntry{Name: "Payload/App.app/SC_Info/App.sinf", Data: []byte("sinf")})).To(Succeed())
+		Expect(read("Payload/App.app/SC_Info/App.sinf")).To(Equal("sinf"))
+		Expect(read("Payload/App.app/Info.plist")).To(Equal("info"))
+		Expect(path + patchRecoverySuffix).ToNot(BeAnExistingFile())
+	})
+
+	It("reads the local header offset of ZIP64 records", func() {
+		raw := make([]byte, zipDirectoryHeaderLength)
+		binary.LittleEndian.PutUint32(raw[20:], zipUint32Max)
+		binary.LittleEndian.PutUint16(raw[28:], 1)
+		binary.LittleEndian.PutUint16(raw[30:], 20)
+		binary.LittleEndian.PutUint32(raw[42:], zipUint32Max)
+		raw = append(raw, 'a')
+		raw = appendUint16s(raw, zip64ExtraID, 16)
+		raw = binary.LittleEndian.AppendUint64(raw, 1<<33)
+		raw = binary.LittleEndian.AppendUint64(raw, 1<<34)
+
+		offset, ok := directoryRecord{name: "a", raw: raw}.headerOffset()
+		Expect(ok).To(BeTrue())
+		Expect(offset).To(Equal(uint64(1 << 34)))
+	})
+})
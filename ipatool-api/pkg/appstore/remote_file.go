@@ -0,0 +1,171 @@
+package appstore
+
+import (
+	"fmt"
+	"io"
+	gohttp "net/http"
+	"strconv"
+	"strings"
+
+	"github.com/majd/ipatool/v2/pkg/http"
+)
+
+// remoteFileBlockSize is the size of the ranges requested by a remoteFile. Large enough that the
+// central directory of a big IPA takes a handful of requests.
+const remoteFileBlockSize = 256 * 1024
+
+// remoteFile reads a file served over HTTP with range requests, so that a zip archive can be inspected
+// without downloading it. Fetched ranges are kept in memory. It is not safe for concurrent use.
+type remoteFile struct {
+	appstore *appstore
+	url      string
+	proxy    string
+	size     int64
+	segments []remoteFileSegment
+}
+
+type remoteFileSegment struct {
+	offset int64
+	data   []byte
+}
+
+// openRemoteFile fetches the last block of the file, which also reveals its size. For a zip archive,
+// that block holds the end of the central directory.
+func (t *appstore) openRemoteFile(url, proxy string) (*remoteFile, error) {
+	file := &remoteFile{appstore: t, url: url, proxy: proxy}
+
+	data, contentRange, err := file.fetch(fmt.Sprintf("bytes=-%d", remoteFileBlockSize))
+	if err != nil {
+		return nil, err
+	}
+
+	size, err := parseContentRangeSize(contentRange)
+	if err != nil {
+		return nil, err
+	}
+
+	file.size = size
+	file.segments = append(file.segments, remoteFileSegment{offset: size - int64(len(data)), data: data})
+
+	return file, nil
+}
+
+func (f *remoteFile) Size() int64 {
+	return f.size
+}
+
+func (f *remoteFile) ReadAt(p []byte, off int64) (int, error) {
+	n := 0
+
+	for n < len(p) {
+		pos := off + int64(n)
+		if pos >= f.size {
+			return n, io.EOF
+		}
+
+		data, err := f.dataAt(pos, int64(len(p)-n))
+		if err != nil {
+			return n, err
+		}
+
+		n += copy(p[n:], data)
+	}
+
+	return n, nil
+}
+
+// dataAt returns the fetched bytes starting at the position. If they were not fetched yet, the block containing
+// the position is fetched, extended to the wanted length so that large reads take a single request.
+func (f *remoteFile) dataAt(pos, want int64) ([]byte, error) {
+	for _, segment := range f.segments {
+		if pos >= segment.offset && pos < segment.offset+int64(len(segment.data)) {
+			return segment.data[pos-segment.offset:], nil
+		}
+	}
+
+	start := pos - pos%remoteFileBlockSize
+	end := min(max(start+remoteFileBlockSize, pos+want), f.size) - 1
+
+	data, _, err := f.fetch(fmt.Sprintf("bytes=%d-%d", start, end))
+	if err != nil {
+		return nil, err
+	}
+
+	if int64(len(data)) != end-start+1 {
+		return nil, fmt.Errorf("received %d bytes for range %d-%d", len(data), start, end)
+	}
+
+	f.segments = append(f.segments, remoteFileSegment{offset: start, data: data})
+
+	return data[pos-start:], nil
+}
+
+func (f *remoteFile) fetch(byteRange string) ([]byte, string, error) {
+	var (
+		data         []byte
+		contentRange string
+	)
+
+	err := f.appstore.retryPolicy.Do("read file range", func() error {
+		req, err := f.appstore.httpClient.NewRequest("GET", f.url, nil)
+		if err != nil {
+			return fmt.Errorf("failed to create request: %w", err)
+		}
+
+		req, err = http.WithProxy(req, f.proxy)
+		if err != nil {
+			return fmt.Errorf("failed to configure proxy: %w", err)
+		}
+
+		req.Header.Set("Range", byteRange)
+
+		res, err := f.appstore.httpClient.Do(req)
+		if err != nil {
+			return http.NewTransientError(fmt.Errorf("request failed: %w", err), 0)
+		}
+		defer res.Body.Close()
+
+		if http.IsTransientStatus(res.StatusCode) {
+			return http.NewTransientError(
+				fmt.Errorf("received status code %d", res.StatusCode),
+				http.ParseRetryAfter(res.Header.Get("Retry-After")),
+			)
+		}
+
+		// A server may answer with the whole file if it is smaller than the range. Anything larger would
+		// defeat the purpose of range requests.
+		wholeFile := res.StatusCode == gohttp.StatusOK && res.ContentLength >= 0 && res.ContentLength <= remoteFileBlockSize
+		if res.StatusCode != gohttp.StatusPartialContent && !wholeFile {
+			return fmt.Errorf("%w: received status code %d", ErrRangesNotSupported, res.StatusCode)
+		}
+
+		data, err = io.ReadAll(res.Body)
+		if err != nil {
+			return http.NewTransientError(fmt.Errorf("failed to read response body: %w", err), 0)
+		}
+
+		contentRange = res.Header.Get("Content-Range")
+		if wholeFile {
+			contentRange = fmt.Sprintf("bytes 0-%d/%d", len(data)-1, len(data))
+		}
+
+		return nil
+	})
+
+	return data, contentRange, err
+}
+
+// parseContentRangeSize returns the complete length from a Content-Range header like "bytes 0-99/1234".
+func parseContentRangeSize(contentRange string) (int64, error) {
+	_, total, ok := strings.Cut(contentRange, "/")
+	if !ok {
+		return 0, fmt.Errorf("invalid content range: %q", contentRange)
+	}
+
+	size, err := strconv.ParseInt(total, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid content range: %q", contentRange)
+	}
+
+	return size, nil
+}
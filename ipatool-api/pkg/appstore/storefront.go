@@ -2,154 +2,281 @@ package appstore
 
 import (
 	"fmt"
+	"sort"
+	"strconv"
 	"strings"
 )
 
-func countryCodeFromStoreFront(storeFront string) (string, error) {
-	for key, val := range storeFronts {
-		parts := strings.Split(storeFront, "-")
+// Storefront is a parsed App Store storefront header value such as "143441-1,29":
+// the storefront ID, followed by the language ID and the platform.
+type Storefront struct {
+	ID          string
+	CountryCode string
+	LanguageID  int
+	Platform    int
+}
+
+// ParseStorefront parses a storefront header value. The language and platform parts are optional.
+func ParseStorefront(value string) (Storefront, error) {
+	rest, platform, hasPlatform := strings.Cut(strings.TrimSpace(value), ",")
+	id, language, hasLanguage := strings.Cut(rest, "-")
+
+	country, ok := storeFrontCountries[id]
+	if !ok {
+		return Storefront{}, fmt.Errorf("country code mapping for store front (%s) was not found", value)
+	}
+
+	sf := Storefront{ID: id, CountryCode: country}
 
-		if len(parts) >= 1 && parts[0] == val {
-			return key, nil
+	if hasLanguage {
+		languageID, err := strconv.Atoi(language)
+		if err != nil {
+			return Storefront{}, fmt.Errorf("invalid language in store front (%s): %w", value, err)
 		}
+
+		sf.LanguageID = languageID
+	}
+
+	if hasPlatform {
+		platformID, err := strconv.Atoi(platform)
+		if err != nil {
+			return Storefront{}, fmt.Errorf("invalid platform in store front (%s): %w", value, err)
+		}
+
+		sf.Platform = platformID
+	}
+
+	return sf, nil
+}
+
+// StorefrontForCountry returns the storefront of the country with the specified ISO 3166-1 alpha-2 code.
+func StorefrontForCountry(countryCode string) (Storefront, bool) {
+	country, ok := countries[strings.ToUpper(countryCode)]
+	if !ok {
+		return Storefront{}, false
+	}
+
+	return Storefront{ID: country.StorefrontID, CountryCode: country.Code}, true
+}
+
+// Language returns the BCP 47 tag of the storefront language, or an empty string if the language ID is not known.
+func (s Storefront) Language() string {
+	return storeFrontLanguages[s.LanguageID]
+}
+
+// String formats the storefront as a header value.
+func (s Storefront) String() string {
+	value := s.ID
+
+	if s.LanguageID != 0 {
+		value += "-" + strconv.Itoa(s.LanguageID)
 	}
 
-	return "", fmt.Errorf("country code mapping for store front (%s) was not found", storeFront)
+	if s.Platform != 0 {
+		value += "," + strconv.Itoa(s.Platform)
+	}
+
+	return value
+}
+
+// Country is a country with an App Store storefront.
+type Country struct {
+	Code         string
+	Name         string
+	Currency     string
+	StorefrontID string
+}
+
+// Countries returns all countries with an App Store storefront, sorted by country code.
+func Countries() []Country {
+	result := make([]Country, 0, len(countries))
+	for _, country := range countries {
+		result = append(result, country)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Code < result[j].Code
+	})
+
+	return result
+}
+
+// CountryByCode returns the country with the specified ISO 3166-1 alpha-2 code.
+func CountryByCode(code string) (Country, bool) {
+	country, ok := countries[strings.ToUpper(code)]
+
+	return country, ok
+}
+
+func countryCodeFromStoreFront(storeFront string) (string, error) {
+	sf, err := ParseStorefront(storeFront)
+	if err != nil {
+		return "", err
+	}
+
+	return sf.CountryCode, nil
 }
 
-var storeFronts = map[string]string{
-	"AE": "143481",
-	"AG": "143540",
-	"AI": "143538",
-	"AL": "143575",
-	"AM": "143524",
-	"AO": "143564",
-	"AR": "143505",
-	"AT": "143445",
-	"AU": "143460",
-	"AZ": "143568",
-	"BB": "143541",
-	"BD": "143490",
-	"BE": "143446",
-	"BG": "143526",
-	"BH": "143559",
-	"BM": "143542",
-	"BN": "143560",
-	"BO": "143556",
-	"BR": "143503",
-	"BS": "143539",
-	"BW": "143525",
-	"BY": "143565",
-	"BZ": "143555",
-	"CA": "143455",
-	"CH": "143459",
-	"CI": "143527",
-	"CL": "143483",
-	"CN": "143465",
-	"CO": "143501",
-	"CR": "143495",
-	"CY": "143557",
-	"CZ": "143489",
-	"DE": "143443",
-	"DK": "143458",
-	"DM": "143545",
-	"DO": "143508",
-	"DZ": "143563",
-	"EC": "143509",
-	"EE": "143518",
-	"EG": "143516",
-	"ES": "143454",
-	"FI": "143447",
-	"FR": "143442",
-	"GB": "143444",
-	"GD": "143546",
-	"GE": "143615",
-	"GH": "143573",
-	"GR": "143448",
-	"GT": "143504",
-	"GY": "143553",
-	"HK": "143463",
-	"HN": "143510",
-	"HR": "143494",
-	"HU": "143482",
-	"ID": "143476",
-	"IE": "143449",
-	"IL": "143491",
-	"IN": "143467",
-	"IS": "143558",
-	"IT": "143450",
-	"IQ": "143617",
-	"JM": "143511",
-	"JO": "143528",
-	"JP": "143462",
-	"KE": "143529",
-	"KN": "143548",
-	"KR": "143466",
-	"KW": "143493",
-	"KY": "143544",
-	"KZ": "143517",
-	"LB": "143497",
-	"LC": "143549",
-	"LI": "143522",
-	"LK": "143486",
-	"LT": "143520",
-	"LU": "143451",
-	"LV": "143519",
-	"MD": "143523",
-	"MG": "143531",
-	"MK": "143530",
-	"ML": "143532",
-	"MN": "143592",
-	"MO": "143515",
-	"MS": "143547",
-	"MT": "143521",
-	"MU": "143533",
-	"MV": "143488",
-	"MX": "143468",
-	"MY": "143473",
-	"NE": "143534",
-	"NG": "143561",
-	"NI": "143512",
-	"NL": "143452",
-	"NO": "143457",
-	"NP": "143484",
-	"NZ": "143461",
-	"OM": "143562",
-	"PA": "143485",
-	"PE": "143507",
-	"PH": "143474",
-	"PK": "143477",
-	"PL": "143478",
-	"PT": "143453",
-	"PY": "143513",
-	"QA": "143498",
-	"RO": "143487",
-	"RS": "143500",
-	"RU": "143469",
-	"SA": "143479",
-	"SE": "143456",
-	"SG": "143464",
-	"SI": "143499",
-	"SK": "143496",
-	"SN": "143535",
-	"SR": "143554",
-	"SV": "143506",
-	"TC": "143552",
-	"TH": "143475",
-	"TN": "143536",
-	"TR": "143480",
-	"TT": "143551",
-	"TW": "143470",
-	"TZ": "143572",
-	"UA": "143492",
-	"UG": "143537",
-	"US": "143441",
-	"UY": "143514",
-	"UZ": "143566",
-	"VC": "143550",
-	"VE": "143502",
-	"VG": "143543",
-	"VN": "143471",
-	"YE": "143571",
-	"ZA": "143472",
+// storeFrontLanguages maps the language IDs used in storefront header values to BCP 47 tags.
+var storeFrontLanguages = map[int]string{
+	1:  "en-US",
+	2:  "en-GB",
+	3:  "fr-FR",
+	4:  "de-DE",
+	5:  "fr-CA",
+	7:  "it-IT",
+	8:  "es-ES",
+	9:  "ja-JP",
+	10: "nl-NL",
+	15: "pt-BR",
+	19: "zh-Hans",
+	27: "en-AU",
+	28: "es-MX",
+}
+
+// storeFrontCountries maps storefront IDs to country codes.
+var storeFrontCountries = func() map[string]string {
+	result := make(map[string]string, len(countries))
+	for code, country := range countries {
+		result[country.StorefrontID] = code
+	}
+
+	return result
+}()
+
+var countries = map[string]Country{
+	"AE": {Code: "AE", Name: "United Arab Emirates", Currency: "AED", StorefrontID: "143481"},
+	"AG": {Code: "AG", Name: "Antigua and Barbuda", Currency: "XCD", StorefrontID: "143540"},
+	"AI": {Code: "AI", Name: "Anguilla", Currency: "XCD", StorefrontID: "143538"},
+	"AL": {Code: "AL", Name: "Albania", Currency: "ALL", StorefrontID: "143575"},
+	"AM": {Code: "AM", Name: "Armenia", Currency: "AMD", StorefrontID: "143524"},
+	"AO": {Code: "AO", Name: "Angola", Currency: "AOA", StorefrontID: "143564"},
+	"AR": {Code: "AR", Name: "Argentina", Currency: "ARS", StorefrontID: "143505"},
+	"AT": {Code: "AT", Name: "Austria", Currency: "EUR", StorefrontID: "143445"},
+	"AU": {Code: "AU", Name: "Australia", Currency: "AUD", StorefrontID: "143460"},
+	"AZ": {Code: "AZ", Name: "Azerbaijan", Currency: "AZN", StorefrontID: "143568"},
+	"BB": {Code: "BB", Name: "Barbados", Currency: "BBD", StorefrontID: "143541"},
+	"BD": {Code: "BD", Name: "Bangladesh", Currency: "BDT", StorefrontID: "143490"},
+	"BE": {Code: "BE", Name: "Belgium", Currency: "EUR", StorefrontID: "143446"},
+	"BG": {Code: "BG", Name: "Bulgaria", Currency: "BGN", StorefrontID: "143526"},
+	"BH": {Code: "BH", Name: "Bahrain", Currency: "BHD", StorefrontID: "143559"},
+	"BM": {Code: "BM", Name: "Bermuda", Currency: "BMD", StorefrontID: "143542"},
+	"BN": {Code: "BN", Name: "Brunei", Currency: "BND", StorefrontID: "143560"},
+	"BO": {Code: "BO", Name: "Bolivia", Currency: "BOB", StorefrontID: "143556"},
+	"BR": {Code: "BR", Name: "Brazil", Currency: "BRL", StorefrontID: "143503"},
+	"BS": {Code: "BS", Name: "Bahamas", Currency: "BSD", StorefrontID: "143539"},
+	"BW": {Code: "BW", Name: "Botswana", Currency: "BWP", StorefrontID: "143525"},
+	"BY": {Code: "BY", Name: "Belarus", Currency: "BYN", StorefrontID: "143565"},
+	"BZ": {Code: "BZ", Name: "Belize", Currency: "BZD", StorefrontID: "143555"},
+	"CA": {Code: "CA", Name: "Canada", Currency: "CAD", StorefrontID: "143455"},
+	"CH": {Code: "CH", Name: "Switzerland", Currency: "CHF", StorefrontID: "143459"},
+	"CI": {Code: "CI", Name: "Côte d'Ivoire", Currency: "XOF", StorefrontID: "143527"},
+	"CL": {Code: "CL", Name: "Chile", Currency: "CLP", StorefrontID: "143483"},
+	"CN": {Code: "CN", Name: "China", Currency: "CNY", StorefrontID: "143465"},
+	"CO": {Code: "CO", Name: "Colombia", Currency: "COP", StorefrontID: "143501"},
+	"CR": {Code: "CR", Name: "Costa Rica", Currency: "CRC", StorefrontID: "143495"},
+	"CY": {Code: "CY", Name: "Cyprus", Currency: "EUR", StorefrontID: "143557"},
+	"CZ": {Code: "CZ", Name: "Czechia", Currency: "CZK", StorefrontID: "143489"},
+	"DE": {Code: "DE", Name: "Germany", Currency: "EUR", StorefrontID: "143443"},
+	"DK": {Code: "DK", Name: "Denmark", Currency: "DKK", StorefrontID: "143458"},
+	"DM": {Code: "DM", Name: "Dominica", Currency: "XCD", StorefrontID: "143545"},
+	"DO": {Code: "DO", Name: "Dominican Republic", Currency: "DOP", StorefrontID: "143508"},
+	"DZ": {Code: "DZ", Name: "Algeria", Currency: "DZD", StorefrontID: "143563"},
+	"EC": {Code: "EC", Name: "Ecuador", Currency: "USD", StorefrontID: "143509"},
+	"EE": {Code: "EE", Name: "Estonia", Currency: "EUR", StorefrontID: "143518"},
+	"EG": {Code: "EG", Name: "Egypt", Currency: "EGP", StorefrontID: "143516"},
+	"ES": {Code: "ES", Name: "Spain", Currency: "EUR", StorefrontID: "143454"},
+	"FI": {Code: "FI", Name: "Finland", Currency: "EUR", StorefrontID: "143447"},
+	"FR": {Code: "FR", Name: "France", Currency: "EUR", StorefrontID: "143442"},
+	"GB": {Code: "GB", Name: "United Kingdom", Currency: "GBP", StorefrontID: "143444"},
+	"GD": {Code: "GD", Name: "Grenada", Currency: "XCD", StorefrontID: "143546"},
+	"GE": {Code: "GE", Name: "Georgia", Currency: "GEL", StorefrontID: "143615"},
+	"GH": {Code: "GH", Name: "Ghana", Currency: "GHS", StorefrontID: "143573"},
+	"GR": {Code: "GR", Name: "Greece", Currency: "EUR", StorefrontID: "143448"},
+	"GT": {Code: "GT", Name: "Guatemala", Currency: "GTQ", StorefrontID: "143504"},
+	"GY": {Code: "GY", Name: "Guyana", Currency: "GYD", StorefrontID: "143553"},
+	"HK": {Code: "HK", Name: "Hong Kong", Currency: "HKD", StorefrontID: "143463"},
+	"HN": {Code: "HN", Name: "Honduras", Currency: "HNL", StorefrontID: "143510"},
+	"HR": {Code: "HR", Name: "Croatia", Currency: "EUR", StorefrontID: "143494"},
+	"HU": {Code: "HU", Name: "Hungary", Currency: "HUF", StorefrontID: "143482"},
+	"ID": {Code: "ID", Name: "Indonesia", Currency: "IDR", StorefrontID: "143476"},
+	"IE": {Code: "IE", Name: "Ireland", Currency: "EUR", StorefrontID: "143449"},
+	"IL": {Code: "IL", Name: "Israel", Currency: "ILS", StorefrontID: "143491"},
+	"IN": {Code: "IN", Name: "India", Currency: "INR", StorefrontID: "143467"},
+	"IS": {Code: "IS", Name: "Iceland", Currency: "ISK", StorefrontID: "143558"},
+	"IT": {Code: "IT", Name: "Italy", Currency: "EUR", StorefrontID: "143450"},
+	"IQ": {Code: "IQ", Name: "Iraq", Currency: "IQD", StorefrontID: "143617"},
+	"JM": {Code: "JM", Name: "Jamaica", Currency: "JMD", StorefrontID: "143511"},
+	"JO": {Code: "JO", Name: "Jordan", Currency: "JOD", StorefrontID: "143528"},
+	"JP": {Code: "JP", Name: "Japan", Currency: "JPY", StorefrontID: "143462"},
+	"KE": {Code: "KE", Name: "Kenya", Currency: "KES", StorefrontID: "143529"},
+	"KN": {Code: "KN", Name: "Saint Kitts and Nevis", Currency: "XCD", StorefrontID: "143548"},
+	"KR": {Code: "KR", Name: "South Korea", Currency: "KRW", StorefrontID: "143466"},
+	"KW": {Code: "KW", Name: "Kuwait", Currency: "KWD", StorefrontID: "143493"},
+	"KY": {Code: "KY", Name: "Cayman Islands", Currency: "KYD", StorefrontID: "143544"},
+	"KZ": {Code: "KZ", Name: "Kazakhstan", Currency: "KZT", StorefrontID: "143517"},
+	"LB": {Code: "LB", Name: "Lebanon", Currency: "LBP", StorefrontID: "143497"},
+	"LC": {Code: "LC", Name: "Saint Lucia", Currency: "XCD", StorefrontID: "143549"},
+	"LI": {Code: "LI", Name: "Liechtenstein", Currency: "CHF", StorefrontID: "143522"},
+	"LK": {Code: "LK", Name: "Sri Lanka", Currency: "LKR", StorefrontID: "143486"},
+	"LT": {Code: "LT", Name: "Lithuania", Currency: "EUR", StorefrontID: "143520"},
+	"LU": {Code: "LU", Name: "Luxembourg", Currency: "EUR", StorefrontID: "143451"},
+	"LV": {Code: "LV", Name: "Latvia", Currency: "EUR", StorefrontID: "143519"},
+	"MD": {Code: "MD", Name: "Moldova", Currency: "MDL", StorefrontID: "143523"},
+	"MG": {Code: "MG", Name: "Madagascar", Currency: "MGA", StorefrontID: "143531"},
+	"MK": {Code: "MK", Name: "North Macedonia", Currency: "MKD", StorefrontID: "143530"},
+	"ML": {Code: "ML", Name: "Mali", Currency: "XOF", StorefrontID: "143532"},
+	"MN": {Code: "MN", Name: "Mongolia", Currency: "MNT", StorefrontID: "143592"},
+	"MO": {Code: "MO", Name: "Macao", Currency: "MOP", StorefrontID: "143515"},
+	"MS": {Code: "MS", Name: "Montserrat", Currency: "XCD", StorefrontID: "143547"},
+	"MT": {Code: "MT", Name: "Malta", Currency: "EUR", StorefrontID: "143521"},
+	"MU": {Code: "MU", Name: "Mauritius", Currency: "MUR", StorefrontID: "143533"},
+	"MV": {Code: "MV", Name: "Maldives", Currency: "MVR", StorefrontID: "143488"},
+	"MX": {Code: "MX", Name: "Mexico", Currency: "MXN", StorefrontID: "143468"},
+	"MY": {Code: "MY", Name: "Malaysia", Currency: "MYR", StorefrontID: "143473"},
+	"NE": {Code: "NE", Name: "Niger", Currency: "XOF", StorefrontID: "143534"},
+	"NG": {Code: "NG", Name: "Nigeria", Currency: "NGN", StorefrontID: "143561"},
+	"NI": {Code: "NI", Name: "Nicaragua", Currency: "NIO", StorefrontID: "143512"},
+	"NL": {Code: "NL", Name: "Netherlands", Currency: "EUR", StorefrontID: "143452"},
+	"NO": {Code: "NO", Name: "Norway", Currency: "NOK", StorefrontID: "143457"},
+	"NP": {Code: "NP", Name: "Nepal", Currency: "NPR", StorefrontID: "143484"},
+	"NZ": {Code: "NZ", Name: "New Zealand", Currency: "NZD", StorefrontID: "143461"},
+	"OM": {Code: "OM", Name: "Oman", Currency: "OMR", StorefrontID: "143562"},
+	"PA": {Code: "PA", Name: "Panama", Currency: "PAB", StorefrontID: "143485"},
+	"PE": {Code: "PE", Name: "Peru", Currency: "PEN", StorefrontID: "143507"},
+	"PH": {Code: "PH", Name: "Philippines", Currency: "PHP", StorefrontID: "143474"},
+	"PK": {Code: "PK", Name: "Pakistan", Currency: "PKR", StorefrontID: "143477"},
+	"PL": {Code: "PL", Name: "Poland", Currency: "PLN", StorefrontID: "143478"},
+	"PT": {Code: "PT", Name: "Portugal", Currency: "EUR", StorefrontID: "143453"},
+	"PY": {Code: "PY", Name: "Paraguay", Currency: "PYG", StorefrontID: "143513"},
+	"QA": {Code: "QA", Name: "Qatar", Currency: "QAR", StorefrontID: "143498"},
+	"RO": {Code: "RO", Name: "Romania", Currency: "RON", StorefrontID: "143487"},
+	"RS": {Code: "RS", Name: "Serbia", Currency: "RSD", StorefrontID: "143500"},
+	"RU": {Code: "RU", Name: "Russia", Currency: "RUB", StorefrontID: "143469"},
+	"SA": {Code: "SA", Name: "Saudi Arabia", Currency: "SAR", StorefrontID: "143479"},
+	"SE": {Code: "SE", Name: "Sweden", Currency: "SEK", StorefrontID: "143456"},
+	"SG": {Code: "SG", Name: "Singapore", Currency: "SGD", StorefrontID: "143464"},
+	"SI": {Code: "SI", Name: "Slovenia", Currency: "EUR", StorefrontID: "143499"},
+	"SK": {Code: "SK", Name: "Slovakia", Currency: "EUR", StorefrontID: "143496"},
+	"SN": {Code: "SN", Name: "Senegal", Currency: "XOF", StorefrontID: "143535"},
+	"SR": {Code: "SR", Name: "Suriname", Currency: "SRD", StorefrontID: "143554"},
+	"SV": {Code: "SV", Name: "El Salvador", Currency: "USD", StorefrontID: "143506"},
+	"TC": {Code: "TC", Name: "Turks and Caicos Islands", Currency: "USD", StorefrontID: "143552"},
+	"TH": {Code: "TH", Name: "Thailand", Currency: "THB", StorefrontID: "143475"},
+	"TN": {Code: "TN", Name: "Tunisia", Currency: "TND", StorefrontID: "143536"},
+	"TR": {Code: "TR", Name: "Türkiye", Currency: "TRY", StorefrontID: "143480"},
+	"TT": {Code: "TT", Name: "Trinidad and Tobago", Currency: "TTD", StorefrontID: "143551"},
+	"TW": {Code: "TW", Name: "Taiwan", Currency: "TWD", StorefrontID: "143470"},
+	"TZ": {Code: "TZ", Name: "Tanzania", Currency: "TZS", StorefrontID: "143572"},
+	"UA": {Code: "UA", Name: "Ukraine", Currency: "UAH", StorefrontID: "143492"},
+	"UG": {Code: "UG", Name: "Uganda", Currency: "UGX", StorefrontID: "143537"},
+	"US": {Code: "US", Name: "United States", Currency: "USD", StorefrontID: "143441"},
+	"UY": {Code: "UY", Name: "Uruguay", Currency: "UYU", StorefrontID: "143514"},
+	"UZ": {Code: "UZ", Name: "Uzbekistan", Currency: "UZS", StorefrontID: "143566"},
+	"VC": {Code: "VC", Name: "Saint Vincent and the Grenadines", Currency: "XCD", StorefrontID: "143550"},
+	"VE": {Code: "VE", Name: "Venezuela", Currency: "VES", StorefrontID: "143502"},
+	"VG": {Code: "VG", Name: "British Virgin Islands", Currency: "USD", StorefrontID: "143543"},
+	"VN": {Code: "VN", Name: "Vietnam", Currency: "VND", StorefrontID: "143471"},
+	"YE": {Code: "YE", Name: "Yemen", Currency: "YER", StorefrontID: "143571"},
+	"ZA": {Code: "ZA", Name: "South Africa", Currency: "ZAR", StorefrontID: "143472"},
 }
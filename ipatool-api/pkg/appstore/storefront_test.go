@@ -0,0 +1,54 @@
+package appstore
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Storefront", func() {
+	It("parses the ID, language and platform", func() {
+		sf, err := ParseStorefront("143444-2,29")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(sf).To(Equal(Storefront{ID: "143444", CountryCode: "GB", LanguageID: 2, Platform: 29}))
+		Expect(sf.Language()).To(Equal("en-GB"))
+		Expect(sf.String()).To(Equal("143444-2,29"))
+	})
+
+	It("parses a bare storefront ID", func() {
+		sf, err := ParseStorefront("143462")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(sf.CountryCode).To(Equal("JP"))
+		Expect(sf.Language()).To(BeEmpty())
+		Expect(sf.String()).To(Equal("143462"))
+	})
+
+	It("rejects unknown storefronts", func() {
+		_, err := ParseStorefront("999999-1,29")
+		Expect(err).To(HaveOccurred())
+
+		_, err = ParseStorefront("143441-x,29")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("maps countries to storefronts", func() {
+		sf, ok := StorefrontForCountry("us")
+		Expect(ok).To(BeTrue())
+		Expect(sf.ID).To(Equal("143441"))
+
+		_, ok = StorefrontForCountry("XX")
+		Expect(ok).To(BeFalse())
+	})
+
+	It("lists countries sorted by code", func() {
+		countries := Countries()
+		Expect(countries[0].Code).To(Equal("AE"))
+
+		for i := 1; i < len(countries); i++ {
+			Expect(countries[i-1].Code < countries[i].Code).To(BeTrue())
+		}
+
+		country, ok := CountryByCode("DE")
+		Expect(ok).To(BeTrue())
+		Expect(country).To(Equal(Country{Code: "DE", Name: "Germany", Currency: "EUR", StorefrontID: "143443"}))
+	})
+})
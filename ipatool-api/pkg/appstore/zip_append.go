@@ -0,0 +1,414 @@
+package appstore
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"slices"
+	"time"
+	"unicode/utf8"
+)
+
+// Zip records, see the PKWARE APPNOTE.TXT.
+const (
+	zipLocalHeaderSignature     = 0x04034b50
+	zipDirectoryHeaderSignature = 0x02014b50
+	zipEndSignature             = 0x06054b50
+	zip64EndSignature           = 0x06064b50
+	zip64LocatorSignature       = 0x07064b50
+
+	zipLocalHeaderLength     = 30
+	zipDirectoryHeaderLength = 46
+	zipEndLength             = 22
+	zip64LocatorLength       = 20
+	zip64EndLength           = 56
+	zipMaxCommentLength      = 0xffff
+
+	zip64ExtraID     = 0x0001
+	zipUTF8Flag      = 0x0800
+	zipVersion20     = 20
+	zipVersion45     = 45
+	zipCreatorUnix   = 3
+	zipRegularFile   = 0100644
+	zipUint16Max     = 0xffff
+	zipUint32Max     = 0xffffffff
+	zipMaxEntryBytes = zipUint32Max - 1
+)
+
+var errInvalidArchive = errors.New("invalid zip archive")
+
+// archiveTail is the end of a zip archive: everything after the local file entries.
+type archiveTail struct {
+	// offset is where the central directory starts and the local file entries end.
+	offset int64
+	// raw is the central directory and the end records as read, up to the end of the archive.
+	raw     []byte
+	records []directoryRecord
+	comment []byte
+}
+
+// directoryRecord is a central directory record of an existing entry.
+type directoryRecord struct {
+	name string
+	raw  []byte
+}
+
+// archiveEntry is a file added to an archive.
+type archiveEntry struct {
+	Name string
+	Data []byte
+}
+
+// readArchiveTail reads the central directory of the archive, including ZIP64 archives. Archives with data before
+// the first entry (e.g. self-extracting archives) are not supported.
+func readArchiveTail(r io.ReaderAt, size int64) (archiveTail, error) {
+	searchLength := min(size, zipEndLength+zipMaxCommentLength)
+
+	buf := make([]byte, searchLength)
+	if _, err := r.ReadAt(buf, size-searchLength); err != nil && !errors.Is(err, io.EOF) {
+		return archiveTail{}, fmt.Errorf("failed to read end of archive: %w", err)
+	}
+
+	index := findZipEnd(buf)
+	if index < 0 {
+		return archiveTail{}, fmt.Errorf("%w: end of central directory not found", errInvalidArchive)
+	}
+
+	end := buf[index:]
+	endOffset := size - searchLength + int64(index)
+	entries := uint64(binary.LittleEndian.Uint16(end[10:]))
+	directorySize := uint64(binary.LittleEndian.Uint32(end[12:]))
+	directoryOffset := uint64(binary.LittleEndian.Uint32(end[16:]))
+	comment := bytes.Clone(end[zipEndLength : zipEndLength+int(binary.LittleEndian.Uint16(end[20:]))])
+
+	if entries == zipUint16Max || directorySize == zipUint32Max || directoryOffset == zipUint32Max {
+		var err error
+
+		entries, directorySize, directoryOffset, err = readZip64End(r, endOffset)
+		if err != nil {
+			return archiveTail{}, err
+		}
+	}
+
+	if directoryOffset+directorySize > uint64(endOffset) {
+		return archiveTail{}, fmt.Errorf("%w: central directory out of bounds", errInvalidArchive)
+	}
+
+	raw := make([]byte, size-int64(directoryOffset))
+	if _, err := r.ReadAt(raw, int64(directoryOffset)); err != nil && !errors.Is(err, io.EOF) {
+		return archiveTail{}, fmt.Errorf("failed to read central directory: %w", err)
+	}
+
+	records, err := parseDirectoryRecords(raw[:directorySize])
+	if err != nil {
+		return archiveTail{}, err
+	}
+
+	if uint64(len(records)) != entries {
+		return archiveTail{}, fmt.Errorf("%w: expected %d entries, found %d", errInvalidArchive, entries, len(records))
+	}
+
+	return archiveTail{
+		offset:  int64(directoryOffset),
+		raw:     raw,
+		records: records,
+		comment: comment,
+	}, nil
+}
+
+// findZipEnd returns the index of the end of central directory record, whose comment must end the block.
+func findZipEnd(b []byte) int {
+	for i := len(b) - zipEndLength; i >= 0; i-- {
+		if binary.LittleEndian.Uint32(b[i:]) != zipEndSignature {
+			continue
+		}
+
+		if commentLength := int(binary.LittleEndian.Uint16(b[i+20:])); i+zipEndLength+commentLength == len(b) {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func readZip64End(r io.ReaderAt, endOffset int64) (uint64, uint64, uint64, error) {
+	if endOffset < zip64LocatorLength {
+		return 0, 0, 0, fmt.Errorf("%w: zip64 locator not found", errInvalidArchive)
+	}
+
+	locator := make([]byte, zip64LocatorLength)
+	if _, err := r.ReadAt(locator, endOffset-zip64LocatorLength); err != nil {
+		return 0, 0, 0, fmt.Errorf("failed to read zip64 locator: %w", err)
+	}
+
+	if binary.LittleEndian.Uint32(locator) != zip64LocatorSignature {
+		return 0, 0, 0, fmt.Errorf("%w: zip64 locator not found", errInvalidArchive)
+	}
+
+	record := make([]byte, zip64EndLength)
+	if _, err := r.ReadAt(record, int64(binary.LittleEndian.Uint64(locator[8:]))); err != nil {
+		return 0, 0, 0, fmt.Errorf("failed to read zip64 end of central directory: %w", err)
+	}
+
+	if binary.LittleEndian.Uint32(record) != zip64EndSignature {
+		return 0, 0, 0, fmt.Errorf("%w: zip64 end of central directory not found", errInvalidArchive)
+	}
+
+	return binary.LittleEndian.Uint64(record[32:]), binary.LittleEndian.Uint64(record[40:]), binary.LittleEndian.Uint64(record[48:]), nil
+}
+
+func parseDirectoryRecords(directory []byte) ([]directoryRecord, error) {
+	var records []directoryRecord
+
+	for pos := 0; pos < len(directory); {
+		header := directory[pos:]
+		if len(header) < zipDirectoryHeaderLength || binary.LittleEndian.Uint32(header) != zipDirectoryHeaderSignature {
+			return nil, fmt.Errorf("%w: invalid central directory record at %d", errInvalidArchive, pos)
+		}
+
+		nameLength := int(binary.LittleEndian.Uint16(header[28:]))
+		length := zipDirectoryHeaderLength + nameLength +
+			int(binary.LittleEndian.Uint16(header[30:])) + int(binary.LittleEndian.Uint16(header[32:]))
+
+		if len(header) < length {
+			return nil, fmt.Errorf("%w: truncated central directory record at %d", errInvalidArchive, pos)
+		}
+
+		records = append(records, directoryRecord{
+			name: string(header[zipDirectoryHeaderLength : zipDirectoryHeaderLength+nameLength]),
+			raw:  header[:length],
+		})
+		pos += length
+	}
+
+	return records, nil
+}
+
+// withoutTrailingEntries leaves out the entries stored last in the archive that are named like one of the
+// entries, so that their bytes are reused for the new entries. An existing entry is stored last when no other
+// entry follows it. It stops at the first entry whose local header offset cannot be read.
+func (tail archiveTail) withoutTrailingEntries(entries []archiveEntry) archiveTail {
+	names := map[string]bool{}
+	for _, entry := range entries {
+		names[entry.Name] = true
+	}
+
+	records := slices.Clone(tail.records)
+
+	for len(records) > 0 {
+		last := -1
+
+		var lastOffset uint64
+
+		for i, record := range records {
+			offset, ok := record.headerOffset()
+			if !ok {
+				return tail
+			}
+
+			if last < 0 || offset > lastOffset {
+				last, lastOffset = i, offset
+			}
+		}
+
+		if !names[records[last].name] || lastOffset >= uint64(tail.offset) {
+			break
+		}
+
+		tail.offset = int64(lastOffset)
+		records = slices.Delete(records, last, last+1)
+	}
+
+	tail.records = records
+
+	return tail
+}
+
+// headerOffset returns the offset of the local file header of the entry, including offsets in a ZIP64 extra field.
+func (record directoryRecord) headerOffset() (uint64, bool) {
+	offset := uint64(binary.LittleEndian.Uint32(record.raw[42:]))
+	if offset != zipUint32Max {
+		return offset, true
+	}
+
+	nameLength := int(binary.LittleEndian.Uint16(record.raw[28:]))
+	extraLength := int(binary.LittleEndian.Uint16(record.raw[30:]))
+	extra := record.raw[zipDirectoryHeaderLength+nameLength : zipDirectoryHeaderLength+nameLength+extraLength]
+
+	for len(extra) >= 4 {
+		id := binary.LittleEndian.Uint16(extra)
+		length := int(binary.LittleEndian.Uint16(extra[2:]))
+
+		if len(extra) < 4+length {
+			return 0, false
+		}
+
+		if id == zip64ExtraID {
+			// The ZIP64 field holds the uncompressed size, compressed size and offset, in that order, but only those
+			// that do not fit the classic record.
+			field := extra[4 : 4+length]
+
+			for _, at := range []int{24, 20} {
+				if binary.LittleEndian.Uint32(record.raw[at:]) == zipUint32Max {
+					if len(field) < 8 {
+						return 0, false
+					}
+
+					field = field[8:]
+				}
+			}
+
+			if len(field) < 8 {
+				return 0, false
+			}
+
+			return binary.LittleEndian.Uint64(field), true
+		}
+
+		extra = extra[4+length:]
+	}
+
+	return 0, false
+}
+
+// writeArchiveSuffix writes what follows the local file entries of the archive: the entries, as stored files, and a
+// new central directory that keeps the records of the existing entries. Existing entries named like a new entry
+// are left out of the central directory, so that they are replaced. ZIP64 end records are written when the
+// classic ones cannot hold the offsets, sizes or number of entries.
+func writeArchiveSuffix(w io.Writer, tail archiveTail, entries []archiveEntry, modified time.Time) error {
+	var (
+		suffix  []byte
+		records []byte
+		names   = map[string]bool{}
+	)
+
+	modTime, modDate := msDosTimeDate(modified)
+
+	for _, entry := range entries {
+		if len(entry.Data) > zipMaxEntryBytes {
+			return fmt.Errorf("entry %s is too large", entry.Name)
+		}
+
+		names[entry.Name] = true
+		offset := uint64(tail.offset) + uint64(len(suffix))
+		crc := crc32.ChecksumIEEE(entry.Data)
+		size := uint32(len(entry.Data))
+
+		var flags uint16
+		if !isASCII(entry.Name) {
+			flags |= zipUTF8Flag
+		}
+
+		suffix = binary.LittleEndian.AppendUint32(suffix, zipLocalHeaderSignature)
+		suffix = appendUint16s(suffix, zipVersion20, flags, 0, modTime, modDate)
+		suffix = appendUint32s(suffix, crc, size, size)
+		suffix = appendUint16s(suffix, uint16(len(entry.Name)), 0)
+		suffix = append(suffix, entry.Name...)
+		suffix = append(suffix, entry.Data...)
+
+		versionNeeded := uint16(zipVersion20)
+		headerOffset := uint32(offset)
+
+		var extra []byte
+		if offset >= zipUint32Max {
+			versionNeeded = zipVersion45
+			headerOffset = zipUint32Max
+			extra = appendUint16s(extra, zip64ExtraID, 8)
+			extra = binary.LittleEndian.AppendUint64(extra, offset)
+		}
+
+		records = binary.LittleEndian.AppendUint32(records, zipDirectoryHeaderSignature)
+		records = appendUint16s(records, zipCreatorUnix<<8|versionNeeded, versionNeeded, flags, 0, modTime, modDate)
+		records = appendUint32s(records, crc, size, size)
+		records = appendUint16s(records, uint16(len(entry.Name)), uint16(len(extra)), 0, 0, 0)
+		records = appendUint32s(records, zipRegularFile<<16, headerOffset)
+		records = append(records, entry.Name...)
+		records = append(records, extra...)
+	}
+
+	directoryOffset := uint64(tail.offset) + uint64(len(suffix))
+	count := uint64(len(entries))
+
+	for _, record := range tail.records {
+		if !names[record.name] {
+			suffix = append(suffix, record.raw...)
+			count++
+		}
+	}
+
+	suffix = append(suffix, records...)
+	directorySize := uint64(tail.offset) + uint64(len(suffix)) - directoryOffset
+
+	if count >= zipUint16Max || directorySize >= zipUint32Max || directoryOffset >= zipUint32Max {
+		zip64EndOffset := uint64(tail.offset) + uint64(len(suffix))
+
+		suffix = binary.LittleEndian.AppendUint32(suffix, zip64EndSignature)
+		suffix = binary.LittleEndian.AppendUint64(suffix, zip64EndLength-12)
+		suffix = appendUint16s(suffix, zipCreatorUnix<<8|zipVersion45, zipVersion45)
+		suffix = appendUint32s(suffix, 0, 0)
+		suffix = binary.LittleEndian.AppendUint64(suffix, count)
+		suffix = binary.LittleEndian.AppendUint64(suffix, count)
+		suffix = binary.LittleEndian.AppendUint64(suffix, directorySize)
+		suffix = binary.LittleEndian.AppendUint64(suffix, directoryOffset)
+
+		suffix = binary.LittleEndian.AppendUint32(suffix, zip64LocatorSignature)
+		suffix = binary.LittleEndian.AppendUint32(suffix, 0)
+		suffix = binary.LittleEndian.AppendUint64(suffix, zip64EndOffset)
+		suffix = binary.LittleEndian.AppendUint32(suffix, 1)
+
+		count = zipUint16Max
+		directorySize = zipUint32Max
+		directoryOffset = zipUint32Max
+	}
+
+	suffix = binary.LittleEndian.AppendUint32(suffix, zipEndSignature)
+	suffix = appendUint16s(suffix, 0, 0, uint16(count), uint16(count))
+	suffix = appendUint32s(suffix, uint32(directorySize), uint32(directoryOffset))
+	suffix = appendUint16s(suffix, uint16(len(tail.comment)))
+	suffix = append(suffix, tail.comment...)
+
+	if _, err := w.Write(suffix); err != nil {
+		return fmt.Errorf("failed to write central directory: %w", err)
+	}
+
+	return nil
+}
+
+func appendUint16s(b []byte, values ...uint16) []byte {
+	for _, value := range values {
+		b = binary.LittleEndian.AppendUint16(b, value)
+	}
+
+	return b
+}
+
+func appendUint32s(b []byte, values ...uint32) []byte {
+	for _, value := range values {
+		b = binary.LittleEndian.AppendUint32(b, value)
+	}
+
+	return b
+}
+
+// msDosTimeDate converts the time to the MS-DOS format of zip headers.
+func msDosTimeDate(t time.Time) (uint16, uint16) {
+	if t.Year() < 1980 {
+		t = time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC)
+	}
+
+	return uint16(t.Hour()<<11 | t.Minute()<<5 | t.Second()/2),
+		uint16((t.Year()-1980)<<9 | int(t.Month())<<5 | t.Day())
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,118 @@
+package appstore
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Zip Append", func() {
+	createArchive := func(files map[string]string, count int) []byte {
+		var buf bytes.Buffer
+
+		writer := zip.NewWriter(&buf)
+		for name, content := range files {
+			file, err := writer.Create(name)
+			Expect(err).ToNot(HaveOccurred())
+
+			_, err = file.Write([]byte(content))
+			Expect(err).ToNot(HaveOccurred())
+		}
+
+		for i := range count {
+			_, err := writer.CreateHeader(&zip.FileHeader{Name: fmt.Sprintf("filler/%d", i), Method: zip.Store})
+			Expect(err).ToNot(HaveOccurred())
+		}
+
+		Expect(writer.SetComment("comment")).To(Succeed())
+		Expect(writer.Close()).To(Succeed())
+
+		return buf.Bytes()
+	}
+
+	appendEntries := func(archive []byte, entries []archiveEntry) (*zip.Reader, []byte) {
+		tail, err := readArchiveTail(bytes.NewReader(archive), int64(len(archive)))
+		Expect(err).ToNot(HaveOccurred())
+
+		var patched bytes.Buffer
+		patched.Write(archive[:tail.offset])
+
+		err = writeArchiveSuffix(&patched, tail, entries, time.Date(2024, 5, 6, 7, 8, 10, 0, time.UTC))
+		Expect(err).ToNot(HaveOccurred())
+
+		reader, err := zip.NewReader(bytes.NewReader(patched.Bytes()), int64(patched.Len()))
+		Expect(err).ToNot(HaveOccurred())
+
+		return reader, patched.Bytes()
+	}
+
+	read := func(reader *zip.Reader, name string) string {
+		file, err := reader.Open(name)
+		Expect(err).ToNot(HaveOccurred())
+		defer file.Close()
+
+		data, err := io.ReadAll(file)
+		Expect(err).ToNot(HaveOccurred())
+
+		return string(data)
+	}
+
+	It("keeps the existing entries and appends new ones", func() {
+		archive := createArchive(map[string]string{
+			"Payload/App.app/Info.plist": "info",
+			"Payload/App.app/App":        "binary",
+		}, 0)
+
+		reader, patched := appendEntries(archive, []archiveEntry{
+			{Name: "Payload/App.app/SC_Info/App.sinf", Data: []byte("sinf")},
+			{Name: "iTunesMetadata.plist", Data: []byte("metadata")},
+		})
+
+		Expect(reader.File).To(HaveLen(4))
+		Expect(reader.Comment).To(Equal("comment"))
+		Expect(read(reader, "Payload/App.app/Info.plist")).To(Equal("info"))
+		Expect(read(reader, "Payload/App.app/App")).To(Equal("binary"))
+		Expect(read(reader, "Payload/App.app/SC_Info/App.sinf")).To(Equal("sinf"))
+		Expect(read(reader, "iTunesMetadata.plist")).To(Equal("metadata"))
+
+		modified := reader.File[len(reader.File)-1].Modified
+		Expect(modified.Format("2006-01-02 15:04:05")).To(Equal("2024-05-06 07:08:10"))
+
+		// The local file entries are left untouched.
+		tail, err := readArchiveTail(bytes.NewReader(archive), int64(len(archive)))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(patched[:tail.offset]).To(Equal(archive[:tail.offset]))
+	})
+
+	It("replaces entries with the same name", func() {
+		archive := createArchive(map[string]string{
+			"Payload/App.app/Info.plist": "info",
+			"iTunesMetadata.plist":       "old",
+		}, 0)
+
+		reader, _ := appendEntries(archive, []archiveEntry{{Name: "iTunesMetadata.plist", Data: []byte("new")}})
+
+		Expect(reader.File).To(HaveLen(2))
+		Expect(read(reader, "iTunesMetadata.plist")).To(Equal("new"))
+	})
+
+	It("supports ZIP64 archives", func() {
+		archive := createArchive(map[string]string{"Payload/App.app/Info.plist": "info"}, zipUint16Max)
+
+		reader, _ := appendEntries(archive, []archiveEntry{{Name: "iTunesMetadata.plist", Data: []byte("metadata")}})
+
+		Expect(reader.File).To(HaveLen(zipUint16Max + 2))
+		Expect(read(reader, "Payload/App.app/Info.plist")).To(Equal("info"))
+		Expect(read(reader, "iTunesMetadata.plist")).To(Equal("metadata"))
+	})
+
+	It("rejects data that is not a zip archive", func() {
+		_, err := readArchiveTail(bytes.NewReader([]byte("ping")), 4)
+		Expect(err).To(MatchError(errInvalidArchive))
+	})
+})
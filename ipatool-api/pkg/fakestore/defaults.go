@@ -0,0 +1,91 @@
+package fakestore
+
+import (
+	"time"
+)
+
+const (
+	DefaultEmail    = "test@example.com"
+	DefaultPassword = "password"
+	// Default2FAEmail is an account that requires DefaultAuthCode to log in.
+	Default2FAEmail = "2fa@example.com"
+	DefaultAuthCode = "123456"
+)
+
+// DefaultConfig returns a fake App Store with two US accounts, one of them using
+// two-factor authentication, and a few free apps with version histories.
+func DefaultConfig() Config {
+	return Config{
+		Accounts: []Account{
+			{
+				Email:               DefaultEmail,
+				Password:            DefaultPassword,
+				FirstName:           "Test",
+				LastName:            "User",
+				DirectoryServicesID: "1000000001",
+				StoreFront:          "143441-1,29",
+			},
+			{
+				Email:               Default2FAEmail,
+				Password:            DefaultPassword,
+				FirstName:           "Second",
+				LastName:            "Factor",
+				DirectoryServicesID: "1000000002",
+				StoreFront:          "143441-1,29",
+				AuthCode:            DefaultAuthCode,
+			},
+		},
+		Apps: []App{
+			{
+				ID:       1000000101,
+				BundleID: "com.example.notes",
+				Name:     "Notes",
+				Seller:   "Example Inc.",
+				Genre:    "Productivity",
+				Versions: []Version{
+					version("800000001", "1.0.0", "100", "2022-01-10", "12.0"),
+					version("800000002", "1.1.0", "110", "2023-03-01", "14.0"),
+					version("800000003", "2.0.0", "200", "2024-06-15", "16.0"),
+				},
+			},
+			{
+				ID:        1000000102,
+				BundleID:  "com.example.radio",
+				Name:      "Radio",
+				Seller:    "Example Inc.",
+				Genre:     "Music",
+				Countries: []string{"US", "GB"},
+				Versions: []Version{
+					version("800000101", "3.2", "32", "2024-02-01", "15.0"),
+				},
+			},
+			{
+				ID:       1000000103,
+				BundleID: "com.example.arcade",
+				Name:     "Arcade",
+				Seller:   "Example Games",
+				Genre:    "Games",
+				Arcade:   true,
+				Versions: []Version{
+					version("800000201", "1.0", "1", "2024-09-01", "17.0"),
+				},
+			},
+		},
+	}
+}
+
+func version(externalVersionID, displayVersion, bundleVersion, releaseDate, minimumOSVersion string) Version {
+	date, err := time.Parse(time.DateOnly, releaseDate)
+	if err != nil {
+		panic(err)
+	}
+
+	return Version{
+		ExternalVersionID: externalVersionID,
+		DisplayVersion:    displayVersion,
+		BundleVersion:     bundleVersion,
+		ReleaseDate:       date,
+		MinimumOSVersion:  minimumOSVersion,
+		ReleaseNotes:      "Release " + displayVersion,
+	}
+}
@@ -0,0 +1,279 @@
+// Package fakestore implements a local fake of the App Store services used by ipatool:
+// authenticate (including the two-factor authentication path), buyProduct,
+// volumeStoreDownloadProduct, search, lookup and a CDN serving generated IPA packages.
+// It is intended for end-to-end tests and offline development.
+package fakestore
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	FailureTypeInvalidCredentials     = "-5000"
+	FailureTypePasswordTokenExpired   = "2034"
+	FailureTypeLicenseNotFound        = "9610"
+	FailureTypeTemporarilyUnavailable = "2059"
+
+	CustomerMessageBadLogin = "MZFinance.BadLogin.Configurator_message"
+
+	PathAuthenticate = "/WebObjects/MZFinance.woa/wa/authenticate"
+	PathPurchase     = "/WebObjects/MZFinance.woa/wa/buyProduct"
+	PathDownload     = "/WebObjects/MZFinance.woa/wa/volumeStoreDownloadProduct"
+	PathSearch       = "/search"
+	PathLookup       = "/lookup"
+	PathCDN          = "/cdn/"
+
+	HTTPHeaderStoreFront = "X-Set-Apple-Store-Front"
+)
+
+// Account is an Apple ID known to the fake App Store.
+type Account struct {
+	Email     string
+	Password  string
+	FirstName string
+	LastName  string
+	// DirectoryServicesID is the dsPersonId returned on login.
+	DirectoryServicesID string
+	// StoreFront is the raw storefront header value, e.g. "143441-1,29".
+	StoreFront string
+	// AuthCode enables the two-factor authentication path when set.
+	AuthCode string
+}
+
+// Version is a single release of an app.
+type Version struct {
+	ExternalVersionID string
+	DisplayVersion    string
+	BundleVersion     string
+	ReleaseDate       time.Time
+	MinimumOSVersion  string
+	ReleaseNotes      string
+}
+
+// App is an app sold by the fake App Store. Versions are ordered oldest first; the last one is the latest.
+type App struct {
+	ID       int64
+	BundleID string
+	Name     string
+	Seller   string
+	Genre    string
+	Price    float64
+	// Countries limits the storefronts the app is available in. Empty means all storefronts.
+	Countries []string
+	// Arcade marks Apple Arcade titles, which require the GAME pricing parameter to be purchased.
+	Arcade   bool
+	Versions []Version
+	// ExecutableSize is the size of the generated executable, to exercise large transfers.
+	ExecutableSize int
+}
+
+// LatestVersion returns the most recent version of the app.
+func (a App) LatestVersion() Version {
+	if len(a.Versions) == 0 {
+		return Version{}
+	}
+
+	return a.Versions[len(a.Versions)-1]
+}
+
+// Version returns the version with the specified external identifier.
+func (a App) Version(externalVersionID string) (Version, bool) {
+	for _, version := range a.Versions {
+		if version.ExternalVersionID == externalVersionID {
+			return version, true
+		}
+	}
+
+	return Version{}, false
+}
+
+func (a App) availableIn(country string) bool {
+	if len(a.Countries) == 0 {
+		return true
+	}
+
+	for _, c := range a.Countries {
+		if strings.EqualFold(c, country) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Config seeds the fake App Store.
+type Config struct {
+	Accounts []Account
+	Apps     []App
+	// Licenses maps account emails to the IDs of apps they already own.
+	Licenses map[string][]int64
+}
+
+// Server is an http.Handler implementing the fake App Store.
+type Server struct {
+	mu       sync.Mutex
+	accounts map[string]Account
+	apps     map[int64]App
+	appOrder []int64
+	licenses map[string]map[int64]bool
+	tokens   map[string]string
+	ipas     map[string][]byte
+	requests map[string]int
+	mux      *http.ServeMux
+}
+
+// New returns a fake App Store seeded with the specified configuration.
+func New(config Config) *Server {
+	s := &Server{
+		accounts: map[string]Account{},
+		apps:     map[int64]App{},
+		licenses: map[string]map[int64]bool{},
+		tokens:   map[string]string{},
+		ipas:     map[string][]byte{},
+		requests: map[string]int{},
+		mux:      http.NewServeMux(),
+	}
+
+	for _, account := range config.Accounts {
+		s.accounts[strings.ToLower(account.Email)] = account
+	}
+
+	for _, app := range config.Apps {
+		s.apps[app.ID] = app
+		s.appOrder = append(s.appOrder, app.ID)
+	}
+
+	for email, appIDs := range config.Licenses {
+		for _, appID := range appIDs {
+			s.grantLicense(s.accounts[strings.ToLower(email)].DirectoryServicesID, appID)
+		}
+	}
+
+	s.mux.HandleFunc("POST "+PathAuthenticate, s.handleAuthenticate)
+	s.mux.HandleFunc("POST "+PathPurchase, s.handlePurchase)
+	s.mux.HandleFunc("POST "+PathDownload, s.handleDownload)
+	s.mux.HandleFunc("GET "+PathSearch, s.handleSearch)
+	s.mux.HandleFunc("GET "+PathLookup, s.handleLookup)
+	s.mux.HandleFunc("GET "+PathCDN+"{appID}/{file}", s.handleCDN)
+
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.requests[r.URL.Path]++
+	s.mu.Unlock()
+
+	s.mux.ServeHTTP(w, r)
+}
+
+// Requests returns how many requests were received for the specified path.
+func (s *Server) Requests(path string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.requests[path]
+}
+
+// GrantLicense gives the account a license for the app, as if it had been purchased.
+func (s *Server) GrantLicense(email string, appID int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.grantLicense(s.accounts[strings.ToLower(email)].DirectoryServicesID, appID)
+}
+
+// HasLicense reports whether the account owns a license for the app.
+func (s *Server) HasLicense(email string, appID int64) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.licenses[s.accounts[strings.ToLower(email)].DirectoryServicesID][appID]
+}
+
+// ExpireTokens invalidates all password tokens, so that further requests fail with
+// FailureTypePasswordTokenExpired until the account logs in again.
+func (s *Server) ExpireTokens() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.tokens = map[string]string{}
+}
+
+// ReleaseVersion publishes a new version of the app, which becomes its latest version.
+func (s *Server) ReleaseVersion(appID int64, version Version) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	app := s.apps[appID]
+	app.Versions = append(app.Versions, version)
+	s.apps[appID] = app
+}
+
+// IPA returns the generated package for the app version, as served by the CDN.
+func (s *Server) IPA(appID int64, externalVersionID string) ([]byte, error) {
+	s.mu.Lock()
+	app, ok := s.apps[appID]
+	s.mu.Unlock()
+
+	if !ok {
+		return nil, fmt.Errorf("app %d not found", appID)
+	}
+
+	version, ok := app.Version(externalVersionID)
+	if !ok {
+		return nil, fmt.Errorf("version %s of app %d not found", externalVersionID, appID)
+	}
+
+	return s.ipa(app, version)
+}
+
+func (s *Server) ipa(app App, version Version) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.ipaLocked(app, version)
+}
+
+// ipaLocked is ipa for callers that already hold s.mu.
+func (s *Server) ipaLocked(app App, version Version) ([]byte, error) {
+	key := fmt.Sprintf("%d/%s", app.ID, version.ExternalVersionID)
+
+	if data, ok := s.ipas[key]; ok {
+		return data, nil
+	}
+
+	data, err := buildIPA(app, version)
+	if err != nil {
+		return nil, err
+	}
+
+	s.ipas[key] = data
+
+	return data, nil
+}
+
+func (s *Server) grantLicense(dsid string, appID int64) {
+	if s.licenses[dsid] == nil {
+		s.licenses[dsid] = map[int64]bool{}
+	}
+
+	s.licenses[dsid][appID] = true
+}
+
+// Sinf returns the sinf the fake App Store issues for the account and app.
+func Sinf(dsid string, appID int64) []byte {
+	return []byte(fmt.Sprintf("fake-sinf-%s-%d", dsid, appID))
+}
+
+func md5Hex(data []byte) string {
+	sum := md5.Sum(data)
+
+	return hex.EncodeToString(sum[:])
+}
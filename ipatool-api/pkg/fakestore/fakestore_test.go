@@ -0,0 +1,171 @@
+package fakestore
+
+import (
+	"archive/zip"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/99designs/keyring"
+	cookiejar "github.com/juju/persistent-cookiejar"
+	"github.com/majd/ipatool/v2/pkg/appstore"
+	"github.com/majd/ipatool/v2/pkg/keychain"
+	"github.com/majd/ipatool/v2/pkg/util/machine"
+	"github.com/majd/ipatool/v2/pkg/util/operatingsystem"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestFakeStore(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Fake Store Suite")
+}
+
+var _ = Describe("Fake Store", func() {
+	var (
+		fake *Server
+		srv  *httptest.Server
+		as   appstore.AppStore
+		dir  string
+	)
+
+	BeforeEach(func() {
+		fake = New(DefaultConfig())
+		srv = httptest.NewServer(fake)
+		dir = GinkgoT().TempDir()
+
+		jar, err := cookiejar.New(&cookiejar.Options{Filename: filepath.Join(dir, "cookies")})
+		Expect(err).ToNot(HaveOccurred())
+
+		os := operatingsystem.New()
+		as = appstore.NewAppStore(appstore.Args{
+			Keychain:        keychain.New(keychain.Args{Keyring: keyring.NewArrayKeyring(nil)}),
+			CookieJar:       jar,
+			OperatingSystem: os,
+			Machine:         machine.New(machine.Args{OS: os}),
+			DeviceGUID:      "0123456789AB",
+			Endpoints:       appstore.EndpointsWithBaseURL(srv.URL),
+		})
+	})
+
+	AfterEach(func() {
+		srv.Close()
+	})
+
+	login := func() appstore.Account {
+		out, err := as.Login(appstore.LoginInput{Email: DefaultEmail, Password: DefaultPassword})
+		Expect(err).ToNot(HaveOccurred())
+
+		return out.Account
+	}
+
+	It("logs in", func() {
+		acc := login()
+		Expect(acc.Email).To(Equal(DefaultEmail))
+		Expect(acc.Name).To(Equal("Test User"))
+		Expect(acc.StoreFront).To(Equal("143441-1,29"))
+		Expect(acc.PasswordToken).ToNot(BeEmpty())
+	})
+
+	It("rejects invalid credentials", func() {
+		_, err := as.Login(appstore.LoginInput{Email: DefaultEmail, Password: "wrong"})
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("requires auth code for two-factor accounts", func() {
+		_, err := as.Login(appstore.LoginInput{Email: Default2FAEmail, Password: DefaultPassword})
+		Expect(err).To(MatchError(appstore.ErrAuthCodeRequired))
+
+		out, err := as.Login(appstore.LoginInput{Email: Default2FAEmail, Password: DefaultPassword, AuthCode: DefaultAuthCode})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(out.Account.Email).To(Equal(Default2FAEmail))
+	})
+
+	It("searches and looks up apps per storefront", func() {
+		acc := login()
+
+		search, err := as.Search(appstore.SearchInput{Account: acc, Term: "example", Limit: 10})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(search.Count).To(Equal(3))
+
+		search, err = as.Search(appstore.SearchInput{Account: acc, Term: "radio", Limit: 10, CountryCode: "DE"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(search.Count).To(BeZero())
+
+		lookup, err := as.Lookup(appstore.LookupInput{Account: acc, BundleID: "com.example.notes"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(lookup.App.ID).To(Equal(int64(1000000101)))
+		Expect(lookup.App.Version).To(Equal("2.0.0"))
+	})
+
+	It("purchases, lists versions and downloads", func() {
+		acc := login()
+		app := appstore.App{ID: 1000000101, BundleID: "com.example.notes"}
+
+		_, err := as.ListVersions(appstore.ListVersionsInput{Account: acc, App: app})
+		Expect(err).To(MatchError(appstore.ErrLicenseRequired))
+
+		Expect(as.Purchase(appstore.PurchaseInput{Account: acc, App: app})).To(Succeed())
+		Expect(fake.HasLicense(DefaultEmail, app.ID)).To(BeTrue())
+
+		versions, err := as.ListVersions(appstore.ListVersionsInput{Account: acc, App: app})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(versions.ExternalVersionIdentifiers).To(Equal([]string{"800000001", "800000002", "800000003"}))
+		Expect(versions.LatestExternalVersionID).To(Equal("800000003"))
+
+		metadata, err := as.GetVersionMetadata(appstore.GetVersionMetadataInput{Account: acc, App: app, VersionID: "800000002"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(metadata.DisplayVersion).To(Equal("1.1.0"))
+		Expect(metadata.ReleaseDate.Format("2006-01-02")).To(Equal("2023-03-01"))
+
+		out, err := as.Download(appstore.DownloadInput{
+			Account:           acc,
+			App:               app,
+			ExternalVersionID: "800000002",
+			OutputPath:        filepath.Join(dir, "notes.ipa"),
+		})
+		Expect(err).ToNot(HaveOccurred())
+
+		reader, err := zip.OpenReader(out.DestinationPath)
+		Expect(err).ToNot(HaveOccurred())
+		defer reader.Close()
+
+		names := []string{}
+		for _, file := range reader.File {
+			names = append(names, file.Name)
+		}
+
+		Expect(names).To(ContainElements(
+			"Payload/Notes.app/Info.plist",
+			"Payload/Notes.app/SC_Info/Notes.sinf",
+			"iTunesMetadata.plist",
+		))
+	})
+
+	It("releases new versions", func() {
+		acc := login()
+		fake.GrantLicense(DefaultEmail, 1000000101)
+		fake.ReleaseVersion(1000000101, Version{ExternalVersionID: "800000004", DisplayVersion: "2.1.0", BundleVersion: "210"})
+
+		versions, err := as.ListVersions(appstore.ListVersionsInput{Account: acc, App: appstore.App{ID: 1000000101}})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(versions.ExternalVersionIdentifiers).To(HaveLen(4))
+		Expect(versions.LatestExternalVersionID).To(Equal("800000004"))
+	})
+
+	It("purchases arcade titles with the arcade pricing parameter", func() {
+		acc := login()
+
+		Expect(as.Purchase(appstore.PurchaseInput{Account: acc, App: appstore.App{ID: 1000000103}})).To(Succeed())
+		Expect(fake.HasLicense(DefaultEmail, 1000000103)).To(BeTrue())
+	})
+
+	It("reports expired tokens", func() {
+		acc := login()
+		fake.GrantLicense(DefaultEmail, 1000000101)
+		fake.ExpireTokens()
+
+		_, err := as.ListVersions(appstore.ListVersionsInput{Account: acc, App: appstore.App{ID: 1000000101}})
+		Expect(err).To(MatchError(appstore.ErrPasswordTokenExpired))
+	})
+})
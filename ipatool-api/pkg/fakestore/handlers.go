@@ -0,0 +1,402 @@
+package fakestore
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"howett.net/plist"
+)
+
+func (s *Server) handleAuthenticate(w http.ResponseWriter, r *http.Request) {
+	payload, err := readPlist(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+
+		return
+	}
+
+	email := strings.ToLower(fmt.Sprintf("%v", payload["appleId"]))
+	password := fmt.Sprintf("%v", payload["password"])
+
+	s.mu.Lock()
+	account, ok := s.accounts[email]
+	s.mu.Unlock()
+
+	if !ok || !strings.HasPrefix(password, account.Password) {
+		writePlist(w, http.StatusOK, map[string]interface{}{
+			"failureType":     FailureTypeInvalidCredentials,
+			"customerMessage": "Your Apple ID or password was entered incorrectly.",
+		})
+
+		return
+	}
+
+	authCode := strings.TrimPrefix(password, account.Password)
+
+	if account.AuthCode != "" && authCode == "" {
+		writePlist(w, http.StatusOK, map[string]interface{}{
+			"customerMessage": CustomerMessageBadLogin,
+		})
+
+		return
+	}
+
+	if authCode != account.AuthCode {
+		writePlist(w, http.StatusOK, map[string]interface{}{
+			"failureType":     FailureTypeInvalidCredentials,
+			"customerMessage": "Your Apple ID or password was entered incorrectly.",
+		})
+
+		return
+	}
+
+	token := newToken()
+
+	s.mu.Lock()
+	s.tokens[token] = account.DirectoryServicesID
+	s.mu.Unlock()
+
+	w.Header().Set(HTTPHeaderStoreFront, account.StoreFront)
+	writePlist(w, http.StatusOK, map[string]interface{}{
+		"accountInfo": map[string]interface{}{
+			"appleId": account.Email,
+			"address": map[string]interface{}{
+				"firstName": account.FirstName,
+				"lastName":  account.LastName,
+			},
+		},
+		"dsPersonId":    account.DirectoryServicesID,
+		"passwordToken": token,
+	})
+}
+
+func (s *Server) handlePurchase(w http.ResponseWriter, r *http.Request) {
+	payload, err := readPlist(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	dsid, ok := s.tokens[r.Header.Get("X-Token")]
+	if !ok || dsid != r.Header.Get("X-Dsid") {
+		writePlist(w, http.StatusOK, map[string]interface{}{"failureType": FailureTypePasswordTokenExpired})
+
+		return
+	}
+
+	app, ok := s.apps[parseInt(payload["salableAdamId"])]
+	if !ok {
+		writePlist(w, http.StatusOK, map[string]interface{}{
+			"failureType":     "5002",
+			"customerMessage": "The item you've requested is not currently available in the App Store.",
+		})
+
+		return
+	}
+
+	pricing := fmt.Sprintf("%v", payload["pricingParameters"])
+	if (app.Arcade && pricing != "GAME") || (!app.Arcade && pricing != "STDQ") {
+		writePlist(w, http.StatusOK, map[string]interface{}{"failureType": FailureTypeTemporarilyUnavailable})
+
+		return
+	}
+
+	if s.licenses[dsid][app.ID] {
+		writePlist(w, http.StatusInternalServerError, map[string]interface{}{})
+
+		return
+	}
+
+	s.grantLicense(dsid, app.ID)
+
+	writePlist(w, http.StatusOK, map[string]interface{}{
+		"jingleDocType": "purchaseSuccess",
+		"status":        0,
+	})
+}
+
+func (s *Server) handleDownload(w http.ResponseWriter, r *http.Request) {
+	payload, err := readPlist(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+
+		return
+	}
+
+	dsid := r.Header.Get("X-Dsid")
+
+	s.mu.Lock()
+	loggedIn := false
+
+	for _, tokenDSID := range s.tokens {
+		if tokenDSID == dsid {
+			loggedIn = true
+
+			break
+		}
+	}
+
+	app, appExists := s.apps[parseInt(payload["salableAdamId"])]
+	licensed := s.licenses[dsid][app.ID]
+	s.mu.Unlock()
+
+	if !loggedIn {
+		writePlist(w, http.StatusOK, map[string]interface{}{"failureType": FailureTypePasswordTokenExpired})
+
+		return
+	}
+
+	if !appExists || !licensed {
+		writePlist(w, http.StatusOK, map[string]interface{}{"failureType": FailureTypeLicenseNotFound})
+
+		return
+	}
+
+	version := app.LatestVersion()
+
+	if externalVersionID, ok := payload["externalVersionId"]; ok {
+		if version, ok = app.Version(fmt.Sprintf("%v", externalVersionID)); !ok {
+			writePlist(w, http.StatusOK, map[string]interface{}{
+				"failureType":     "5002",
+				"customerMessage": "An unknown error has occurred.",
+			})
+
+			return
+		}
+	}
+
+	data, err := s.ipa(app, version)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+
+		return
+	}
+
+	externalVersionIDs := make([]interface{}, len(app.Versions))
+	for i, v := range app.Versions {
+		externalVersionIDs[i] = parseInt(v.ExternalVersionID)
+	}
+
+	writePlist(w, http.StatusOK, map[string]interface{}{
+		"songList": []interface{}{
+			map[string]interface{}{
+				"URL": fmt.Sprintf("%s%s%d/%s.ipa", baseURL(r), PathCDN, app.ID, version.ExternalVersionID),
+				"md5": md5Hex(data),
+				"sinfs": []interface{}{
+					map[string]interface{}{
+						"id":   0,
+						"sinf": Sinf(dsid, app.ID),
+					},
+				},
+				"metadata": map[string]interface{}{
+					"artistName":                         app.Seller,
+					"bundleDisplayName":                  app.Name,
+					"bundleShortVersionString":           version.DisplayVersion,
+					"bundleVersion":                      version.BundleVersion,
+					"itemId":                             app.ID,
+					"itemName":                           app.Name,
+					"kind":                               "software",
+					"releaseDate":                        version.ReleaseDate.UTC().Format(time.RFC3339),
+					"softwareVersionBundleId":            app.BundleID,
+					"softwareVersionExternalIdentifier":  parseInt(app.LatestVersion().ExternalVersionID),
+					"softwareVersionExternalIdentifiers": externalVersionIDs,
+				},
+			},
+		},
+	})
+}
+
+func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	term := strings.ToLower(query.Get("term"))
+	country := query.Get("country")
+
+	limit, err := strconv.Atoi(query.Get("limit"))
+	if err != nil || limit <= 0 {
+		limit = 50
+	}
+
+	s.mu.Lock()
+	results := []map[string]interface{}{}
+
+	for _, appID := range s.appOrder {
+		app := s.apps[appID]
+		if !app.availableIn(country) {
+			continue
+		}
+
+		if strings.Contains(strings.ToLower(app.Name), term) || strings.Contains(strings.ToLower(app.BundleID), term) {
+			results = append(results, s.lookupResult(app))
+		}
+
+		if len(results) == limit {
+			break
+		}
+	}
+	s.mu.Unlock()
+
+	writeJSON(w, results)
+}
+
+func (s *Server) handleLookup(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	country := query.Get("country")
+
+	s.mu.Lock()
+	results := []map[string]interface{}{}
+
+	for _, appID := range s.appOrder {
+		app := s.apps[appID]
+		if !app.availableIn(country) {
+			continue
+		}
+
+		if matchesLookup(app, query.Get("bundleId"), query.Get("id")) {
+			results = append(results, s.lookupResult(app))
+		}
+	}
+	s.mu.Unlock()
+
+	writeJSON(w, results)
+}
+
+func (s *Server) handleCDN(w http.ResponseWriter, r *http.Request) {
+	appID, err := strconv.ParseInt(r.PathValue("appID"), 10, 64)
+	if err != nil {
+		http.NotFound(w, r)
+
+		return
+	}
+
+	data, err := s.IPA(appID, strings.TrimSuffix(r.PathValue("file"), ".ipa"))
+	if err != nil {
+		http.NotFound(w, r)
+
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	http.ServeContent(w, r, r.PathValue("file"), time.Time{}, strings.NewReader(string(data)))
+}
+
+func matchesLookup(app App, bundleIDs, ids string) bool {
+	for _, bundleID := range strings.Split(bundleIDs, ",") {
+		if bundleID != "" && strings.EqualFold(bundleID, app.BundleID) {
+			return true
+		}
+	}
+
+	for _, id := range strings.Split(ids, ",") {
+		if id != "" && id == strconv.FormatInt(app.ID, 10) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (s *Server) lookupResult(app App) map[string]interface{} {
+	latest := app.LatestVersion()
+	fileSize := 0
+
+	if data, err := s.ipaLocked(app, latest); err == nil {
+		fileSize = len(data)
+	}
+
+	formattedPrice := "Free"
+	if app.Price > 0 {
+		formattedPrice = fmt.Sprintf("$%.2f", app.Price)
+	}
+
+	return map[string]interface{}{
+		"trackId":                   app.ID,
+		"bundleId":                  app.BundleID,
+		"trackName":                 app.Name,
+		"version":                   latest.DisplayVersion,
+		"price":                     app.Price,
+		"sellerName":                app.Seller,
+		"primaryGenreName":          app.Genre,
+		"minimumOsVersion":          latest.MinimumOSVersion,
+		"releaseNotes":              latest.ReleaseNotes,
+		"fileSizeBytes":             strconv.Itoa(fileSize),
+		"currency":                  "USD",
+		"formattedPrice":            formattedPrice,
+		"supportedDevices":          []string{"iPhone15-iPhone15", "iPadPro11M4-iPadPro11M4"},
+		"averageUserRating":         4.5,
+		"contentAdvisoryRating":     "4+",
+		"currentVersionReleaseDate": latest.ReleaseDate.UTC().Format(time.RFC3339),
+		"screenshotUrls":            []string{fmt.Sprintf("https://example.com/screenshots/%d/1.png", app.ID)},
+		"artworkUrl512":             fmt.Sprintf("https://example.com/artwork/%d/512x512bb.jpg", app.ID),
+		"artworkUrl100":             fmt.Sprintf("https://example.com/artwork/%d/100x100bb.jpg", app.ID),
+		"artworkUrl60":              fmt.Sprintf("https://example.com/artwork/%d/60x60bb.jpg", app.ID),
+	}
+}
+
+func readPlist(r *http.Request) (map[string]interface{}, error) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read body: %w", err)
+	}
+
+	payload := map[string]interface{}{}
+
+	if _, err := plist.Unmarshal(body, &payload); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal plist: %w", err)
+	}
+
+	return payload, nil
+}
+
+func writePlist(w http.ResponseWriter, statusCode int, data interface{}) {
+	body, err := plist.Marshal(data, plist.XMLFormat)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/xml; charset=UTF-8")
+	w.WriteHeader(statusCode)
+	_, _ = w.Write(body)
+}
+
+func writeJSON(w http.ResponseWriter, results []map[string]interface{}) {
+	w.Header().Set("Content-Type", "text/javascript; charset=utf-8")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"resultCount": len(results),
+		"results":     results,
+	})
+}
+
+func parseInt(value interface{}) int64 {
+	id, _ := strconv.ParseInt(fmt.Sprintf("%v", value), 10, 64)
+
+	return id
+}
+
+func baseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+
+	return fmt.Sprintf("%s://%s", scheme, r.Host)
+}
+
+func newToken() string {
+	buf := make([]byte, 16)
+	_, _ = rand.Read(buf)
+
+	return hex.EncodeToString(buf)
+}
@@ -0,0 +1,73 @@
+package fakestore
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+
+	"howett.net/plist"
+)
+
+// buildIPA generates a minimal, deterministic IPA package for the app version.
+// It contains an Info.plist, an executable of the configured size and an SC_Info manifest listing the sinf path.
+func buildIPA(app App, version Version) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	writer := zip.NewWriter(buf)
+	bundle := fmt.Sprintf("Payload/%s.app", app.Name)
+
+	info, err := plist.Marshal(map[string]interface{}{
+		"CFBundleExecutable":         app.Name,
+		"CFBundleIdentifier":         app.BundleID,
+		"CFBundleDisplayName":        app.Name,
+		"CFBundleShortVersionString": version.DisplayVersion,
+		"CFBundleVersion":            version.BundleVersion,
+		"MinimumOSVersion":           version.MinimumOSVersion,
+	}, plist.BinaryFormat)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal info plist: %w", err)
+	}
+
+	manifest, err := plist.Marshal(map[string]interface{}{
+		"SinfPaths": []string{fmt.Sprintf("SC_Info/%s.sinf", app.Name)},
+	}, plist.XMLFormat)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal manifest plist: %w", err)
+	}
+
+	executableSize := app.ExecutableSize
+	if executableSize <= 0 {
+		executableSize = 4096
+	}
+
+	executable := bytes.Repeat([]byte(fmt.Sprintf("%s-%s;", app.BundleID, version.ExternalVersionID)), executableSize)[:executableSize]
+
+	files := []struct {
+		name   string
+		data   []byte
+		method uint16
+	}{
+		{name: bundle + "/Info.plist", data: info, method: zip.Deflate},
+		{name: bundle + "/" + app.Name, data: executable, method: zip.Store},
+		{name: bundle + "/SC_Info/Manifest.plist", data: manifest, method: zip.Deflate},
+	}
+
+	for _, file := range files {
+		w, err := writer.CreateHeader(&zip.FileHeader{
+			Name:   file.name,
+			Method: file.method,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create %s: %w", file.name, err)
+		}
+
+		if _, err := w.Write(file.data); err != nil {
+			return nil, fmt.Errorf("failed to write %s: %w", file.name, err)
+		}
+	}
+
+	if err := writer.Close(); err != nil {
+		return nil, fmt.Errorf("failed to close zip: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
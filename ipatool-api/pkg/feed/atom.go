@@ -0,0 +1,123 @@
+package feed
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"time"
+)
+
+const atomNamespace = "http://www.w3.org/2005/Atom"
+
+// Meta describes the feed itself.
+type Meta struct {
+	// ID is a permanent identifier of the feed, e.g. "urn:ipatool:feed:updates".
+	ID    string
+	Title string
+	// SelfURL is the URL the feed is served from, without credentials.
+	SelfURL string
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"feed"`
+	XMLNS   string      `xml:"xmlns,attr"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Author  atomAuthor  `xml:"author"`
+	Links   []atomLink  `xml:"link,omitempty"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr,omitempty"`
+	Href string `xml:"href,attr"`
+}
+
+type atomEntry struct {
+	ID        string     `xml:"id"`
+	Title     string     `xml:"title"`
+	Updated   string     `xml:"updated"`
+	Published string     `xml:"published,omitempty"`
+	Links     []atomLink `xml:"link"`
+	Summary   string     `xml:"summary"`
+	Content   *atomText  `xml:"content,omitempty"`
+}
+
+type atomText struct {
+	Type string `xml:"type,attr"`
+	Text string `xml:",chardata"`
+}
+
+// WriteAtom writes the versions, in the order given, as an Atom 1.0 feed.
+func WriteAtom(w io.Writer, meta Meta, versions []Version) error {
+	updated := time.Unix(0, 0).UTC()
+	if len(versions) > 0 {
+		updated = versions[0].ObservedAt
+	}
+
+	feed := atomFeed{
+		XMLNS:   atomNamespace,
+		ID:      meta.ID,
+		Title:   meta.Title,
+		Updated: updated.UTC().Format(time.RFC3339),
+		Author:  atomAuthor{Name: "ipatool"},
+		Entries: []atomEntry{},
+	}
+
+	if meta.SelfURL != "" {
+		feed.Links = append(feed.Links, atomLink{Rel: "self", Href: meta.SelfURL})
+	}
+
+	for _, version := range versions {
+		feed.Entries = append(feed.Entries, newAtomEntry(version))
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return fmt.Errorf("failed to write feed: %w", err)
+	}
+
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+
+	if err := encoder.Encode(feed); err != nil {
+		return fmt.Errorf("failed to encode feed: %w", err)
+	}
+
+	return nil
+}
+
+func newAtomEntry(version Version) atomEntry {
+	name := version.Name
+	if name == "" {
+		name = version.BundleID
+	}
+
+	displayVersion := version.DisplayVersion
+	if displayVersion == "" {
+		displayVersion = version.ExternalVersionID
+	}
+
+	entry := atomEntry{
+		ID:      fmt.Sprintf("urn:ipatool:app:%d:version:%s", version.AppID, version.ExternalVersionID),
+		Title:   fmt.Sprintf("%s %s", name, displayVersion),
+		Updated: version.ObservedAt.UTC().Format(time.RFC3339),
+		Links:   []atomLink{{Rel: "alternate", Href: fmt.Sprintf("https://apps.apple.com/app/id%d", version.AppID)}},
+		Summary: fmt.Sprintf("%s (%s) version %s, external version ID %s", name, version.BundleID, displayVersion, version.ExternalVersionID),
+	}
+
+	if !version.ReleaseDate.IsZero() {
+		entry.Published = version.ReleaseDate.UTC().Format(time.RFC3339)
+		entry.Summary += fmt.Sprintf(", released %s", version.ReleaseDate.UTC().Format("2006-01-02"))
+	}
+
+	if version.ReleaseNotes != "" {
+		entry.Content = &atomText{Type: "text", Text: version.ReleaseNotes}
+	}
+
+	return entry
+}
@@ -0,0 +1,60 @@
+package feed
+
+import (
+	"bytes"
+	"encoding/xml"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("WriteAtom", func() {
+	It("writes an Atom feed", func() {
+		observedAt := time.Date(2024, 9, 2, 8, 0, 0, 0, time.UTC)
+
+		var buffer bytes.Buffer
+		Expect(WriteAtom(&buffer, Meta{ID: "urn:ipatool:feed:updates", Title: "Updates", SelfURL: "https://example.com/feeds/updates.atom"}, []Version{
+			{
+				AppID:             1000000101,
+				BundleID:          "com.example.notes",
+				Name:              "Notes & More",
+				ExternalVersionID: "800000004",
+				DisplayVersion:    "2.1.0",
+				ReleaseDate:       time.Date(2024, 9, 1, 0, 0, 0, 0, time.UTC),
+				ReleaseNotes:      "Fixes <bugs>",
+				ObservedAt:        observedAt,
+			},
+			{AppID: 1000000102, BundleID: "com.example.radio", ExternalVersionID: "800000101", ObservedAt: observedAt.Add(-time.Hour)},
+		})).To(Succeed())
+
+		Expect(buffer.String()).To(HavePrefix(xml.Header))
+
+		var feed atomFeed
+		Expect(xml.Unmarshal(buffer.Bytes(), &feed)).To(Succeed())
+		Expect(feed.XMLName.Space).To(Equal(atomNamespace))
+		Expect(feed.Updated).To(Equal("2024-09-02T08:00:00Z"))
+		Expect(feed.Links).To(ConsistOf(atomLink{Rel: "self", Href: "https://example.com/feeds/updates.atom"}))
+		Expect(feed.Entries).To(HaveLen(2))
+
+		entry := feed.Entries[0]
+		Expect(entry.ID).To(Equal("urn:ipatool:app:1000000101:version:800000004"))
+		Expect(entry.Title).To(Equal("Notes & More 2.1.0"))
+		Expect(entry.Published).To(Equal("2024-09-01T00:00:00Z"))
+		Expect(entry.Content.Text).To(Equal("Fixes <bugs>"))
+		Expect(entry.Summary).To(ContainSubstring("released 2024-09-01"))
+
+		Expect(feed.Entries[1].Title).To(Equal("com.example.radio 800000101"))
+		Expect(feed.Entries[1].Content).To(BeNil())
+	})
+
+	It("writes an empty feed", func() {
+		var buffer bytes.Buffer
+		Expect(WriteAtom(&buffer, Meta{ID: "urn:ipatool:feed:empty", Title: "Empty"}, nil)).To(Succeed())
+
+		var feed atomFeed
+		Expect(xml.Unmarshal(buffer.Bytes(), &feed)).To(Succeed())
+		Expect(feed.Entries).To(BeEmpty())
+		Expect(feed.Updated).To(Equal("1970-01-01T00:00:00Z"))
+	})
+})
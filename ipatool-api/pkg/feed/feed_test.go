@@ -0,0 +1,50 @@
+package feed
+
+import (
+	"encoding/json"
+	"sync"
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestFeed(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Feed Suite")
+}
+
+// memoryStore is an in-memory Store.
+type memoryStore struct {
+	mu     sync.Mutex
+	values map[string][]byte
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{values: map[string][]byte{}}
+}
+
+func (s *memoryStore) Get(key string, value interface{}) bool {
+	s.mu.Lock()
+	data, ok := s.values[key]
+	s.mu.Unlock()
+
+	if !ok {
+		return false
+	}
+
+	return json.Unmarshal(data, value) == nil
+}
+
+func (s *memoryStore) Set(key string, value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.values[key] = data
+	s.mu.Unlock()
+
+	return nil
+}
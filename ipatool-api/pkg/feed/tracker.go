@@ -0,0 +1,241 @@
+// Package feed observes new versions of apps and renders them as an Atom feed.
+package feed
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/majd/ipatool/v2/pkg/appstore"
+	"github.com/majd/ipatool/v2/pkg/log"
+)
+
+const (
+	DefaultRefreshInterval = 15 * time.Minute
+	// maxVersionsPerApp is the number of observed versions kept per app.
+	maxVersionsPerApp = 50
+)
+
+// Store persists the versions seen per app across restarts. It must be safe for concurrent use.
+type Store interface {
+	// Get decodes the value stored under key into value and reports whether it was found.
+	Get(key string, value interface{}) bool
+	// Set stores value under key.
+	Set(key string, value interface{}) error
+}
+
+// Version is a newly observed version of an app.
+type Version struct {
+	AppID             int64     `json:"app_id"`
+	BundleID          string    `json:"bundle_id"`
+	Name              string    `json:"name,omitempty"`
+	ExternalVersionID string    `json:"external_version_id"`
+	DisplayVersion    string    `json:"display_version,omitempty"`
+	ReleaseDate       time.Time `json:"release_date,omitempty"`
+	// ReleaseNotes are only known for the version that was the latest one when it was observed.
+	ReleaseNotes string    `json:"release_notes,omitempty"`
+	ObservedAt   time.Time `json:"observed_at"`
+}
+
+// appState is what the store keeps per app.
+type appState struct {
+	Seen     []string  `json:"seen"`
+	Versions []Version `json:"versions"`
+}
+
+type Args struct {
+	AppStore appstore.AppStore
+	Store    Store
+	Logger   log.Logger
+	// RefreshInterval is the minimum time between two checks of the same app. Zero uses DefaultRefreshInterval.
+	RefreshInterval time.Duration
+}
+
+// Tracker records the versions of apps that appear after an app was first checked.
+type Tracker struct {
+	appStore        appstore.AppStore
+	store           Store
+	logger          log.Logger
+	refreshInterval time.Duration
+
+	// mu guards checkedAt and refreshing only. It is not held while apps are checked, so that a slow App Store
+	// response does not block the requests of other feeds.
+	mu         sync.Mutex
+	checkedAt  map[string]time.Time
+	refreshing map[string]bool
+}
+
+func NewTracker(args Args) *Tracker {
+	refreshInterval := args.RefreshInterval
+	if refreshInterval <= 0 {
+		refreshInterval = DefaultRefreshInterval
+	}
+
+	return &Tracker{
+		appStore:        args.AppStore,
+		store:           args.Store,
+		logger:          args.Logger,
+		refreshInterval: refreshInterval,
+		checkedAt:       map[string]time.Time{},
+		refreshing:      map[string]bool{},
+	}
+}
+
+// Versions checks the apps that were not checked within the refresh interval and returns the versions
+// observed for all of them, newest first. The first check of an app only reports its latest version;
+// older versions are marked as seen. Apps that fail to be checked, or that are being checked by another
+// request, report the versions observed before.
+func (t *Tracker) Versions(acc appstore.Account, bundleIDs []string) []Version {
+	var versions []Version
+
+	for _, bundleID := range bundleIDs {
+		state := t.state(bundleID)
+
+		if t.startRefresh(bundleID) {
+			updated, err := t.refresh(acc, bundleID, state)
+			if err != nil {
+				t.logger.Error().Err(err).Str("bundleID", bundleID).Msg("Feed: failed to check app")
+			} else {
+				state = updated
+			}
+
+			t.finishRefresh(bundleID, err == nil)
+		}
+
+		versions = append(versions, state.Versions...)
+	}
+
+	slices.SortStableFunc(versions, func(a, b Version) int {
+		return b.ObservedAt.Compare(a.ObservedAt)
+	})
+
+	return versions
+}
+
+// startRefresh reports whether the app is due to be checked and, if so, marks it as being checked, so that
+// concurrent requests do not check it at the same time.
+func (t *Tracker) startRefresh(bundleID string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.refreshing[bundleID] || time.Since(t.checkedAt[bundleID]) < t.refreshInterval {
+		return false
+	}
+
+	t.refreshing[bundleID] = true
+
+	return true
+}
+
+func (t *Tracker) finishRefresh(bundleID string, checked bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.refreshing, bundleID)
+
+	if checked {
+		t.checkedAt[bundleID] = time.Now()
+	}
+}
+
+func (t *Tracker) state(bundleID string) appState {
+	var state appState
+	t.store.Get(stateKey(bundleID), &state)
+
+	return state
+}
+
+func (t *Tracker) refresh(acc appstore.Account, bundleID string, state appState) (appState, error) {
+	lookup, err := t.appStore.Lookup(appstore.LookupInput{Account: acc, BundleID: bundleID})
+	if err != nil {
+		return appState{}, fmt.Errorf("failed to look up app: %w", err)
+	}
+
+	app := lookup.App
+
+	list, err := t.appStore.ListVersions(appstore.ListVersionsInput{Account: acc, App: app})
+	if err != nil {
+		return appState{}, fmt.Errorf("failed to list versions: %w", err)
+	}
+
+	var newIDs []string
+
+	for _, id := range list.ExternalVersionIdentifiers {
+		if !slices.Contains(state.Seen, id) {
+			newIDs = append(newIDs, id)
+		}
+	}
+
+	if len(newIDs) == 0 {
+		return state, nil
+	}
+
+	// On the first check, every version but the latest one is old news.
+	if len(state.Seen) == 0 {
+		for _, id := range newIDs {
+			if id != list.LatestExternalVersionID {
+				state.Seen = append(state.Seen, id)
+			}
+		}
+
+		newIDs = slices.DeleteFunc(newIDs, func(id string) bool {
+			return id != list.LatestExternalVersionID
+		})
+	}
+
+	for _, id := range newIDs {
+		version, err := t.version(acc, app, id, list.LatestExternalVersionID)
+		if err != nil {
+			// Leave the version unseen, so that it is reported by the next check.
+			t.logger.Error().Err(err).Str("bundleID", bundleID).Str("externalVersionID", id).Msg("Feed: failed to get version metadata")
+
+			continue
+		}
+
+		state.Seen = append(state.Seen, id)
+		state.Versions = append(state.Versions, version)
+	}
+
+	if excess := len(state.Versions) - maxVersionsPerApp; excess > 0 {
+		state.Versions = slices.Clone(state.Versions[excess:])
+	}
+
+	if err := t.store.Set(stateKey(bundleID), state); err != nil {
+		return appState{}, fmt.Errorf("failed to save seen versions: %w", err)
+	}
+
+	return state, nil
+}
+
+func (t *Tracker) version(acc appstore.Account, app appstore.App, externalVersionID, latestExternalVersionID string) (Version, error) {
+	metadata, err := t.appStore.GetVersionMetadata(appstore.GetVersionMetadataInput{
+		Account:   acc,
+		App:       app,
+		VersionID: externalVersionID,
+	})
+	if err != nil {
+		return Version{}, err
+	}
+
+	version := Version{
+		AppID:             app.ID,
+		BundleID:          app.BundleID,
+		Name:              app.Name,
+		ExternalVersionID: externalVersionID,
+		DisplayVersion:    metadata.DisplayVersion,
+		ReleaseDate:       metadata.ReleaseDate,
+		ObservedAt:        time.Now(),
+	}
+
+	// Lookup only describes the current version.
+	if externalVersionID == latestExternalVersionID && app.Version == metadata.DisplayVersion {
+		version.ReleaseNotes = app.ReleaseNotes
+	}
+
+	return version, nil
+}
+
+func stateKey(bundleID string) string {
+	return fmt.Sprintf("feed/%s", bundleID)
+}
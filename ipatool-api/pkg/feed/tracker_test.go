@@ -0,0 +1,158 @@
+package feed
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"time"
+
+	"github.com/99designs/keyring"
+	cookiejar "github.com/juju/persistent-cookiejar"
+	"github.com/majd/ipatool/v2/pkg/appstore"
+	"github.com/majd/ipatool/v2/pkg/fakestore"
+	"github.com/majd/ipatool/v2/pkg/keychain"
+	"github.com/majd/ipatool/v2/pkg/log"
+	"github.com/majd/ipatool/v2/pkg/util/machine"
+	"github.com/majd/ipatool/v2/pkg/util/operatingsystem"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Tracker", func() {
+	var (
+		fake  *fakestore.Server
+		as    appstore.AppStore
+		acc   appstore.Account
+		store *memoryStore
+		// blockLookup, if set, holds lookups of com.example.radio until it is closed. lookupBlocked receives
+		// a value when a lookup is held.
+		blockLookup   chan struct{}
+		lookupBlocked chan struct{}
+		tracker       *Tracker
+	)
+
+	newTracker := func(refreshInterval time.Duration) *Tracker {
+		return NewTracker(Args{
+			AppStore:        as,
+			Store:           store,
+			Logger:          log.NewLogger(log.Args{Writer: GinkgoWriter}),
+			RefreshInterval: refreshInterval,
+		})
+	}
+
+	BeforeEach(func() {
+		fake = fakestore.New(fakestore.DefaultConfig())
+		blockLookup = nil
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if blockLookup != nil && r.URL.Path == fakestore.PathLookup && r.URL.Query().Get("bundleId") == "com.example.radio" {
+				lookupBlocked <- struct{}{}
+
+				select {
+				case <-blockLookup:
+				case <-r.Context().Done():
+				}
+			}
+
+			fake.ServeHTTP(w, r)
+		}))
+		DeferCleanup(srv.Close)
+
+		jar, err := cookiejar.New(&cookiejar.Options{Filename: filepath.Join(GinkgoT().TempDir(), "cookies")})
+		Expect(err).ToNot(HaveOccurred())
+
+		os := operatingsystem.New()
+		as = appstore.NewAppStore(appstore.Args{
+			Keychain:        keychain.New(keychain.Args{Keyring: keyring.NewArrayKeyring(nil)}),
+			CookieJar:       jar,
+			OperatingSystem: os,
+			Machine:         machine.New(machine.Args{OS: os}),
+			DeviceGUID:      "0123456789AB",
+			Endpoints:       appstore.EndpointsWithBaseURL(srv.URL),
+		})
+
+		out, err := as.Login(appstore.LoginInput{Email: fakestore.DefaultEmail, Password: fakestore.DefaultPassword})
+		Expect(err).ToNot(HaveOccurred())
+		acc = out.Account
+
+		fake.GrantLicense(fakestore.DefaultEmail, 1000000101)
+		fake.GrantLicense(fakestore.DefaultEmail, 1000000102)
+
+		store = newMemoryStore()
+		tracker = newTracker(time.Nanosecond)
+	})
+
+	It("reports the latest version on the first check and new versions afterwards", func() {
+		versions := tracker.Versions(acc, []string{"com.example.notes"})
+		Expect(versions).To(HaveLen(1))
+		Expect(versions[0].ExternalVersionID).To(Equal("800000003"))
+		Expect(versions[0].DisplayVersion).To(Equal("2.0.0"))
+		Expect(versions[0].ReleaseDate.Format("2006-01-02")).To(Equal("2024-06-15"))
+		Expect(versions[0].ReleaseNotes).To(Equal("Release 2.0.0"))
+		Expect(versions[0].Name).To(Equal("Notes"))
+
+		Expect(tracker.Versions(acc, []string{"com.example.notes"})).To(HaveLen(1))
+
+		fake.ReleaseVersion(1000000101, fakestore.Version{
+			ExternalVersionID: "800000004",
+			DisplayVersion:    "2.1.0",
+			BundleVersion:     "210",
+			ReleaseDate:       time.Date(2024, 9, 1, 0, 0, 0, 0, time.UTC),
+			ReleaseNotes:      "Bug fixes",
+		})
+
+		versions = tracker.Versions(acc, []string{"com.example.notes", "com.example.radio"})
+		Expect(versions).To(HaveLen(3))
+		Expect(versions[0].ExternalVersionID).To(Equal("800000101"))
+		Expect(versions[1].ExternalVersionID).To(Equal("800000004"))
+		Expect(versions[1].ReleaseNotes).To(Equal("Bug fixes"))
+		Expect(versions[2].ExternalVersionID).To(Equal("800000003"))
+	})
+
+	It("persists the seen versions", func() {
+		tracker.Versions(acc, []string{"com.example.notes"})
+
+		fake.ReleaseVersion(1000000101, fakestore.Version{ExternalVersionID: "800000004", DisplayVersion: "2.1.0", BundleVersion: "210"})
+
+		versions := newTracker(time.Nanosecond).Versions(acc, []string{"com.example.notes"})
+		Expect(versions).To(HaveLen(2))
+		Expect(versions[0].ExternalVersionID).To(Equal("800000004"))
+	})
+
+	It("checks each app at most once per refresh interval", func() {
+		tracker = newTracker(time.Hour)
+
+		tracker.Versions(acc, []string{"com.example.notes"})
+		lookups := fake.Requests(fakestore.PathLookup)
+
+		Expect(tracker.Versions(acc, []string{"com.example.notes"})).To(HaveLen(1))
+		Expect(fake.Requests(fakestore.PathLookup)).To(Equal(lookups))
+	})
+
+	It("does not block other apps while an app is checked", func() {
+		tracker.Versions(acc, []string{"com.example.notes"})
+
+		blockLookup = make(chan struct{})
+		lookupBlocked = make(chan struct{}, 1)
+		done := make(chan []Version)
+		go func() {
+			defer GinkgoRecover()
+			done <- tracker.Versions(acc, []string{"com.example.radio"})
+		}()
+
+		Eventually(lookupBlocked).Should(Receive())
+		Expect(tracker.Versions(acc, []string{"com.example.notes"})).To(HaveLen(1))
+
+		close(blockLookup)
+		Eventually(done).Should(Receive(HaveLen(1)))
+	})
+
+	It("keeps the observed versions of apps that fail to be checked", func() {
+		tracker.Versions(acc, []string{"com.example.notes", "com.example.missing"})
+
+		fake.ExpireTokens()
+
+		versions := tracker.Versions(acc, []string{"com.example.notes", "com.example.missing"})
+		Expect(versions).To(HaveLen(1))
+		Expect(versions[0].ExternalVersionID).To(Equal("800000003"))
+	})
+})
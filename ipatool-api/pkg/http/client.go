@@ -3,9 +3,11 @@ package http
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"strings"
 
 	"howett.net/plist"
@@ -25,10 +27,15 @@ type Client[R interface{}] interface {
 type client[R interface{}] struct {
 	internalClient http.Client
 	cookieJar      CookieJar
+	retryPolicy    RetryPolicy
 }
 
 type Args struct {
 	CookieJar CookieJar
+	// Proxy is used for requests without a per-request proxy. If nil, the proxy environment variables apply.
+	Proxy *url.URL
+	// Retry is applied to idempotent requests. The zero value disables retries.
+	Retry RetryPolicy
 }
 
 type AddHeaderTransport struct {
@@ -49,6 +56,9 @@ func (t *AddHeaderTransport) RoundTrip(req *http.Request) (*http.Response, error
 }
 
 func NewClient[R interface{}](args Args) Client[R] {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = proxyFunc(args.Proxy)
+
 	return &client[R]{
 		internalClient: http.Client{
 			Timeout: 0,
@@ -60,13 +70,43 @@ func NewClient[R interface{}](args Args) Client[R] {
 
 				return nil
 			},
-			Transport: &AddHeaderTransport{http.DefaultTransport},
+			Transport: &AddHeaderTransport{transport},
 		},
-		cookieJar: args.CookieJar,
+		cookieJar:   args.CookieJar,
+		retryPolicy: args.Retry,
 	}
 }
 
 func (c *client[R]) Send(req Request) (Result[R], error) {
+	policy := c.retryPolicy
+	if !req.isIdempotent() {
+		policy = RetryPolicy{Logger: policy.Logger, Metrics: policy.Metrics}
+	}
+
+	var result Result[R]
+
+	err := policy.Do(fmt.Sprintf("%s %s", req.Method, req.URL), func() error {
+		var err error
+		result, err = c.send(req)
+
+		return err
+	})
+
+	// Retries are exhausted but the response itself was decoded; let the caller interpret it.
+	if errors.Is(err, errTransientResponse) {
+		return result, nil
+	}
+
+	if err != nil {
+		return Result[R]{}, err
+	}
+
+	return result, nil
+}
+
+// send performs a single attempt. Network failures, transient status codes and
+// transient payloads are reported as TransientError.
+func (c *client[R]) send(req Request) (Result[R], error) {
 	var (
 		data []byte
 		err  error
@@ -88,9 +128,14 @@ func (c *client[R]) Send(req Request) (Result[R], error) {
 		request.Header.Set(key, val)
 	}
 
+	request, err = WithProxy(request, req.Proxy)
+	if err != nil {
+		return Result[R]{}, fmt.Errorf("failed to configure proxy: %w", err)
+	}
+
 	res, err := c.internalClient.Do(request)
 	if err != nil {
-		return Result[R]{}, fmt.Errorf("request failed: %w", err)
+		return Result[R]{}, NewTransientError(fmt.Errorf("request failed: %w", err), 0)
 	}
 	defer res.Body.Close()
 
@@ -99,15 +144,35 @@ func (c *client[R]) Send(req Request) (Result[R], error) {
 		return Result[R]{}, fmt.Errorf("failed to save cookies: %w", err)
 	}
 
-	if req.ResponseFormat == ResponseFormatJSON {
-		return c.handleJSONResponse(res)
+	var result Result[R]
+
+	switch req.ResponseFormat {
+	case ResponseFormatJSON:
+		result, err = c.handleJSONResponse(res)
+	case ResponseFormatXML:
+		result, err = c.handleXMLResponse(res)
+	default:
+		return Result[R]{}, fmt.Errorf("content type is not supported (%s)", req.ResponseFormat)
+	}
+
+	if IsTransientStatus(res.StatusCode) {
+		retryAfter := ParseRetryAfter(res.Header.Get("Retry-After"))
+		if err != nil {
+			return result, NewTransientError(err, retryAfter)
+		}
+
+		return result, NewTransientError(errTransientResponse, retryAfter)
+	}
+
+	if err != nil {
+		return result, err
 	}
 
-	if req.ResponseFormat == ResponseFormatXML {
-		return c.handleXMLResponse(res)
+	if transient, ok := any(result.Data).(TransientResult); ok && transient.IsTransientFailure() {
+		return result, NewTransientError(errTransientResponse, 0)
 	}
 
-	return Result[R]{}, fmt.Errorf("content type is not supported (%s)", req.ResponseFormat)
+	return result, nil
 }
 
 func (c *client[R]) Do(req *http.Request) (*http.Response, error) {
@@ -6,4 +6,12 @@ type Request struct {
 	Headers        map[string]string
 	Payload        Payload
 	ResponseFormat ResponseFormat
+	// Proxy overrides the client's default proxy for this request.
+	Proxy string
+	// Idempotent allows the request to be retried. GET requests are always idempotent.
+	Idempotent bool
+}
+
+func (r Request) isIdempotent() bool {
+	return r.Idempotent || r.Method == MethodGET
 }
@@ -0,0 +1,196 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/avast/retry-go"
+	"github.com/majd/ipatool/v2/pkg/log"
+)
+
+var (
+	errTransientResponse = errors.New("received transient failure response")
+)
+
+// RetryPolicy configures how idempotent requests are retried on transient failures.
+// The zero value performs a single attempt.
+type RetryPolicy struct {
+	// Attempts is the maximum number of attempts, including the first one.
+	Attempts uint
+	// Delay is the initial backoff delay; it doubles with every retry.
+	Delay time.Duration
+	// MaxDelay caps the delay between attempts, including delays requested via Retry-After.
+	MaxDelay time.Duration
+	// MaxJitter is the upper bound of the random jitter added to every backoff delay.
+	MaxJitter time.Duration
+	// Logger logs every attempt, if set.
+	Logger log.Logger
+	// Metrics counts every attempt, if set.
+	Metrics *RetryMetrics
+}
+
+// DefaultRetryPolicy returns the policy used for App Store and CDN requests.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		Attempts:  4,
+		Delay:     500 * time.Millisecond,
+		MaxDelay:  30 * time.Second,
+		MaxJitter: 250 * time.Millisecond,
+	}
+}
+
+// RetryMetrics counts attempts made under a retry policy. It is safe for concurrent use.
+type RetryMetrics struct {
+	attempts  atomic.Int64
+	retries   atomic.Int64
+	exhausted atomic.Int64
+}
+
+// RetryStats is a point-in-time snapshot of RetryMetrics.
+type RetryStats struct {
+	Attempts  int64 `json:"attempts"`
+	Retries   int64 `json:"retries"`
+	Exhausted int64 `json:"exhausted"`
+}
+
+// Stats returns a snapshot of the counters.
+func (m *RetryMetrics) Stats() RetryStats {
+	return RetryStats{
+		Attempts:  m.attempts.Load(),
+		Retries:   m.retries.Load(),
+		Exhausted: m.exhausted.Load(),
+	}
+}
+
+// TransientError marks an error as transient so that it is retried.
+type TransientError struct {
+	Err        error
+	RetryAfter time.Duration
+}
+
+func (e *TransientError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *TransientError) Unwrap() error {
+	return e.Err
+}
+
+// NewTransientError marks the error as transient. A positive retryAfter overrides the backoff delay.
+func NewTransientError(err error, retryAfter time.Duration) error {
+	return &TransientError{Err: err, RetryAfter: retryAfter}
+}
+
+// IsTransient reports whether the error is marked as transient.
+func IsTransient(err error) bool {
+	var transientErr *TransientError
+
+	return errors.As(err, &transientErr)
+}
+
+// TransientResult is implemented by response payloads that can report a transient failure
+// inside an otherwise successful response (e.g. App Store failure type 2059).
+type TransientResult interface {
+	IsTransientFailure() bool
+}
+
+// Do runs the operation until it succeeds, fails with an error that is not transient,
+// or the policy runs out of attempts. The last error is returned.
+func (p RetryPolicy) Do(operation string, fn func() error) error {
+	attempts := p.Attempts
+	if attempts == 0 {
+		attempts = 1
+	}
+
+	var attempt uint
+
+	err := retry.Do(
+		func() error {
+			attempt++
+
+			if p.Metrics != nil {
+				p.Metrics.attempts.Add(1)
+
+				if attempt > 1 {
+					p.Metrics.retries.Add(1)
+				}
+			}
+
+			err := fn()
+			if err != nil && IsTransient(err) && attempt < attempts && p.Logger != nil {
+				p.Logger.Log().
+					Err(err).
+					Str("operation", operation).
+					Uint("attempt", attempt).
+					Uint("maxAttempts", attempts).
+					Msg("Transient failure, retrying")
+			}
+
+			return err
+		},
+		retry.Attempts(attempts),
+		retry.Delay(p.Delay),
+		retry.MaxDelay(p.MaxDelay),
+		retry.MaxJitter(p.MaxJitter),
+		retry.DelayType(p.delay),
+		retry.RetryIf(IsTransient),
+		retry.LastErrorOnly(true),
+	)
+
+	if err != nil && IsTransient(err) && attempt > 1 {
+		if p.Metrics != nil {
+			p.Metrics.exhausted.Add(1)
+		}
+
+		if p.Logger != nil {
+			p.Logger.Error().
+				Err(err).
+				Str("operation", operation).
+				Uint("attempts", attempt).
+				Msg("Giving up after transient failures")
+		}
+	}
+
+	return err //nolint:wrapcheck
+}
+
+// delay honors Retry-After and otherwise uses exponential backoff with jitter.
+func (p RetryPolicy) delay(n uint, err error, config *retry.Config) time.Duration {
+	var transientErr *TransientError
+	if errors.As(err, &transientErr) && transientErr.RetryAfter > 0 {
+		return transientErr.RetryAfter
+	}
+
+	if p.MaxJitter <= 0 {
+		return retry.BackOffDelay(n, err, config)
+	}
+
+	return retry.CombineDelay(retry.BackOffDelay, retry.RandomDelay)(n, err, config)
+}
+
+// IsTransientStatus reports whether the status code indicates a failure worth retrying.
+func IsTransientStatus(code int) bool {
+	return code == http.StatusTooManyRequests || code >= http.StatusInternalServerError
+}
+
+// ParseRetryAfter parses a Retry-After header given in seconds or as an HTTP date.
+func ParseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		if d := time.Until(date); d > 0 {
+			return d
+		}
+	}
+
+	return 0
+}
@@ -0,0 +1,183 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"go.uber.org/mock/gomock"
+)
+
+type transientPayload struct {
+	FailureType string `plist:"failureType,omitempty"`
+}
+
+func (p transientPayload) IsTransientFailure() bool {
+	return p.FailureType == "2059"
+}
+
+var _ = Describe("Retry", func() {
+	var (
+		metrics *RetryMetrics
+		policy  RetryPolicy
+	)
+
+	BeforeEach(func() {
+		metrics = &RetryMetrics{}
+		policy = RetryPolicy{
+			Attempts: 3,
+			Delay:    time.Millisecond,
+			MaxDelay: 5 * time.Millisecond,
+			Metrics:  metrics,
+		}
+	})
+
+	Context("RetryPolicy", func() {
+		It("retries transient errors until success", func() {
+			calls := 0
+			err := policy.Do("test", func() error {
+				calls++
+				if calls < 3 {
+					return NewTransientError(errors.New("reset"), 0)
+				}
+
+				return nil
+			})
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(calls).To(Equal(3))
+			Expect(metrics.Stats()).To(Equal(RetryStats{Attempts: 3, Retries: 2}))
+		})
+
+		It("does not retry permanent errors", func() {
+			calls := 0
+			err := policy.Do("test", func() error {
+				calls++
+
+				return errors.New("permanent")
+			})
+
+			Expect(err).To(MatchError("permanent"))
+			Expect(calls).To(Equal(1))
+		})
+
+		It("returns last error when attempts are exhausted", func() {
+			err := policy.Do("test", func() error {
+				return NewTransientError(errors.New("reset"), 0)
+			})
+
+			Expect(IsTransient(err)).To(BeTrue())
+			Expect(metrics.Stats()).To(Equal(RetryStats{Attempts: 3, Retries: 2, Exhausted: 1}))
+		})
+
+		It("performs a single attempt with the zero value", func() {
+			calls := 0
+			_ = RetryPolicy{}.Do("test", func() error {
+				calls++
+
+				return NewTransientError(errors.New("reset"), 0)
+			})
+
+			Expect(calls).To(Equal(1))
+		})
+
+		It("parses Retry-After values", func() {
+			Expect(ParseRetryAfter("")).To(BeZero())
+			Expect(ParseRetryAfter("3")).To(Equal(3 * time.Second))
+			Expect(ParseRetryAfter("invalid")).To(BeZero())
+			Expect(ParseRetryAfter(time.Now().Add(time.Hour).UTC().Format(http.TimeFormat))).To(BeNumerically(">", 59*time.Minute))
+		})
+	})
+
+	Context("Client", func() {
+		var (
+			ctrl          *gomock.Controller
+			mockCookieJar *MockCookieJar
+			srv           *httptest.Server
+			handler       func(w http.ResponseWriter, calls int)
+			calls         int
+		)
+
+		BeforeEach(func() {
+			ctrl = gomock.NewController(GinkgoT())
+			mockCookieJar = NewMockCookieJar(ctrl)
+			mockCookieJar.EXPECT().Cookies(gomock.Any()).Return(nil).AnyTimes()
+			mockCookieJar.EXPECT().SetCookies(gomock.Any(), gomock.Any()).AnyTimes()
+			mockCookieJar.EXPECT().Save().Return(nil).AnyTimes()
+
+			calls = 0
+			srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				calls++
+				handler(w, calls)
+			}))
+		})
+
+		AfterEach(func() {
+			srv.Close()
+		})
+
+		It("retries idempotent requests on transient status codes", func() {
+			handler = func(w http.ResponseWriter, calls int) {
+				if calls == 1 {
+					w.Header().Set("Retry-After", "0")
+					w.WriteHeader(http.StatusServiceUnavailable)
+
+					return
+				}
+
+				_, _ = w.Write([]byte("<dict><key>failureType</key><string></string></dict>"))
+			}
+
+			sut := NewClient[transientPayload](Args{CookieJar: mockCookieJar, Retry: policy})
+			res, err := sut.Send(Request{
+				URL:            srv.URL,
+				Method:         MethodPOST,
+				ResponseFormat: ResponseFormatXML,
+				Idempotent:     true,
+			})
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(res.StatusCode).To(Equal(http.StatusOK))
+			Expect(calls).To(Equal(2))
+		})
+
+		It("retries transient payloads and returns the last response", func() {
+			handler = func(w http.ResponseWriter, _ int) {
+				_, _ = w.Write([]byte("<dict><key>failureType</key><string>2059</string></dict>"))
+			}
+
+			sut := NewClient[transientPayload](Args{CookieJar: mockCookieJar, Retry: policy})
+			res, err := sut.Send(Request{
+				URL:            srv.URL,
+				Method:         MethodPOST,
+				ResponseFormat: ResponseFormatXML,
+				Idempotent:     true,
+			})
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(res.Data.FailureType).To(Equal("2059"))
+			Expect(calls).To(Equal(3))
+		})
+
+		It("does not retry non-idempotent requests", func() {
+			handler = func(w http.ResponseWriter, _ int) {
+				w.WriteHeader(http.StatusInternalServerError)
+				_, _ = w.Write([]byte("<dict></dict>"))
+			}
+
+			sut := NewClient[transientPayload](Args{CookieJar: mockCookieJar, Retry: policy})
+			res, err := sut.Send(Request{
+				URL:            srv.URL,
+				Method:         MethodPOST,
+				ResponseFormat: ResponseFormatXML,
+			})
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(res.StatusCode).To(Equal(http.StatusInternalServerError))
+			Expect(calls).To(Equal(1))
+		})
+	})
+})
@@ -0,0 +1,272 @@
+// Package kvstore implements a small persistent key/value store backed by a single append-only file.
+package kvstore
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// minCompactLines is the number of stale lines a store may accumulate before Set compacts its file.
+const minCompactLines = 1000
+
+// Store keeps all entries in memory and appends every write to its file as a JSON line, so that
+// entries survive restarts. Values are JSON-encoded. It is safe for concurrent use.
+//
+// Writes that don't change a value are skipped. Lines overwritten by later writes are dropped when the
+// store is opened, and when they outnumber the entries while the store is in use.
+type Store struct {
+	mu      sync.Mutex
+	path    string
+	file    *os.File
+	entries map[string]json.RawMessage
+	size    int64
+	// lines is the number of records in the file, including overwritten ones.
+	lines  int
+	hits   uint64
+	misses uint64
+}
+
+// Stats describes the contents and usage of a store since it was opened.
+type Stats struct {
+	Path      string
+	Entries   int
+	SizeBytes int64
+	Hits      uint64
+	Misses    uint64
+}
+
+type record struct {
+	Key   string          `json:"k"`
+	Value json.RawMessage `json:"v"`
+}
+
+// Open opens the store at the specified path, creating the file and its directory if needed.
+// Lines that cannot be decoded are skipped, and a last line cut off by a crash is removed.
+func Open(path string) (*Store, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+
+	store := &Store{
+		path:    path,
+		file:    file,
+		entries: map[string]json.RawMessage{},
+	}
+
+	if err := store.load(); err != nil {
+		_ = file.Close()
+
+		return nil, err
+	}
+
+	// Compaction is best effort: the file is still valid without it, and it is tried again on the next open.
+	if store.lines > len(store.entries) {
+		_ = store.compact()
+	}
+
+	return store, nil
+}
+
+func (s *Store) load() error {
+	reader := bufio.NewReader(s.file)
+
+	for {
+		line, err := reader.ReadBytes('\n')
+
+		if errors.Is(err, io.EOF) {
+			if len(line) > 0 {
+				if err := s.file.Truncate(s.size); err != nil {
+					return fmt.Errorf("failed to truncate file: %w", err)
+				}
+			}
+
+			return nil
+		}
+
+		if err != nil {
+			return fmt.Errorf("failed to read file: %w", err)
+		}
+
+		s.size += int64(len(line))
+		s.lines++
+
+		var rec record
+		if json.Unmarshal(bytes.TrimSpace(line), &rec) == nil && rec.Key != "" {
+			s.entries[rec.Key] = rec.Value
+		}
+	}
+}
+
+// Get decodes the value stored under key into value and reports whether it was found.
+func (s *Store) Get(key string, value interface{}) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, ok := s.entries[key]
+	if !ok || json.Unmarshal(data, value) != nil {
+		s.misses++
+
+		return false
+	}
+
+	s.hits++
+
+	return true
+}
+
+// Set stores value under key and appends it to the file.
+func (s *Store) Set(key string, value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to encode value: %w", err)
+	}
+
+	line, err := json.Marshal(record{Key: key, Value: data})
+	if err != nil {
+		return fmt.Errorf("failed to encode record: %w", err)
+	}
+
+	line = append(line, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if existing, ok := s.entries[key]; ok && bytes.Equal(existing, data) {
+		return nil
+	}
+
+	if _, err := s.file.Write(line); err != nil {
+		return fmt.Errorf("failed to write record: %w", err)
+	}
+
+	s.entries[key] = data
+	s.size += int64(len(line))
+	s.lines++
+
+	// The value is stored even if compaction fails; it is tried again on the next write.
+	if stale := s.lines - len(s.entries); stale > minCompactLines && stale > len(s.entries) {
+		_ = s.compact()
+	}
+
+	return nil
+}
+
+// compact rewrites the file with the current entries only. The new file replaces the old one atomically, so a
+// crash leaves either of them.
+func (s *Store) compact() error {
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	writer := bufio.NewWriter(tmp)
+
+	var size int64
+
+	for key, value := range s.entries {
+		line, err := json.Marshal(record{Key: key, Value: value})
+		if err != nil {
+			_ = tmp.Close()
+
+			return fmt.Errorf("failed to encode record: %w", err)
+		}
+
+		line = append(line, '\n')
+		size += int64(len(line))
+
+		if _, err := writer.Write(line); err != nil {
+			_ = tmp.Close()
+
+			return fmt.Errorf("failed to write record: %w", err)
+		}
+	}
+
+	if err := writer.Flush(); err != nil {
+		_ = tmp.Close()
+
+		return fmt.Errorf("failed to write records: %w", err)
+	}
+
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+
+		return fmt.Errorf("failed to sync file: %w", err)
+	}
+
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close file: %w", err)
+	}
+
+	if err := os.Chmod(tmp.Name(), 0600); err != nil {
+		return fmt.Errorf("failed to set file permissions: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("failed to replace file: %w", err)
+	}
+
+	file, err := os.OpenFile(s.path, os.O_RDWR|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open file: %w", err)
+	}
+
+	_ = s.file.Close()
+	s.file = file
+	s.size = size
+	s.lines = len(s.entries)
+
+	return nil
+}
+
+// Purge removes all entries and truncates the file. Usage counters are reset as well.
+func (s *Store) Purge() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.file.Truncate(0); err != nil {
+		return fmt.Errorf("failed to truncate file: %w", err)
+	}
+
+	s.entries = map[string]json.RawMessage{}
+	s.size = 0
+	s.lines = 0
+	s.hits = 0
+	s.misses = 0
+
+	return nil
+}
+
+// Stats returns the current statistics of the store.
+func (s *Store) Stats() Stats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return Stats{
+		Path:      s.path,
+		Entries:   len(s.entries),
+		SizeBytes: s.size,
+		Hits:      s.hits,
+		Misses:    s.misses,
+	}
+}
+
+// Close closes the underlying file.
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.file.Close()
+}
@@ -0,0 +1,165 @@
+package kvstore
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestKVStore(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "KV Store Suite")
+}
+
+type testValue struct {
+	Name string `json:"name"`
+}
+
+var _ = Describe("Store", func() {
+	var (
+		path  string
+		store *Store
+	)
+
+	BeforeEach(func() {
+		path = filepath.Join(GinkgoT().TempDir(), "nested", "cache")
+
+		var err error
+		store, err = Open(path)
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		_ = store.Close()
+	})
+
+	It("stores and retrieves values", func() {
+		Expect(store.Set("a", testValue{Name: "first"})).To(Succeed())
+		Expect(store.Set("a", testValue{Name: "second"})).To(Succeed())
+
+		var value testValue
+		Expect(store.Get("a", &value)).To(BeTrue())
+		Expect(value.Name).To(Equal("second"))
+		Expect(store.Get("b", &value)).To(BeFalse())
+
+		stats := store.Stats()
+		Expect(stats.Path).To(Equal(path))
+		Expect(stats.Entries).To(Equal(1))
+		Expect(stats.Hits).To(Equal(uint64(1)))
+		Expect(stats.Misses).To(Equal(uint64(1)))
+		Expect(stats.SizeBytes).To(BeNumerically(">", 0))
+	})
+
+	It("persists values across reopening", func() {
+		Expect(store.Set("a", testValue{Name: "first"})).To(Succeed())
+		Expect(store.Set("a", testValue{Name: "second"})).To(Succeed())
+		Expect(store.Close()).To(Succeed())
+
+		var err error
+		store, err = Open(path)
+		Expect(err).ToNot(HaveOccurred())
+
+		var value testValue
+		Expect(store.Get("a", &value)).To(BeTrue())
+		Expect(value.Name).To(Equal("second"))
+		Expect(store.Stats().Entries).To(Equal(1))
+	})
+
+	It("skips a line cut off by a crash", func() {
+		Expect(store.Set("a", testValue{Name: "first"})).To(Succeed())
+		Expect(store.Close()).To(Succeed())
+
+		file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0600)
+		Expect(err).ToNot(HaveOccurred())
+		_, err = file.WriteString(`{"k":"b","v":{"na`)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(file.Close()).To(Succeed())
+
+		store, err = Open(path)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(store.Stats().Entries).To(Equal(1))
+
+		Expect(store.Set("c", testValue{Name: "third"})).To(Succeed())
+		Expect(store.Close()).To(Succeed())
+
+		store, err = Open(path)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(store.Stats().Entries).To(Equal(2))
+	})
+
+	It("does not write values that did not change", func() {
+		Expect(store.Set("a", testValue{Name: "first"})).To(Succeed())
+		size := store.Stats().SizeBytes
+
+		Expect(store.Set("a", testValue{Name: "first"})).To(Succeed())
+		Expect(store.Stats().SizeBytes).To(Equal(size))
+
+		info, err := os.Stat(path)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(info.Size()).To(Equal(size))
+	})
+
+	It("drops overwritten values when reopened", func() {
+		Expect(store.Set("a", testValue{Name: "first"})).To(Succeed())
+		Expect(store.Set("a", testValue{Name: "second"})).To(Succeed())
+		Expect(store.Set("b", testValue{Name: "third"})).To(Succeed())
+		Expect(store.Close()).To(Succeed())
+
+		var err error
+		store, err = Open(path)
+		Expect(err).ToNot(HaveOccurred())
+
+		data, err := os.ReadFile(path)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(strings.Count(string(data), "\n")).To(Equal(2))
+		Expect(store.Stats().SizeBytes).To(Equal(int64(len(data))))
+
+		var value testValue
+		Expect(store.Get("a", &value)).To(BeTrue())
+		Expect(value.Name).To(Equal("second"))
+
+		Expect(store.Set("c", testValue{Name: "fourth"})).To(Succeed())
+		Expect(store.Close()).To(Succeed())
+
+		store, err = Open(path)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(store.Stats().Entries).To(Equal(3))
+	})
+
+	It("compacts the file while in use", func() {
+		for i := 0; i <= minCompactLines+1; i++ {
+			Expect(store.Set("a", testValue{Name: fmt.Sprintf("value %d", i)})).To(Succeed())
+		}
+
+		data, err := os.ReadFile(path)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(strings.Count(string(data), "\n")).To(BeNumerically("<", minCompactLines))
+		Expect(store.Stats().SizeBytes).To(Equal(int64(len(data))))
+
+		var value testValue
+		Expect(store.Get("a", &value)).To(BeTrue())
+		Expect(value.Name).To(Equal(fmt.Sprintf("value %d", minCompactLines+1)))
+	})
+
+	It("purges all entries", func() {
+		Expect(store.Set("a", testValue{Name: "first"})).To(Succeed())
+		Expect(store.Purge()).To(Succeed())
+
+		var value testValue
+		Expect(store.Get("a", &value)).To(BeFalse())
+		Expect(store.Stats().SizeBytes).To(BeZero())
+
+		Expect(store.Set("b", testValue{Name: "second"})).To(Succeed())
+		Expect(store.Close()).To(Succeed())
+
+		var err error
+		store, err = Open(path)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(store.Stats().Entries).To(Equal(1))
+	})
+})
@@ -0,0 +1,62 @@
+package util
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ChecksumSidecarSuffix is appended to the path of a file to name its checksum file.
+const ChecksumSidecarSuffix = ".sha256"
+
+// FileSHA256 returns the SHA-256 checksum of the file.
+func FileSHA256(path string) ([]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	digest := sha256.New()
+	if _, err := io.Copy(digest, file); err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+
+	return digest.Sum(nil), nil
+}
+
+// WriteChecksumSidecar writes the SHA-256 checksum of the file to <path>.sha256, in the format of sha256sum, so
+// that `sha256sum -c` verifies the file.
+func WriteChecksumSidecar(path string, sum []byte) error {
+	line := fmt.Sprintf("%s  %s\n", hex.EncodeToString(sum), filepath.Base(path))
+
+	if err := os.WriteFile(path+ChecksumSidecarSuffix, []byte(line), 0644); err != nil {
+		return fmt.Errorf("failed to write checksum file: %w", err)
+	}
+
+	return nil
+}
+
+// ReadChecksumSidecar returns the checksum in the checksum file of the file. The checksum file must name the file.
+func ReadChecksumSidecar(path string) ([]byte, error) {
+	data, err := os.ReadFile(path + ChecksumSidecarSuffix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read checksum file: %w", err)
+	}
+
+	encoded, name, ok := strings.Cut(strings.TrimSuffix(string(data), "\n"), "  ")
+	if !ok || name != filepath.Base(path) {
+		return nil, fmt.Errorf("checksum file of %s is invalid", path)
+	}
+
+	sum, err := hex.DecodeString(encoded)
+	if err != nil || len(sum) != sha256.Size {
+		return nil, fmt.Errorf("checksum file of %s is invalid", path)
+	}
+
+	return sum, nil
+}
@@ -0,0 +1,50 @@
+package util
+
+import (
+	"encoding/hex"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Checksum", func() {
+	It("writes the checksum of the file next to it", func() {
+		path := filepath.Join(GinkgoT().TempDir(), "app.ipa")
+		Expect(os.WriteFile(path, []byte("ping"), 0644)).To(Succeed())
+
+		sum, err := FileSHA256(path)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(WriteChecksumSidecar(path, sum)).To(Succeed())
+
+		data, err := os.ReadFile(path + ChecksumSidecarSuffix)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(data)).To(Equal("758d61f26a44448384e5c4468a0dcb7a2abe456067b0f7b505bc28b9411fe931  app.ipa\n"))
+	})
+
+	It("reads the checksum file of the file", func() {
+		path := filepath.Join(GinkgoT().TempDir(), "app.ipa")
+		Expect(os.WriteFile(path, []byte("ping"), 0644)).To(Succeed())
+
+		sum, err := FileSHA256(path)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(WriteChecksumSidecar(path, sum)).To(Succeed())
+		Expect(ReadChecksumSidecar(path)).To(Equal(sum))
+
+		// The checksum file of another file does not count.
+		line := hex.EncodeToString(sum) + "  other.ipa\n"
+		Expect(os.WriteFile(path+ChecksumSidecarSuffix, []byte(line), 0644)).To(Succeed())
+		Expect(ReadChecksumSidecar(path)).Error().To(HaveOccurred())
+
+		Expect(os.WriteFile(path+ChecksumSidecarSuffix, []byte("ping  app.ipa\n"), 0644)).To(Succeed())
+		Expect(ReadChecksumSidecar(path)).Error().To(HaveOccurred())
+	})
+
+	When("file does not exist", func() {
+		It("returns error", func() {
+			_, err := FileSHA256(filepath.Join(GinkgoT().TempDir(), "missing"))
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
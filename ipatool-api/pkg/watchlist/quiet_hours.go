@@ -0,0 +1,83 @@
+package watchlist
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// QuietHours is a daily time window, in local time, during which no scheduled checks run.
+// The window may span midnight, e.g. 22:00-07:00. The zero value has no quiet hours.
+type QuietHours struct {
+	// Start and End are offsets from midnight.
+	Start time.Duration
+	End   time.Duration
+}
+
+// ParseQuietHours parses a window in the form "HH:MM-HH:MM". An empty value has no quiet hours.
+func ParseQuietHours(value string) (QuietHours, error) {
+	if value == "" {
+		return QuietHours{}, nil
+	}
+
+	start, end, ok := strings.Cut(value, "-")
+	if !ok {
+		return QuietHours{}, fmt.Errorf("invalid quiet hours %q (expected HH:MM-HH:MM)", value)
+	}
+
+	var (
+		quietHours QuietHours
+		err        error
+	)
+
+	if quietHours.Start, err = parseTimeOfDay(strings.TrimSpace(start)); err != nil {
+		return QuietHours{}, fmt.Errorf("invalid quiet hours %q: %w", value, err)
+	}
+
+	if quietHours.End, err = parseTimeOfDay(strings.TrimSpace(end)); err != nil {
+		return QuietHours{}, fmt.Errorf("invalid quiet hours %q: %w", value, err)
+	}
+
+	return quietHours, nil
+}
+
+func parseTimeOfDay(value string) (time.Duration, error) {
+	parsed, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %q", value)
+	}
+
+	return time.Duration(parsed.Hour())*time.Hour + time.Duration(parsed.Minute())*time.Minute, nil
+}
+
+// IsZero reports whether there are no quiet hours.
+func (q QuietHours) IsZero() bool {
+	return q.Start == q.End
+}
+
+// Contains reports whether t falls within the quiet hours.
+func (q QuietHours) Contains(t time.Time) bool {
+	if q.IsZero() {
+		return false
+	}
+
+	offset := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second
+
+	if q.Start < q.End {
+		return offset >= q.Start && offset < q.End
+	}
+
+	return offset >= q.Start || offset < q.End
+}
+
+func (q QuietHours) String() string {
+	if q.IsZero() {
+		return ""
+	}
+
+	format := func(d time.Duration) string {
+		return fmt.Sprintf("%02d:%02d", int(d.Hours()), int(d.Minutes())%60)
+	}
+
+	return format(q.Start) + "-" + format(q.End)
+}
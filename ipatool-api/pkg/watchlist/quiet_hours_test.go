@@ -0,0 +1,55 @@
+package watchlist
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("QuietHours", func() {
+	at := func(hour, minute int) time.Time {
+		return time.Date(2024, 6, 15, hour, minute, 0, 0, time.Local)
+	}
+
+	It("parses windows", func() {
+		quietHours, err := ParseQuietHours("22:00-07:30")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(quietHours.Start).To(Equal(22 * time.Hour))
+		Expect(quietHours.End).To(Equal(7*time.Hour + 30*time.Minute))
+		Expect(quietHours.String()).To(Equal("22:00-07:30"))
+
+		quietHours, err = ParseQuietHours("")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(quietHours.IsZero()).To(BeTrue())
+
+		for _, value := range []string{"22:00", "22:00-25:00", "night"} {
+			_, err := ParseQuietHours(value)
+			Expect(err).To(HaveOccurred(), value)
+		}
+	})
+
+	It("contains times within a window spanning midnight", func() {
+		quietHours, err := ParseQuietHours("22:00-07:00")
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(quietHours.Contains(at(23, 15))).To(BeTrue())
+		Expect(quietHours.Contains(at(3, 0))).To(BeTrue())
+		Expect(quietHours.Contains(at(7, 0))).To(BeFalse())
+		Expect(quietHours.Contains(at(12, 0))).To(BeFalse())
+	})
+
+	It("contains times within a window during the day", func() {
+		quietHours, err := ParseQuietHours("09:00-17:00")
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(quietHours.Contains(at(9, 0))).To(BeTrue())
+		Expect(quietHours.Contains(at(16, 59))).To(BeTrue())
+		Expect(quietHours.Contains(at(17, 0))).To(BeFalse())
+		Expect(quietHours.Contains(at(23, 0))).To(BeFalse())
+	})
+
+	It("never contains times without quiet hours", func() {
+		Expect(QuietHours{}.Contains(at(3, 0))).To(BeFalse())
+	})
+})
@@ -0,0 +1,177 @@
+// Package watchlist keeps a list of apps whose new versions are downloaded automatically,
+// and the watcher that checks them periodically.
+package watchlist
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+var (
+	ErrNotWatched     = errors.New("app is not on the watchlist")
+	ErrAlreadyWatched = errors.New("app is already on the watchlist")
+)
+
+// Item is an app on the watchlist.
+type Item struct {
+	AppID    int64  `json:"app_id"`
+	BundleID string `json:"bundle_id"`
+	Name     string `json:"name,omitempty"`
+	// KeepVersions is the number of downloaded versions to keep. Zero uses the watcher default.
+	KeepVersions int       `json:"keep_versions,omitempty"`
+	AddedAt      time.Time `json:"added_at"`
+	// LastSeenExternalVersionID is the latest version that was downloaded.
+	LastSeenExternalVersionID string    `json:"last_seen_external_version_id,omitempty"`
+	LastCheckedAt             time.Time `json:"last_checked_at,omitempty"`
+	// LastError is the error of the last check, empty if it succeeded.
+	LastError string `json:"last_error,omitempty"`
+	// Downloads are the versions kept on disk, oldest first.
+	Downloads []Download `json:"downloads,omitempty"`
+}
+
+// Download is a version of a watched app saved on disk.
+type Download struct {
+	ExternalVersionID string `json:"external_version_id"`
+	Path              string `json:"path"`
+	SizeBytes         int64  `json:"size_bytes"`
+	// SHA256 is the hex checksum of the file, also written to <path>.sha256.
+	SHA256       string    `json:"sha256,omitempty"`
+	DownloadedAt time.Time `json:"downloaded_at"`
+}
+
+// Store persists the watchlist as a JSON file. It is safe for concurrent use.
+type Store struct {
+	mu    sync.Mutex
+	path  string
+	items []Item
+}
+
+// Open opens the watchlist at the specified path, creating its directory if needed.
+func Open(path string) (*Store, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	store := &Store{path: path}
+
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &store.items); err != nil {
+			return nil, fmt.Errorf("failed to decode watchlist: %w", err)
+		}
+	}
+
+	return store, nil
+}
+
+// List returns the items in the order they were added.
+func (s *Store) List() []Item {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	items := make([]Item, 0, len(s.items))
+	for _, item := range s.items {
+		items = append(items, item.clone())
+	}
+
+	return items
+}
+
+// Get returns the item of the specified app.
+func (s *Store) Get(appID int64) (Item, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	index := s.indexOf(appID)
+	if index < 0 {
+		return Item{}, ErrNotWatched
+	}
+
+	return s.items[index].clone(), nil
+}
+
+// Add adds the item to the watchlist.
+func (s *Store) Add(item Item) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.indexOf(item.AppID) >= 0 {
+		return ErrAlreadyWatched
+	}
+
+	s.items = append(s.items, item.clone())
+
+	return s.save()
+}
+
+// Update applies the update to the item of the specified app and returns the result.
+func (s *Store) Update(appID int64, update func(item *Item)) (Item, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	index := s.indexOf(appID)
+	if index < 0 {
+		return Item{}, ErrNotWatched
+	}
+
+	update(&s.items[index])
+
+	return s.items[index].clone(), s.save()
+}
+
+// Remove removes the item of the specified app and returns it.
+func (s *Store) Remove(appID int64) (Item, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	index := s.indexOf(appID)
+	if index < 0 {
+		return Item{}, ErrNotWatched
+	}
+
+	item := s.items[index]
+	s.items = slices.Delete(s.items, index, index+1)
+
+	return item, s.save()
+}
+
+func (s *Store) indexOf(appID int64) int {
+	return slices.IndexFunc(s.items, func(item Item) bool {
+		return item.AppID == appID
+	})
+}
+
+// save writes the watchlist to a temporary file first, so that a crash never leaves a partial file behind.
+func (s *Store) save() error {
+	data, err := json.MarshalIndent(s.items, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode watchlist: %w", err)
+	}
+
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("failed to replace file: %w", err)
+	}
+
+	return nil
+}
+
+func (i Item) clone() Item {
+	i.Downloads = slices.Clone(i.Downloads)
+
+	return i
+}
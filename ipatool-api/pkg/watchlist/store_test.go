@@ -0,0 +1,90 @@
+package watchlist
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Store", func() {
+	var (
+		path  string
+		store *Store
+	)
+
+	BeforeEach(func() {
+		path = filepath.Join(GinkgoT().TempDir(), "nested", "watchlist.json")
+
+		var err error
+		store, err = Open(path)
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	It("adds, updates and removes items", func() {
+		Expect(store.Add(Item{AppID: 1, BundleID: "com.example.first"})).To(Succeed())
+		Expect(store.Add(Item{AppID: 2, BundleID: "com.example.second"})).To(Succeed())
+		Expect(store.Add(Item{AppID: 1})).To(MatchError(ErrAlreadyWatched))
+
+		item, err := store.Update(2, func(item *Item) {
+			item.KeepVersions = 3
+		})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(item.KeepVersions).To(Equal(3))
+
+		removed, err := store.Remove(1)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(removed.BundleID).To(Equal("com.example.first"))
+
+		_, err = store.Get(1)
+		Expect(err).To(MatchError(ErrNotWatched))
+		_, err = store.Remove(1)
+		Expect(err).To(MatchError(ErrNotWatched))
+
+		Expect(store.List()).To(HaveLen(1))
+	})
+
+	It("persists items across restarts", func() {
+		downloadedAt := time.Date(2024, 6, 15, 10, 0, 0, 0, time.UTC)
+
+		Expect(store.Add(Item{AppID: 1, BundleID: "com.example.first"})).To(Succeed())
+		_, err := store.Update(1, func(item *Item) {
+			item.LastSeenExternalVersionID = "100"
+			item.Downloads = []Download{{ExternalVersionID: "100", Path: "/tmp/first.ipa", SizeBytes: 42, DownloadedAt: downloadedAt}}
+		})
+		Expect(err).ToNot(HaveOccurred())
+
+		reopened, err := Open(path)
+		Expect(err).ToNot(HaveOccurred())
+
+		item, err := reopened.Get(1)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(item.LastSeenExternalVersionID).To(Equal("100"))
+		Expect(item.Downloads).To(HaveLen(1))
+		Expect(item.Downloads[0].DownloadedAt.Equal(downloadedAt)).To(BeTrue())
+
+		_, err = os.Stat(path + ".tmp")
+		Expect(os.IsNotExist(err)).To(BeTrue())
+	})
+
+	It("returns copies of the items", func() {
+		Expect(store.Add(Item{AppID: 1, Downloads: []Download{{ExternalVersionID: "100"}}})).To(Succeed())
+
+		item, err := store.Get(1)
+		Expect(err).ToNot(HaveOccurred())
+		item.Downloads[0].ExternalVersionID = "changed"
+
+		item, err = store.Get(1)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(item.Downloads[0].ExternalVersionID).To(Equal("100"))
+	})
+
+	It("fails to open a corrupted file", func() {
+		Expect(os.WriteFile(path, []byte("{"), 0600)).To(Succeed())
+
+		_, err := Open(path)
+		Expect(err).To(HaveOccurred())
+	})
+})
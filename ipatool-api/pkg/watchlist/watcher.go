@@ -0,0 +1,363 @@
+package watchlist
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/majd/ipatool/v2/pkg/appstore"
+	"github.com/majd/ipatool/v2/pkg/log"
+	"github.com/majd/ipatool/v2/pkg/util"
+)
+
+const (
+	DefaultInterval     = 6 * time.Hour
+	DefaultKeepVersions = 2
+	// maxEvents is the number of recent events kept in memory.
+	maxEvents = 100
+)
+
+// EventType identifies what happened to a watched app.
+type EventType string
+
+const (
+	// EventNewVersion is emitted when a version that was not seen before has been downloaded.
+	EventNewVersion EventType = "new_version"
+	// EventDownloadFailed is emitted when a new version was found but could not be downloaded.
+	EventDownloadFailed EventType = "download_failed"
+)
+
+// Event describes a new version of a watched app.
+type Event struct {
+	Type     EventType
+	Time     time.Time
+	AppID    int64
+	BundleID string
+	Name     string
+	// ExternalVersionID is the new version.
+	ExternalVersionID string
+	// PreviousExternalVersionID is the version seen before, empty on the first check.
+	PreviousExternalVersionID string
+	// Path is where the new version was saved.
+	Path string
+	Err  error
+}
+
+type Args struct {
+	Store    *Store
+	AppStore appstore.AppStore
+	Logger   log.Logger
+	// Directory is where new versions are saved, in a subdirectory per app.
+	Directory string
+	// Interval between scheduled checks. Zero uses DefaultInterval.
+	Interval time.Duration
+	// QuietHours skips scheduled checks during this window.
+	QuietHours QuietHours
+	// KeepVersions is the number of versions kept per app unless the item sets its own. Zero uses DefaultKeepVersions.
+	KeepVersions int
+	// OnEvent is called for every event, from the goroutine running the check.
+	OnEvent func(event Event)
+}
+
+// Watcher checks the apps on the watchlist for new versions and downloads them.
+type Watcher struct {
+	store        *Store
+	appStore     appstore.AppStore
+	logger       log.Logger
+	directory    string
+	interval     time.Duration
+	quietHours   QuietHours
+	keepVersions int
+	onEvent      func(event Event)
+
+	// checkMu serializes checks, so that a manual check never overlaps a scheduled one.
+	checkMu sync.Mutex
+	trigger chan struct{}
+
+	eventsMu sync.Mutex
+	events   []Event
+}
+
+func New(args Args) *Watcher {
+	interval := args.Interval
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+
+	keepVersions := args.KeepVersions
+	if keepVersions <= 0 {
+		keepVersions = DefaultKeepVersions
+	}
+
+	return &Watcher{
+		store:        args.Store,
+		appStore:     args.AppStore,
+		logger:       args.Logger,
+		directory:    args.Directory,
+		interval:     interval,
+		quietHours:   args.QuietHours,
+		keepVersions: keepVersions,
+		onEvent:      args.OnEvent,
+		trigger:      make(chan struct{}, 1),
+	}
+}
+
+// Store returns the watchlist the watcher checks.
+func (w *Watcher) Store() *Store {
+	return w.store
+}
+
+// Directory returns where new versions are saved.
+func (w *Watcher) Directory() string {
+	return w.directory
+}
+
+// Interval returns the time between scheduled checks.
+func (w *Watcher) Interval() time.Duration {
+	return w.interval
+}
+
+// QuietHours returns the window during which scheduled checks are skipped.
+func (w *Watcher) QuietHours() QuietHours {
+	return w.quietHours
+}
+
+// KeepVersions returns the number of versions kept for the item.
+func (w *Watcher) KeepVersions(item Item) int {
+	if item.KeepVersions > 0 {
+		return item.KeepVersions
+	}
+
+	return w.keepVersions
+}
+
+// Run checks the watchlist when started and then at every interval outside of the quiet hours,
+// until the context is canceled. Checks requested with Trigger run regardless of the quiet hours.
+func (w *Watcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	w.scheduledCheck()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			w.scheduledCheck()
+		case <-w.trigger:
+			w.CheckAll()
+		}
+	}
+}
+
+// Trigger requests a check from Run without waiting for it.
+func (w *Watcher) Trigger() {
+	select {
+	case w.trigger <- struct{}{}:
+	default:
+		// A check is already pending.
+	}
+}
+
+func (w *Watcher) scheduledCheck() {
+	if w.quietHours.Contains(time.Now()) {
+		w.logger.Verbose().Str("quietHours", w.quietHours.String()).Msg("Watchlist: skipping check during quiet hours")
+
+		return
+	}
+
+	w.CheckAll()
+}
+
+// CheckAll checks every app on the watchlist and downloads new versions.
+func (w *Watcher) CheckAll() {
+	w.checkMu.Lock()
+	defer w.checkMu.Unlock()
+
+	items := w.store.List()
+	if len(items) == 0 {
+		return
+	}
+
+	info, err := w.appStore.AccountInfo()
+	if err != nil {
+		w.logger.Error().Err(err).Msg("Watchlist: no account to check the watchlist with")
+
+		for _, item := range items {
+			w.recordCheck(item.AppID, err)
+		}
+
+		return
+	}
+
+	for _, item := range items {
+		w.check(info.Account, item)
+	}
+}
+
+func (w *Watcher) check(acc appstore.Account, item Item) {
+	app := appstore.App{ID: item.AppID, BundleID: item.BundleID, Name: item.Name}
+
+	versions, err := w.appStore.ListVersions(appstore.ListVersionsInput{Account: acc, App: app})
+	if err != nil {
+		w.logger.Error().Err(err).Int64("appID", item.AppID).Msg("Watchlist: failed to list versions")
+		w.recordCheck(item.AppID, err)
+
+		return
+	}
+
+	latest := versions.LatestExternalVersionID
+	if latest == "" || latest == item.LastSeenExternalVersionID {
+		w.recordCheck(item.AppID, nil)
+
+		return
+	}
+
+	event := Event{
+		AppID:                     item.AppID,
+		BundleID:                  item.BundleID,
+		Name:                      item.Name,
+		ExternalVersionID:         latest,
+		PreviousExternalVersionID: item.LastSeenExternalVersionID,
+	}
+
+	download, err := w.download(acc, app, latest)
+	if err != nil {
+		w.logger.Error().Err(err).Int64("appID", item.AppID).Str("externalVersionID", latest).Msg("Watchlist: failed to download new version")
+		w.recordCheck(item.AppID, err)
+
+		event.Type = EventDownloadFailed
+		event.Err = err
+		w.emit(event)
+
+		return
+	}
+
+	var removed []Download
+
+	_, err = w.store.Update(item.AppID, func(item *Item) {
+		item.LastSeenExternalVersionID = latest
+		item.LastCheckedAt = time.Now()
+		item.LastError = ""
+		item.Downloads = append(item.Downloads, download)
+
+		if excess := len(item.Downloads) - w.KeepVersions(*item); excess > 0 {
+			removed = append(removed, item.Downloads[:excess]...)
+			item.Downloads = append([]Download{}, item.Downloads[excess:]...)
+		}
+	})
+	if err != nil {
+		w.logger.Error().Err(err).Int64("appID", item.AppID).Msg("Watchlist: failed to record new version")
+	}
+
+	for _, old := range removed {
+		if old.Path == download.Path {
+			continue
+		}
+
+		for _, path := range []string{old.Path, old.Path + util.ChecksumSidecarSuffix} {
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				w.logger.Error().Err(err).Str("path", path).Msg("Watchlist: failed to remove old version")
+			}
+		}
+	}
+
+	event.Type = EventNewVersion
+	event.Path = download.Path
+	w.emit(event)
+}
+
+// download saves the version to <directory>/<bundle ID>/<bundle ID>_<external version ID>.ipa.
+func (w *Watcher) download(acc appstore.Account, app appstore.App, externalVersionID string) (Download, error) {
+	name := app.BundleID
+	if name == "" || name != filepath.Base(name) || strings.HasPrefix(name, ".") {
+		name = strconv.FormatInt(app.ID, 10)
+	}
+
+	dir := filepath.Join(w.directory, name)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return Download{}, fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	output, err := w.appStore.Download(appstore.DownloadInput{
+		Account:           acc,
+		App:               app,
+		ExternalVersionID: externalVersionID,
+		OutputPath:        filepath.Join(dir, fmt.Sprintf("%s_%s.ipa", name, externalVersionID)),
+	})
+	if err != nil {
+		return Download{}, err
+	}
+
+	info, err := os.Stat(output.DestinationPath)
+	if err != nil {
+		return Download{}, fmt.Errorf("failed to read file metadata: %w", err)
+	}
+
+	sum, err := util.FileSHA256(output.DestinationPath)
+	if err != nil {
+		return Download{}, err
+	}
+
+	if err := util.WriteChecksumSidecar(output.DestinationPath, sum); err != nil {
+		return Download{}, err
+	}
+
+	return Download{
+		ExternalVersionID: externalVersionID,
+		Path:              output.DestinationPath,
+		SizeBytes:         info.Size(),
+		SHA256:            hex.EncodeToString(sum),
+		DownloadedAt:      time.Now(),
+	}, nil
+}
+
+// recordCheck stores the outcome of a check that did not download anything. A nil error clears the last error.
+func (w *Watcher) recordCheck(appID int64, err error) {
+	_, updateErr := w.store.Update(appID, func(item *Item) {
+		item.LastCheckedAt = time.Now()
+		item.LastError = ""
+
+		if err != nil {
+			item.LastError = err.Error()
+		}
+	})
+	if updateErr != nil {
+		w.logger.Verbose().Err(updateErr).Int64("appID", appID).Msg("Watchlist: failed to record check")
+	}
+}
+
+func (w *Watcher) emit(event Event) {
+	event.Time = time.Now()
+
+	w.eventsMu.Lock()
+	w.events = append(w.events, event)
+	if len(w.events) > maxEvents {
+		w.events = w.events[len(w.events)-maxEvents:]
+	}
+	w.eventsMu.Unlock()
+
+	if w.onEvent != nil {
+		w.onEvent(event)
+	}
+}
+
+// Events returns the recent events, newest first.
+func (w *Watcher) Events() []Event {
+	w.eventsMu.Lock()
+	defer w.eventsMu.Unlock()
+
+	events := make([]Event, 0, len(w.events))
+	for i := len(w.events) - 1; i >= 0; i-- {
+		events = append(events, w.events[i])
+	}
+
+	return events
+}
@@ -0,0 +1,153 @@
+package watchlist
+
+import (
+	"context"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/99designs/keyring"
+	cookiejar "github.com/juju/persistent-cookiejar"
+	"github.com/majd/ipatool/v2/pkg/appstore"
+	"github.com/majd/ipatool/v2/pkg/fakestore"
+	"github.com/majd/ipatool/v2/pkg/keychain"
+	"github.com/majd/ipatool/v2/pkg/log"
+	"github.com/majd/ipatool/v2/pkg/util/machine"
+	"github.com/majd/ipatool/v2/pkg/util/operatingsystem"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Watcher", func() {
+	var (
+		fake    *fakestore.Server
+		store   *Store
+		dir     string
+		events  []Event
+		watcher *Watcher
+	)
+
+	newWatcher := func(storeURL string, args Args) *Watcher {
+		args.Store = store
+		args.Directory = filepath.Join(dir, "downloads")
+		args.Logger = log.NewLogger(log.Args{Writer: GinkgoWriter})
+		args.OnEvent = func(event Event) {
+			events = append(events, event)
+		}
+
+		jar, err := cookiejar.New(&cookiejar.Options{Filename: filepath.Join(dir, "cookies")})
+		Expect(err).ToNot(HaveOccurred())
+
+		os := operatingsystem.New()
+		args.AppStore = appstore.NewAppStore(appstore.Args{
+			Keychain:        keychain.New(keychain.Args{Keyring: keyring.NewArrayKeyring(nil)}),
+			CookieJar:       jar,
+			OperatingSystem: os,
+			Machine:         machine.New(machine.Args{OS: os}),
+			DeviceGUID:      "0123456789AB",
+			Endpoints:       appstore.EndpointsWithBaseURL(storeURL),
+		})
+
+		_, err = args.AppStore.Login(appstore.LoginInput{Email: fakestore.DefaultEmail, Password: fakestore.DefaultPassword})
+		Expect(err).ToNot(HaveOccurred())
+
+		return New(args)
+	}
+
+	BeforeEach(func() {
+		fake = fakestore.New(fakestore.DefaultConfig())
+		srv := httptest.NewServer(fake)
+		DeferCleanup(srv.Close)
+
+		dir = GinkgoT().TempDir()
+		events = nil
+
+		var err error
+		store, err = Open(filepath.Join(dir, "watchlist.json"))
+		Expect(err).ToNot(HaveOccurred())
+
+		watcher = newWatcher(srv.URL, Args{})
+	})
+
+	It("downloads new versions and keeps the configured number of them", func() {
+		fake.GrantLicense(fakestore.DefaultEmail, 1000000101)
+		Expect(store.Add(Item{AppID: 1000000101, BundleID: "com.example.notes", KeepVersions: 1})).To(Succeed())
+
+		watcher.CheckAll()
+
+		item, err := store.Get(1000000101)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(item.LastSeenExternalVersionID).To(Equal("800000003"))
+		Expect(item.Downloads).To(HaveLen(1))
+		Expect(item.Downloads[0].Path).To(Equal(filepath.Join(dir, "downloads", "com.example.notes", "com.example.notes_800000003.ipa")))
+		Expect(item.Downloads[0].SizeBytes).To(BeNumerically(">", 0))
+
+		sidecar, err := os.ReadFile(item.Downloads[0].Path + ".sha256")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(sidecar)).To(Equal(item.Downloads[0].SHA256 + "  com.example.notes_800000003.ipa\n"))
+		Expect(events).To(HaveLen(1))
+		Expect(events[0].Type).To(Equal(EventNewVersion))
+		Expect(events[0].PreviousExternalVersionID).To(BeEmpty())
+
+		watcher.CheckAll()
+		unchanged, err := store.Get(1000000101)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(unchanged.Downloads).To(Equal(item.Downloads))
+		Expect(events).To(HaveLen(1))
+
+		fake.ReleaseVersion(1000000101, fakestore.Version{ExternalVersionID: "800000004", DisplayVersion: "2.1.0", BundleVersion: "210"})
+		watcher.CheckAll()
+
+		item, err = store.Get(1000000101)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(item.LastSeenExternalVersionID).To(Equal("800000004"))
+		Expect(item.Downloads).To(HaveLen(1))
+		Expect(item.Downloads[0].ExternalVersionID).To(Equal("800000004"))
+		Expect(events).To(HaveLen(2))
+		Expect(events[1].PreviousExternalVersionID).To(Equal("800000003"))
+
+		_, err = os.Stat(filepath.Join(dir, "downloads", "com.example.notes", "com.example.notes_800000003.ipa"))
+		Expect(os.IsNotExist(err)).To(BeTrue())
+		_, err = os.Stat(filepath.Join(dir, "downloads", "com.example.notes", "com.example.notes_800000003.ipa.sha256"))
+		Expect(os.IsNotExist(err)).To(BeTrue())
+		Expect(watcher.Events()[0].ExternalVersionID).To(Equal("800000004"))
+	})
+
+	It("records errors and retries on the next check", func() {
+		Expect(store.Add(Item{AppID: 1000000101, BundleID: "com.example.notes"})).To(Succeed())
+
+		watcher.CheckAll()
+
+		item, err := store.Get(1000000101)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(item.LastError).To(ContainSubstring("license is required"))
+		Expect(item.LastCheckedAt).ToNot(BeZero())
+		Expect(item.Downloads).To(BeEmpty())
+
+		fake.GrantLicense(fakestore.DefaultEmail, 1000000101)
+		watcher.CheckAll()
+
+		item, err = store.Get(1000000101)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(item.LastError).To(BeEmpty())
+		Expect(item.Downloads).To(HaveLen(1))
+	})
+
+	It("runs triggered checks during quiet hours", func() {
+		fake.GrantLicense(fakestore.DefaultEmail, 1000000101)
+		Expect(store.Add(Item{AppID: 1000000101, BundleID: "com.example.notes"})).To(Succeed())
+
+		// Quiet all day, so that only the triggered check runs.
+		watcher.quietHours = QuietHours{Start: 0, End: 24*time.Hour - time.Nanosecond}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		DeferCleanup(cancel)
+		go watcher.Run(ctx)
+
+		Consistently(func() int { return len(watcher.Events()) }, 100*time.Millisecond).Should(BeZero())
+
+		watcher.Trigger()
+		Eventually(func() int { return len(watcher.Events()) }).Should(Equal(1))
+	})
+})